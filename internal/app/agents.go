@@ -0,0 +1,73 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/chasedut/toke/internal/config"
+	"github.com/chasedut/toke/internal/llm/agent"
+)
+
+// SetSessionAgent selects the agent that handles the prompts of a session.
+func (app *App) SetSessionAgent(sessionID, agentID string) error {
+	agentCfg, ok := app.config.Agents[agentID]
+	if !ok {
+		return fmt.Errorf("unknown agent %q", agentID)
+	}
+	if agentCfg.Disabled {
+		return fmt.Errorf("agent %q is disabled", agentID)
+	}
+	if app.Agents == nil {
+		return fmt.Errorf("no providers configured")
+	}
+	// Create the agent now so configuration errors show up when it is
+	// selected rather than on the next prompt.
+	if _, err := app.Agents.Get(agentID); err != nil {
+		return err
+	}
+	if agentID == config.AgentCoder {
+		app.sessionAgents.Del(sessionID)
+	} else {
+		app.sessionAgents.Set(sessionID, agentID)
+	}
+	return nil
+}
+
+// SessionAgentID returns the ID of the agent selected for a session.
+func (app *App) SessionAgentID(sessionID string) string {
+	if id, ok := app.sessionAgents.Get(sessionID); ok {
+		return id
+	}
+	return config.AgentCoder
+}
+
+// AgentForSession returns the agent selected for a session, falling back to
+// the coder agent. Sessions with a worktree get an agent bound to it.
+func (app *App) AgentForSession(sessionID string) agent.Service {
+	id := app.SessionAgentID(sessionID)
+	if app.Agents == nil {
+		return app.CoderAgent
+	}
+	ws := app.workspace(sessionID)
+	if id == config.AgentCoder && ws == nil {
+		return app.CoderAgent
+	}
+	a, err := app.Agents.GetIn(id, ws)
+	if err != nil {
+		slog.Error("Failed to load session agent, using coder", "session_id", sessionID, "agent", id, "error", err)
+		return app.CoderAgent
+	}
+	return a
+}
+
+// IsBusy reports whether any agent is processing a request.
+func (app *App) IsBusy() bool {
+	if app.Agents == nil {
+		return app.CoderAgent != nil && app.CoderAgent.IsBusy()
+	}
+	return app.Agents.IsBusy()
+}
+
+func (app *App) agentConfig(sessionID string) config.Agent {
+	return app.config.Agents[app.SessionAgentID(sessionID)]
+}
@@ -7,15 +7,22 @@ import (
 	"fmt"
 	"log/slog"
 	"maps"
+	"os"
+	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/chasedut/toke/internal/audit"
+	"github.com/chasedut/toke/internal/checkpoint"
 	"github.com/chasedut/toke/internal/config"
 	"github.com/chasedut/toke/internal/csync"
 	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/fork"
 	"github.com/chasedut/toke/internal/format"
 	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/limits"
 	"github.com/chasedut/toke/internal/llm/agent"
 	"github.com/chasedut/toke/internal/log"
 	"github.com/chasedut/toke/internal/pubsub"
@@ -25,6 +32,9 @@ import (
 	"github.com/chasedut/toke/internal/backend"
 	"github.com/chasedut/toke/internal/permission"
 	"github.com/chasedut/toke/internal/session"
+	"github.com/chasedut/toke/internal/shell"
+	"github.com/chasedut/toke/internal/usage"
+	"github.com/chasedut/toke/internal/worktree"
 )
 
 type App struct {
@@ -32,8 +42,25 @@ type App struct {
 	Messages    message.Service
 	History     history.Service
 	Permissions permission.Service
+	Checkpoints *checkpoint.Service
+	Forks       *fork.Service
+	Worktrees   *worktree.Manager
+	Audit       audit.Service
+	Usage       usage.Service
+	Limits      limits.Service
 
 	CoderAgent agent.Service
+	// Agents holds every agent defined in the config; CoderAgent is the
+	// default one.
+	Agents *agent.Registry
+
+	// sessionAgents maps session IDs to the ID of the agent selected for
+	// them. Sessions without an entry use the coder agent.
+	sessionAgents *csync.Map[string, string]
+
+	// workspaces holds the worktrees of the sessions that have one, keyed by
+	// session ID.
+	workspaces *csync.Map[string, *sessionWorkspace]
 
 	LSPClients map[string]*lsp.Client
 
@@ -69,12 +96,30 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	if cfg.Permissions != nil && cfg.Permissions.AllowedTools != nil {
 		allowedTools = cfg.Permissions.AllowedTools
 	}
+	var permissionRules []config.PermissionRule
+	if cfg.Permissions != nil {
+		permissionRules = cfg.Permissions.Rules
+	}
+
+	// Usage is also recorded in a database shared by all projects, for
+	// global reports and budgets.
+	var globalUsage db.Querier
+	usageConn := openGlobalUsage(ctx, cfg)
+	if usageConn != nil {
+		globalUsage = db.New(usageConn)
+	}
 
 	app := &App{
 		Sessions:    sessions,
 		Messages:    messages,
 		History:     files,
-		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools),
+		Checkpoints: checkpoint.NewService(messages, files),
+		Forks:       fork.NewService(sessions, messages, files),
+		Worktrees:   worktree.NewManager(cfg.WorkingDir(), cfg.Options.DataDirectory),
+		Audit:       audit.NewService(q),
+		Usage:       usage.NewService(q, globalUsage, cfg.WorkingDir(), cfg.Options.Budget),
+		Limits:      limits.NewService(),
+		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools, permissionRules),
 		LSPClients:  make(map[string]*lsp.Client),
 
 		globalCtx: ctx,
@@ -82,6 +127,8 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 		config: cfg,
 
 		watcherCancelFuncs: csync.NewSlice[context.CancelFunc](),
+		sessionAgents:      csync.NewMap[string, string](),
+		workspaces:         csync.NewMap[string, *sessionWorkspace](),
 
 		events:          make(chan tea.Msg, 100),
 		serviceEventsWG: &sync.WaitGroup{},
@@ -89,6 +136,10 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	}
 
 	app.setupEvents()
+	audit.RecordPermissions(app.Audit, app.Permissions)
+	if usageConn != nil {
+		app.cleanupFuncs = append(app.cleanupFuncs, func() { usageConn.Close() })
+	}
 
 	// Initialize LSP clients in the background.
 	app.initLSPClients(ctx)
@@ -100,25 +151,35 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 		if cfg.Options != nil && cfg.Options.DataDirectory != "" {
 			dataDir = cfg.Options.DataDirectory
 		}
-		app.localBackend = backend.NewOrchestrator(dataDir)
-		
-		// Start local backend if model is configured
-		if model := backend.GetModelByID(localConfig.ModelID); model != nil {
-			// Setup and start in background
-			go func() {
+		app.localBackend = backend.NewOrchestratorWithOptions(dataDir, cfg.LocalPoolOptions())
+		cfg.SyncLocalContextWindows()
+
+		// The large and small models may both be local, each gets its own
+		// server in the pool when first used.
+		modelIDs := cfg.LocalModelIDs()
+		if !slices.Contains(modelIDs, localConfig.ModelID) {
+			modelIDs = append(modelIDs, localConfig.ModelID)
+		}
+
+		// Setup and start in background
+		go func() {
+			for _, id := range modelIDs {
+				model := backend.GetModelByID(id)
+				if model == nil {
+					continue
+				}
 				if err := app.localBackend.SetupModel(ctx, model, func(downloaded, total int64) {
 					// Progress is logged, not shown in non-interactive mode
-					slog.Debug("Model download progress", "downloaded", downloaded, "total", total)
+					slog.Debug("Model download progress", "model", id, "downloaded", downloaded, "total", total)
 				}); err != nil {
-					slog.Error("Failed to setup local model", "error", err)
-					return
-				}
-				
-				if err := app.localBackend.Start(ctx); err != nil {
-					slog.Error("Failed to start local backend", "error", err)
+					slog.Error("Failed to setup local model", "model", id, "error", err)
 				}
-			}()
-		}
+			}
+
+			if err := app.localBackend.Start(ctx); err != nil {
+				slog.Error("Failed to start local backend", "error", err)
+			}
+		}()
 	}
 
 	// Check if any providers are configured
@@ -135,6 +196,21 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	return app, nil
 }
 
+// openGlobalUsage opens the usage database shared by all projects. It
+// returns nil if it can't be opened or is the project database itself.
+func openGlobalUsage(ctx context.Context, cfg *config.Config) *sql.DB {
+	dataDir, err := filepath.Abs(cfg.Options.DataDirectory)
+	if err == nil && dataDir == usage.GlobalDir() {
+		return nil
+	}
+	conn, err := usage.OpenGlobal(ctx)
+	if err != nil {
+		slog.Warn("Failed to open the global usage database", "error", err)
+		return nil
+	}
+	return conn
+}
+
 // Config returns the application configuration.
 func (app *App) Config() *config.Config {
 	return app.config
@@ -142,12 +218,15 @@ func (app *App) Config() *config.Config {
 
 // RunNonInteractive handles the execution flow when a prompt is provided via
 // CLI flag.
-func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool) error {
+func (app *App) RunNonInteractive(ctx context.Context, prompt string, opts RunOptions) error {
 	slog.Info("Running in non-interactive mode")
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// The spinner would only get in the way of machine readable output.
+	quiet := opts.Quiet || opts.OutputFormat.IsJSON()
+
 	// Start spinner if not in quiet mode.
 	var spinner *format.Spinner
 	if !quiet {
@@ -164,66 +243,123 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	}
 	defer stopSpinner()
 
-	const maxPromptLengthForTitle = 100
-	titlePrefix := "Non-interactive: "
-	var titleSuffix string
-
-	if len(prompt) > maxPromptLengthForTitle {
-		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
-	} else {
-		titleSuffix = prompt
-	}
-	title := titlePrefix + titleSuffix
-
-	sess, err := app.Sessions.Create(ctx, title)
+	sess, err := app.runSession(ctx, prompt, opts)
 	if err != nil {
-		return fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+		return err
 	}
-	slog.Info("Created session for non-interactive run", "session_id", sess.ID)
 
 	// Automatically approve all permission requests for this non-interactive session
 	app.Permissions.AutoApproveSession(sess.ID)
+	// There is no one to ask whether to go on at a limit
+	app.Limits.SetAutoStop(true)
+
+	// Subscribe before starting the agent so that no events are missed.
+	messageEvents := app.Messages.Subscribe(ctx)
+	sessionEvents := app.Sessions.Subscribe(ctx)
+	permissionEvents := app.Permissions.SubscribeNotifications(ctx)
+	budgetEvents := app.Usage.Subscribe(ctx)
 
-	done, err := app.CoderAgent.Run(ctx, sess.ID, prompt)
+	if opts.Agent != "" {
+		if err := app.SetSessionAgent(sess.ID, opts.Agent); err != nil {
+			return err
+		}
+	}
+	if _, ok := app.SessionWorktree(sess.ID); opts.Worktree && !ok {
+		wt, err := app.CreateWorktree(ctx, sess.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Working in %s on branch %s\nFinish with: toke worktree merge|pr|discard %s\n", wt.Dir, wt.Branch, sess.ID)
+	}
+	runAgent := app.AgentForSession(sess.ID)
+
+	reporter := newRunReporter(opts.OutputFormat, os.Stdout, sess.ID)
+	model := runAgent.Model()
+	reporter.init(model.ID, app.config.Models[app.agentConfig(sess.ID).Model].Provider)
+
+	// Messages that were there before the run were reported by earlier runs.
+	previous := make(map[string]bool)
+	if msgs, err := app.Messages.List(ctx, sess.ID); err == nil {
+		for _, msg := range msgs {
+			previous[msg.ID] = true
+		}
+	}
+
+	done, err := runAgent.Run(ctx, sess.ID, prompt)
 	if err != nil {
 		return fmt.Errorf("failed to start agent processing stream: %w", err)
 	}
 
-	messageEvents := app.Messages.Subscribe(ctx)
-	readBts := 0
+	// drain reports the events that are still queued, then the messages of
+	// the run as saved. The broker drops events when a subscriber falls
+	// behind, so the saved messages fill in the tool calls and results
+	// whose events were lost.
+	drain := func() {
+		for {
+			select {
+			case event := <-messageEvents:
+				reporter.message(event.Payload)
+			case event := <-sessionEvents:
+				reporter.session(event.Payload)
+			case event := <-permissionEvents:
+				reporter.permission(event.Payload)
+			default:
+				msgs, err := app.Messages.List(context.Background(), sess.ID)
+				if err != nil {
+					slog.Error("Failed to list session messages", "session_id", sess.ID, "error", err)
+					return
+				}
+				for _, msg := range msgs {
+					if !previous[msg.ID] {
+						reporter.message(msg)
+					}
+				}
+				return
+			}
+		}
+	}
 
 	for {
 		select {
 		case result := <-done:
 			stopSpinner()
+			drain()
+
+			// Usage is saved just before the run completes; make sure the
+			// latest totals are reported even if the event was dropped.
+			if latest, err := app.Sessions.Get(context.Background(), sess.ID); err == nil {
+				reporter.session(latest)
+			}
+			filesChanged := app.sessionFilesChanged(context.Background(), sess.ID, reporter.started.Unix())
 
 			if result.Error != nil {
 				if errors.Is(result.Error, context.Canceled) || errors.Is(result.Error, agent.ErrRequestCancelled) {
 					slog.Info("Non-interactive: agent processing cancelled", "session_id", sess.ID)
+					reporter.finish(result.Message, filesChanged, agent.ErrRequestCancelled)
 					return nil
 				}
+				reporter.finish(result.Message, filesChanged, result.Error)
 				return fmt.Errorf("agent processing failed: %w", result.Error)
 			}
 
-			msgContent := result.Message.Content().String()
-			if len(msgContent) < readBts {
-				slog.Error("Non-interactive: message content is shorter than read bytes", "message_length", len(msgContent), "read_bytes", readBts)
-				return fmt.Errorf("message content is shorter than read bytes: %d < %d", len(msgContent), readBts)
-			}
-			fmt.Println(msgContent[readBts:])
-
+			reporter.finish(result.Message, filesChanged, nil)
 			slog.Info("Non-interactive: run completed", "session_id", sess.ID)
 			return nil
 
 		case event := <-messageEvents:
-			msg := event.Payload
-			if msg.SessionID == sess.ID && msg.Role == message.Assistant && len(msg.Parts) > 0 {
+			if reporter.message(event.Payload) {
 				stopSpinner()
-				part := msg.Content().String()[readBts:]
-				fmt.Print(part)
-				readBts += len(part)
 			}
 
+		case event := <-sessionEvents:
+			reporter.session(event.Payload)
+
+		case event := <-permissionEvents:
+			reporter.permission(event.Payload)
+
+		case event := <-budgetEvents:
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", event.Payload)
+
 		case <-ctx.Done():
 			stopSpinner()
 			return ctx.Err()
@@ -231,8 +367,95 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	}
 }
 
+// runSession returns the session a non-interactive run should use: the one
+// requested with --session, the most recent one with --continue, or a new
+// session titled after the prompt.
+func (app *App) runSession(ctx context.Context, prompt string, opts RunOptions) (session.Session, error) {
+	switch {
+	case opts.SessionID != "":
+		sess, err := app.Sessions.Get(ctx, opts.SessionID)
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to find session %s: %w", opts.SessionID, err)
+		}
+		slog.Info("Resuming session for non-interactive run", "session_id", sess.ID)
+		return sess, nil
+	case opts.Continue:
+		sess, err := app.LatestSession(ctx)
+		if err != nil {
+			return session.Session{}, err
+		}
+		slog.Info("Continuing latest session for non-interactive run", "session_id", sess.ID)
+		return sess, nil
+	}
+
+	const maxPromptLengthForTitle = 100
+	titlePrefix := "Non-interactive: "
+	var titleSuffix string
+
+	if len(prompt) > maxPromptLengthForTitle {
+		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
+	} else {
+		titleSuffix = prompt
+	}
+	title := titlePrefix + titleSuffix
+
+	sess, err := app.Sessions.Create(ctx, title)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+	}
+	slog.Info("Created session for non-interactive run", "session_id", sess.ID)
+	return sess, nil
+}
+
+// LatestSession returns the most recently updated top level session. Each
+// project has its own database, so this is the latest session for the
+// working directory.
+func (app *App) LatestSession(ctx context.Context) (session.Session, error) {
+	sessions, err := app.Sessions.List(ctx)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		return session.Session{}, fmt.Errorf("no previous session to continue")
+	}
+	latest := sessions[0]
+	for _, sess := range sessions[1:] {
+		if sess.UpdatedAt > latest.UpdatedAt {
+			latest = sess
+		}
+	}
+	return latest, nil
+}
+
+// sessionFilesChanged returns the sorted, de-duplicated paths of the files
+// the agent modified in the given session since the given unix time.
+func (app *App) sessionFilesChanged(ctx context.Context, sessionID string, since int64) []string {
+	files, err := app.History.ListBySession(ctx, sessionID)
+	if err != nil {
+		slog.Error("Failed to list session files", "session_id", sessionID, "error", err)
+		return nil
+	}
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		if f.CreatedAt >= since {
+			paths = append(paths, f.Path)
+		}
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths)
+}
+
 func (app *App) UpdateAgentModel() error {
-	return app.CoderAgent.UpdateModel()
+	if app.Agents == nil {
+		return app.CoderAgent.UpdateModel()
+	}
+	var errs []error
+	for _, a := range app.Agents.Loaded() {
+		if err := a.UpdateModel(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (app *App) setupEvents() {
@@ -245,6 +468,9 @@ func (app *App) setupEvents() {
 	setupSubscriber(ctx, app.serviceEventsWG, "history", app.History.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "mcp", agent.SubscribeMCPEvents, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "lsp", SubscribeLSPEvents, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "jobs", shell.SubscribeJobs, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "usage", app.Usage.Subscribe, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "limits", app.Limits.Subscribe, app.events)
 	cleanupFunc := func() {
 		cancel()
 		app.serviceEventsWG.Wait()
@@ -288,29 +514,34 @@ func setupSubscriber[T any](
 }
 
 func (app *App) InitCoderAgent() error {
-	coderAgentCfg := app.config.Agents["coder"]
+	coderAgentCfg := app.config.Agents[config.AgentCoder]
 	if coderAgentCfg.ID == "" {
 		return fmt.Errorf("coder agent configuration is missing")
 	}
-	var err error
-	app.CoderAgent, err = agent.NewAgent(
+	registry := agent.NewRegistry(
 		app.globalCtx,
-		coderAgentCfg,
 		app.Permissions,
 		app.Sessions,
 		app.Messages,
 		app.History,
-		app.LSPClients,
+		app.Audit,
+		app.Usage,
+		app.Limits,
+		app.runningLSPClients,
+		func(id string, a agent.Service) {
+			setupSubscriber(app.eventsCtx, app.serviceEventsWG, id+"Agent", a.Subscribe, app.events)
+		},
 	)
+	coderAgent, err := registry.Get(config.AgentCoder)
 	if err != nil {
 		slog.Error("Failed to create coder agent", "err", err)
 		return err
 	}
+	app.Agents = registry
+	app.CoderAgent = coderAgent
 
 	// Add MCP client cleanup to shutdown process
 	app.cleanupFuncs = append(app.cleanupFuncs, agent.CloseMCPClients)
-
-	setupSubscriber(app.eventsCtx, app.serviceEventsWG, "coderAgent", app.CoderAgent.Subscribe, app.events)
 	return nil
 }
 
@@ -345,6 +576,15 @@ func (app *App) Subscribe(program *tea.Program) {
 	}
 }
 
+// LocalModels returns the pool running the downloaded models, or nil if no
+// local model is configured.
+func (app *App) LocalModels() *backend.Pool {
+	if app.localBackend == nil {
+		return nil
+	}
+	return app.localBackend.Pool()
+}
+
 // NeedsFirstSetup returns true if the app needs first-time setup
 func (app *App) NeedsFirstSetup() bool {
 	return app.needsFirstSetup
@@ -358,7 +598,7 @@ func (app *App) SetupLocalModel(ctx context.Context, model *backend.ModelOption,
 		if app.config.Options != nil && app.config.Options.DataDirectory != "" {
 			dataDir = app.config.Options.DataDirectory
 		}
-		app.localBackend = backend.NewOrchestrator(dataDir)
+		app.localBackend = backend.NewOrchestratorWithOptions(dataDir, app.config.LocalPoolOptions())
 	}
 	
 	// Run quick setup
@@ -382,8 +622,10 @@ func (app *App) SetupLocalModel(ctx context.Context, model *backend.ModelOption,
 
 // Shutdown performs a graceful shutdown of the application.
 func (app *App) Shutdown() {
-	if app.CoderAgent != nil {
-		app.CoderAgent.CancelAll()
+	if app.Agents != nil {
+		for _, a := range app.Agents.Loaded() {
+			a.CancelAll()
+		}
 	}
 
 	// Stop local backend if running
@@ -393,9 +635,15 @@ func (app *App) Shutdown() {
 		}
 	}
 
+	// Stop the background jobs of the bash tool.
+	shell.KillAllJobs()
+
 	for cancel := range app.watcherCancelFuncs.Seq() {
 		cancel()
 	}
+	for ws := range app.workspaces.Seq() {
+		ws.close()
+	}
 
 	// Wait for all LSP watchers to finish.
 	app.lspWatcherWG.Wait()
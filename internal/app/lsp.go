@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"log/slog"
+	"maps"
 	"time"
 
 	"github.com/chasedut/toke/internal/log"
@@ -85,6 +86,14 @@ func (app *App) createAndStartLSPClient(ctx context.Context, name string, comman
 	go app.runWorkspaceWatcher(watchCtx, name, workspaceWatcher)
 }
 
+// runningLSPClients returns a copy of the LSP clients started so far. The
+// agent tools look their clients up with it.
+func (app *App) runningLSPClients() map[string]*lsp.Client {
+	app.clientsMutex.RLock()
+	defer app.clientsMutex.RUnlock()
+	return maps.Clone(app.LSPClients)
+}
+
 // runWorkspaceWatcher executes the workspace watcher for an LSP client.
 func (app *App) runWorkspaceWatcher(ctx context.Context, name string, workspaceWatcher *watcher.WorkspaceWatcher) {
 	defer app.lspWatcherWG.Done()
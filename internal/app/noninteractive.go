@@ -0,0 +1,304 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chasedut/toke/internal/format"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/permission"
+	"github.com/chasedut/toke/internal/session"
+)
+
+// RunOptions configures a non-interactive run.
+type RunOptions struct {
+	// Quiet hides the spinner.
+	Quiet bool
+	// OutputFormat selects plain text or JSON reporting.
+	OutputFormat format.OutputFormat
+	// SessionID resumes an existing session instead of creating a new one.
+	SessionID string
+	// Continue resumes the most recently updated session.
+	Continue bool
+	// Agent selects the agent that handles the prompt. Empty means the
+	// coder agent.
+	Agent string
+	// Worktree runs the session in its own git worktree and branch. Sessions
+	// that already have one keep using it either way.
+	Worktree bool
+}
+
+// RunEventType identifies a record emitted by a non-interactive run in
+// stream-json mode.
+type RunEventType string
+
+const (
+	RunEventInit           RunEventType = "init"
+	RunEventAssistantDelta RunEventType = "assistant_delta"
+	RunEventToolCall       RunEventType = "tool_call"
+	RunEventToolResult     RunEventType = "tool_result"
+	RunEventPermission     RunEventType = "permission"
+	RunEventUsage          RunEventType = "usage"
+	RunEventResult         RunEventType = "result"
+)
+
+// RunEvent is a single NDJSON record describing something the agent did.
+type RunEvent struct {
+	Type       RunEventType   `json:"type"`
+	SessionID  string         `json:"session_id"`
+	MessageID  string         `json:"message_id,omitempty"`
+	Timestamp  int64          `json:"timestamp"`
+	Model      string         `json:"model,omitempty"`
+	Provider   string         `json:"provider,omitempty"`
+	Text       string         `json:"text,omitempty"`
+	ToolCall   *RunToolCall   `json:"tool_call,omitempty"`
+	ToolResult *RunToolResult `json:"tool_result,omitempty"`
+	Permission *RunPermission `json:"permission,omitempty"`
+	Usage      *RunUsage      `json:"usage,omitempty"`
+}
+
+// RunToolCall describes a finished tool call requested by the model.
+type RunToolCall struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Input any    `json:"input"`
+}
+
+// RunToolResult is the output of a tool call sent back to the model.
+type RunToolResult struct {
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name,omitempty"`
+	Content    string `json:"content"`
+	IsError    bool   `json:"is_error"`
+}
+
+// RunPermission records a permission request and how it was decided.
+type RunPermission struct {
+	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
+	Action     string `json:"action"`
+	Decision   string `json:"decision"`
+	Auto       bool   `json:"auto"`
+}
+
+// RunUsage holds the session's token and cost totals.
+type RunUsage struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// RunSummary is the final record of a non-interactive run. In json mode it
+// is the only thing written to stdout.
+type RunSummary struct {
+	Type         RunEventType `json:"type"`
+	SessionID    string       `json:"session_id"`
+	Result       string       `json:"result"`
+	FinishReason string       `json:"finish_reason"`
+	IsError      bool         `json:"is_error"`
+	Error        string       `json:"error,omitempty"`
+	DurationMS   int64        `json:"duration_ms"`
+	NumTurns     int          `json:"num_turns"`
+	NumToolCalls int          `json:"num_tool_calls"`
+	FilesChanged []string     `json:"files_changed"`
+	Usage        RunUsage     `json:"usage"`
+}
+
+// runReporter turns service events for a single session into output in the
+// requested format.
+type runReporter struct {
+	format    format.OutputFormat
+	out       io.Writer
+	json      *format.JSONWriter
+	sessionID string
+	started   time.Time
+
+	// printed tracks how many bytes of each assistant message have been
+	// reported so far.
+	printed     map[string]int
+	toolCalls   map[string]bool
+	toolResults map[string]bool
+	turns       map[string]bool
+	usage       RunUsage
+}
+
+func newRunReporter(f format.OutputFormat, out io.Writer, sessionID string) *runReporter {
+	return &runReporter{
+		format:      f,
+		out:         out,
+		json:        format.NewJSONWriter(out),
+		sessionID:   sessionID,
+		started:     time.Now(),
+		printed:     make(map[string]int),
+		toolCalls:   make(map[string]bool),
+		toolResults: make(map[string]bool),
+		turns:       make(map[string]bool),
+	}
+}
+
+func (r *runReporter) emit(event RunEvent) {
+	if r.format != format.OutputStreamJSON {
+		return
+	}
+	event.SessionID = r.sessionID
+	event.Timestamp = time.Now().UnixMilli()
+	_ = r.json.Write(event)
+}
+
+func (r *runReporter) init(model, provider string) {
+	r.emit(RunEvent{Type: RunEventInit, Model: model, Provider: provider})
+}
+
+// message reports a created or updated message. It returns true when new
+// assistant text was written.
+func (r *runReporter) message(msg message.Message) bool {
+	if msg.SessionID != r.sessionID {
+		return false
+	}
+	switch msg.Role {
+	case message.Assistant:
+		r.turns[msg.ID] = true
+		wrote := r.assistantText(msg)
+		for _, tc := range msg.ToolCalls() {
+			if !tc.Finished || r.toolCalls[tc.ID] {
+				continue
+			}
+			r.toolCalls[tc.ID] = true
+			r.emit(RunEvent{
+				Type:      RunEventToolCall,
+				MessageID: msg.ID,
+				ToolCall: &RunToolCall{
+					ID:    tc.ID,
+					Name:  tc.Name,
+					Input: toolInput(tc.Input),
+				},
+			})
+		}
+		return wrote
+	case message.Tool:
+		for _, tr := range msg.ToolResults() {
+			if r.toolResults[tr.ToolCallID] {
+				continue
+			}
+			r.toolResults[tr.ToolCallID] = true
+			r.emit(RunEvent{
+				Type:      RunEventToolResult,
+				MessageID: msg.ID,
+				ToolResult: &RunToolResult{
+					ToolCallID: tr.ToolCallID,
+					Name:       tr.Name,
+					Content:    tr.Content,
+					IsError:    tr.IsError,
+				},
+			})
+		}
+	}
+	return false
+}
+
+func (r *runReporter) assistantText(msg message.Message) bool {
+	content := msg.Content().String()
+	read := r.printed[msg.ID]
+	if len(content) <= read {
+		return false
+	}
+	delta := content[read:]
+	r.printed[msg.ID] = len(content)
+	switch r.format {
+	case format.OutputText:
+		fmt.Fprint(r.out, delta)
+	case format.OutputStreamJSON:
+		r.emit(RunEvent{Type: RunEventAssistantDelta, MessageID: msg.ID, Text: delta})
+	}
+	return true
+}
+
+func (r *runReporter) permission(n permission.PermissionNotification) {
+	if n.SessionID != r.sessionID {
+		return
+	}
+	decision := "requested"
+	switch {
+	case n.Granted:
+		decision = "granted"
+	case n.Denied:
+		decision = "denied"
+	}
+	r.emit(RunEvent{
+		Type: RunEventPermission,
+		Permission: &RunPermission{
+			ToolCallID: n.ToolCallID,
+			ToolName:   n.ToolName,
+			Action:     n.Action,
+			Decision:   decision,
+			Auto:       n.Auto,
+		},
+	})
+}
+
+func (r *runReporter) session(sess session.Session) {
+	if sess.ID != r.sessionID {
+		return
+	}
+	usage := RunUsage{
+		PromptTokens:     sess.PromptTokens,
+		CompletionTokens: sess.CompletionTokens,
+		Cost:             sess.Cost,
+	}
+	if usage == r.usage {
+		return
+	}
+	r.usage = usage
+	r.emit(RunEvent{Type: RunEventUsage, Usage: &usage})
+}
+
+// finish writes whatever is left of the final message and, for the JSON
+// formats, the summary record. filesChanged lists the paths the agent
+// modified during the session.
+func (r *runReporter) finish(msg message.Message, filesChanged []string, runErr error) {
+	if msg.ID != "" {
+		r.message(msg)
+	}
+	if r.format == format.OutputText {
+		if runErr == nil {
+			fmt.Fprintln(r.out)
+		}
+		return
+	}
+
+	summary := RunSummary{
+		Type:         RunEventResult,
+		SessionID:    r.sessionID,
+		Result:       msg.Content().String(),
+		DurationMS:   time.Since(r.started).Milliseconds(),
+		NumTurns:     len(r.turns),
+		NumToolCalls: len(r.toolCalls),
+		FilesChanged: filesChanged,
+		Usage:        r.usage,
+	}
+	if summary.FilesChanged == nil {
+		summary.FilesChanged = []string{}
+	}
+	if f := msg.FinishPart(); f != nil {
+		summary.FinishReason = string(f.Reason)
+	}
+	if runErr != nil {
+		summary.IsError = true
+		summary.Error = runErr.Error()
+		if summary.FinishReason == "" {
+			summary.FinishReason = string(message.FinishReasonError)
+		}
+	}
+	_ = r.json.Write(summary)
+}
+
+// toolInput returns the tool input as raw JSON when it is valid, so that
+// consumers don't have to decode it twice.
+func toolInput(input string) any {
+	if json.Valid([]byte(input)) {
+		return json.RawMessage(input)
+	}
+	return input
+}
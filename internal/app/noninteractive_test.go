@@ -0,0 +1,181 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chasedut/toke/internal/format"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/permission"
+	"github.com/chasedut/toke/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func assistantMessage(id, sessionID, text string, parts ...message.ContentPart) message.Message {
+	return message.Message{
+		ID:        id,
+		SessionID: sessionID,
+		Role:      message.Assistant,
+		Parts:     append([]message.ContentPart{message.TextContent{Text: text}}, parts...),
+	}
+}
+
+func decodeRecords(t *testing.T, out string) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for line := range strings.Lines(out) {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	return records
+}
+
+func recordTypes(records []map[string]any) []string {
+	types := make([]string, len(records))
+	for i, r := range records {
+		types[i], _ = r["type"].(string)
+	}
+	return types
+}
+
+func TestRunReporterTextPrintsDeltasOnce(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	r := newRunReporter(format.OutputText, &buf, "s1")
+
+	require.True(t, r.message(assistantMessage("m1", "s1", "Hel")))
+	require.True(t, r.message(assistantMessage("m1", "s1", "Hello")))
+	require.False(t, r.message(assistantMessage("m1", "s1", "Hello")))
+	// Messages from other sessions are ignored.
+	require.False(t, r.message(assistantMessage("m9", "other", "nope")))
+	// A second, shorter message is printed from its own start.
+	require.True(t, r.message(assistantMessage("m2", "s1", "Hi")))
+
+	r.finish(assistantMessage("m2", "s1", "Hi!"), nil, nil)
+	require.Equal(t, "HelloHi!\n", buf.String())
+}
+
+func TestRunReporterStreamJSONToolCallOnce(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	r := newRunReporter(format.OutputStreamJSON, &buf, "s1")
+
+	pending := message.ToolCall{ID: "t1", Name: "bash", Input: `{"command":"ls"}`}
+	finished := pending
+	finished.Finished = true
+
+	r.message(assistantMessage("m1", "s1", "", pending))
+	r.message(assistantMessage("m1", "s1", "", finished))
+	r.message(assistantMessage("m1", "s1", "", finished))
+	result := message.Message{
+		ID:        "m2",
+		SessionID: "s1",
+		Role:      message.Tool,
+		Parts:     []message.ContentPart{message.ToolResult{ToolCallID: "t1", Name: "bash", Content: "a.go"}},
+	}
+	r.message(result)
+	// The saved messages are reported again at the end of the run.
+	r.message(result)
+
+	records := decodeRecords(t, buf.String())
+	require.Equal(t, []string{"tool_call", "tool_result"}, recordTypes(records))
+	call := records[0]["tool_call"].(map[string]any)
+	require.Equal(t, "t1", call["id"])
+	require.Equal(t, map[string]any{"command": "ls"}, call["input"])
+}
+
+func TestRunReporterUsageOnlyOnChange(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	r := newRunReporter(format.OutputStreamJSON, &buf, "s1")
+
+	sess := session.Session{ID: "s1", PromptTokens: 10, CompletionTokens: 5, Cost: 0.1}
+	r.session(sess)
+	r.session(sess)
+	r.session(session.Session{ID: "other", PromptTokens: 99})
+	sess.CompletionTokens = 7
+	r.session(sess)
+
+	records := decodeRecords(t, buf.String())
+	require.Equal(t, []string{"usage", "usage"}, recordTypes(records))
+}
+
+func TestRunReporterPermissionFiltersSession(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	r := newRunReporter(format.OutputStreamJSON, &buf, "s1")
+
+	r.permission(permission.PermissionNotification{SessionID: "task", ToolCallID: "t0", Granted: true})
+	r.permission(permission.PermissionNotification{
+		SessionID:  "s1",
+		ToolCallID: "t1",
+		ToolName:   "bash",
+		Action:     "execute",
+		Granted:    true,
+		Auto:       true,
+	})
+	r.permission(permission.PermissionNotification{SessionID: "s1", ToolCallID: "t2", ToolName: "edit", Action: "write", Denied: true})
+
+	records := decodeRecords(t, buf.String())
+	require.Len(t, records, 2)
+	require.Equal(t, map[string]any{
+		"tool_call_id": "t1",
+		"tool_name":    "bash",
+		"action":       "execute",
+		"decision":     "granted",
+		"auto":         true,
+	}, records[0]["permission"])
+	require.Equal(t, "denied", records[1]["permission"].(map[string]any)["decision"])
+}
+
+func TestRunReporterFinishWithError(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	r := newRunReporter(format.OutputStreamJSON, &buf, "s1")
+
+	r.finish(message.Message{}, nil, errors.New("boom"))
+
+	records := decodeRecords(t, buf.String())
+	require.Len(t, records, 1)
+	require.Equal(t, "result", records[0]["type"])
+	require.Equal(t, true, records[0]["is_error"])
+	require.Equal(t, "boom", records[0]["error"])
+	require.Equal(t, "error", records[0]["finish_reason"])
+	require.Equal(t, []any{}, records[0]["files_changed"])
+}
+
+func TestRunReporterJSONWritesOnlySummary(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	r := newRunReporter(format.OutputJSON, &buf, "s1")
+
+	finished := message.ToolCall{ID: "t1", Name: "view", Input: "{}", Finished: true}
+	r.message(assistantMessage("m1", "s1", "Let me look", finished))
+	r.session(session.Session{ID: "s1", PromptTokens: 3})
+	r.permission(permission.PermissionNotification{SessionID: "s1", ToolCallID: "t1", Granted: true})
+
+	final := assistantMessage("m2", "s1", "Done", message.Finish{Reason: message.FinishReasonEndTurn})
+	r.finish(final, []string{"main.go"}, nil)
+
+	records := decodeRecords(t, buf.String())
+	require.Len(t, records, 1)
+	summary := records[0]
+	require.Equal(t, "result", summary["type"])
+	require.Equal(t, "Done", summary["result"])
+	require.Equal(t, "end_turn", summary["finish_reason"])
+	require.Equal(t, false, summary["is_error"])
+	require.EqualValues(t, 2, summary["num_turns"])
+	require.EqualValues(t, 1, summary["num_tool_calls"])
+	require.Equal(t, []any{"main.go"}, summary["files_changed"])
+	require.EqualValues(t, 3, summary["usage"].(map[string]any)["prompt_tokens"])
+}
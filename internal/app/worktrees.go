@@ -0,0 +1,209 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/chasedut/toke/internal/llm/agent"
+	"github.com/chasedut/toke/internal/lsp"
+	"github.com/chasedut/toke/internal/lsp/watcher"
+	"github.com/chasedut/toke/internal/shell"
+	"github.com/chasedut/toke/internal/worktree"
+)
+
+// WorktreeAction is what happens to a session's worktree when the session
+// is finished.
+type WorktreeAction string
+
+const (
+	// WorktreeMerge merges the session's branch into the project's branch.
+	WorktreeMerge WorktreeAction = "merge"
+	// WorktreePR pushes the session's branch and opens a pull request.
+	WorktreePR WorktreeAction = "pr"
+	// WorktreeDiscard throws the session's changes away.
+	WorktreeDiscard WorktreeAction = "discard"
+)
+
+// sessionWorkspace is the worktree of a session along with the LSP clients
+// started for it.
+type sessionWorkspace struct {
+	agent.Workspace
+
+	// mu guards lspClients, which are started in the background.
+	mu         sync.Mutex
+	lspClients map[string]*lsp.Client
+	cancel     context.CancelFunc
+}
+
+// CreateWorktree gives a session its own git worktree and branch. The
+// session's agent, persistent shell and LSP clients work in it from then on.
+func (app *App) CreateWorktree(ctx context.Context, sessionID string) (worktree.Worktree, error) {
+	wt, err := app.Worktrees.Create(ctx, sessionID)
+	if err != nil {
+		return worktree.Worktree{}, fmt.Errorf("failed to create worktree: %w", err)
+	}
+	app.workspaces.Set(sessionID, app.newWorkspace(wt))
+	slog.Info("Created worktree for session", "session_id", sessionID, "dir", wt.Dir, "branch", wt.Branch)
+	return wt, nil
+}
+
+// SessionWorktree returns the worktree of a session if it has one.
+func (app *App) SessionWorktree(sessionID string) (worktree.Worktree, bool) {
+	if sessionID == "" {
+		return worktree.Worktree{}, false
+	}
+	return app.Worktrees.Get(sessionID)
+}
+
+// WorkingDir returns the directory a session works in: its worktree if it has
+// one, otherwise the project directory.
+func (app *App) WorkingDir(sessionID string) string {
+	if wt, ok := app.SessionWorktree(sessionID); ok {
+		return wt.Dir
+	}
+	return app.config.WorkingDir()
+}
+
+// FinishWorktree merges, publishes or discards the worktree of a session and
+// removes it. The session itself is kept and goes back to working in the
+// project directory. For pull requests it returns the URL.
+func (app *App) FinishWorktree(ctx context.Context, sessionID string, action WorktreeAction) (string, error) {
+	wt, ok := app.SessionWorktree(sessionID)
+	if !ok {
+		return "", fmt.Errorf("session %s has no worktree", sessionID)
+	}
+	if app.AgentForSession(sessionID).IsSessionBusy(sessionID) {
+		return "", agent.ErrSessionBusy
+	}
+
+	title := "toke session " + sessionID
+	if sess, err := app.Sessions.Get(ctx, sessionID); err == nil && sess.Title != "" {
+		title = sess.Title
+	}
+
+	var url string
+	var err error
+	switch action {
+	case WorktreeMerge:
+		err = app.Worktrees.Merge(ctx, wt, title)
+	case WorktreePR:
+		url, err = app.Worktrees.OpenPR(ctx, wt, title)
+	case WorktreeDiscard:
+		err = app.Worktrees.Discard(ctx, wt)
+	default:
+		err = fmt.Errorf("unknown worktree action %q", action)
+	}
+	if err != nil {
+		return "", err
+	}
+	app.releaseWorkspace(sessionID)
+	return url, nil
+}
+
+// workspace returns the workspace of a session, or nil when it works in the
+// project directory. Worktrees created in an earlier run are picked up again
+// when their session is resumed.
+func (app *App) workspace(sessionID string) *agent.Workspace {
+	if ws, ok := app.workspaces.Get(sessionID); ok {
+		return &ws.Workspace
+	}
+	wt, ok := app.SessionWorktree(sessionID)
+	if !ok {
+		return nil
+	}
+	ws := app.workspaces.GetOrSet(sessionID, func() *sessionWorkspace {
+		return app.newWorkspace(wt)
+	})
+	return &ws.Workspace
+}
+
+// newWorkspace sets up a workspace for a worktree and starts its LSP clients
+// in the background.
+func (app *App) newWorkspace(wt worktree.Worktree) *sessionWorkspace {
+	ctx, cancel := context.WithCancel(app.globalCtx)
+	ws := &sessionWorkspace{
+		Workspace:  agent.Workspace{Dir: wt.Dir},
+		lspClients: make(map[string]*lsp.Client),
+		cancel:     cancel,
+	}
+	ws.LSPClients = ws.runningLSPClients
+	for name, clientConfig := range app.config.LSP {
+		if clientConfig.Disabled {
+			continue
+		}
+		go ws.startLSPClient(ctx, name, clientConfig.Command, clientConfig.Args...)
+	}
+	return ws
+}
+
+func (app *App) releaseWorkspace(sessionID string) {
+	ws, ok := app.workspaces.Take(sessionID)
+	if !ok {
+		return
+	}
+	if app.Agents != nil {
+		app.Agents.Release(&ws.Workspace)
+	}
+	shell.ReleasePersistentShell(ws.Dir)
+	ws.close()
+}
+
+// startLSPClient starts an LSP server rooted at the workspace. Unlike the
+// project's clients it is not restarted when it crashes and doesn't report
+// its state to the UI.
+func (ws *sessionWorkspace) startLSPClient(ctx context.Context, name string, command string, args ...string) {
+	client, err := lsp.NewClient(ctx, name, command, args...)
+	if err != nil {
+		slog.Error("Failed to create worktree LSP client", "name", name, "error", err)
+		return
+	}
+
+	initCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if _, err := client.InitializeLSPClient(initCtx, ws.Dir); err != nil {
+		slog.Error("Worktree LSP initialize failed", "name", name, "error", err)
+		client.Close()
+		return
+	}
+	if err := client.WaitForServerReady(initCtx); err != nil {
+		client.SetServerState(lsp.StateError)
+	} else {
+		client.SetServerState(lsp.StateReady)
+	}
+
+	ws.mu.Lock()
+	if ctx.Err() != nil {
+		ws.mu.Unlock()
+		client.Close()
+		return
+	}
+	ws.lspClients[name] = client
+	ws.mu.Unlock()
+
+	go watcher.NewWorkspaceWatcher(name, client).WatchWorkspace(ctx, ws.Dir)
+}
+
+// runningLSPClients returns a copy of the LSP clients started so far.
+func (ws *sessionWorkspace) runningLSPClients() map[string]*lsp.Client {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return maps.Clone(ws.lspClients)
+}
+
+func (ws *sessionWorkspace) close() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.cancel()
+	for name, client := range ws.lspClients {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := client.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to shutdown worktree LSP client", "name", name, "error", err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,219 @@
+// Package audit keeps a persistent log of the tools the agent ran and of the
+// permission decisions made for them.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/permission"
+	"github.com/google/uuid"
+)
+
+// MaxOutputLength is the number of bytes of tool output kept in the log.
+const MaxOutputLength = 4096
+
+// Kind tells what an entry records.
+type Kind string
+
+const (
+	KindTool       Kind = "tool"
+	KindPermission Kind = "permission"
+)
+
+// Decisions recorded for permission entries.
+const (
+	DecisionGranted = "granted"
+	DecisionDenied  = "denied"
+)
+
+// ReasonUser is recorded when the user answered the permission request.
+const ReasonUser = "user"
+
+type Entry struct {
+	ID         string `json:"id"`
+	SessionID  string `json:"session_id"`
+	MessageID  string `json:"message_id,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Kind       Kind   `json:"kind"`
+	ToolName   string `json:"tool_name"`
+	Action     string `json:"action,omitempty"`
+
+	// Set for tool calls.
+	Input    string        `json:"input,omitempty"`
+	Output   string        `json:"output,omitempty"`
+	IsError  bool          `json:"is_error,omitempty"`
+	Duration time.Duration `json:"duration_ns,omitempty"`
+	// ExitCode is the exit code of bash commands.
+	ExitCode *int `json:"exit_code,omitempty"`
+
+	// Set for permission decisions.
+	Decision string `json:"decision,omitempty"`
+	// Reason tells who or what made the decision: the user, a permission
+	// rule, yolo mode and so on.
+	Reason string `json:"reason,omitempty"`
+
+	// CreatedAt is a Unix timestamp in milliseconds.
+	CreatedAt int64 `json:"created_at"`
+}
+
+type ListOptions struct {
+	SessionID string
+	Since     time.Time
+}
+
+type Service interface {
+	Record(ctx context.Context, entry Entry) error
+	List(ctx context.Context, opts ListOptions) ([]Entry, error)
+}
+
+type service struct {
+	q db.Querier
+}
+
+func NewService(q db.Querier) Service {
+	return &service{q: q}
+}
+
+func (s *service) Record(ctx context.Context, entry Entry) error {
+	if entry.ID == "" {
+		entry.ID = uuid.New().String()
+	}
+	if entry.CreatedAt == 0 {
+		entry.CreatedAt = time.Now().UnixMilli()
+	}
+	var isError int64
+	if entry.IsError {
+		isError = 1
+	}
+	var duration, exitCode sql.NullInt64
+	if entry.Kind == KindTool {
+		duration = sql.NullInt64{Int64: entry.Duration.Milliseconds(), Valid: true}
+	}
+	if entry.ExitCode != nil {
+		exitCode = sql.NullInt64{Int64: int64(*entry.ExitCode), Valid: true}
+	}
+	return s.q.CreateAuditEntry(ctx, db.CreateAuditEntryParams{
+		ID:         entry.ID,
+		SessionID:  entry.SessionID,
+		MessageID:  entry.MessageID,
+		ToolCallID: entry.ToolCallID,
+		Kind:       string(entry.Kind),
+		ToolName:   entry.ToolName,
+		Action:     entry.Action,
+		Input:      entry.Input,
+		Output:     truncate(entry.Output),
+		IsError:    isError,
+		DurationMs: duration,
+		ExitCode:   exitCode,
+		Decision:   entry.Decision,
+		Reason:     entry.Reason,
+		CreatedAt:  entry.CreatedAt,
+	})
+}
+
+func (s *service) List(ctx context.Context, opts ListOptions) ([]Entry, error) {
+	var since int64
+	if !opts.Since.IsZero() {
+		since = opts.Since.UnixMilli()
+	}
+	var items []db.AuditLog
+	var err error
+	if opts.SessionID != "" {
+		items, err = s.q.ListAuditEntriesBySession(ctx, db.ListAuditEntriesBySessionParams{
+			SessionID: opts.SessionID,
+			CreatedAt: since,
+		})
+	} else {
+		items, err = s.q.ListAuditEntries(ctx, since)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(items))
+	for i, item := range items {
+		entries[i] = fromDBItem(item)
+	}
+	return entries, nil
+}
+
+func fromDBItem(item db.AuditLog) Entry {
+	entry := Entry{
+		ID:         item.ID,
+		SessionID:  item.SessionID,
+		MessageID:  item.MessageID,
+		ToolCallID: item.ToolCallID,
+		Kind:       Kind(item.Kind),
+		ToolName:   item.ToolName,
+		Action:     item.Action,
+		Input:      item.Input,
+		Output:     item.Output,
+		IsError:    item.IsError != 0,
+		Duration:   time.Duration(item.DurationMs.Int64) * time.Millisecond,
+		Decision:   item.Decision,
+		Reason:     item.Reason,
+		CreatedAt:  item.CreatedAt,
+	}
+	if item.ExitCode.Valid {
+		exitCode := int(item.ExitCode.Int64)
+		entry.ExitCode = &exitCode
+	}
+	return entry
+}
+
+// PermissionEntry returns the entry for a permission notification. It
+// returns false for notifications that only announce a request.
+func PermissionEntry(n permission.PermissionNotification) (Entry, bool) {
+	if !n.Granted && !n.Denied {
+		return Entry{}, false
+	}
+	entry := Entry{
+		SessionID:  n.SessionID,
+		MessageID:  n.MessageID,
+		ToolCallID: n.ToolCallID,
+		Kind:       KindPermission,
+		ToolName:   n.ToolName,
+		Action:     n.Action,
+		Decision:   DecisionGranted,
+		Reason:     ReasonUser,
+	}
+	if n.Denied {
+		entry.Decision = DecisionDenied
+	}
+	switch {
+	case n.Rule != "":
+		entry.Reason = permission.ReasonRule + ": " + n.Rule
+	case n.Reason != "":
+		entry.Reason = n.Reason
+	}
+	return entry, true
+}
+
+// RecordPermissions records the decisions of the permission service as they
+// are made.
+func RecordPermissions(s Service, permissions permission.Service) {
+	permissions.OnDecision(func(n permission.PermissionNotification) {
+		entry, ok := PermissionEntry(n)
+		if !ok {
+			return
+		}
+		if err := s.Record(context.Background(), entry); err != nil {
+			slog.Error("Failed to record permission decision", "error", err)
+		}
+	})
+}
+
+func truncate(s string) string {
+	if len(s) <= MaxOutputLength {
+		return s
+	}
+	n := MaxOutputLength
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "\n[truncated]"
+}
@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chasedut/toke/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermissionEntry(t *testing.T) {
+	_, ok := PermissionEntry(permission.PermissionNotification{SessionID: "s", ToolName: "bash"})
+	require.False(t, ok, "requests are not decisions")
+
+	entry, ok := PermissionEntry(permission.PermissionNotification{
+		SessionID: "s",
+		MessageID: "m",
+		ToolName:  "bash",
+		Granted:   true,
+		Auto:      true,
+		Reason:    permission.ReasonYolo,
+	})
+	require.True(t, ok)
+	require.Equal(t, KindPermission, entry.Kind)
+	require.Equal(t, "m", entry.MessageID)
+	require.Equal(t, DecisionGranted, entry.Decision)
+	require.Equal(t, permission.ReasonYolo, entry.Reason)
+
+	entry, _ = PermissionEntry(permission.PermissionNotification{
+		ToolName: "edit",
+		Denied:   true,
+		Auto:     true,
+		Reason:   permission.ReasonRule,
+		Rule:     `deny edit "**/*.pem"`,
+	})
+	require.Equal(t, DecisionDenied, entry.Decision)
+	require.Equal(t, `rule: deny edit "**/*.pem"`, entry.Reason)
+
+	entry, _ = PermissionEntry(permission.PermissionNotification{ToolName: "edit", Granted: true})
+	require.Equal(t, ReasonUser, entry.Reason)
+}
+
+func TestTruncate(t *testing.T) {
+	require.Equal(t, "short", truncate("short"))
+
+	long := truncate(strings.Repeat("é", MaxOutputLength))
+	require.True(t, strings.HasSuffix(long, "\n[truncated]"))
+	require.LessOrEqual(t, len(long), MaxOutputLength+len("\n[truncated]"))
+	require.True(t, strings.HasPrefix(long, "éé"))
+}
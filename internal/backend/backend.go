@@ -35,8 +35,9 @@ const (
 	// - Launch script
 	macOSARM64URL = "https://github.com/chasedut/toke-mlx-backend/releases/download/v0.1.0/mlx-glm-bundle-darwin-arm64.tar.gz"
 
-	// Expected checksums for verification
-	macOSARM64Checksum = "placeholder_checksum" // TODO: Update with actual checksum
+	// Expected checksums for verification, empty until the bundle is
+	// published
+	macOSARM64Checksum = ""
 
 	// Download timeout
 	downloadTimeout = 60 * time.Minute // Longer timeout for large model
@@ -164,7 +165,7 @@ func (b *Backend) Download(ctx context.Context, progressFn func(downloaded, tota
 
 	// Verify checksum
 	actualChecksum := hex.EncodeToString(hasher.Sum(nil))
-	if expectedChecksum != "placeholder_checksum" && actualChecksum != expectedChecksum {
+	if expectedChecksum != "" && actualChecksum != expectedChecksum {
 		return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedChecksum, actualChecksum)
 	}
 
@@ -0,0 +1,357 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+)
+
+// ServerKind is the software behind a discovered local server.
+type ServerKind string
+
+const (
+	ServerOllama   ServerKind = "ollama"
+	ServerLMStudio ServerKind = "lmstudio"
+	ServerVLLM     ServerKind = "vllm"
+	ServerLlamaCpp ServerKind = "llamacpp"
+	// ServerGeneric is any other server with an OpenAI-compatible API.
+	ServerGeneric ServerKind = "openai"
+)
+
+// WellKnownServers are the default addresses of Ollama, LM Studio, vLLM
+// and the llama.cpp server. DiscoverServers always probes them, and skips
+// toke's own pool when it is the one on the Ollama port.
+var WellKnownServers = []string{
+	"http://localhost:11434",
+	"http://localhost:1234",
+	"http://localhost:8000",
+	"http://localhost:8080",
+}
+
+// errPool is returned for the endpoint of toke's own pool, which is served
+// on the default port of Ollama.
+var errPool = errors.New("toke's model pool")
+
+const (
+	discoveryTimeout = 2 * time.Second
+	// Used when a server does not tell the context size of a model.
+	defaultContextWindow = 8192
+	defaultMaxTokens     = 4096
+)
+
+// LocalServer is a running server with an OpenAI-compatible API.
+type LocalServer struct {
+	// ID is unique per server and used as its provider ID, e.g. ollama-11434.
+	ID   string
+	Name string
+	Kind ServerKind
+	// BaseURL is the OpenAI-compatible endpoint, ending in /v1.
+	BaseURL string
+	Models  []LocalServerModel
+}
+
+// LocalServerModel is a model served by a LocalServer.
+type LocalServerModel struct {
+	catwalk.Model
+	// SupportsTools is false when the server says the model can't call
+	// tools. Servers that don't tell are assumed to support them.
+	SupportsTools bool
+}
+
+// DiscoverServers probes the well-known ports and the given URLs and returns
+// the servers that answered with at least one model.
+func DiscoverServers(ctx context.Context, urls []string) []LocalServer {
+	var bases []string
+	for _, u := range append(slices.Clone(WellKnownServers), urls...) {
+		base := serverBase(u)
+		if base != "" && !slices.Contains(bases, base) {
+			bases = append(bases, base)
+		}
+	}
+
+	found := make([]*LocalServer, len(bases))
+	var wg sync.WaitGroup
+	for i, base := range bases {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server, err := ProbeServer(ctx, base)
+			if err != nil {
+				slog.Debug("No local server found", "url", base, "error", err)
+				return
+			}
+			found[i] = server
+		}()
+	}
+	wg.Wait()
+
+	var servers []LocalServer
+	for _, server := range found {
+		if server != nil && len(server.Models) > 0 {
+			servers = append(servers, *server)
+		}
+	}
+	return servers
+}
+
+// ProbeServer asks the server at rawURL which models it serves. Ollama and
+// LM Studio are asked through their own APIs, which tell the context size
+// and capabilities of each model; other servers through /v1/models.
+func ProbeServer(ctx context.Context, rawURL string) (*LocalServer, error) {
+	base := serverBase(rawURL)
+	if base == "" {
+		return nil, fmt.Errorf("invalid server URL: %q", rawURL)
+	}
+	p := &prober{
+		base:   base,
+		client: &http.Client{Timeout: discoveryTimeout},
+	}
+
+	ollamaModels, err := p.ollamaModels(ctx)
+	if err == nil {
+		return p.server(ServerOllama, ollamaModels), nil
+	}
+	if errors.Is(err, errPool) {
+		return nil, err
+	}
+
+	var list struct {
+		Data []struct {
+			ID      string `json:"id"`
+			OwnedBy string `json:"owned_by"`
+			// Set by vLLM.
+			MaxModelLen int64 `json:"max_model_len"`
+		} `json:"data"`
+	}
+	if err := p.get(ctx, "/v1/models", &list); err != nil {
+		return nil, err
+	}
+
+	if models, err := p.lmStudioModels(ctx); err == nil {
+		return p.server(ServerLMStudio, models), nil
+	}
+
+	kind := ServerGeneric
+	var models []LocalServerModel
+	for _, m := range list.Data {
+		switch m.OwnedBy {
+		case "vllm":
+			kind = ServerVLLM
+		case "llamacpp":
+			kind = ServerLlamaCpp
+		}
+		models = append(models, newLocalModel(m.ID, m.MaxModelLen, true))
+	}
+	if kind == ServerLlamaCpp {
+		p.llamaCppProps(ctx, models)
+	}
+	return p.server(kind, models), nil
+}
+
+type prober struct {
+	base   string
+	client *http.Client
+}
+
+func (p *prober) server(kind ServerKind, models []LocalServerModel) *LocalServer {
+	u, _ := url.Parse(p.base)
+	id := string(kind)
+	if host := u.Hostname(); host != "localhost" && host != "127.0.0.1" {
+		id += "-" + strings.ReplaceAll(host, ".", "-")
+	}
+	if port := u.Port(); port != "" {
+		id += "-" + port
+	}
+	return &LocalServer{
+		ID:      id,
+		Name:    fmt.Sprintf("%s (%s)", kindNames[kind], u.Host),
+		Kind:    kind,
+		BaseURL: p.base + "/v1",
+		Models:  models,
+	}
+}
+
+var kindNames = map[ServerKind]string{
+	ServerOllama:   "Ollama",
+	ServerLMStudio: "LM Studio",
+	ServerVLLM:     "vLLM",
+	ServerLlamaCpp: "llama.cpp",
+	ServerGeneric:  "OpenAI-compatible",
+}
+
+// ollamaModels lists the models of an Ollama server, with the context
+// length and capabilities reported by /api/show.
+func (p *prober) ollamaModels(ctx context.Context) ([]LocalServerModel, error) {
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := p.get(ctx, "/api/tags", &tags); err != nil {
+		return nil, err
+	}
+
+	var models []LocalServerModel
+	for _, tag := range tags.Models {
+		var show struct {
+			ModelInfo    map[string]any `json:"model_info"`
+			Capabilities []string       `json:"capabilities"`
+		}
+		if err := p.post(ctx, "/api/show", map[string]string{"model": tag.Name}, &show); err != nil {
+			slog.Debug("Failed to get Ollama model info", "model", tag.Name, "error", err)
+			models = append(models, newLocalModel(tag.Name, 0, true))
+			continue
+		}
+		if len(show.Capabilities) > 0 && !slices.Contains(show.Capabilities, "completion") {
+			continue // Embedding models
+		}
+
+		var contextLength int64
+		for key, value := range show.ModelInfo {
+			if n, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+				contextLength = int64(n)
+			}
+		}
+		model := newLocalModel(tag.Name, contextLength,
+			len(show.Capabilities) == 0 || slices.Contains(show.Capabilities, "tools"))
+		model.SupportsImages = slices.Contains(show.Capabilities, "vision")
+		model.CanReason = slices.Contains(show.Capabilities, "thinking")
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// lmStudioModels lists the language models of an LM Studio server. The
+// context length of loaded models is the one they were loaded with.
+func (p *prober) lmStudioModels(ctx context.Context) ([]LocalServerModel, error) {
+	var list struct {
+		Data []struct {
+			ID                  string   `json:"id"`
+			Type                string   `json:"type"`
+			MaxContextLength    int64    `json:"max_context_length"`
+			LoadedContextLength int64    `json:"loaded_context_length"`
+			Capabilities        []string `json:"capabilities"`
+		} `json:"data"`
+	}
+	if err := p.get(ctx, "/api/v0/models", &list); err != nil {
+		return nil, err
+	}
+
+	var models []LocalServerModel
+	for _, m := range list.Data {
+		if m.Type != "llm" && m.Type != "vlm" {
+			continue
+		}
+		contextLength := m.MaxContextLength
+		if m.LoadedContextLength > 0 {
+			contextLength = m.LoadedContextLength
+		}
+		model := newLocalModel(m.ID, contextLength, slices.Contains(m.Capabilities, "tool_use"))
+		model.SupportsImages = m.Type == "vlm"
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// llamaCppProps sets the context size the llama.cpp server was started
+// with, and whether its chat template supports tools.
+func (p *prober) llamaCppProps(ctx context.Context, models []LocalServerModel) {
+	var props struct {
+		DefaultGenerationSettings struct {
+			NCtx int64 `json:"n_ctx"`
+		} `json:"default_generation_settings"`
+		ChatTemplateCaps struct {
+			SupportsTools *bool `json:"supports_tools"`
+		} `json:"chat_template_caps"`
+	}
+	if err := p.get(ctx, "/props", &props); err != nil {
+		slog.Debug("Failed to get llama.cpp server properties", "error", err)
+		return
+	}
+	for i := range models {
+		if n := props.DefaultGenerationSettings.NCtx; n > 0 {
+			models[i] = withContextWindow(models[i], n)
+		}
+		if supports := props.ChatTemplateCaps.SupportsTools; supports != nil {
+			models[i].SupportsTools = *supports
+		}
+	}
+}
+
+func (p *prober) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.base+path, nil)
+	if err != nil {
+		return err
+	}
+	return p.do(req, v)
+}
+
+func (p *prober) post(ctx context.Context, path string, body, v any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.base+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return p.do(req, v)
+}
+
+func (p *prober) do(req *http.Request, v any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get(poolHeader) != "" {
+		return errPool
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: status %d", req.Method, req.URL.Path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func newLocalModel(id string, contextWindow int64, supportsTools bool) LocalServerModel {
+	return withContextWindow(LocalServerModel{
+		Model:         catwalk.Model{ID: id, Name: id},
+		SupportsTools: supportsTools,
+	}, contextWindow)
+}
+
+// withContextWindow sets the context window of m, leaving at least half of
+// it for the prompt.
+func withContextWindow(m LocalServerModel, contextWindow int64) LocalServerModel {
+	if contextWindow <= 0 {
+		contextWindow = defaultContextWindow
+	}
+	m.ContextWindow = contextWindow
+	m.DefaultMaxTokens = min(defaultMaxTokens, contextWindow/2)
+	return m
+}
+
+// serverBase returns rawURL without a trailing slash or /v1 suffix, or ""
+// if it isn't an http(s) URL.
+func serverBase(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/v1")
+	u.RawQuery = ""
+	u.Fragment = ""
+	return strings.TrimSuffix(u.String(), "/")
+}
@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeOllama(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"models":[{"name":"qwen3:8b"},{"name":"nomic-embed-text"}]}`))
+	})
+	mux.HandleFunc("POST /api/show", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Model string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Model == "nomic-embed-text" {
+			w.Write([]byte(`{"capabilities":["embedding"]}`))
+			return
+		}
+		w.Write([]byte(`{"model_info":{"qwen3.context_length":40960},"capabilities":["completion","tools","thinking"]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	server, err := ProbeServer(t.Context(), srv.URL+"/v1/")
+	require.NoError(t, err)
+	require.Equal(t, ServerOllama, server.Kind)
+	require.Equal(t, srv.URL+"/v1", server.BaseURL)
+	require.Len(t, server.Models, 1)
+
+	model := server.Models[0]
+	require.Equal(t, "qwen3:8b", model.ID)
+	require.EqualValues(t, 40960, model.ContextWindow)
+	require.EqualValues(t, 4096, model.DefaultMaxTokens)
+	require.True(t, model.SupportsTools)
+	require.True(t, model.CanReason)
+}
+
+func TestProbeVLLM(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"id":"Qwen/Qwen2.5-Coder-7B","owned_by":"vllm","max_model_len":32768}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	server, err := ProbeServer(t.Context(), srv.URL)
+	require.NoError(t, err)
+	require.Equal(t, ServerVLLM, server.Kind)
+	require.Len(t, server.Models, 1)
+	require.EqualValues(t, 32768, server.Models[0].ContextWindow)
+
+	_, err = ProbeServer(t.Context(), "localhost:1234")
+	require.Error(t, err, "URLs need a scheme")
+}
+
+func TestProbeSkipsPool(t *testing.T) {
+	p, started := newTestPool(t, PoolOptions{}, "http://127.0.0.1:1/v1")
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	_, err := ProbeServer(t.Context(), srv.URL)
+	require.ErrorIs(t, err, errPool)
+	require.Empty(t, started, "probing must not start a model")
+}
@@ -0,0 +1,468 @@
+package backend
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// downloadWorkers is the number of chunks downloaded at the same time,
+	// from one large file or from the shards of a model.
+	downloadWorkers = 4
+	// downloadChunkSize is the size of the byte ranges files are split in.
+	downloadChunkSize = 64 * 1024 * 1024
+
+	// userAgent avoids being blocked by the download servers.
+	userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36"
+)
+
+// ErrChecksumMismatch is returned when a downloaded file doesn't have the
+// SHA-256 checksum published for it.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// RemoteFile is a file of a model to download.
+type RemoteFile struct {
+	URL string `json:"url"`
+	// Path is where the file is stored, relative to the models directory.
+	Path string `json:"path"`
+	// Size is 0 when unknown, the server is asked for it.
+	Size int64 `json:"size"`
+	// SHA256 is the checksum the file is verified against, empty to only
+	// check its size.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// partialState is saved next to a partial download to resume it.
+type partialState struct {
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+	// ChunkSize is 0 when the server doesn't serve byte ranges, the file is
+	// then downloaded in a single chunk from the start.
+	ChunkSize int64 `json:"chunk_size"`
+	// Done is the number of bytes downloaded from the start of each chunk.
+	Done []int64 `json:"done"`
+}
+
+// bounds returns the byte range of chunk i, end is 0 when the size is
+// unknown.
+func (s *partialState) bounds(i int) (start, end int64) {
+	if s.ChunkSize == 0 {
+		return 0, s.Size
+	}
+	start = int64(i) * s.ChunkSize
+	return start, min(start+s.ChunkSize, s.Size)
+}
+
+func (s *partialState) complete(i int) bool {
+	start, end := s.bounds(i)
+	return end > 0 && start+s.Done[i] >= end
+}
+
+func (s *partialState) downloaded() int64 {
+	var n int64
+	for _, done := range s.Done {
+		n += done
+	}
+	return n
+}
+
+type fileDownload struct {
+	RemoteFile
+	path string
+	file *os.File
+
+	mu        sync.Mutex
+	state     partialState
+	remaining int
+}
+
+func (f *fileDownload) partialPath() string {
+	return f.path + ".partial"
+}
+
+func (f *fileDownload) statePath() string {
+	return f.path + ".partial.json"
+}
+
+// loadState reads the state of a previous download of the same file.
+func (f *fileDownload) loadState() bool {
+	data, err := os.ReadFile(f.statePath())
+	if err != nil {
+		return false
+	}
+	var state partialState
+	if err := json.Unmarshal(data, &state); err != nil || state.URL != f.URL || state.ChunkSize == 0 {
+		return false
+	}
+	if info, err := os.Stat(f.partialPath()); err != nil || info.Size() != state.Size {
+		return false
+	}
+	f.state = state
+	f.Size = state.Size
+	return true
+}
+
+// saveState records the progress of the download, unless it's finished.
+func (f *fileDownload) saveState() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.remaining == 0 {
+		return
+	}
+	data, err := json.Marshal(f.state)
+	if err == nil {
+		err = os.WriteFile(f.statePath(), data, 0o644)
+	}
+	if err != nil {
+		slog.Warn("Failed to save download state", "path", f.statePath(), "error", err)
+	}
+}
+
+// chunkDone finishes the file once its last chunk is downloaded.
+func (f *fileDownload) chunkDone() error {
+	f.mu.Lock()
+	f.remaining--
+	last := f.remaining == 0
+	f.mu.Unlock()
+	if !last {
+		return nil
+	}
+	return f.finish()
+}
+
+// finish verifies the checksum of the downloaded file and moves it in place.
+func (f *fileDownload) finish() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", f.Path, err)
+	}
+	if f.SHA256 != "" {
+		sum, err := FileSHA256(f.partialPath())
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sum, f.SHA256) {
+			// Start over next time, we can't tell which chunk is wrong.
+			os.Remove(f.partialPath())
+			os.Remove(f.statePath())
+			return fmt.Errorf("%s: %w: expected %s, got %s", f.Path, ErrChecksumMismatch, f.SHA256, sum)
+		}
+	}
+	if err := os.Rename(f.partialPath(), f.path); err != nil {
+		return fmt.Errorf("failed to move %s in place: %w", f.Path, err)
+	}
+	os.Remove(f.statePath())
+	slog.Info("Model file downloaded", "path", f.path, "size", f.Size, "verified", f.SHA256 != "")
+	return nil
+}
+
+// close saves the state of an unfinished download to resume it later.
+func (f *fileDownload) close() {
+	f.mu.Lock()
+	unfinished := f.remaining > 0
+	f.mu.Unlock()
+	if unfinished {
+		f.saveState()
+		f.file.Close()
+	}
+}
+
+type chunk struct {
+	f *fileDownload
+	i int
+}
+
+type downloader struct {
+	client    *http.Client
+	workers   int
+	chunkSize int64
+}
+
+func newDownloader() *downloader {
+	return &downloader{
+		client:    &http.Client{},
+		workers:   downloadWorkers,
+		chunkSize: downloadChunkSize,
+	}
+}
+
+// download fetches files into dir, several chunks at a time. Interrupted
+// downloads resume from the bytes already written, and each file is checked
+// against its checksum before it's moved in place. Files that are already
+// there are skipped.
+func (d *downloader) download(ctx context.Context, dir string, files []RemoteFile, progressFn func(downloaded, total int64)) error {
+	var total int64
+	var downloaded atomic.Int64
+	var pending []*fileDownload
+	var chunks []chunk
+	defer func() {
+		for _, f := range pending {
+			f.close()
+		}
+	}()
+
+	for _, rf := range files {
+		path := filepath.Join(dir, rf.Path)
+		if info, err := os.Stat(path); err == nil && (rf.Size == 0 || info.Size() == rf.Size) {
+			total += info.Size()
+			downloaded.Add(info.Size())
+			continue
+		}
+		f, err := d.prepare(ctx, path, rf)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, f)
+		total += f.Size
+		downloaded.Add(f.state.downloaded())
+		for i := range f.state.Done {
+			if !f.state.complete(i) {
+				chunks = append(chunks, chunk{f: f, i: i})
+			}
+		}
+		if f.remaining == 0 {
+			if err := f.finish(); err != nil {
+				return err
+			}
+		}
+	}
+
+	report := func() {
+		if progressFn != nil && total > 0 {
+			progressFn(min(downloaded.Load(), total), total)
+		}
+	}
+	report()
+
+	workCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	work := make(chan chunk)
+	go func() {
+		defer close(work)
+		for _, c := range chunks {
+			select {
+			case work <- c:
+			case <-workCtx.Done():
+				return
+			}
+		}
+	}()
+
+	errs := make(chan error, d.workers)
+	var wg sync.WaitGroup
+	for range min(d.workers, len(chunks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range work {
+				if err := d.fetch(workCtx, c, &downloaded); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	progress := time.NewTicker(100 * time.Millisecond)
+	defer progress.Stop()
+	save := time.NewTicker(time.Second)
+	defer save.Stop()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-progress.C:
+			report()
+		case <-save.C:
+			for _, f := range pending {
+				f.saveState()
+			}
+		}
+	}
+
+	select {
+	case err := <-errs:
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	default:
+	}
+	report()
+	return nil
+}
+
+// prepare opens the partial file of rf, resuming a previous download of it
+// when there is one.
+func (d *downloader) prepare(ctx context.Context, path string, rf RemoteFile) (*fileDownload, error) {
+	f := &fileDownload{RemoteFile: rf, path: path}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create models directory: %w", err)
+	}
+
+	if f.loadState() {
+		slog.Info("Resuming download", "path", f.partialPath(), "downloaded", f.state.downloaded(), "size", f.Size)
+	} else {
+		size, ranges, err := d.probe(ctx, rf.URL)
+		if err != nil {
+			return nil, err
+		}
+		if size > 0 {
+			f.Size = size
+		}
+		f.state = partialState{URL: rf.URL, Size: f.Size, Done: []int64{0}}
+		if ranges && f.Size > 0 {
+			f.state.ChunkSize = d.chunkSize
+			f.state.Done = make([]int64, (f.Size+d.chunkSize-1)/d.chunkSize)
+			// A partial file without state was written from the start in one
+			// go by an older version.
+			if info, err := os.Stat(f.partialPath()); err == nil && info.Size() <= f.Size {
+				for i := range f.state.Done {
+					start, end := f.state.bounds(i)
+					f.state.Done[i] = max(min(info.Size(), end)-start, 0)
+				}
+				slog.Info("Resuming download", "path", f.partialPath(), "downloaded", info.Size(), "size", f.Size)
+			}
+		}
+	}
+
+	file, err := os.OpenFile(f.partialPath(), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create model file: %w", err)
+	}
+	// Chunks are written at their offset, a single chunk starts over.
+	size := f.Size
+	if f.state.ChunkSize == 0 {
+		size = 0
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to allocate model file: %w", err)
+	}
+	f.file = file
+	for i := range f.state.Done {
+		if !f.state.complete(i) {
+			f.remaining++
+		}
+	}
+	return f, nil
+}
+
+// probe returns the size of the file at url, 0 if unknown, and whether the
+// server serves byte ranges of it.
+func (d *downloader) probe(ctx context.Context, url string) (int64, bool, error) {
+	resp, err := d.get(ctx, url, "bytes=0-0")
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/<size>
+		_, total, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		size, err := strconv.ParseInt(total, 10, 64)
+		if err != nil {
+			return 0, false, nil
+		}
+		return size, true, nil
+	case http.StatusOK:
+		return max(resp.ContentLength, 0), false, nil
+	default:
+		return 0, false, fmt.Errorf("download of %s failed with status: %s", url, resp.Status)
+	}
+}
+
+// fetch downloads the rest of chunk c.
+func (d *downloader) fetch(ctx context.Context, c chunk, downloaded *atomic.Int64) error {
+	f := c.f
+	start, end := f.state.bounds(c.i)
+	f.mu.Lock()
+	offset := start + f.state.Done[c.i]
+	f.mu.Unlock()
+
+	var byteRange string
+	status := http.StatusOK
+	if f.state.ChunkSize > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, end-1)
+		status = http.StatusPartialContent
+	}
+	resp, err := d.get(ctx, f.URL, byteRange)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", f.Path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		return fmt.Errorf("download of %s failed with status: %s", f.Path, resp.Status)
+	}
+
+	buf := make([]byte, 256*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := f.file.WriteAt(buf[:n], offset); err != nil {
+				return fmt.Errorf("failed to write %s: %w", f.Path, err)
+			}
+			offset += int64(n)
+			f.mu.Lock()
+			f.state.Done[c.i] += int64(n)
+			f.mu.Unlock()
+			downloaded.Add(int64(n))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to download %s: %w", f.Path, err)
+		}
+	}
+	if end > 0 && offset < end {
+		return fmt.Errorf("failed to download %s: %w", f.Path, io.ErrUnexpectedEOF)
+	}
+	return f.chunkDone()
+}
+
+func (d *downloader) get(ctx context.Context, url, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	return d.client.Do(req)
+}
+
+// FileSHA256 returns the hex encoded SHA-256 checksum of the file at path.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,255 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrDownloadCancelled is returned for downloads cancelled from the queue.
+var ErrDownloadCancelled = errors.New("download cancelled")
+
+// Downloads is the queue of the model downloads of the process.
+var Downloads = NewDownloadQueue()
+
+// DownloadState is the state of a download in the queue.
+type DownloadState string
+
+const (
+	DownloadQueued    DownloadState = "queued"
+	DownloadRunning   DownloadState = "downloading"
+	DownloadPaused    DownloadState = "paused"
+	DownloadDone      DownloadState = "done"
+	DownloadFailed    DownloadState = "failed"
+	DownloadCancelled DownloadState = "cancelled"
+)
+
+// Download is a model download of the queue.
+type Download struct {
+	Model      ModelOption
+	State      DownloadState
+	Downloaded int64
+	Total      int64
+	Err        error
+}
+
+// Finished reports whether the download is done, failed or cancelled.
+func (d Download) Finished() bool {
+	return d.State == DownloadDone || d.State == DownloadFailed || d.State == DownloadCancelled
+}
+
+// DownloadTask downloads a model, and deletes what it downloaded when the
+// download is cancelled.
+type DownloadTask struct {
+	Model   ModelOption
+	Fetch   func(ctx context.Context, progressFn func(downloaded, total int64)) error
+	Discard func() error
+}
+
+type downloadJob struct {
+	Download
+	task   DownloadTask
+	cancel context.CancelFunc
+	// done is closed when the download is finished.
+	done chan struct{}
+}
+
+// DownloadQueue downloads models one after the other. Downloads can be
+// paused, they resume from the bytes already downloaded, and cancelled,
+// which deletes what was downloaded.
+type DownloadQueue struct {
+	mu   sync.Mutex
+	jobs []*downloadJob
+}
+
+// NewDownloadQueue creates an empty download queue.
+func NewDownloadQueue() *DownloadQueue {
+	return &DownloadQueue{}
+}
+
+// Add queues task, unless its model is already in the queue.
+func (q *DownloadQueue) Add(task DownloadTask) {
+	q.add(task)
+}
+
+func (q *DownloadQueue) add(task DownloadTask) *downloadJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	model := task.Model
+	if job := q.job(model.ID); job != nil {
+		if !job.Finished() {
+			return job
+		}
+		q.jobs = slices.DeleteFunc(q.jobs, func(j *downloadJob) bool { return j == job })
+	}
+	job := &downloadJob{
+		Download: Download{Model: model, State: DownloadQueued, Total: model.Size},
+		task:     task,
+		done:     make(chan struct{}),
+	}
+	q.jobs = append(q.jobs, job)
+	q.startNext()
+	return job
+}
+
+// Download queues task and waits for it to finish, reporting its progress.
+// Cancelling ctx cancels the download.
+func (q *DownloadQueue) Download(ctx context.Context, task DownloadTask, progressFn func(downloaded, total int64)) error {
+	job := q.add(task)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-job.done:
+			return job.Err
+		case <-ctx.Done():
+			q.Cancel(task.Model.ID)
+			return ctx.Err()
+		case <-ticker.C:
+			q.mu.Lock()
+			downloaded, total := job.Downloaded, job.Total
+			q.mu.Unlock()
+			if progressFn != nil && total > 0 {
+				progressFn(downloaded, total)
+			}
+		}
+	}
+}
+
+// Downloads returns the downloads of the queue in the order they were
+// added.
+func (q *DownloadQueue) Downloads() []Download {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	downloads := make([]Download, len(q.jobs))
+	for i, job := range q.jobs {
+		downloads[i] = job.Download
+	}
+	return downloads
+}
+
+// Pause stops the download of a model until it's resumed.
+func (q *DownloadQueue) Pause(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := q.job(id)
+	if job == nil || job.Finished() || job.State == DownloadPaused {
+		return
+	}
+	if job.State == DownloadRunning {
+		job.cancel()
+	}
+	job.State = DownloadPaused
+}
+
+// Resume queues a paused download again.
+func (q *DownloadQueue) Resume(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job := q.job(id); job != nil && job.State == DownloadPaused {
+		job.State = DownloadQueued
+		q.startNext()
+	}
+}
+
+// Cancel stops the download of a model and deletes what was downloaded.
+func (q *DownloadQueue) Cancel(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := q.job(id)
+	if job == nil || job.Finished() {
+		return
+	}
+	job.State = DownloadCancelled
+	job.Err = ErrDownloadCancelled
+	if job.cancel != nil {
+		// The download deletes its files once stopped.
+		job.cancel()
+		return
+	}
+	close(job.done)
+	go job.discard()
+}
+
+// Clear removes the finished downloads from the queue.
+func (q *DownloadQueue) Clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.jobs = slices.DeleteFunc(q.jobs, func(j *downloadJob) bool { return j.Finished() })
+}
+
+func (q *DownloadQueue) job(id string) *downloadJob {
+	for _, job := range q.jobs {
+		if job.Model.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// startNext starts the first queued download unless one is running.
+func (q *DownloadQueue) startNext() {
+	var next *downloadJob
+	for _, job := range q.jobs {
+		if job.cancel != nil {
+			return
+		}
+		if next == nil && job.State == DownloadQueued {
+			next = job
+		}
+	}
+	if next == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	next.cancel = cancel
+	next.State = DownloadRunning
+	go func() {
+		err := next.task.Fetch(ctx, func(downloaded, total int64) {
+			q.mu.Lock()
+			next.Downloaded, next.Total = downloaded, total
+			q.mu.Unlock()
+		})
+		cancel()
+
+		q.mu.Lock()
+		next.cancel = nil
+		switch {
+		case next.State == DownloadCancelled:
+			close(next.done)
+			go next.discard()
+		case err == nil:
+			next.State = DownloadDone
+			next.Downloaded = next.Total
+			close(next.done)
+		case next.State == DownloadPaused || next.State == DownloadQueued:
+			// Paused, and maybe resumed already. The download continues
+			// from the bytes already downloaded.
+		default:
+			next.State = DownloadFailed
+			next.Err = err
+			close(next.done)
+		}
+		q.startNext()
+		q.mu.Unlock()
+	}()
+}
+
+func (j *downloadJob) discard() {
+	if j.task.Discard == nil {
+		return
+	}
+	if err := j.task.Discard(); err != nil {
+		slog.Warn("Failed to delete cancelled download", "model", j.Model.ID, "error", err)
+	}
+}
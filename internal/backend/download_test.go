@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFileServer(t *testing.T, content []byte, failFrom *atomic.Int64, served *atomic.Int64) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start int64
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start)
+		if from := failFrom.Load(); from > 0 && start >= from {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		cw := &countingWriter{ResponseWriter: w, n: served}
+		http.ServeContent(cw, r, "model.gguf", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n *atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+	return w.ResponseWriter.Write(p)
+}
+
+func TestDownloadResumes(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	sum := sha256.Sum256(content)
+	var failFrom, served atomic.Int64
+	failFrom.Store(500)
+	srv := newTestFileServer(t, content, &failFrom, &served)
+
+	dir := t.TempDir()
+	d := &downloader{client: srv.Client(), workers: 1, chunkSize: 100}
+	files := []RemoteFile{{URL: srv.URL + "/model.gguf", Path: "model.gguf", SHA256: hex.EncodeToString(sum[:])}}
+
+	err := d.download(t.Context(), dir, files, nil)
+	require.Error(t, err, "the server fails after 500 bytes")
+	require.FileExists(t, filepath.Join(dir, "model.gguf.partial.json"))
+	require.NoFileExists(t, filepath.Join(dir, "model.gguf"))
+
+	failFrom.Store(0)
+	served.Store(0)
+	var downloaded, total int64
+	err = d.download(t.Context(), dir, files, func(d, t int64) { downloaded, total = d, t })
+	require.NoError(t, err)
+	require.Equal(t, int64(500), served.Load(), "only the missing chunks are downloaded again")
+	require.Equal(t, int64(1000), downloaded)
+	require.Equal(t, int64(1000), total)
+
+	got, err := os.ReadFile(filepath.Join(dir, "model.gguf"))
+	require.NoError(t, err)
+	require.Equal(t, content, got)
+	require.NoFileExists(t, filepath.Join(dir, "model.gguf.partial"))
+	require.NoFileExists(t, filepath.Join(dir, "model.gguf.partial.json"))
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	var failFrom, served atomic.Int64
+	srv := newTestFileServer(t, []byte("corrupted weights"), &failFrom, &served)
+
+	dir := t.TempDir()
+	d := &downloader{client: srv.Client(), workers: 4, chunkSize: 4}
+	err := d.download(t.Context(), dir, []RemoteFile{{
+		URL:    srv.URL + "/model.gguf",
+		Path:   "model.gguf",
+		SHA256: strings.Repeat("0", 64),
+	}}, nil)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+	require.NoFileExists(t, filepath.Join(dir, "model.gguf"))
+	require.NoFileExists(t, filepath.Join(dir, "model.gguf.partial"))
+}
+
+func TestDownloadQueue(t *testing.T) {
+	started := make(chan string, 10)
+	finish := make(chan struct{})
+	var discarded atomic.Value
+	task := func(id string) DownloadTask {
+		return DownloadTask{
+			Model: ModelOption{ID: id},
+			Fetch: func(ctx context.Context, progressFn func(downloaded, total int64)) error {
+				started <- id
+				progressFn(1, 2)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-finish:
+					return nil
+				}
+			},
+			Discard: func() error {
+				discarded.Store(id)
+				return nil
+			},
+		}
+	}
+
+	q := NewDownloadQueue()
+	q.Add(task("a"))
+	q.Add(task("b"))
+	require.Equal(t, "a", <-started, "downloads run one at a time")
+
+	q.Pause("a")
+	require.Equal(t, "b", <-started, "the next download starts while a is paused")
+	q.Cancel("b")
+	require.Eventually(t, func() bool { return discarded.Load() == "b" }, time.Second, 10*time.Millisecond)
+
+	q.Resume("a")
+	require.Equal(t, "a", <-started)
+	close(finish)
+	require.Eventually(t, func() bool {
+		downloads := q.Downloads()
+		return downloads[0].State == DownloadDone && downloads[1].State == DownloadCancelled
+	}, time.Second, 10*time.Millisecond)
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -33,10 +35,14 @@ type HuggingFaceFile struct {
 	Path string `json:"path"`
 	Size int64  `json:"size"`
 	LFS  struct {
-		Size int64 `json:"size"`
+		Size int64  `json:"size"`
+		Oid  string `json:"oid"` // SHA-256 checksum of the file
 	} `json:"lfs"`
 }
 
+// hfFileURL matches the download URL of a file in a Hugging Face repo.
+var hfFileURL = regexp.MustCompile(`^https://huggingface\.co/([^/]+/[^/]+)/resolve/([^/]+)/(.+)$`)
+
 // HuggingFaceClient provides access to the Hugging Face API
 type HuggingFaceClient struct {
 	baseURL string
@@ -144,7 +150,42 @@ func (h *HuggingFaceClient) GetRecentModels(ctx context.Context) ([]HuggingFaceM
 
 // GetModelFiles gets the list of files in a model repository
 func (h *HuggingFaceClient) GetModelFiles(ctx context.Context, modelID string) ([]HuggingFaceFile, error) {
-	reqURL := fmt.Sprintf("%s/models/%s/tree/main", h.baseURL, modelID)
+	return h.getTree(ctx, modelID, "main", "")
+}
+
+// FileInfo returns the size and SHA-256 checksum Hugging Face publishes for
+// the file downloaded from fileURL.
+func (h *HuggingFaceClient) FileInfo(ctx context.Context, fileURL string) (int64, string, error) {
+	m := hfFileURL.FindStringSubmatch(fileURL)
+	if m == nil {
+		return 0, "", fmt.Errorf("not a Hugging Face file: %s", fileURL)
+	}
+	repo, revision, file := m[1], m[2], m[3]
+	dir := path.Dir(file)
+	if dir == "." {
+		dir = ""
+	}
+	files, err := h.getTree(ctx, repo, revision, dir)
+	if err != nil {
+		return 0, "", err
+	}
+	for _, f := range files {
+		if f.Path != file {
+			continue
+		}
+		if f.LFS.Size > 0 {
+			return f.LFS.Size, f.LFS.Oid, nil
+		}
+		return f.Size, "", nil
+	}
+	return 0, "", fmt.Errorf("%s not found in %s", file, repo)
+}
+
+func (h *HuggingFaceClient) getTree(ctx context.Context, modelID, revision, dir string) ([]HuggingFaceFile, error) {
+	reqURL := fmt.Sprintf("%s/models/%s/tree/%s", h.baseURL, modelID, revision)
+	if dir != "" {
+		reqURL += "/" + dir
+	}
 	
 	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
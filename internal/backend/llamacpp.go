@@ -27,6 +27,7 @@ type LlamaCppBackend struct {
 	port       int
 	process    *exec.Cmd
 	modelID    string
+	profile    LaunchProfile
 }
 
 // NewLlamaCppBackend creates a new llama.cpp backend
@@ -156,183 +157,33 @@ func (b *LlamaCppBackend) DownloadServer(ctx context.Context, progressFn func(do
 	return nil
 }
 
-// DownloadModel downloads the GGUF model
+// DownloadModel downloads the GGUF model, resuming an interrupted download
+// and verifying it against the checksum published on Hugging Face
 func (b *LlamaCppBackend) DownloadModel(ctx context.Context, model ModelOption, progressFn func(downloaded, total int64)) error {
-	modelPath := filepath.Join(b.dataDir, "models", model.ID+".gguf")
-	
-	// Check if already exists
-	if info, err := os.Stat(modelPath); err == nil {
-		if info.Size() == model.Size {
-			b.modelPath = modelPath
-			slog.Info("Model already downloaded", "path", modelPath)
-			// Report 100% progress
-			if progressFn != nil {
-				progressFn(model.Size, model.Size)
-			}
-			return nil
-		}
-		// Size mismatch, remove corrupted file
-		slog.Warn("Removing corrupted model file", "path", modelPath, "expected", model.Size, "actual", info.Size())
-		os.Remove(modelPath)
-	}
-	
-	// Create models directory
-	if err := os.MkdirAll(filepath.Dir(modelPath), 0755); err != nil {
-		return fmt.Errorf("failed to create models directory: %w", err)
-	}
-	
-	// Check for stuck partial download (older than 5 minutes with no progress)
-	partialPath := modelPath + ".partial"
-	var startByte int64
-	if info, err := os.Stat(partialPath); err == nil {
-		// Check if file is stale (not modified in last 5 minutes)
-		if time.Since(info.ModTime()) > 5*time.Minute {
-			slog.Info("Removing stale partial download", "path", partialPath, "age", time.Since(info.ModTime()))
-			os.Remove(partialPath)
-			startByte = 0
-		} else {
-			startByte = info.Size()
-			slog.Info("Resuming download", "path", partialPath, "startByte", startByte)
-		}
-	}
-	
-	// Download the model
-	slog.Info("Downloading model", "url", model.URL, "startByte", startByte)
-	req, err := http.NewRequestWithContext(ctx, "GET", model.URL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	
-	// Add user agent to avoid being blocked
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
-	
-	// Support resume if we have partial data
-	if startByte > 0 {
-		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", startByte))
-		slog.Info("Resuming download", "range", req.Header.Get("Range"))
-		// Report initial progress
+	modelPath := localModelPath(b.dataDir, model)
+
+	// Downloads are only moved in place once complete
+	if _, err := os.Stat(modelPath); err == nil {
+		b.modelPath = modelPath
+		slog.Info("Model already downloaded", "path", modelPath)
+		// Report 100% progress
 		if progressFn != nil {
-			progressFn(startByte, model.Size)
+			progressFn(model.Size, model.Size)
 		}
+		return nil
 	}
-	
-	// Create custom client that follows redirects
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// Copy the Range header to the redirect request if present
-			if rangeHeader := via[0].Header.Get("Range"); rangeHeader != "" {
-				req.Header.Set("Range", rangeHeader)
-			}
-			return nil
-		},
-		Timeout: 0, // No timeout for large downloads
-	}
-	
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to download model: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	slog.Info("Download response", "status", resp.StatusCode, "statusText", resp.Status, "contentLength", resp.ContentLength)
-	
-	// Accept 200 (OK), 206 (Partial Content), and check for failed resume
-	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && startByte > 0 {
-		// Server doesn't support resume, start from beginning
-		slog.Info("Server doesn't support resume, starting from beginning")
-		os.Remove(partialPath)
-		startByte = 0
-		
-		// Retry without Range header
-		req.Header.Del("Range")
-		resp.Body.Close()
-		resp, err = client.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to download model (retry): %w", err)
-		}
-		defer resp.Body.Close()
-	}
-	
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		return fmt.Errorf("download failed with status: %s (code: %d)", resp.Status, resp.StatusCode)
-	}
-	
-	// Open file for writing (append if resuming)
-	var file *os.File
-	if startByte > 0 {
-		// Try to open for append, but create if it doesn't exist
-		file, err = os.OpenFile(partialPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-		if err != nil {
-			// If that fails, just create a new file
-			slog.Warn("Failed to open partial file for append, creating new", "error", err)
-			startByte = 0
-			file, err = os.Create(partialPath)
-		}
-	} else {
-		file, err = os.Create(partialPath)
-	}
+
+	files, err := ResolveModelFiles(ctx, model)
 	if err != nil {
-		return fmt.Errorf("failed to create model file: %w", err)
-	}
-	
-	// Download with progress
-	progressReader := &progressReader{
-		reader:     resp.Body,
-		total:      model.Size,
-		downloaded: startByte,
-		progressFn: progressFn,
+		return err
 	}
-	
-	_, err = io.Copy(file, progressReader)
-	if err != nil {
-		file.Close()
+	slog.Info("Downloading model", "url", model.URL, "size", files[0].Size, "sha256", files[0].SHA256)
+	if err := pullModel(ctx, b.dataDir, model, files, progressFn); err != nil {
 		return fmt.Errorf("failed to download model: %w", err)
 	}
-	
-	// Close file before renaming (important for file locks)
-	if err := file.Close(); err != nil {
-		return fmt.Errorf("failed to close model file: %w", err)
-	}
-	
-	// Ensure target directory exists
-	if err := os.MkdirAll(filepath.Dir(modelPath), 0755); err != nil {
-		return fmt.Errorf("failed to create target directory: %w", err)
-	}
-	
-	// Try to rename first (fastest option)
-	renameErr := os.Rename(partialPath, modelPath)
-	if renameErr != nil {
-		// If rename fails (e.g., cross-device), fall back to copy and delete
-		slog.Warn("Rename failed, trying copy instead", "error", renameErr)
-		
-		// Copy the file
-		srcFile, err := os.Open(partialPath)
-		if err != nil {
-			return fmt.Errorf("failed to open partial file for copy: %w", err)
-		}
-		defer srcFile.Close()
-		
-		dstFile, err := os.Create(modelPath)
-		if err != nil {
-			return fmt.Errorf("failed to create final model file: %w", err)
-		}
-		
-		_, copyErr := io.Copy(dstFile, srcFile)
-		dstFile.Close()
-		
-		if copyErr != nil {
-			os.Remove(modelPath) // Clean up partial copy
-			return fmt.Errorf("failed to copy model file: %w", copyErr)
-		}
-		
-		// Remove the partial file
-		if err := os.Remove(partialPath); err != nil {
-			slog.Warn("Failed to remove partial file after copy", "path", partialPath, "error", err)
-		}
-	}
-	
+
 	b.modelPath = modelPath
-	slog.Info("Model downloaded successfully", "path", modelPath, "size", model.Size)
+	slog.Info("Model downloaded successfully", "path", modelPath)
 	return nil
 }
 
@@ -348,20 +199,20 @@ func (b *LlamaCppBackend) Start(ctx context.Context) error {
 	}
 	
 	// Prepare command arguments
+	var modelSize int64
+	if info, err := os.Stat(b.modelPath); err == nil {
+		modelSize = info.Size()
+	}
+	profile := ResolveProfile(b.profile, modelSize)
 	args := []string{
 		"--model", b.modelPath,
 		"--port", fmt.Sprintf("%d", b.port),
 		"--host", "127.0.0.1",
-		"--n-gpu-layers", "-1", // Use all GPU layers on Apple Silicon
-		"--ctx-size", "8192",
-		"--threads", fmt.Sprintf("%d", runtime.NumCPU()),
-		"--mlock", // Lock model in memory
-		"--no-mmap", // Don't use memory mapping for better performance
-		"--jinja", // Enable jinja templating for tool support
 	}
+	args = append(args, profile.args()...)
 	
 	// Add Apple Silicon optimizations
-	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" {
+	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" && *profile.GPULayers != 0 {
 		args = append(args, "--use-metal") // Enable Metal acceleration
 	}
 	
@@ -394,7 +245,7 @@ func (b *LlamaCppBackend) Start(ctx context.Context) error {
 		return fmt.Errorf("server failed to start: %w", err)
 	}
 	
-	slog.Info("llama.cpp server started successfully", "port", b.port, "model", b.modelID)
+	slog.Info("llama.cpp server started successfully", "port", b.port, "model", b.modelID, "ctx_size", profile.ContextSize, "threads", profile.Threads)
 	return nil
 }
 
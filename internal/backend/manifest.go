@@ -0,0 +1,285 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Manifest records the files of a downloaded model to verify and remove
+// them later.
+type Manifest struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Provider string       `json:"provider"`
+	URL      string       `json:"url"`
+	Files    []RemoteFile `json:"files"`
+	// DownloadedAt is zero for models downloaded by older versions, which
+	// have no manifest.
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// Model returns the model option the manifest was written for.
+func (m Manifest) Model() ModelOption {
+	if model := GetModelByID(m.ID); model != nil {
+		return *model
+	}
+	return ModelOption{ID: m.ID, Name: m.Name, Provider: m.Provider, URL: m.URL}
+}
+
+// Size returns the size of the files of the model.
+func (m Manifest) Size() int64 {
+	var size int64
+	for _, f := range m.Files {
+		size += f.Size
+	}
+	return size
+}
+
+// Verified reports whether every file of the model has a checksum.
+func (m Manifest) Verified() bool {
+	return len(m.Files) > 0 && !slices.ContainsFunc(m.Files, func(f RemoteFile) bool {
+		return f.SHA256 == ""
+	})
+}
+
+func modelsDir(dataDir string) string {
+	return filepath.Join(dataDir, "models")
+}
+
+func manifestPath(dataDir, id string) string {
+	return filepath.Join(modelsDir(dataDir), "manifests", id+".json")
+}
+
+// localModelPath returns the GGUF file or the MLX directory of a model.
+func localModelPath(dataDir string, model ModelOption) string {
+	if model.Provider == "mlx" {
+		return filepath.Join(modelsDir(dataDir), "mlx", model.ID)
+	}
+	return filepath.Join(modelsDir(dataDir), model.ID+".gguf")
+}
+
+// ResolveModelFiles returns the files to download for model with the size
+// and checksum Hugging Face publishes for them.
+func ResolveModelFiles(ctx context.Context, model ModelOption) ([]RemoteFile, error) {
+	switch model.Provider {
+	case "mlx":
+		return mlxModelFiles(ctx, model)
+	case "llamacpp":
+		file := RemoteFile{URL: model.URL, Path: model.ID + ".gguf", SHA256: model.Checksum}
+		size, sum, err := NewHuggingFaceClient().FileInfo(ctx, model.URL)
+		if err != nil {
+			slog.Warn("Can't get the checksum of the model, it won't be verified", "model", model.ID, "error", err)
+			return []RemoteFile{file}, nil
+		}
+		file.Size = size
+		if file.SHA256 == "" {
+			file.SHA256 = sum
+		}
+		return []RemoteFile{file}, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", model.Provider)
+	}
+}
+
+// PullModel downloads the files of model into the data directory, resuming
+// a previous download of it.
+func PullModel(ctx context.Context, dataDir string, model ModelOption, progressFn func(downloaded, total int64)) error {
+	b, err := newModelBackend(dataDir, model, 0, LaunchProfile{})
+	if err != nil {
+		return err
+	}
+	return b.DownloadModel(ctx, model, progressFn)
+}
+
+// pullModel downloads files and records them in the manifest of model.
+func pullModel(ctx context.Context, dataDir string, model ModelOption, files []RemoteFile, progressFn func(downloaded, total int64)) error {
+	if err := newDownloader().download(ctx, modelsDir(dataDir), files, progressFn); err != nil {
+		return err
+	}
+	for i, f := range files {
+		if info, err := os.Stat(filepath.Join(modelsDir(dataDir), f.Path)); err == nil {
+			files[i].Size = info.Size()
+		}
+	}
+	m := Manifest{
+		ID:           model.ID,
+		Name:         model.Name,
+		Provider:     model.Provider,
+		URL:          model.URL,
+		Files:        files,
+		DownloadedAt: time.Now(),
+	}
+	return writeManifest(dataDir, m)
+}
+
+func writeManifest(dataDir string, m Manifest) error {
+	path := manifestPath(dataDir, m.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create manifests directory: %w", err)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest of %s: %w", m.ID, err)
+	}
+	return nil
+}
+
+// ReadManifest returns the manifest of a downloaded model.
+func ReadManifest(dataDir, id string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestPath(dataDir, id))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest of %s: %w", id, err)
+	}
+	return &m, nil
+}
+
+// InstalledModels returns the manifests of the downloaded models, including
+// the models of the catalog downloaded by older versions, without files.
+func InstalledModels(dataDir string) ([]Manifest, error) {
+	paths, err := filepath.Glob(filepath.Join(modelsDir(dataDir), "manifests", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var installed []Manifest
+	for _, path := range paths {
+		m, err := ReadManifest(dataDir, strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		installed = append(installed, *m)
+	}
+	for _, model := range AvailableModels() {
+		if slices.ContainsFunc(installed, func(m Manifest) bool { return m.ID == model.ID }) || !isDownloaded(dataDir, model) {
+			continue
+		}
+		installed = append(installed, Manifest{ID: model.ID, Name: model.Name, Provider: model.Provider, URL: model.URL})
+	}
+	return installed, nil
+}
+
+// isDownloaded reports whether a model downloaded by an older version is
+// complete.
+func isDownloaded(dataDir string, model ModelOption) bool {
+	path := localModelPath(dataDir, model)
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+	if model.Provider == "mlx" {
+		return isMLXModelComplete(path)
+	}
+	return true
+}
+
+// VerifyModel checks the size and checksum of the files of a downloaded
+// model. Models without manifest are checked against Hugging Face, and get
+// one when they match.
+func VerifyModel(ctx context.Context, dataDir string, m Manifest) error {
+	files := m.Files
+	if len(files) == 0 {
+		var err error
+		if files, err = ResolveModelFiles(ctx, m.Model()); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		path := filepath.Join(modelsDir(dataDir), f.Path)
+		info, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path, err))
+			continue
+		}
+		if f.Size > 0 && info.Size() != f.Size {
+			errs = append(errs, fmt.Errorf("%s: size is %d, expected %d", f.Path, info.Size(), f.Size))
+			continue
+		}
+		if f.SHA256 == "" {
+			continue
+		}
+		sum, err := FileSHA256(path)
+		if err != nil {
+			errs = append(errs, err)
+		} else if !strings.EqualFold(sum, f.SHA256) {
+			errs = append(errs, fmt.Errorf("%s: %w: expected %s, got %s", f.Path, ErrChecksumMismatch, f.SHA256, sum))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if len(m.Files) == 0 {
+		m.Files = files
+		return writeManifest(dataDir, m)
+	}
+	return nil
+}
+
+// RemoveModel deletes the files of a model, downloaded or partial, and its
+// manifest.
+func RemoveModel(dataDir string, model ModelOption) error {
+	path := localModelPath(dataDir, model)
+	if err := RemovePartialDownloads(dataDir, model); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", model.ID, err)
+	}
+	if err := os.Remove(manifestPath(dataDir, model.ID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove manifest of %s: %w", model.ID, err)
+	}
+	return nil
+}
+
+// RemovePartialDownloads deletes what was downloaded of a model whose
+// download didn't finish.
+func RemovePartialDownloads(dataDir string, model ModelOption) error {
+	path := localModelPath(dataDir, model)
+	if model.Provider == "mlx" {
+		if _, err := ReadManifest(dataDir, model.ID); err == nil {
+			return nil
+		}
+		// The files of an MLX model are only usable together.
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		return nil
+	}
+	for _, p := range []string{path + ".partial", path + ".partial.json"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// partialFiles returns the partial downloads in the directory of an MLX
+// model.
+func partialFiles(dir string) []string {
+	var partial []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && (strings.HasSuffix(path, ".partial") || strings.HasSuffix(path, ".partial.json")) {
+			partial = append(partial, path)
+		}
+		return nil
+	})
+	return partial
+}
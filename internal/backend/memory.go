@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// SystemMemory returns the physical memory of the machine in bytes, or 0 if
+// it can't be told.
+func SystemMemory() int64 {
+	switch runtime.GOOS {
+	case "linux":
+		f, err := os.Open("/proc/meminfo")
+		if err != nil {
+			return 0
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			// MemTotal:       32795588 kB
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "MemTotal:" {
+				kb, _ := strconv.ParseInt(fields[1], 10, 64)
+				return kb * 1024
+			}
+		}
+	case "darwin":
+		out, err := exec.Command("sysctl", "-n", "hw.memsize").Output()
+		if err != nil {
+			return 0
+		}
+		n, _ := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		return n
+	}
+	return 0
+}
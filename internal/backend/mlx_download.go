@@ -19,106 +19,72 @@ type HFFile struct {
 	Path string `json:"path"`
 	Size int64  `json:"size"`
 	LFS  struct {
-		Size int64 `json:"size"`
+		Size int64  `json:"size"`
+		Oid  string `json:"oid"` // SHA-256 checksum of the file
 	} `json:"lfs"`
 }
 
 // DownloadMLXModel downloads all necessary files for an MLX model
 func (b *MLXBackend) DownloadMLXModel(ctx context.Context, model ModelOption, progressFn func(downloaded, total int64)) error {
-	modelPath := filepath.Join(b.dataDir, "models", "mlx", model.ID)
-	
+	path := localModelPath(b.dataDir, model)
+
 	// Quick check if already downloaded
-	if b.isModelComplete(modelPath) {
-		b.modelPath = modelPath
-		slog.Info("Model already downloaded", "path", modelPath)
+	if _, err := ReadManifest(b.dataDir, model.ID); err == nil || isMLXModelComplete(path) {
+		b.modelPath = path
+		slog.Info("Model already downloaded", "path", path)
 		if progressFn != nil {
 			progressFn(model.Size, model.Size)
 		}
 		return nil
 	}
-	
-	// Create model directory
-	if err := os.MkdirAll(modelPath, 0755); err != nil {
-		return fmt.Errorf("failed to create model directory: %w", err)
+
+	files, err := mlxModelFiles(ctx, model)
+	if err != nil {
+		return err
 	}
-	
+	if err := pullModel(ctx, b.dataDir, model, files, progressFn); err != nil {
+		return err
+	}
+
+	b.modelPath = path
+	slog.Info("MLX model downloaded successfully", "path", path)
+	return nil
+}
+
+// mlxModelFiles returns the files of an MLX model with their checksums.
+func mlxModelFiles(ctx context.Context, model ModelOption) ([]RemoteFile, error) {
 	// Get list of files from HuggingFace API
 	slog.Info("Getting file list for MLX model", "url", model.URL)
-	files, err := b.getModelFiles(ctx, model.URL)
+	files, err := getModelFiles(ctx, model.URL)
 	if err != nil {
 		slog.Warn("Failed to get file list from API, using fallback", "error", err, "url", model.URL)
-		// Use fallback file list
-		files = b.getFallbackFiles(model.URL)
+		files = getFallbackFiles(model.URL)
 	}
-	
-	slog.Info("Found files for MLX model", "count", len(files))
-	
+	// The sizes of the fallback files are estimates, ask the server.
+	listed := err == nil
+
 	// Filter to only necessary files
-	necessaryFiles := b.filterNecessaryFiles(files)
-	slog.Info("Filtered to necessary files", "count", len(necessaryFiles))
-	
-	// If no files found, return error
-	if len(necessaryFiles) == 0 {
-		return fmt.Errorf("no model files found to download")
-	}
-	
-	// Calculate total size
-	totalSize := int64(0)
-	for _, file := range necessaryFiles {
-		totalSize += file.Size
-	}
-	if totalSize == 0 {
-		totalSize = model.Size // Use estimated size if we can't calculate
-	}
-	slog.Info("Total download size", "size", totalSize)
-	
-	// Report initial progress
-	if progressFn != nil && totalSize > 0 {
-		progressFn(0, totalSize)
+	files = filterNecessaryFiles(files)
+	slog.Info("Found files for MLX model", "count", len(files))
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no model files found to download")
 	}
-	
-	// Download files
-	totalDownloaded := int64(0)
-	for _, file := range necessaryFiles {
-		filePath := filepath.Join(modelPath, file.Path)
-		
-		// Skip if file exists and has correct size
-		if info, err := os.Stat(filePath); err == nil && info.Size() == file.Size {
-			totalDownloaded += file.Size
-			if progressFn != nil {
-				progressFn(totalDownloaded, totalSize)
-			}
-			continue
-		}
-		
-		// Download file with progress
-		fileURL := fmt.Sprintf("%s/resolve/main/%s", model.URL, file.Path)
-		slog.Info("Downloading model file", "file", file.Path, "size", file.Size)
-		
-		// Create a progress callback for this specific file
-		fileProgressFn := func(fileDownloaded int64) {
-			if progressFn != nil {
-				progressFn(totalDownloaded + fileDownloaded, totalSize)
-			}
-		}
-		
-		downloaded, err := b.downloadFileWithProgress(ctx, fileURL, filePath, fileProgressFn)
-		if err != nil {
-			return fmt.Errorf("failed to download %s: %w", file.Path, err)
+
+	remote := make([]RemoteFile, len(files))
+	for i, file := range files {
+		remote[i] = RemoteFile{
+			URL:    fmt.Sprintf("%s/resolve/main/%s", model.URL, file.Path),
+			Path:   filepath.Join("mlx", model.ID, file.Path),
+			SHA256: file.LFS.Oid,
 		}
-		
-		totalDownloaded += downloaded
-		if progressFn != nil {
-			progressFn(totalDownloaded, totalSize)
+		if listed {
+			remote[i].Size = file.Size
 		}
 	}
-	
-	b.modelPath = modelPath
-	slog.Info("MLX model downloaded successfully", "path", modelPath)
-	return nil
+	return remote, nil
 }
 
-func (b *MLXBackend) getModelFiles(ctx context.Context, modelURL string) ([]HFFile, error) {
+func getModelFiles(ctx context.Context, modelURL string) ([]HFFile, error) {
 	// Convert model URL to API URL
 	apiURL := strings.Replace(modelURL, "https://huggingface.co/", "https://huggingface.co/api/models/", 1) + "/tree/main"
 	
@@ -156,7 +122,7 @@ func (b *MLXBackend) getModelFiles(ctx context.Context, modelURL string) ([]HFFi
 	return files, nil
 }
 
-func (b *MLXBackend) getFallbackFiles(modelURL string) []HFFile {
+func getFallbackFiles(modelURL string) []HFFile {
 	// Return common MLX model files as fallback
 	// For MLX models, we typically have weight files split into parts
 	files := []HFFile{
@@ -185,7 +151,7 @@ func (b *MLXBackend) getFallbackFiles(modelURL string) []HFFile {
 	return files
 }
 
-func (b *MLXBackend) filterNecessaryFiles(files []HFFile) []HFFile {
+func filterNecessaryFiles(files []HFFile) []HFFile {
 	necessary := []HFFile{}
 	
 	for _, file := range files {
@@ -217,108 +183,9 @@ func (b *MLXBackend) filterNecessaryFiles(files []HFFile) []HFFile {
 	return necessary
 }
 
-// progressWriter wraps an io.Writer to report progress
-type progressWriter struct {
-	writer      io.Writer
-	total       int64
-	written     int64
-	progressFn  func(written int64)
-	lastReport  time.Time
-}
-
-func (pw *progressWriter) Write(p []byte) (int, error) {
-	n, err := pw.writer.Write(p)
-	pw.written += int64(n)
-	
-	// Report progress at most once per 100ms to avoid overwhelming the UI
-	if time.Since(pw.lastReport) > 100*time.Millisecond {
-		if pw.progressFn != nil {
-			pw.progressFn(pw.written)
-		}
-		pw.lastReport = time.Now()
-	}
-	
-	return n, err
-}
-
-func (b *MLXBackend) downloadFileWithProgress(ctx context.Context, url, filePath string, progressFn func(int64)) (int64, error) {
-	slog.Info("Starting file download with progress", "url", url, "path", filePath)
-	
-	// Check if file directory exists
-	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-		return 0, fmt.Errorf("failed to create directory: %w", err)
-	}
-	
-	// Create the file
-	out, err := os.Create(filePath)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create file %s: %w", filePath, err)
-	}
-	defer out.Close()
-	
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %w", err)
-	}
-	
-	// Set headers to avoid rate limiting
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
-	
-	// Do request
-	client := &http.Client{
-		Timeout: 30 * time.Minute, // Large timeout for big files
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// Handle redirects
-			if len(via) >= 10 {
-				return fmt.Errorf("too many redirects")
-			}
-			return nil
-		},
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		slog.Error("Download failed", "status", resp.StatusCode, "url", url, "response", string(body))
-		return 0, fmt.Errorf("server returned status %d", resp.StatusCode)
-	}
-	
-	slog.Info("Download response received", "status", resp.StatusCode, "contentLength", resp.ContentLength)
-	
-	// Create progress writer
-	pw := &progressWriter{
-		writer:     out,
-		total:      resp.ContentLength,
-		progressFn: progressFn,
-		lastReport: time.Now(),
-	}
-	
-	// Copy data with progress
-	written, err := io.Copy(pw, resp.Body)
-	if err != nil {
-		return 0, fmt.Errorf("failed to copy data: %w", err)
-	}
-	
-	// Final progress report
-	if progressFn != nil {
-		progressFn(written)
-	}
-	
-	slog.Info("File downloaded successfully", "path", filePath, "size", written)
-	return written, nil
-}
-
-// Keep the old function for compatibility
-func (b *MLXBackend) downloadFile(ctx context.Context, url, filePath string) (int64, error) {
-	return b.downloadFileWithProgress(ctx, url, filePath, nil)
-}
-
-func (b *MLXBackend) isModelComplete(modelPath string) bool {
+// isMLXModelComplete reports whether an MLX model downloaded without
+// manifest is complete.
+func isMLXModelComplete(modelPath string) bool {
 	// Check for essential files
 	essentialFiles := []string{
 		"config.json",
@@ -348,5 +215,5 @@ func (b *MLXBackend) isModelComplete(modelPath string) bool {
 		}
 	}
 	
-	return hasWeights
+	return hasWeights && len(partialFiles(modelPath)) == 0
 }
\ No newline at end of file
@@ -22,7 +22,7 @@ type ModelOption struct {
 	Size        int64     // Download size in bytes
 	Memory      int64     // Required RAM in bytes
 	URL         string    // Download URL
-	Checksum    string    // SHA256 checksum
+	Checksum    string    // SHA256 checksum, looked up on Hugging Face when empty
 	Provider    string    // Provider type (mlx, llamacpp, onnx)
 	Tier        ModelTier // Performance tier
 	Recommended bool      // Is this the recommended model for the tier
@@ -48,7 +48,6 @@ func AvailableModels() []ModelOption {
 			Size:        110 * 1024 * 1024 * 1024,  // ~110 GB (actual: 109.61 GB)
 			Memory:      128 * 1024 * 1024 * 1024,  // 128 GB RAM recommended for 8-bit
 			URL:         "https://huggingface.co/lmstudio-community/GLM-4.5-Air-MLX-8bit",
-			Checksum:    "placeholder",
 			Provider:    "mlx",
 			Tier:        TierPowerUser,
 			Recommended: true, // Best quality for power users with high RAM
@@ -67,7 +66,6 @@ func AvailableModels() []ModelOption {
 			Size:        56 * 1024 * 1024 * 1024,  // ~56 GB (actual size with all shards)
 			Memory:      24 * 1024 * 1024 * 1024,  // 24 GB RAM
 			URL:         "https://huggingface.co/mlx-community/GLM-4.5-Air-4bit",
-			Checksum:    "placeholder",
 			Provider:    "mlx",
 			Tier:        TierBalanced,
 			Recommended: true, // Recommended for Apple Silicon users with moderate RAM
@@ -86,7 +84,6 @@ func AvailableModels() []ModelOption {
 			Size:        13 * 1024 * 1024 * 1024,  // ~13 GB
 			Memory:      16 * 1024 * 1024 * 1024,  // 16 GB RAM
 			URL:         "https://huggingface.co/mlx-community/GLM-4.5-Air-3bit",
-			Checksum:    "placeholder",
 			Provider:    "mlx",
 			Tier:        TierLight,
 			Recommended: false,
@@ -105,7 +102,6 @@ func AvailableModels() []ModelOption {
 			Size:        5 * 1024 * 1024 * 1024,   // ~5 GB
 			Memory:      8 * 1024 * 1024 * 1024,   // 8 GB RAM
 			URL:         "https://huggingface.co/mlx-community/Qwen2.5-Coder-7B-Instruct-4bit",
-			Checksum:    "placeholder",
 			Provider:    "mlx",
 			Tier:        TierLight,
 			Recommended: false,
@@ -126,7 +122,6 @@ func AvailableModels() []ModelOption {
 			Size:        4794158596,              // 4.47 GB actual size
 			Memory:      8 * 1024 * 1024 * 1024,  // 8 GB RAM
 			URL:         "https://huggingface.co/Qwen/Qwen2.5-Coder-7B-Instruct-GGUF/resolve/main/qwen2.5-coder-7b-instruct-q4_k_m.gguf",
-			Checksum:    "placeholder",
 			Provider:    "llamacpp",
 			Tier:        TierLight,
 			Recommended: !isAppleSilicon, // Recommended for non-Apple Silicon
@@ -139,7 +134,6 @@ func AvailableModels() []ModelOption {
 			Size:        2 * 1024 * 1024 * 1024, // 2 GB
 			Memory:      4 * 1024 * 1024 * 1024, // 4 GB RAM
 			URL:         "https://huggingface.co/Qwen/Qwen2.5-3B-Instruct-GGUF/resolve/main/qwen2.5-3b-instruct-q4_k_m.gguf",
-			Checksum:    "placeholder",
 			Provider:    "llamacpp",
 			Tier:        TierLight,
 			Recommended: false,
@@ -153,7 +147,6 @@ func AvailableModels() []ModelOption {
 			Size:        8 * 1024 * 1024 * 1024,  // 8 GB
 			Memory:      16 * 1024 * 1024 * 1024, // 16 GB RAM
 			URL:         "https://huggingface.co/Qwen/Qwen2.5-14B-Instruct-GGUF/resolve/main/qwen2.5-14b-instruct-q4_k_m.gguf",
-			Checksum:    "placeholder",
 			Provider:    "llamacpp",
 			Tier:        TierBalanced,
 			Recommended: true,
@@ -165,7 +158,6 @@ func AvailableModels() []ModelOption {
 			Size:        9 * 1024 * 1024 * 1024,  // 9 GB
 			Memory:      18 * 1024 * 1024 * 1024, // 18 GB RAM
 			URL:         "https://huggingface.co/deepseek-ai/DeepSeek-Coder-V2-Lite-Instruct-GGUF/resolve/main/deepseek-coder-v2-lite-instruct-q4_k_m.gguf",
-			Checksum:    "placeholder",
 			Provider:    "llamacpp",
 			Tier:        TierBalanced,
 			Recommended: false,
@@ -179,7 +171,6 @@ func AvailableModels() []ModelOption {
 			Size:        44 * 1024 * 1024 * 1024,  // 44 GB
 			Memory:      48 * 1024 * 1024 * 1024,  // 48 GB RAM
 			URL:         "https://huggingface.co/mradermacher/GLM-4.5-Air-GGUF/resolve/main/GLM-4.5-Air.Q2_K.gguf",
-			Checksum:    "placeholder",
 			Provider:    "llamacpp",
 			Tier:        TierPowerUser,
 			Recommended: true,
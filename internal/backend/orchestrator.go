@@ -2,13 +2,15 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sync"
-	"time"
 )
 
 // ModelBackend interface for different model providers
@@ -21,24 +23,50 @@ type ModelBackend interface {
 	IsRunning() bool
 }
 
-// Orchestrator manages the complete local AI backend lifecycle
+// Orchestrator manages the complete local AI backend lifecycle. The models
+// it sets up are served from a Pool behind a single endpoint on PoolPort.
 type Orchestrator struct {
-	dataDir      string
-	backend      ModelBackend
-	model        *ModelOption
-	mu           sync.Mutex
-	isRunning    bool
-	downloadFunc func(ctx context.Context, progress func(downloaded, total int64)) error
+	dataDir   string
+	pool      *Pool
+	server    *http.Server
+	model     *ModelOption
+	mu        sync.Mutex
+	isRunning bool
 }
 
 // NewOrchestrator creates a new backend orchestrator
 func NewOrchestrator(dataDir string) *Orchestrator {
+	return NewOrchestratorWithOptions(dataDir, PoolOptions{})
+}
+
+// NewOrchestratorWithOptions creates a backend orchestrator whose model pool
+// is configured with opts.
+func NewOrchestratorWithOptions(dataDir string, opts PoolOptions) *Orchestrator {
 	return &Orchestrator{
 		dataDir: dataDir,
+		pool:    NewPool(dataDir, opts),
+	}
+}
+
+// Pool returns the pool that runs the models.
+func (o *Orchestrator) Pool() *Pool {
+	return o.pool
+}
+
+// DownloadTask returns the task that sets up model in the download queue.
+func (o *Orchestrator) DownloadTask(model ModelOption) DownloadTask {
+	return DownloadTask{
+		Model: model,
+		Fetch: func(ctx context.Context, progressFn func(downloaded, total int64)) error {
+			return o.SetupModel(ctx, &model, progressFn)
+		},
+		Discard: func() error {
+			return RemovePartialDownloads(o.dataDir, model)
+		},
 	}
 }
 
-// SetupModel downloads and configures the specified model
+// SetupModel downloads the specified model and adds it to the pool
 func (o *Orchestrator) SetupModel(ctx context.Context, model *ModelOption, progressFn func(downloaded, total int64)) error {
 	o.mu.Lock()
 	defer o.mu.Unlock()
@@ -51,14 +79,9 @@ func (o *Orchestrator) SetupModel(ctx context.Context, model *ModelOption, progr
 	}
 	
 	// Create appropriate backend based on provider
-	var backend ModelBackend
-	switch model.Provider {
-	case "mlx":
-		backend = NewMLXBackend(o.dataDir, model.ID)
-	case "llamacpp":
-		backend = NewLlamaCppBackend(o.dataDir, model.ID)
-	default:
-		return fmt.Errorf("unsupported provider: %s", model.Provider)
+	backend, err := newModelBackend(o.dataDir, *model, 0, LaunchProfile{})
+	if err != nil {
+		return err
 	}
 	
 	// Step 1: Download server if needed
@@ -78,54 +101,53 @@ func (o *Orchestrator) SetupModel(ctx context.Context, model *ModelOption, progr
 		return fmt.Errorf("failed to download model: %w", err)
 	}
 	
-	// Step 3: Store backend instance
-	o.backend = backend
+	// Step 3: Serve it from the pool, it starts on the first request
+	o.pool.Register(*model)
 	
 	return nil
 }
 
-// Start starts the backend server
+// Start serves the models of the pool on PoolPort. Each model starts on
+// the first request for it.
 func (o *Orchestrator) Start(ctx context.Context) error {
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	
+
 	if o.isRunning {
 		return nil
 	}
-	
-	if o.backend == nil {
-		return fmt.Errorf("backend not initialized, call SetupModel first")
-	}
-	
-	slog.Info("Starting local AI backend...")
-	
-	if err := o.backend.Start(ctx); err != nil {
+
+	slog.Info("Starting local AI backend...", "port", PoolPort)
+
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", PoolPort))
+	if err != nil {
 		return fmt.Errorf("failed to start backend: %w", err)
 	}
-	
+	o.server = &http.Server{Handler: o.pool}
+	go func() {
+		if err := o.server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Local AI backend stopped", "error", err)
+		}
+	}()
+
 	o.isRunning = true
-	
-	// Start health monitor
-	go o.monitorHealth(ctx)
-	
 	return nil
 }
 
-// Stop stops the backend server
+// Stop stops the endpoint and every model server of the pool
 func (o *Orchestrator) Stop() error {
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	
+
+	o.pool.Close()
 	if !o.isRunning {
 		return nil
 	}
-	
-	if o.backend != nil {
-		if err := o.backend.Stop(); err != nil {
-			return fmt.Errorf("failed to stop backend: %w", err)
-		}
+
+	if err := o.server.Close(); err != nil {
+		return fmt.Errorf("failed to stop backend: %w", err)
 	}
-	
+
 	o.isRunning = false
 	return nil
 }
@@ -134,20 +156,20 @@ func (o *Orchestrator) Stop() error {
 func (o *Orchestrator) GetEndpoint() (string, error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	
-	if !o.isRunning || o.backend == nil {
+
+	if !o.isRunning {
 		return "", fmt.Errorf("backend not running")
 	}
-	
-	return o.backend.GetEndpoint(), nil
+
+	return fmt.Sprintf("http://localhost:%d/v1", PoolPort), nil
 }
 
 // IsRunning checks if the backend is running
 func (o *Orchestrator) IsRunning() bool {
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	
-	return o.isRunning && o.backend != nil && o.backend.IsRunning()
+
+	return o.isRunning
 }
 
 // GetModel returns the currently configured model
@@ -220,11 +242,13 @@ func (o *Orchestrator) QuickSetup(ctx context.Context, progressFn func(status st
 		return err
 	}
 	
-	// Verify it's working
+	// Verify it's working by loading the model
 	progressFn("Verifying connection...", model.Size, model.Size)
-	if !o.waitForReady(ctx, 30*time.Second) {
-		return fmt.Errorf("backend failed to start properly")
+	_, release, err := o.pool.Acquire(ctx, model.ID)
+	if err != nil {
+		return fmt.Errorf("backend failed to start properly: %w", err)
 	}
+	release()
 	
 	progressFn("Ready!", model.Size, model.Size)
 	return nil
@@ -247,42 +271,3 @@ func (o *Orchestrator) ensureDirectories() error {
 	
 	return nil
 }
-
-func (o *Orchestrator) monitorHealth(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if !o.backend.IsRunning() {
-				slog.Warn("Backend stopped unexpectedly, attempting restart...")
-				if err := o.backend.Start(ctx); err != nil {
-					slog.Error("Failed to restart backend", "error", err)
-				}
-			}
-		}
-	}
-}
-
-func (o *Orchestrator) waitForReady(ctx context.Context, timeout time.Duration) bool {
-	deadline := time.Now().Add(timeout)
-	ticker := time.NewTicker(500 * time.Millisecond)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ctx.Done():
-			return false
-		case <-ticker.C:
-			if time.Now().After(deadline) {
-				return false
-			}
-			if o.backend.IsRunning() {
-				return true
-			}
-		}
-	}
-}
\ No newline at end of file
@@ -0,0 +1,503 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotEnoughMemory is returned when a model doesn't fit in the memory
+	// limit of the pool, even after unloading every idle model.
+	ErrNotEnoughMemory = errors.New("not enough memory to load model")
+	// ErrUnknownModel is returned for models that weren't registered.
+	ErrUnknownModel = errors.New("model is not in the pool")
+)
+
+const (
+	// PoolPort is where the orchestrator serves the OpenAI-compatible API
+	// of all the models in its pool.
+	PoolPort = 11434
+	// poolHeader is set on every response of the pool. Ollama listens on
+	// PoolPort too, and discovery uses it to skip the pool.
+	poolHeader = "X-Toke-Pool"
+
+	defaultIdleTimeout  = 15 * time.Minute
+	healthCheckInterval = 30 * time.Second
+)
+
+// PoolOptions configures a Pool.
+type PoolOptions struct {
+	// MemoryLimit caps the sum of ModelOption.Memory of the loaded models.
+	// Defaults to the memory of the machine.
+	MemoryLimit int64
+	// IdleTimeout unloads models that weren't used for that long. Defaults
+	// to 15 minutes, negative keeps them loaded.
+	IdleTimeout time.Duration
+	// Profiles holds the llama.cpp parameters by model ID.
+	Profiles map[string]LaunchProfile
+}
+
+// LoadedModel is a model of the pool whose server is running or starting.
+type LoadedModel struct {
+	Model ModelOption
+	Port  int
+	Ready bool
+	// Active is the number of requests in flight.
+	Active   int
+	LastUsed time.Time
+}
+
+// Pool runs a llama.cpp or MLX server per model, each on its own port.
+// Servers start on the first request for their model and stop when idle,
+// or when the least recently used ones make room for another model.
+type Pool struct {
+	dataDir string
+	opts    PoolOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	models    map[string]ModelOption
+	instances map[string]*instance
+
+	// start downloads what's missing and starts the server of a model.
+	start func(ctx context.Context, model ModelOption, port int) (ModelBackend, error)
+	now   func() time.Time
+}
+
+type instance struct {
+	model ModelOption
+	port  int
+	// ready is closed once the server started or failed to.
+	ready    chan struct{}
+	backend  ModelBackend
+	err      error
+	active   int
+	lastUsed time.Time
+}
+
+// NewPool creates a pool for the models downloaded to dataDir.
+func NewPool(dataDir string, opts PoolOptions) *Pool {
+	if opts.MemoryLimit == 0 {
+		opts.MemoryLimit = SystemMemory()
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = defaultIdleTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		dataDir:   dataDir,
+		opts:      opts,
+		ctx:       ctx,
+		cancel:    cancel,
+		models:    make(map[string]ModelOption),
+		instances: make(map[string]*instance),
+		now:       time.Now,
+	}
+	p.start = p.startBackend
+	go p.watch()
+	return p
+}
+
+// Register makes a downloaded model available to Acquire.
+func (p *Pool) Register(model ModelOption) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models[model.ID] = model
+}
+
+// Models returns the registered models.
+func (p *Pool) Models() []ModelOption {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	models := make([]ModelOption, 0, len(p.models))
+	for _, m := range p.models {
+		models = append(models, m)
+	}
+	slices.SortFunc(models, func(a, b ModelOption) int { return strings.Compare(a.ID, b.ID) })
+	return models
+}
+
+// MemoryLimit returns the memory the loaded models may use, 0 if unlimited.
+func (p *Pool) MemoryLimit() int64 {
+	return p.opts.MemoryLimit
+}
+
+// Acquire returns the OpenAI-compatible endpoint of a registered model,
+// starting its server first if needed. The model isn't unloaded until the
+// returned release func is called.
+func (p *Pool) Acquire(ctx context.Context, modelID string) (string, func(), error) {
+	p.mu.Lock()
+	inst, ok := p.instances[modelID]
+	if !ok {
+		model, ok := p.models[modelID]
+		if !ok {
+			p.mu.Unlock()
+			return "", nil, fmt.Errorf("%w: %q", ErrUnknownModel, modelID)
+		}
+		victims, err := p.makeRoom(model)
+		if err != nil {
+			p.mu.Unlock()
+			return "", nil, err
+		}
+		port, err := freePort()
+		if err != nil {
+			p.mu.Unlock()
+			return "", nil, fmt.Errorf("failed to find a free port: %w", err)
+		}
+		inst = &instance{model: model, port: port, ready: make(chan struct{})}
+		p.instances[modelID] = inst
+		go p.load(inst, victims)
+	}
+	inst.active++
+	inst.lastUsed = p.now()
+	p.mu.Unlock()
+
+	release := func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		inst.active--
+		inst.lastUsed = p.now()
+	}
+	select {
+	case <-inst.ready:
+	case <-ctx.Done():
+		release()
+		return "", nil, ctx.Err()
+	}
+	if inst.err != nil {
+		release()
+		return "", nil, inst.err
+	}
+	return inst.backend.GetEndpoint(), release, nil
+}
+
+// makeRoom removes least recently used idle instances from the pool until
+// model fits in the memory limit, and returns them to be stopped.
+func (p *Pool) makeRoom(model ModelOption) ([]*instance, error) {
+	if p.opts.MemoryLimit <= 0 {
+		return nil, nil
+	}
+	var used int64
+	for _, inst := range p.instances {
+		used += inst.model.Memory
+	}
+
+	var victims []*instance
+	for used+model.Memory > p.opts.MemoryLimit {
+		var lru *instance
+		for _, inst := range p.instances {
+			if inst.active == 0 && (lru == nil || inst.lastUsed.Before(lru.lastUsed)) {
+				lru = inst
+			}
+		}
+		if lru == nil {
+			// Put back what would have been unloaded for nothing.
+			for _, v := range victims {
+				p.instances[v.model.ID] = v
+			}
+			return nil, fmt.Errorf("%w: %s needs %s, %s of %s in use", ErrNotEnoughMemory,
+				model.Name, FormatSize(model.Memory), FormatSize(used), FormatSize(p.opts.MemoryLimit))
+		}
+		slog.Info("Unloading least recently used local model", "model", lru.model.ID, "for", model.ID)
+		delete(p.instances, lru.model.ID)
+		victims = append(victims, lru)
+		used -= lru.model.Memory
+	}
+	return victims, nil
+}
+
+// load stops the instances unloaded to make room and starts inst.
+func (p *Pool) load(inst *instance, victims []*instance) {
+	for _, v := range victims {
+		v.stop()
+	}
+
+	slog.Info("Starting local model", "model", inst.model.ID, "port", inst.port)
+	b, err := p.start(p.ctx, inst.model, inst.port)
+	if err != nil {
+		err = fmt.Errorf("failed to start %s: %w", inst.model.Name, err)
+	}
+
+	p.mu.Lock()
+	inst.backend, inst.err = b, err
+	if err != nil && p.instances[inst.model.ID] == inst {
+		delete(p.instances, inst.model.ID)
+	}
+	p.mu.Unlock()
+	close(inst.ready)
+}
+
+func (p *Pool) startBackend(ctx context.Context, model ModelOption, port int) (ModelBackend, error) {
+	b, err := newModelBackend(p.dataDir, model, port, p.opts.Profiles[model.ID])
+	if err != nil {
+		return nil, err
+	}
+	noProgress := func(downloaded, total int64) {}
+	if err := b.DownloadServer(ctx, noProgress); err != nil {
+		return nil, err
+	}
+	if err := b.DownloadModel(ctx, model, noProgress); err != nil {
+		return nil, err
+	}
+	if err := b.Start(ctx); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// stop stops the server of the instance once it's done starting.
+func (i *instance) stop() {
+	<-i.ready
+	if i.backend == nil {
+		return
+	}
+	if err := i.backend.Stop(); err != nil {
+		slog.Error("Failed to stop local model", "model", i.model.ID, "error", err)
+	}
+}
+
+// Unload stops the server of a model. Models answering a request aren't
+// unloaded.
+func (p *Pool) Unload(modelID string) error {
+	p.mu.Lock()
+	inst, ok := p.instances[modelID]
+	if !ok {
+		p.mu.Unlock()
+		return nil
+	}
+	if inst.active > 0 {
+		p.mu.Unlock()
+		return fmt.Errorf("%s is answering a request", inst.model.Name)
+	}
+	delete(p.instances, modelID)
+	p.mu.Unlock()
+
+	inst.stop()
+	return nil
+}
+
+// Loaded returns the models whose server is running or starting, the most
+// recently used first.
+func (p *Pool) Loaded() []LoadedModel {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	loaded := make([]LoadedModel, 0, len(p.instances))
+	for _, inst := range p.instances {
+		ready := false
+		select {
+		case <-inst.ready:
+			ready = inst.err == nil
+		default:
+		}
+		loaded = append(loaded, LoadedModel{
+			Model:    inst.model,
+			Port:     inst.port,
+			Ready:    ready,
+			Active:   inst.active,
+			LastUsed: inst.lastUsed,
+		})
+	}
+	slices.SortFunc(loaded, func(a, b LoadedModel) int { return b.LastUsed.Compare(a.LastUsed) })
+	return loaded
+}
+
+// Close stops every server of the pool.
+func (p *Pool) Close() {
+	p.cancel()
+	p.mu.Lock()
+	instances := p.instances
+	p.instances = make(map[string]*instance)
+	p.mu.Unlock()
+
+	for _, inst := range instances {
+		inst.stop()
+	}
+}
+
+// watch unloads idle models and forgets servers that died, so they are
+// started again on the next request.
+func (p *Pool) watch() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.unloadIdle()
+			p.dropDead()
+		}
+	}
+}
+
+func (p *Pool) unloadIdle() {
+	if p.opts.IdleTimeout < 0 {
+		return
+	}
+	p.mu.Lock()
+	var idle []*instance
+	for id, inst := range p.instances {
+		if inst.active == 0 && p.now().Sub(inst.lastUsed) >= p.opts.IdleTimeout {
+			delete(p.instances, id)
+			idle = append(idle, inst)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, inst := range idle {
+		slog.Info("Unloading idle local model", "model", inst.model.ID)
+		inst.stop()
+	}
+}
+
+func (p *Pool) dropDead() {
+	p.mu.Lock()
+	var running []*instance
+	for _, inst := range p.instances {
+		select {
+		case <-inst.ready:
+			if inst.backend != nil {
+				running = append(running, inst)
+			}
+		default:
+		}
+	}
+	p.mu.Unlock()
+
+	for _, inst := range running {
+		if inst.backend.IsRunning() {
+			continue
+		}
+		slog.Warn("Local model stopped unexpectedly, it will restart on the next request", "model", inst.model.ID)
+		p.mu.Lock()
+		if p.instances[inst.model.ID] == inst {
+			delete(p.instances, inst.model.ID)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// ServeHTTP proxies OpenAI-compatible requests to the server of the model
+// they name, and lists the registered models on /v1/models.
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(poolHeader, "1")
+	if !strings.HasPrefix(r.URL.Path, "/v1/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/v1/models" {
+		p.serveModels(w)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(body, &req)
+	if req.Model == "" {
+		if models := p.Models(); len(models) == 1 {
+			req.Model = models[0].ID
+		}
+	}
+
+	endpoint, release, err := p.Acquire(r.Context(), req.Model)
+	if err != nil {
+		status := http.StatusBadGateway
+		switch {
+		case errors.Is(err, ErrUnknownModel):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrNotEnoughMemory):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, context.Canceled):
+			return
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer release()
+
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(&url.URL{Scheme: target.Scheme, Host: target.Host})
+		},
+		// Stream the responses as they come.
+		FlushInterval: -1,
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+func (p *Pool) serveModels(w http.ResponseWriter) {
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by"`
+	}
+	list := struct {
+		Object string  `json:"object"`
+		Data   []model `json:"data"`
+	}{Object: "list", Data: []model{}}
+	for _, m := range p.Models() {
+		list.Data = append(list.Data, model{ID: m.ID, Object: "model", OwnedBy: m.Provider})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// newModelBackend creates the backend that serves model on port, or on the
+// default port of the backend if port is 0. The profile only applies to
+// llama.cpp.
+func newModelBackend(dataDir string, model ModelOption, port int, profile LaunchProfile) (ModelBackend, error) {
+	switch model.Provider {
+	case "mlx":
+		b := NewMLXBackend(dataDir, model.ID)
+		if port > 0 {
+			b.port = port
+		}
+		return b, nil
+	case "llamacpp":
+		b := NewLlamaCppBackend(dataDir, model.ID)
+		b.profile = profile
+		if port > 0 {
+			b.port = port
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", model.Provider)
+	}
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
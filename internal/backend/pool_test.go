@@ -0,0 +1,108 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBackend struct {
+	endpoint string
+	stopped  bool
+}
+
+func (b *fakeBackend) DownloadServer(context.Context, func(downloaded, total int64)) error {
+	return nil
+}
+func (b *fakeBackend) DownloadModel(context.Context, ModelOption, func(downloaded, total int64)) error {
+	return nil
+}
+func (b *fakeBackend) Start(context.Context) error { return nil }
+func (b *fakeBackend) Stop() error                 { b.stopped = true; return nil }
+func (b *fakeBackend) GetEndpoint() string         { return b.endpoint }
+func (b *fakeBackend) IsRunning() bool             { return !b.stopped }
+
+func newTestPool(t *testing.T, opts PoolOptions, endpoint string) (*Pool, map[string]*fakeBackend) {
+	p := NewPool(t.TempDir(), opts)
+	t.Cleanup(p.Close)
+	started := make(map[string]*fakeBackend)
+	p.start = func(_ context.Context, model ModelOption, _ int) (ModelBackend, error) {
+		b := &fakeBackend{endpoint: endpoint}
+		started[model.ID] = b
+		return b, nil
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		p.Register(ModelOption{ID: id, Name: id, Memory: 4, Provider: "llamacpp"})
+	}
+	return p, started
+}
+
+func TestPoolEvictsLeastRecentlyUsed(t *testing.T) {
+	p, started := newTestPool(t, PoolOptions{MemoryLimit: 8}, "http://localhost:1/v1")
+	ctx := t.Context()
+
+	_, releaseA, err := p.Acquire(ctx, "a")
+	require.NoError(t, err)
+	_, releaseB, err := p.Acquire(ctx, "b")
+	require.NoError(t, err)
+
+	_, _, err = p.Acquire(ctx, "c")
+	require.ErrorIs(t, err, ErrNotEnoughMemory, "a and b are answering requests")
+
+	releaseB()
+	releaseA()
+	_, releaseC, err := p.Acquire(ctx, "c")
+	require.NoError(t, err)
+	releaseC()
+	require.False(t, started["a"].stopped)
+	require.True(t, started["b"].stopped, "b was used less recently than a")
+
+	_, _, err = p.Acquire(ctx, "d")
+	require.ErrorIs(t, err, ErrUnknownModel)
+}
+
+func TestPoolUnloadsIdle(t *testing.T) {
+	p, started := newTestPool(t, PoolOptions{IdleTimeout: time.Minute}, "http://localhost:1/v1")
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	_, release, err := p.Acquire(t.Context(), "a")
+	require.NoError(t, err)
+	release()
+
+	now = now.Add(time.Minute)
+	p.unloadIdle()
+	require.True(t, started["a"].stopped)
+	require.Empty(t, p.Loaded())
+}
+
+func TestPoolProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.URL.Path + " " + string(body)))
+	}))
+	defer upstream.Close()
+
+	p, started := newTestPool(t, PoolOptions{}, upstream.URL+"/v1")
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"b"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	require.Equal(t, `/v1/chat/completions {"model":"b"}`, string(body))
+	require.Contains(t, started, "b", "the model starts on the first request")
+	require.NotContains(t, started, "a")
+
+	resp, err = http.Post(srv.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"x"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
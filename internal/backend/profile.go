@@ -0,0 +1,145 @@
+package backend
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+const (
+	// defaultContextSize is the context size on machines with a GPU.
+	defaultContextSize = 8192
+	minContextSize     = 2048
+	maxContextSize     = 32768
+)
+
+// LaunchProfile holds the llama.cpp server parameters of a model. Unset
+// fields are tuned to the machine by ResolveProfile.
+type LaunchProfile struct {
+	ContextSize int
+	Threads     int
+	BatchSize   int
+	// GPULayers is the number of layers offloaded to the GPU, -1 for all.
+	GPULayers *int
+	// CacheType is the type of both the K and V caches, e.g. q8_0.
+	CacheType     string
+	MMap          *bool
+	MLock         *bool
+	RopeScaling   string
+	RopeFreqScale float64
+	// ChatTemplate is the name of a built-in template or the path of a
+	// Jinja template file.
+	ChatTemplate string
+	ExtraArgs    []string
+}
+
+// ResolveProfile fills the unset fields of p for a model of modelSize bytes.
+// With a GPU, every layer is offloaded and the model locked in memory as
+// before. On CPU-only machines the model is memory-mapped, one thread runs
+// per physical core and the context is as large as the memory allows.
+func ResolveProfile(p LaunchProfile, modelSize int64) LaunchProfile {
+	return tuneProfile(p, modelSize, HasGPU(), SystemMemory(), runtime.NumCPU())
+}
+
+func tuneProfile(p LaunchProfile, modelSize int64, gpu bool, memory int64, cpus int) LaunchProfile {
+	if p.GPULayers == nil {
+		layers := 0
+		if gpu {
+			layers = -1
+		}
+		p.GPULayers = &layers
+	}
+	if p.Threads == 0 {
+		p.Threads = cpus
+		// Assume two hardware threads per core, more threads than cores
+		// slow generation down.
+		if !gpu && cpus > 4 {
+			p.Threads = cpus / 2
+		}
+	}
+	if p.ContextSize == 0 {
+		p.ContextSize = defaultContextSize
+		if !gpu {
+			p.ContextSize = contextSizeFor(modelSize, memory)
+		}
+	}
+	if p.MMap == nil {
+		mmap := !gpu
+		p.MMap = &mmap
+	}
+	if p.MLock == nil {
+		mlock := gpu
+		p.MLock = &mlock
+	}
+	return p
+}
+
+// contextSizeFor returns the largest power of two context whose KV cache
+// fits in half the memory left by the weights. The cache takes about
+// modelSize/16384 bytes per token with f16 caches.
+func contextSizeFor(modelSize, memory int64) int {
+	if modelSize <= 0 || memory <= modelSize {
+		return minContextSize
+	}
+	tokens := (memory - modelSize) / 2 / max(modelSize/16384, 1)
+	size := minContextSize
+	for int64(size*2) <= tokens && size < maxContextSize {
+		size *= 2
+	}
+	return size
+}
+
+// args returns the llama-server flags of a resolved profile.
+func (p LaunchProfile) args() []string {
+	args := []string{
+		"--ctx-size", strconv.Itoa(p.ContextSize),
+		"--threads", strconv.Itoa(p.Threads),
+		"--jinja", // Enable jinja templating for tool support
+	}
+	if p.GPULayers != nil {
+		args = append(args, "--n-gpu-layers", strconv.Itoa(*p.GPULayers))
+	}
+	if p.BatchSize > 0 {
+		args = append(args, "--batch-size", strconv.Itoa(p.BatchSize))
+	}
+	if p.CacheType != "" {
+		args = append(args, "--cache-type-k", p.CacheType, "--cache-type-v", p.CacheType)
+	}
+	if p.MLock != nil && *p.MLock {
+		args = append(args, "--mlock")
+	}
+	if p.MMap != nil && !*p.MMap {
+		args = append(args, "--no-mmap")
+	}
+	if p.RopeScaling != "" {
+		args = append(args, "--rope-scaling", p.RopeScaling)
+	}
+	if p.RopeFreqScale > 0 {
+		args = append(args, "--rope-freq-scale", fmt.Sprintf("%g", p.RopeFreqScale))
+	}
+	if p.ChatTemplate != "" {
+		if _, err := os.Stat(p.ChatTemplate); err == nil {
+			args = append(args, "--chat-template-file", p.ChatTemplate)
+		} else {
+			args = append(args, "--chat-template", p.ChatTemplate)
+		}
+	}
+	return append(args, p.ExtraArgs...)
+}
+
+// HasGPU reports whether llama.cpp can offload layers to a GPU: Metal on
+// Apple Silicon, or an NVIDIA or AMD GPU.
+func HasGPU() bool {
+	if IsAppleSilicon() {
+		return true
+	}
+	for _, dev := range []string{"/dev/nvidia0", "/dev/kfd"} {
+		if _, err := os.Stat(dev); err == nil {
+			return true
+		}
+	}
+	_, err := exec.LookPath("nvidia-smi")
+	return err == nil
+}
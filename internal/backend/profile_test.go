@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const gb = 1024 * 1024 * 1024
+
+func TestTuneProfile(t *testing.T) {
+	cpu := tuneProfile(LaunchProfile{}, 4*gb, false, 16*gb, 8)
+	require.Equal(t, []string{
+		"--ctx-size", "16384",
+		"--threads", "4",
+		"--jinja",
+		"--n-gpu-layers", "0",
+	}, cpu.args())
+
+	require.Equal(t, minContextSize, tuneProfile(LaunchProfile{}, 4*gb, false, 5*gb, 2).ContextSize)
+
+	gpu := tuneProfile(LaunchProfile{}, 4*gb, true, 16*gb, 8)
+	require.Equal(t, []string{
+		"--ctx-size", "8192",
+		"--threads", "8",
+		"--jinja",
+		"--n-gpu-layers", "-1",
+		"--mlock",
+		"--no-mmap",
+	}, gpu.args())
+
+	mmap := false
+	configured := tuneProfile(LaunchProfile{
+		ContextSize: 4096,
+		CacheType:   "q8_0",
+		MMap:        &mmap,
+		ExtraArgs:   []string{"--flash-attn"},
+	}, 4*gb, false, 16*gb, 8)
+	require.Equal(t, []string{
+		"--ctx-size", "4096",
+		"--threads", "4",
+		"--jinja",
+		"--n-gpu-layers", "0",
+		"--cache-type-k", "q8_0",
+		"--cache-type-v", "q8_0",
+		"--no-mmap",
+		"--flash-attn",
+	}, configured.args())
+}
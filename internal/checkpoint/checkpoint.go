@@ -0,0 +1,239 @@
+// Package checkpoint rewinds a session to the point just before one of its
+// user messages, restoring the files the agent touched afterwards from the
+// versions recorded by the history service.
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/message"
+)
+
+// ErrModified is returned by Rewind when files were changed outside toke
+// since the agent last wrote them and force is not set.
+var ErrModified = errors.New("files were modified outside toke since the checkpoint")
+
+// Change is what rewinding does to a single file.
+type Change struct {
+	Path string
+	// Content is what the file is restored to.
+	Content string
+	// Delete is set when the file did not exist at the checkpoint.
+	Delete bool
+	// Modified is set when the file on disk no longer matches the last
+	// version toke recorded, i.e. someone else changed it since.
+	Modified bool
+}
+
+// Plan describes how to rewind a session to a checkpoint.
+type Plan struct {
+	SessionID string
+	// Message is the user message the session is rewound to. Rewinding
+	// restores the files to their state before it was sent.
+	Message message.Message
+	// Messages are the messages from the checkpoint on, which are removed
+	// when the conversation is rewound too.
+	Messages []message.Message
+	Changes  []Change
+}
+
+// Modified returns the changes to files that were modified outside toke.
+func (p Plan) Modified() []Change {
+	var modified []Change
+	for _, c := range p.Changes {
+		if c.Modified {
+			modified = append(modified, c)
+		}
+	}
+	return modified
+}
+
+// Options control what Rewind does.
+type Options struct {
+	// Conversation also deletes the checkpoint message and everything
+	// after it.
+	Conversation bool
+	// Force overwrites files that were modified outside toke.
+	Force bool
+}
+
+// Service finds checkpoints and rewinds sessions to them.
+type Service struct {
+	messages message.Service
+	files    history.Service
+}
+
+func NewService(messages message.Service, files history.Service) *Service {
+	return &Service{messages: messages, files: files}
+}
+
+// List returns the checkpoints of a session, which are its user messages, in
+// the order they were sent.
+func (s *Service) List(ctx context.Context, sessionID string) ([]message.Message, error) {
+	msgs, err := s.messages.List(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(msgs, func(m message.Message) bool {
+		return m.Role != message.User
+	}), nil
+}
+
+// Plan works out how to rewind a session to the given user message. An
+// empty messageID picks the last user message, undoing the latest prompt.
+func (s *Service) Plan(ctx context.Context, sessionID, messageID string) (Plan, error) {
+	msgs, err := s.messages.List(ctx, sessionID)
+	if err != nil {
+		return Plan{}, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	idx := -1
+	for i, m := range msgs {
+		if m.Role != message.User {
+			continue
+		}
+		if messageID == "" || m.ID == messageID {
+			idx = i
+			if messageID != "" {
+				break
+			}
+		}
+	}
+	if idx < 0 {
+		if messageID == "" {
+			return Plan{}, fmt.Errorf("session %s has no messages to rewind", sessionID)
+		}
+		return Plan{}, fmt.Errorf("user message %s not found in session %s", messageID, sessionID)
+	}
+
+	files, err := s.files.ListBySession(ctx, sessionID)
+	if err != nil {
+		return Plan{}, fmt.Errorf("failed to list file history: %w", err)
+	}
+
+	return Plan{
+		SessionID: sessionID,
+		Message:   msgs[idx],
+		Messages:  msgs[idx:],
+		Changes:   planChanges(files, msgs[idx].CreatedAt, readFile),
+	}, nil
+}
+
+// Rewind applies a plan. Restored files are recorded as new versions so that
+// later checkpoints see them.
+func (s *Service) Rewind(ctx context.Context, plan Plan, opts Options) error {
+	if !opts.Force && len(plan.Modified()) > 0 {
+		return ErrModified
+	}
+
+	for _, c := range plan.Changes {
+		if err := apply(c); err != nil {
+			return err
+		}
+		if _, err := s.files.CreateVersion(ctx, plan.SessionID, c.Path, c.Content); err != nil {
+			return fmt.Errorf("failed to record restored version of %s: %w", c.Path, err)
+		}
+	}
+
+	if opts.Conversation {
+		// Delete newest first so a failure leaves a consistent prefix.
+		for _, m := range slices.Backward(plan.Messages) {
+			if err := s.messages.Delete(ctx, m.ID); err != nil {
+				return fmt.Errorf("failed to delete message %s: %w", m.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// planChanges returns the changes needed to restore every file to its state
+// at the given unix time. files must be ordered by version.
+func planChanges(files []history.File, since int64, read func(path string) (string, bool, error)) []Change {
+	byPath := make(map[string][]history.File)
+	var paths []string
+	for _, f := range files {
+		if _, ok := byPath[f.Path]; !ok {
+			paths = append(paths, f.Path)
+		}
+		byPath[f.Path] = append(byPath[f.Path], f)
+	}
+	slices.Sort(paths)
+
+	var changes []Change
+	for _, path := range paths {
+		versions := byPath[path]
+		latest := versions[len(versions)-1]
+		if latest.CreatedAt < since {
+			// Not touched since the checkpoint.
+			continue
+		}
+
+		// The first version of a file holds its content from before the
+		// agent first touched it in this session. If that happened after
+		// the checkpoint and the file was empty, the agent created it.
+		target := versions[0]
+		for _, v := range versions {
+			if v.CreatedAt < since {
+				target = v
+			}
+		}
+		change := Change{
+			Path:    path,
+			Content: target.Content,
+			Delete:  versions[0].CreatedAt >= since && target.Content == "",
+		}
+
+		current, exists, err := read(path)
+		if err != nil {
+			// Unreadable files can't be compared; treat them as modified so
+			// they are only overwritten when forced.
+			change.Modified = true
+			changes = append(changes, change)
+			continue
+		}
+		change.Modified = current != latest.Content
+		if current == change.Content && exists != change.Delete {
+			// Already in the checkpoint state.
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func readFile(path string) (string, bool, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(content), true, nil
+}
+
+func apply(c Change) error {
+	if c.Delete {
+		if err := os.Remove(c.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete %s: %w", c.Path, err)
+		}
+		return nil
+	}
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(c.Path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", c.Path, err)
+	}
+	if err := os.WriteFile(c.Path, []byte(c.Content), mode); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", c.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package checkpoint
+
+import (
+	"testing"
+
+	"github.com/chasedut/toke/internal/history"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanChanges(t *testing.T) {
+	t.Parallel()
+
+	const since = 100
+	files := []history.File{
+		// Edited before and after the checkpoint.
+		{Path: "/p/edited.go", Version: 0, Content: "v0", CreatedAt: 10},
+		{Path: "/p/edited.go", Version: 1, Content: "v1", CreatedAt: 20},
+		{Path: "/p/edited.go", Version: 2, Content: "v2", CreatedAt: 110},
+		// Created by the agent after the checkpoint.
+		{Path: "/p/new.go", Version: 0, Content: "", CreatedAt: 120},
+		{Path: "/p/new.go", Version: 1, Content: "new", CreatedAt: 120},
+		// Only touched before the checkpoint.
+		{Path: "/p/old.go", Version: 0, Content: "a", CreatedAt: 10},
+		{Path: "/p/old.go", Version: 1, Content: "b", CreatedAt: 20},
+		// Changed on disk since the agent wrote it.
+		{Path: "/p/touched.go", Version: 0, Content: "orig", CreatedAt: 130},
+		{Path: "/p/touched.go", Version: 1, Content: "agent", CreatedAt: 130},
+		// Already rewound.
+		{Path: "/p/restored.go", Version: 0, Content: "orig", CreatedAt: 140},
+		{Path: "/p/restored.go", Version: 1, Content: "agent", CreatedAt: 140},
+		{Path: "/p/restored.go", Version: 2, Content: "orig", CreatedAt: 150},
+	}
+	disk := map[string]string{
+		"/p/edited.go":   "v2",
+		"/p/new.go":      "new",
+		"/p/old.go":      "b",
+		"/p/touched.go":  "user",
+		"/p/restored.go": "orig",
+	}
+	read := func(path string) (string, bool, error) {
+		content, ok := disk[path]
+		return content, ok, nil
+	}
+
+	changes := planChanges(files, since, read)
+	require.Equal(t, []Change{
+		{Path: "/p/edited.go", Content: "v1"},
+		{Path: "/p/new.go", Delete: true},
+		{Path: "/p/touched.go", Content: "orig", Modified: true},
+	}, changes)
+}
+
+func TestPlanChangesDeletedFileIsRestored(t *testing.T) {
+	t.Parallel()
+
+	files := []history.File{
+		{Path: "/p/gone.go", Version: 0, Content: "keep", CreatedAt: 10},
+		{Path: "/p/gone.go", Version: 1, Content: "", CreatedAt: 110},
+	}
+	read := func(string) (string, bool, error) { return "", false, nil }
+
+	require.Equal(t, []Change{{Path: "/p/gone.go", Content: "keep"}}, planChanges(files, 100, read))
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chasedut/toke/internal/audit"
+	"github.com/chasedut/toke/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the audit log of tool calls and permission decisions",
+	Long: `Show every tool the agent ran in this project and every permission that was
+granted or denied, including the ones decided by rules, auto-approved sessions
+and yolo mode.`,
+	Example: `
+# Show everything from the last day
+toke audit --since 24h
+
+# Show one session as JSON
+toke audit --session 3f2c... --json
+
+# Show everything since a date
+toke audit --since 2025-01-31
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessionID, _ := cmd.Flags().GetString("session")
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		opts := audit.ListOptions{SessionID: sessionID}
+		if sinceFlag != "" {
+			since, err := parseSince(sinceFlag, time.Now())
+			if err != nil {
+				return err
+			}
+			opts.Since = since
+		}
+
+		_, conn, err := setupDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		entries, err := audit.NewService(db.New(conn)).List(cmd.Context(), opts)
+		if err != nil {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(entries)
+		}
+
+		if len(entries) == 0 {
+			fmt.Println("No audit entries found.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tSESSION\tTOOL\tEVENT\tDURATION\tEXIT\tDETAILS")
+		for _, e := range entries {
+			event, details := e.Decision, e.Reason
+			duration, exit := "-", "-"
+			if e.Kind == audit.KindTool {
+				event = "ran"
+				if e.IsError {
+					event = "failed"
+				}
+				details = e.Input
+				duration = e.Duration.Round(time.Millisecond).String()
+			}
+			if e.ExitCode != nil {
+				exit = strconv.Itoa(*e.ExitCode)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				time.UnixMilli(e.CreatedAt).Format("2006-01-02 15:04:05"),
+				e.SessionID,
+				e.ToolName,
+				event,
+				duration,
+				exit,
+				truncate(details, 60),
+			)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	auditCmd.Flags().StringP("session", "s", "", "Only show entries of this session")
+	auditCmd.Flags().String("since", "", "Only show entries since a duration ago (24h, 7d) or a date (2006-01-02, RFC 3339)")
+	auditCmd.Flags().Bool("json", false, "Print entries as JSON")
+	rootCmd.AddCommand(auditCmd)
+}
+
+// parseSince parses a duration before now, with d for days, or a date.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: use a duration like 24h or 7d, or a date like 2006-01-02", s)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/session"
+	"github.com/chasedut/toke/internal/transcript"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <session-id>",
+	Short: "Export a session as HTML, Markdown or JSON",
+	Long: `Write a session to a single file, with its tool calls, the diffs of the files
+it changed and its token and cost totals. HTML and Markdown transcripts fold the
+tool calls and diffs so they can be pasted in pull requests and postmortems.
+JSON transcripts can be imported again.
+
+The format is taken from --format, then from the extension of --output, and
+defaults to JSON. --redact replaces API keys, tokens, passwords and private
+keys with [REDACTED].`,
+	Example: `
+# Write a self-contained HTML page
+toke export 3f2c... -o session.html
+
+# Print a redacted Markdown transcript
+toke export 3f2c... --format md --redact
+
+# Export a session as JSON
+toke export 3f2c... > session.json
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+func init() {
+	addExportFlags(exportCmd)
+	rootCmd.AddCommand(exportCmd)
+}
+
+// addExportFlags adds the flags of runExport to a command.
+func addExportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("format", "f", "", "Format of the transcript: html, md or json")
+	cmd.Flags().StringP("output", "o", "", "Write the transcript to a file instead of stdout")
+	cmd.Flags().Bool("redact", false, "Replace secrets in the transcript with [REDACTED]")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	formatFlag, _ := cmd.Flags().GetString("format")
+	output, _ := cmd.Flags().GetString("output")
+	redact, _ := cmd.Flags().GetBool("redact")
+
+	format := transcript.FormatJSON
+	if formatFlag == "" && filepath.Ext(output) != "" {
+		formatFlag = filepath.Ext(output)
+	}
+	if formatFlag != "" {
+		var err error
+		if format, err = transcript.ParseFormat(formatFlag); err != nil {
+			return err
+		}
+	}
+
+	cfg, conn, err := setupDB(cmd)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	q := db.New(conn)
+	t, err := transcript.Load(cmd.Context(), session.NewService(q), message.NewService(q), args[0])
+	if err != nil {
+		return err
+	}
+	files := history.NewService(q, conn)
+	if err := t.LoadDiffs(cmd.Context(), files, cfg.WorkingDir()); err != nil {
+		return err
+	}
+	// JSON transcripts carry the file history so that imports can restore it
+	if format == transcript.FormatJSON {
+		if err := t.LoadFiles(cmd.Context(), files, cfg.WorkingDir()); err != nil {
+			return err
+		}
+	}
+	if redact {
+		if err := t.Redact(cfg.Secrets()...); err != nil {
+			return err
+		}
+	}
+
+	if output == "" || output == "-" {
+		return t.Write(os.Stdout, format)
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", output, err)
+	}
+	defer f.Close()
+	return t.Write(f, format)
+}
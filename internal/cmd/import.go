@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/session"
+	"github.com/chasedut/toke/internal/transcript"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a session from a JSON transcript or another assistant",
+	Long: `Recreate a session from a toke JSON transcript, a Claude Code session log
+(~/.claude/projects/*/<session>.jsonl) or a list of OpenAI chat completion
+messages. Messages keep their tool calls and results, and toke transcripts also
+restore the file history of the session.
+
+The source is detected from the file unless --from is given. Use - to read from
+stdin. The imported session gets a new ID, so a transcript can be imported more
+than once.`,
+	Example: `
+# Import a session exported with toke export
+toke import session.json
+
+# Import a Claude Code session
+toke import ~/.claude/projects/-home-me-project/5b1e....jsonl
+
+# Import OpenAI messages from stdin
+cat messages.json | toke import --from openai -
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+
+		var (
+			data []byte
+			err  error
+		)
+		if args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read transcript: %w", err)
+		}
+		t, source, err := transcript.Parse(data, transcript.Source(from))
+		if err != nil {
+			return err
+		}
+
+		cfg, conn, err := setupDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		q := db.New(conn)
+		sess, err := t.Save(cmd.Context(), session.NewService(q), message.NewService(q), history.NewService(q, conn), cfg.WorkingDir())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d messages from %s as session %s\n", len(t.Messages), source, sess.ID)
+		return nil
+	},
+}
+
+func init() {
+	importCmd.Flags().String("from", "", "Source of the transcript: toke, claude-code or openai (detected by default)")
+	rootCmd.AddCommand(importCmd)
+}
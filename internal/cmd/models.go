@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chasedut/toke/internal/backend"
+	"github.com/chasedut/toke/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "Manage downloaded local models",
+	Long: `Download, list, verify and remove the models run locally with llama.cpp or MLX.
+Downloads resume where they stopped and are checked against the SHA-256
+checksums published on Hugging Face.`,
+	Example: `
+# List the models that can be downloaded
+toke models list --available
+
+# Download a model of the catalog, or a GGUF file of a Hugging Face repo
+toke models pull qwen2.5-coder-7b-q4_k_m
+toke models pull Qwen/Qwen2.5-Coder-3B-Instruct-GGUF/qwen2.5-coder-3b-instruct-q4_k_m.gguf
+
+# Check the downloaded files are intact
+toke models verify
+
+# Delete a model
+toke models rm qwen2.5-coder-7b-q4_k_m
+  `,
+}
+
+var modelsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List downloaded models",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		available, _ := cmd.Flags().GetBool("available")
+
+		dataDir, err := modelsDataDir(cmd)
+		if err != nil {
+			return err
+		}
+		installed, err := backend.InstalledModels(dataDir)
+		if err != nil {
+			return fmt.Errorf("failed to list models: %w", err)
+		}
+
+		if available {
+			downloaded := make(map[string]bool, len(installed))
+			for _, m := range installed {
+				downloaded[m.ID] = true
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tPROVIDER\tSIZE\tDOWNLOADED")
+			for _, m := range backend.AvailableModels() {
+				if !m.Available {
+					continue
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.ID, truncate(m.Name, 40), m.Provider, backend.FormatSize(m.Size), yesNo(downloaded[m.ID]))
+			}
+			return w.Flush()
+		}
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(installed)
+		}
+		if len(installed) == 0 {
+			fmt.Println("No models downloaded. See toke models list --available.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPROVIDER\tSIZE\tCHECKSUMS\tDOWNLOADED")
+		for _, m := range installed {
+			size, checksums, downloadedAt := "-", "unknown", "-"
+			if len(m.Files) > 0 {
+				size = backend.FormatSize(m.Size())
+				checksums = "size only"
+			}
+			if m.Verified() {
+				checksums = "sha256"
+			}
+			if !m.DownloadedAt.IsZero() {
+				downloadedAt = m.DownloadedAt.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", m.ID, truncate(m.Name, 40), m.Provider, size, checksums, downloadedAt)
+		}
+		return w.Flush()
+	},
+}
+
+var modelsPullCmd = &cobra.Command{
+	Use:   "pull <model>...",
+	Short: "Download models",
+	Long: `Download models of the catalog by ID, or GGUF files of Hugging Face repos as
+<owner>/<repo>/<file>.gguf. An interrupted download resumes from the bytes
+already downloaded.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, err := modelsDataDir(cmd)
+		if err != nil {
+			return err
+		}
+		for _, ref := range args {
+			model, err := findModel(dataDir, ref)
+			if err != nil {
+				return err
+			}
+			if !model.Available && model.WhyNotAvailable != "" {
+				return fmt.Errorf("can't run %s here: %s", model.ID, model.WhyNotAvailable)
+			}
+			err = backend.PullModel(cmd.Context(), dataDir, model, printProgress(model.Name))
+			fmt.Fprintln(os.Stderr)
+			if err != nil {
+				if cmd.Context().Err() != nil {
+					return fmt.Errorf("download of %s stopped, run the same command to resume it", model.ID)
+				}
+				return fmt.Errorf("failed to download %s: %w", model.ID, err)
+			}
+			fmt.Printf("Downloaded %s\n", model.ID)
+		}
+		return nil
+	},
+}
+
+var modelsRmCmd = &cobra.Command{
+	Use:   "rm <model>...",
+	Short: "Delete downloaded models",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, err := modelsDataDir(cmd)
+		if err != nil {
+			return err
+		}
+		for _, ref := range args {
+			model, err := findModel(dataDir, ref)
+			if err != nil {
+				return err
+			}
+			if err := backend.RemoveModel(dataDir, model); err != nil {
+				return err
+			}
+			fmt.Printf("Deleted %s\n", model.ID)
+		}
+		return nil
+	},
+}
+
+var modelsVerifyCmd = &cobra.Command{
+	Use:   "verify [model]...",
+	Short: "Check the checksums of downloaded models",
+	Long: `Check the size and SHA-256 checksum of the files of downloaded models, all of
+them by default. Models downloaded by older versions are checked against
+Hugging Face.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dataDir, err := modelsDataDir(cmd)
+		if err != nil {
+			return err
+		}
+		installed, err := backend.InstalledModels(dataDir)
+		if err != nil {
+			return fmt.Errorf("failed to list models: %w", err)
+		}
+
+		var manifests []backend.Manifest
+		for _, ref := range args {
+			i := slices.IndexFunc(installed, func(m backend.Manifest) bool { return m.ID == ref })
+			if i < 0 {
+				return fmt.Errorf("model %s is not downloaded", ref)
+			}
+			manifests = append(manifests, installed[i])
+		}
+		if len(args) == 0 {
+			manifests = installed
+		}
+
+		failed := 0
+		for _, m := range manifests {
+			fmt.Printf("Verifying %s... ", m.ID)
+			if err := backend.VerifyModel(cmd.Context(), dataDir, m); err != nil {
+				failed++
+				fmt.Println("FAILED")
+				for _, line := range strings.Split(err.Error(), "\n") {
+					fmt.Printf("  %s\n", line)
+				}
+				continue
+			}
+			fmt.Println("OK")
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d model(s) failed verification, download them again with toke models pull", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	modelsListCmd.Flags().Bool("json", false, "Print the models as JSON")
+	modelsListCmd.Flags().Bool("available", false, "List the models of the catalog that can be downloaded")
+
+	modelsCmd.AddCommand(modelsListCmd, modelsPullCmd, modelsRmCmd, modelsVerifyCmd)
+	rootCmd.AddCommand(modelsCmd)
+}
+
+// modelsDataDir returns the data directory the local models are stored in.
+func modelsDataDir(cmd *cobra.Command) (string, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return "", err
+	}
+	cfg, err := config.Load(cwd, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to load configuration: %w", err)
+	}
+	dataDir := cfg.Options.DataDirectory
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(cfg.WorkingDir(), dataDir)
+	}
+	return dataDir, nil
+}
+
+// findModel returns the downloaded model, the model of the catalog or the
+// Hugging Face file ref names.
+func findModel(dataDir, ref string) (backend.ModelOption, error) {
+	if m, err := backend.ReadManifest(dataDir, ref); err == nil {
+		return m.Model(), nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return backend.ModelOption{}, err
+	}
+	if m := backend.GetModelByID(ref); m != nil {
+		return *m, nil
+	}
+	// <owner>/<repo>/<file>.gguf
+	if parts := strings.SplitN(ref, "/", 3); len(parts) == 3 && strings.HasSuffix(parts[2], ".gguf") {
+		repo := backend.HuggingFaceModel{ID: parts[0] + "/" + parts[1]}
+		model := backend.NewHuggingFaceClient().ConvertToModelOption(repo, parts[2], 0)
+		model.ID = strings.ReplaceAll(model.ID, "/", "-")
+		model.Name = ref
+		return model, nil
+	}
+	return backend.ModelOption{}, fmt.Errorf("unknown model %s, see toke models list --available", ref)
+}
+
+// printProgress returns a progress function that prints the progress of a
+// download on stderr.
+func printProgress(name string) func(downloaded, total int64) {
+	var last time.Time
+	return func(downloaded, total int64) {
+		if total <= 0 || (time.Since(last) < 500*time.Millisecond && downloaded < total) {
+			return
+		}
+		last = time.Now()
+		fmt.Fprintf(os.Stderr, "\r%s: %s of %s (%d%%)   ", name, backend.FormatSize(downloaded), backend.FormatSize(total), 100*downloaded/total)
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -13,6 +15,7 @@ import (
 	"github.com/chasedut/toke/internal/config"
 	"github.com/chasedut/toke/internal/db"
 	"github.com/chasedut/toke/internal/env"
+	"github.com/chasedut/toke/internal/limits"
 	"github.com/chasedut/toke/internal/tui"
 	"github.com/chasedut/toke/internal/version"
 	"github.com/charmbracelet/fang"
@@ -124,6 +127,9 @@ toke -y
 	},
 }
 
+// exitLimitReached is the exit code of runs stopped at a limit of the config.
+const exitLimitReached = 3
+
 func Execute() {
 	// Load .env file if it exists
 	if err := env.LoadDotEnv(); err != nil {
@@ -137,6 +143,9 @@ func Execute() {
 		fang.WithVersion(version.Version),
 		fang.WithNotifySignal(os.Interrupt),
 	); err != nil {
+		if errors.Is(err, limits.ErrLimitReached) {
+			os.Exit(exitLimitReached)
+		}
 		os.Exit(1)
 	}
 }
@@ -183,6 +192,29 @@ func setupApp(cmd *cobra.Command) (*app.App, error) {
 	return appInstance, nil
 }
 
+// setupDB loads the configuration and connects to the project database
+// without starting the agent, LSP clients or MCP servers. It is used by the
+// commands that only read or manage stored data.
+func setupDB(cmd *cobra.Command) (*config.Config, *sql.DB, error) {
+	debug, _ := cmd.Flags().GetBool("debug")
+
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := config.Init(cwd, debug)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := db.Connect(cmd.Context(), cfg.Options.DataDirectory)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, conn, nil
+}
+
 func MaybePrependStdin(prompt string) (string, error) {
 	if term.IsTerminal(os.Stdin.Fd()) {
 		return prompt, nil
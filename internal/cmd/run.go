@@ -5,6 +5,8 @@ import (
 	"log/slog"
 	"strings"
 
+	"github.com/chasedut/toke/internal/app"
+	"github.com/chasedut/toke/internal/format"
 	"github.com/spf13/cobra"
 )
 
@@ -12,7 +14,10 @@ var runCmd = &cobra.Command{
 	Use:   "run [prompt...]",
 	Short: "Run a single non-interactive prompt",
 	Long: `Run a single prompt in non-interactive mode and exit.
-The prompt can be provided as arguments or piped from stdin.`,
+The prompt can be provided as arguments or piped from stdin.
+
+A run that reaches one of the limits of the config stops and exits with
+status 3.`,
 	Example: `
 # Run a simple prompt
 toke run Explain the use of context in Go
@@ -22,17 +27,53 @@ echo "What is this code doing?" | toke run
 
 # Run with quiet mode (no spinner)
 toke run -q "Generate a README for this project"
+
+# Stream every agent event as NDJSON for scripting
+toke run --output-format stream-json "Fix the failing tests"
+
+# Keep feeding the most recent session
+toke run --continue "Now add tests for it"
+
+# Resume a specific session
+toke run --session 3f2c... "Summarize what changed"
+
+# Use a custom agent defined in toke.json
+toke run --agent reviewer "Review the staged changes"
+
+# Work on a separate branch in its own git worktree
+toke run --worktree "Try rewriting the parser with a state machine"
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		quiet, _ := cmd.Flags().GetBool("quiet")
+		outputFormat, _ := cmd.Flags().GetString("output-format")
+		sessionID, _ := cmd.Flags().GetString("session")
+		continueLatest, _ := cmd.Flags().GetBool("continue")
+		agentID, _ := cmd.Flags().GetString("agent")
+		useWorktree, _ := cmd.Flags().GetBool("worktree")
+
+		outFormat, err := format.ParseOutputFormat(outputFormat)
+		if err != nil {
+			return err
+		}
+		if sessionID != "" && continueLatest {
+			return fmt.Errorf("--session and --continue cannot be used together")
+		}
+		opts := app.RunOptions{
+			Quiet:        quiet,
+			OutputFormat: outFormat,
+			SessionID:    sessionID,
+			Continue:     continueLatest,
+			Agent:        agentID,
+			Worktree:     useWorktree,
+		}
 
-		app, err := setupApp(cmd)
+		tokeApp, err := setupApp(cmd)
 		if err != nil {
 			return err
 		}
-		defer app.Shutdown()
+		defer tokeApp.Shutdown()
 
-		if !app.Config().IsConfigured() {
+		if !tokeApp.Config().IsConfigured() {
 			return fmt.Errorf("no providers configured - please run 'toke' to set up a provider interactively")
 		}
 
@@ -49,10 +90,15 @@ toke run -q "Generate a README for this project"
 		}
 
 		// Run non-interactive flow using the App method
-		return app.RunNonInteractive(cmd.Context(), prompt, quiet)
+		return tokeApp.RunNonInteractive(cmd.Context(), prompt, opts)
 	},
 }
 
 func init() {
 	runCmd.Flags().BoolP("quiet", "q", false, "Hide spinner")
+	runCmd.Flags().StringP("output-format", "o", string(format.OutputText), "Output format: text, json or stream-json")
+	runCmd.Flags().StringP("session", "s", "", "Resume the session with the given ID")
+	runCmd.Flags().Bool("continue", false, "Continue the most recent session")
+	runCmd.Flags().StringP("agent", "a", "", "Agent to handle the prompt, as defined in the agents config")
+	runCmd.Flags().Bool("worktree", false, "Run the session in its own git worktree and branch")
 }
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the messages of all sessions",
+	Long: `Search the text, tool calls and tool results of every stored session. A
+message matches when it contains all the words of the query, and the last word
+also matches as a prefix. Results are printed best matches first, with the
+session they belong to.`,
+	Example: `
+# Find the command the agent ran to migrate the database
+toke search goose up
+
+# Print the matches as JSON
+toke search --json "docker compose"
+  `,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		_, messages, cleanup, err := setupSessionServices(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		results, err := messages.Search(cmd.Context(), strings.Join(args, " "), limit)
+		if err != nil {
+			return fmt.Errorf("failed to search messages: %w", err)
+		}
+
+		if asJSON {
+			type result struct {
+				SessionID    string `json:"session_id"`
+				SessionTitle string `json:"session_title"`
+				MessageID    string `json:"message_id"`
+				Role         string `json:"role"`
+				CreatedAt    int64  `json:"created_at"`
+				Snippet      string `json:"snippet"`
+			}
+			out := make([]result, len(results))
+			for i, r := range results {
+				out[i] = result{
+					SessionID:    r.SessionID,
+					SessionTitle: r.SessionTitle,
+					MessageID:    r.MessageID,
+					Role:         string(r.Role),
+					CreatedAt:    r.CreatedAt,
+					Snippet:      r.HighlightSnippet(func(s string) string { return s }),
+				}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(out)
+		}
+
+		if len(results) == 0 {
+			fmt.Println("No matching messages found.")
+			return nil
+		}
+		highlight := func(s string) string { return s }
+		if term.IsTerminal(os.Stdout.Fd()) {
+			highlight = func(s string) string { return "\x1b[1m" + s + "\x1b[0m" }
+		}
+		for _, r := range results {
+			fmt.Printf("%s  %s\n", r.SessionID, truncate(r.SessionTitle, 50))
+			fmt.Printf("  [%s] %s  %s\n\n", r.Role, formatUnix(r.CreatedAt), r.HighlightSnippet(highlight))
+		}
+		return nil
+	},
+}
+
+func init() {
+	searchCmd.Flags().IntP("limit", "n", 20, "Maximum number of results")
+	searchCmd.Flags().Bool("json", false, "Print the results as JSON")
+	rootCmd.AddCommand(searchCmd)
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chasedut/toke/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the agent over a local HTTP API",
+	Long: `Start a headless HTTP server exposing sessions, the agent and the
+permission flow as a JSON API, with Server-Sent Events on /v1/events for
+streaming updates. Editors and scripts can use it to drive toke without the TUI.
+
+Clients must send a bearer token. A random one is generated and printed unless
+--token or $TOKE_SERVER_TOKEN sets it.`,
+	Example: `
+# Listen on the default address with a generated token
+toke serve
+
+# Use a chosen token
+toke serve --token s3cret
+
+# Stream events for a session
+curl -N -H "Authorization: Bearer s3cret" "http://127.0.0.1:7777/v1/events?session_id=3f2c..."
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		token, _ := cmd.Flags().GetString("token")
+		if token == "" {
+			token = os.Getenv("TOKE_SERVER_TOKEN")
+		}
+		generated := token == ""
+		if generated {
+			var err error
+			if token, err = server.GenerateToken(); err != nil {
+				return err
+			}
+		}
+
+		tokeApp, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer tokeApp.Shutdown()
+		// Clients are not asked whether to go on at a limit
+		tokeApp.Limits.SetAutoStop(true)
+
+		services := server.Services{
+			Sessions:    tokeApp.Sessions,
+			Messages:    tokeApp.Messages,
+			Permissions: tokeApp.Permissions,
+		}
+		if tokeApp.CoderAgent != nil {
+			services.Agent = tokeApp.CoderAgent
+			services.AgentForSession = tokeApp.AgentForSession
+		} else {
+			fmt.Fprintln(os.Stderr, "Warning: no providers configured; run, cancel and summarize are unavailable")
+		}
+
+		opts := server.Options{Addr: addr, Token: token}
+		for _, w := range opts.Warnings() {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+
+		fmt.Fprintf(os.Stderr, "Listening on http://%s\n", addr)
+		if generated {
+			fmt.Fprintf(os.Stderr, "Token: %s\n", token)
+		}
+		return server.New(services, opts).ListenAndServe(cmd.Context())
+	},
+}
+
+func init() {
+	serveCmd.Flags().String("addr", "127.0.0.1:7777", "Address to listen on")
+	serveCmd.Flags().String("token", "", "Bearer token required by clients (defaults to $TOKE_SERVER_TOKEN, or a generated one)")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/fork"
+	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/session"
+	"github.com/chasedut/toke/internal/transcript"
+	"github.com/spf13/cobra"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage stored sessions",
+	Long:  `List, inspect, fork, delete and export the sessions stored for the current project.`,
+	Example: `
+# List sessions, most recent first
+toke sessions list
+
+# Show the conversation of a session
+toke sessions show 3f2c...
+
+# Export a session as HTML
+toke sessions export 3f2c... -o session.html
+
+# Fork a session at one of its messages
+toke sessions fork 3f2c... 9a1b...
+
+# Delete a session
+toke sessions delete 3f2c...
+  `,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sessions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		sessions, _, cleanup, err := setupSessionServices(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		list, err := sessions.List(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to list sessions: %w", err)
+		}
+
+		if asJSON {
+			out := make([]transcript.Session, len(list))
+			for i, s := range list {
+				out[i] = transcript.FromSession(s)
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(out)
+		}
+
+		if len(list) == 0 {
+			fmt.Println("No sessions found.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTITLE\tMESSAGES\tTOKENS\tCOST\tUPDATED")
+		for _, s := range list {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t$%.4f\t%s\n",
+				s.ID,
+				truncate(s.Title, 50),
+				s.MessageCount,
+				s.PromptTokens+s.CompletionTokens,
+				s.Cost,
+				formatUnix(s.UpdatedAt),
+			)
+		}
+		return w.Flush()
+	},
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <session-id>",
+	Short: "Show the messages of a session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		sessions, messages, cleanup, err := setupSessionServices(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		if asJSON {
+			t, err := transcript.Load(cmd.Context(), sessions, messages, args[0])
+			if err != nil {
+				return err
+			}
+			return t.WriteJSON(os.Stdout)
+		}
+
+		sess, err := sessions.Get(cmd.Context(), args[0])
+		if err != nil {
+			return fmt.Errorf("failed to get session %s: %w", args[0], err)
+		}
+		msgs, err := messages.List(cmd.Context(), sess.ID)
+		if err != nil {
+			return fmt.Errorf("failed to list messages: %w", err)
+		}
+		printSession(os.Stdout, sess, msgs)
+		return nil
+	},
+}
+
+var sessionsDeleteCmd = &cobra.Command{
+	Use:   "delete <session-id>...",
+	Short: "Delete one or more sessions",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessions, _, cleanup, err := setupSessionServices(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		for _, id := range args {
+			// Messages and file history are removed by the foreign key
+			// cascade.
+			if err := sessions.Delete(cmd.Context(), id); err != nil {
+				return fmt.Errorf("failed to delete session %s: %w", id, err)
+			}
+			fmt.Printf("Deleted session %s\n", id)
+		}
+		return nil
+	},
+}
+
+var sessionsForkCmd = &cobra.Command{
+	Use:   "fork <session-id> [message-id]",
+	Short: "Fork a session at one of its messages",
+	Long: `Copy the history of a session up to a message into a new session, with the
+versions of files recorded until then, to try another approach from the same
+context. Forking at a user message stops just before it; without a message the
+whole session is copied. Files on disk are left as they are, see toke undo to
+restore them.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, conn, err := setupDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		q := db.New(conn)
+		forks := fork.NewService(session.NewService(q), message.NewService(q), history.NewService(q, conn))
+		var messageID string
+		if len(args) > 1 {
+			messageID = args[1]
+		}
+		forked, err := forks.Fork(cmd.Context(), args[0], messageID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Forked session %s into %s with %d message(s)\n", args[0], forked.ID, forked.MessageCount)
+		return nil
+	},
+}
+
+var sessionsExportCmd = &cobra.Command{
+	Use:   "export <session-id>",
+	Short: "Export a session as HTML, Markdown or JSON",
+	Long:  `Same as toke export.`,
+	Args:  cobra.ExactArgs(1),
+	RunE:  runExport,
+}
+
+func init() {
+	sessionsListCmd.Flags().Bool("json", false, "Print sessions as JSON")
+	sessionsShowCmd.Flags().Bool("json", false, "Print the session as a JSON transcript")
+	addExportFlags(sessionsExportCmd)
+
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsDeleteCmd, sessionsForkCmd, sessionsExportCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
+
+// setupSessionServices connects to the database and returns the session and
+// message services along with a function that closes the connection.
+func setupSessionServices(cmd *cobra.Command) (session.Service, message.Service, func(), error) {
+	_, conn, err := setupDB(cmd)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	q := db.New(conn)
+	cleanup := func() { _ = conn.Close() }
+	return session.NewService(q), message.NewService(q), cleanup, nil
+}
+
+func printSession(w io.Writer, sess session.Session, msgs []message.Message) {
+	fmt.Fprintf(w, "Session:  %s\n", sess.ID)
+	fmt.Fprintf(w, "Title:    %s\n", sess.Title)
+	fmt.Fprintf(w, "Created:  %s\n", formatUnix(sess.CreatedAt))
+	fmt.Fprintf(w, "Updated:  %s\n", formatUnix(sess.UpdatedAt))
+	fmt.Fprintf(w, "Tokens:   %d prompt, %d completion\n", sess.PromptTokens, sess.CompletionTokens)
+	fmt.Fprintf(w, "Cost:     $%.4f\n", sess.Cost)
+
+	for _, msg := range msgs {
+		fmt.Fprintf(w, "\n[%s] %s", msg.Role, formatUnix(msg.CreatedAt))
+		if msg.Model != "" {
+			fmt.Fprintf(w, " (%s)", msg.Model)
+		}
+		fmt.Fprintln(w)
+		for _, part := range msg.Parts {
+			switch p := part.(type) {
+			case message.TextContent:
+				if text := strings.TrimSpace(p.Text); text != "" {
+					fmt.Fprintln(w, text)
+				}
+			case message.ToolCall:
+				fmt.Fprintf(w, "→ %s %s\n", p.Name, truncate(p.Input, 200))
+			case message.ToolResult:
+				status := "←"
+				if p.IsError {
+					status = "✗"
+				}
+				fmt.Fprintf(w, "%s %s\n", status, truncate(strings.TrimSpace(p.Content), 200))
+			case message.BinaryContent:
+				fmt.Fprintf(w, "[attachment %s]\n", p.Path)
+			}
+		}
+	}
+}
+
+func formatUnix(ts int64) string {
+	if ts == 0 {
+		return "-"
+	}
+	return time.Unix(ts, 0).Format("2006-01-02 15:04")
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if len([]rune(s)) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/chasedut/toke/internal/checkpoint"
+	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Rewind file changes made by the agent",
+	Long: `Restore the files the agent changed since a user message to their state
+before that message was sent. By default the latest prompt of the most recent
+session is undone. Files that were modified outside toke since the agent wrote
+them are only overwritten with --force.`,
+	Example: `
+# Undo the file changes of the latest prompt
+toke undo
+
+# Preview what would be restored
+toke undo --dry-run
+
+# Rewind a session to a specific message, removing the conversation after it
+toke undo --session 3f2c... --message 9a1b... --conversation
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessionID, _ := cmd.Flags().GetString("session")
+		messageID, _ := cmd.Flags().GetString("message")
+		conversation, _ := cmd.Flags().GetBool("conversation")
+		force, _ := cmd.Flags().GetBool("force")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		_, conn, err := setupDB(cmd)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		q := db.New(conn)
+		sessions := session.NewService(q)
+		checkpoints := checkpoint.NewService(message.NewService(q), history.NewService(q, conn))
+
+		if sessionID == "" {
+			if messageID != "" {
+				return fmt.Errorf("--message requires --session")
+			}
+			sess, err := latestSession(cmd, sessions)
+			if err != nil {
+				return err
+			}
+			sessionID = sess.ID
+		}
+
+		plan, err := checkpoints.Plan(cmd.Context(), sessionID, messageID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Rewinding session %s to before: %s\n", sessionID, truncate(plan.Message.Content().Text, 60))
+		if len(plan.Changes) == 0 {
+			fmt.Println("No files changed since this message.")
+		}
+		for _, c := range plan.Changes {
+			action := "restore"
+			if c.Delete {
+				action = "delete"
+			}
+			if c.Modified {
+				action += " (modified outside toke)"
+			}
+			fmt.Printf("  %s %s\n", c.Path, action)
+		}
+		if conversation {
+			fmt.Printf("%d message(s) will be removed.\n", len(plan.Messages))
+		}
+		if dryRun {
+			return nil
+		}
+
+		err = checkpoints.Rewind(cmd.Context(), plan, checkpoint.Options{
+			Conversation: conversation,
+			Force:        force,
+		})
+		if errors.Is(err, checkpoint.ErrModified) {
+			fmt.Fprintln(os.Stderr, "Nothing was changed. Re-run with --force to overwrite the modified files.")
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("Done.")
+		return nil
+	},
+}
+
+func init() {
+	undoCmd.Flags().StringP("session", "s", "", "Session to rewind (defaults to the most recent one)")
+	undoCmd.Flags().StringP("message", "m", "", "User message to rewind to (defaults to the last one)")
+	undoCmd.Flags().Bool("conversation", false, "Also remove the message and everything after it")
+	undoCmd.Flags().Bool("force", false, "Overwrite files that were modified outside toke")
+	undoCmd.Flags().Bool("dry-run", false, "Show what would be rewound without changing anything")
+	rootCmd.AddCommand(undoCmd)
+}
+
+// latestSession returns the most recently updated session.
+func latestSession(cmd *cobra.Command, sessions session.Service) (session.Session, error) {
+	list, err := sessions.List(cmd.Context())
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if len(list) == 0 {
+		return session.Session{}, fmt.Errorf("no sessions found")
+	}
+	latest := list[0]
+	for _, s := range list[1:] {
+		if s.UpdatedAt > latest.UpdatedAt {
+			latest = s
+		}
+	}
+	return latest, nil
+}
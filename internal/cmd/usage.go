@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/session"
+	"github.com/chasedut/toke/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show token usage and cost",
+	Long: `Show the tokens used and the cost of the requests made to models, added up by
+model, day, session or project. The usage of this project is shown unless
+--global is given, which adds up the usage of every project on this machine.
+
+Sessions from before usage was recorded only count their total cost, under the
+last model they used.`,
+	Example: `
+# Show the cost of each model this month
+toke usage --since 30d
+
+# Show the cost of each day across all projects
+toke usage --global --by day
+
+# Show the cost of each session as JSON
+toke usage --by session --json
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		byFlag, _ := cmd.Flags().GetString("by")
+		global, _ := cmd.Flags().GetBool("global")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		by := usage.GroupBy(byFlag)
+		switch by {
+		case usage.ByModel, usage.ByDay, usage.BySession:
+		case usage.ByProject:
+			if !global {
+				return fmt.Errorf("--by project requires --global")
+			}
+		default:
+			return fmt.Errorf("invalid --by %q: use model, day, session or project", byFlag)
+		}
+		var since time.Time
+		if sinceFlag != "" {
+			var err error
+			if since, err = parseSince(sinceFlag, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		var (
+			conn *sql.DB
+			err  error
+		)
+		if global {
+			conn, err = usage.OpenGlobal(cmd.Context())
+		} else {
+			_, conn, err = setupDB(cmd)
+		}
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		q := db.New(conn)
+		records, err := usage.NewService(q, nil, "", nil).List(cmd.Context(), since)
+		if err != nil {
+			return fmt.Errorf("failed to read usage: %w", err)
+		}
+		rows := usage.Summarize(records, by)
+		total := usage.Total(records)
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(struct {
+				By    usage.GroupBy `json:"by"`
+				Rows  []usage.Row   `json:"rows"`
+				Total usage.Row     `json:"total"`
+			}{by, rows, total})
+		}
+
+		if len(records) == 0 {
+			fmt.Println("No usage recorded.")
+			return nil
+		}
+		// Sessions only exist in the database of their project.
+		withTitles := by == usage.BySession && !global
+		sessions := session.NewService(q)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		header := strings.ToUpper(string(by))
+		if withTitles {
+			header += "\tTITLE"
+		}
+		fmt.Fprintln(w, header+"\tREQUESTS\tINPUT\tOUTPUT\tCACHE WRITE\tCACHE READ\tCOST")
+		printRow := func(key string, row usage.Row) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t$%.4f\n",
+				key,
+				row.Requests,
+				row.InputTokens,
+				row.OutputTokens,
+				row.CacheCreationTokens,
+				row.CacheReadTokens,
+				row.Cost,
+			)
+		}
+		for _, row := range rows {
+			key := cmp.Or(row.Key, "-")
+			if withTitles {
+				title := "(deleted)"
+				if sess, err := sessions.Get(cmd.Context(), row.Key); err == nil {
+					title = truncate(sess.Title, 50)
+				}
+				key += "\t" + title
+			}
+			printRow(key, row)
+		}
+		if withTitles {
+			printRow("TOTAL\t", total)
+		} else {
+			printRow("TOTAL", total)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	usageCmd.Flags().String("since", "", "Only count usage since a duration ago (24h, 7d) or a date (2006-01-02, RFC 3339)")
+	usageCmd.Flags().String("by", string(usage.ByModel), "Add up usage by model, day, session or project (with --global)")
+	usageCmd.Flags().Bool("global", false, "Show the usage of every project")
+	usageCmd.Flags().Bool("json", false, "Print the report as JSON")
+	rootCmd.AddCommand(usageCmd)
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/session"
+	"github.com/chasedut/toke/internal/worktree"
+	"github.com/spf13/cobra"
+)
+
+var worktreeCmd = &cobra.Command{
+	Use:   "worktree",
+	Short: "Manage session worktrees",
+	Long: `Sessions started with "toke run --worktree" or "New Session in Worktree"
+work on their own branch in a separate git worktree. These commands list them
+and merge, publish or discard their work.`,
+	Example: `
+# List the sessions that have a worktree
+toke worktree list
+
+# Merge a session's branch into the current branch
+toke worktree merge 3f2c...
+
+# Push a session's branch and open a pull request
+toke worktree pr 3f2c...
+
+# Throw a session's changes away
+toke worktree discard 3f2c...
+  `,
+}
+
+var worktreeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List session worktrees",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, sessions, cleanup, err := setupWorktrees(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		list, err := manager.List()
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Println("No worktrees found.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SESSION\tTITLE\tBRANCH\tCHANGED\tPATH")
+		for _, wt := range list {
+			title := "-"
+			if sess, err := sessions.Get(cmd.Context(), wt.SessionID); err == nil {
+				title = truncate(sess.Title, 40)
+			}
+			changed, err := manager.Changed(cmd.Context(), wt)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", wt.SessionID, title, wt.Branch, changed, wt.Dir)
+		}
+		return w.Flush()
+	},
+}
+
+var worktreeMergeCmd = &cobra.Command{
+	Use:   "merge <session-id>",
+	Short: "Merge a session's branch into the current branch and remove its worktree",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, sessions, cleanup, err := setupWorktrees(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		wt, err := sessionWorktree(manager, args[0])
+		if err != nil {
+			return err
+		}
+		if err := manager.Merge(cmd.Context(), wt, worktreeTitle(cmd, sessions, wt)); err != nil {
+			return err
+		}
+		fmt.Printf("Merged %s\n", wt.Branch)
+		return nil
+	},
+}
+
+var worktreePRCmd = &cobra.Command{
+	Use:   "pr <session-id>",
+	Short: "Push a session's branch, open a pull request and remove its worktree",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, sessions, cleanup, err := setupWorktrees(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		wt, err := sessionWorktree(manager, args[0])
+		if err != nil {
+			return err
+		}
+		url, err := manager.OpenPR(cmd.Context(), wt, worktreeTitle(cmd, sessions, wt))
+		if err != nil {
+			return err
+		}
+		fmt.Println(url)
+		return nil
+	},
+}
+
+var worktreeDiscardCmd = &cobra.Command{
+	Use:   "discard <session-id>",
+	Short: "Remove a session's worktree and delete its branch",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, _, cleanup, err := setupWorktrees(cmd)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		wt, err := sessionWorktree(manager, args[0])
+		if err != nil {
+			return err
+		}
+		if err := manager.Discard(cmd.Context(), wt); err != nil {
+			return err
+		}
+		fmt.Printf("Discarded %s\n", wt.Branch)
+		return nil
+	},
+}
+
+func init() {
+	worktreeCmd.AddCommand(worktreeListCmd, worktreeMergeCmd, worktreePRCmd, worktreeDiscardCmd)
+	rootCmd.AddCommand(worktreeCmd)
+}
+
+// setupWorktrees returns the worktree manager of the project and the session
+// service, used for commit messages and PR titles.
+func setupWorktrees(cmd *cobra.Command) (*worktree.Manager, session.Service, func(), error) {
+	cfg, conn, err := setupDB(cmd)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cleanup := func() { _ = conn.Close() }
+	manager := worktree.NewManager(cfg.WorkingDir(), cfg.Options.DataDirectory)
+	return manager, session.NewService(db.New(conn)), cleanup, nil
+}
+
+func sessionWorktree(manager *worktree.Manager, sessionID string) (worktree.Worktree, error) {
+	wt, ok := manager.Get(sessionID)
+	if !ok {
+		return worktree.Worktree{}, fmt.Errorf("session %s has no worktree", sessionID)
+	}
+	return wt, nil
+}
+
+func worktreeTitle(cmd *cobra.Command, sessions session.Service, wt worktree.Worktree) string {
+	if sess, err := sessions.Get(cmd.Context(), wt.SessionID); err == nil && sess.Title != "" {
+		return sess.Title
+	}
+	return "toke session " + wt.SessionID
+}
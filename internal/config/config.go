@@ -130,16 +130,144 @@ type TUIOptions struct {
 type Permissions struct {
 	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
 	SkipRequests bool     `json:"-"`                                                                                                                              // Automatically accept all permissions (YOLO mode)
+	// Rules are evaluated in order before anything else; the first rule that
+	// matches a request decides it.
+	Rules []PermissionRule `json:"rules,omitempty" jsonschema:"description=Ordered allow/deny/ask rules for tool permission requests,example=allow bash \"go test ./...\",example=deny edit \"**/*.pem\""`
 }
 
 type Options struct {
-	ContextPaths         []string       `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=TOKE.md"`
-	TUI                  *TUIOptions    `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
-	Debug                bool           `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
-	DebugLSP             bool           `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
-	DisableAutoSummarize bool           `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable automatic conversation summarization,default=false"`
-	DataDirectory        string         `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.toke,example=.toke"` // Relative to the cwd
-	Update               *UpdateOptions `json:"update,omitempty" jsonschema:"description=Auto-update configuration options"`
+	ContextPaths         []string           `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=TOKE.md"`
+	TUI                  *TUIOptions        `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
+	Debug                bool               `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
+	DebugLSP             bool               `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
+	DisableAutoSummarize bool               `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable automatic conversation summarization,default=false"`
+	DataDirectory        string             `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.toke,example=.toke"` // Relative to the cwd
+	Update               *UpdateOptions     `json:"update,omitempty" jsonschema:"description=Auto-update configuration options"`
+	Sandbox              *SandboxOptions    `json:"sandbox,omitempty" jsonschema:"description=Sandbox for commands run by the bash tool"`
+	WebShare             *WebShareOptions   `json:"web_share,omitempty" jsonschema:"description=Options for sharing sessions on the web"`
+	Budget               *BudgetOptions     `json:"budget,omitempty" jsonschema:"description=Daily and monthly spending limits"`
+	Limits               *LimitOptions      `json:"limits,omitempty" jsonschema:"description=Limits after which the agent asks whether to go on"`
+	LocalModels          *LocalModelOptions `json:"local_models,omitempty" jsonschema:"description=Memory and idle time of the downloaded models run locally"`
+	LocalServers         []string           `json:"local_servers,omitempty" jsonschema:"description=URLs of OpenAI-compatible servers to list models from besides the well-known local ports,example=http://192.168.1.20:11434"`
+}
+
+// BudgetAction is what happens to new requests once a budget is used up.
+type BudgetAction string
+
+const (
+	BudgetWarn  BudgetAction = "warn"
+	BudgetBlock BudgetAction = "block"
+)
+
+// BudgetScope is the spending a budget applies to.
+type BudgetScope string
+
+const (
+	BudgetProject BudgetScope = "project"
+	// BudgetGlobal counts the spending of every project.
+	BudgetGlobal BudgetScope = "global"
+)
+
+type BudgetOptions struct {
+	Daily   float64      `json:"daily,omitempty" jsonschema:"description=Maximum cost in dollars per day. No limit by default,minimum=0,example=5"`
+	Monthly float64      `json:"monthly,omitempty" jsonschema:"description=Maximum cost in dollars per calendar month. No limit by default,minimum=0,example=100"`
+	Action  BudgetAction `json:"action,omitempty" jsonschema:"description=Warn or refuse new requests once a budget is used up,enum=warn,enum=block,default=warn"`
+	Scope   BudgetScope  `json:"scope,omitempty" jsonschema:"description=Count the spending of this project or of all projects,enum=project,enum=global,default=project"`
+}
+
+// LocalModelOptions configures the pool that runs the downloaded models, a
+// server per model.
+type LocalModelOptions struct {
+	MemoryLimitGB float64                  `json:"memory_limit_gb,omitempty" jsonschema:"description=Memory in GB the loaded models may use. The least recently used ones are unloaded to make room. Defaults to the memory of the machine,minimum=0,example=32"`
+	IdleMinutes   int                      `json:"idle_minutes,omitempty" jsonschema:"description=Minutes after which an unused model is unloaded. -1 keeps models loaded,default=15,minimum=-1"`
+	Profiles      map[string]LaunchProfile `json:"profiles,omitempty" jsonschema:"description=llama.cpp server parameters by model ID"`
+}
+
+// LaunchProfile holds the llama.cpp server parameters of a model. Unset
+// fields are tuned to the machine: without a GPU the context size and
+// threads follow the memory and cores available.
+type LaunchProfile struct {
+	ContextSize   int      `json:"context_size,omitempty" jsonschema:"description=Context size in tokens. Also the context window of the model,minimum=0,example=16384"`
+	Threads       int      `json:"threads,omitempty" jsonschema:"description=Number of threads used for generation,minimum=0,example=8"`
+	BatchSize     int      `json:"batch_size,omitempty" jsonschema:"description=Logical batch size for prompt processing,minimum=0,example=512"`
+	GPULayers     *int     `json:"gpu_layers,omitempty" jsonschema:"description=Number of layers offloaded to the GPU. -1 offloads all of them and 0 runs on the CPU only,minimum=-1"`
+	CacheType     string   `json:"cache_type,omitempty" jsonschema:"description=Type of the K and V caches. Quantized V caches may need --flash-attn in extra_args,enum=f32,enum=f16,enum=bf16,enum=q8_0,enum=q5_1,enum=q5_0,enum=q4_1,enum=q4_0,enum=iq4_nl"`
+	MMap          *bool    `json:"mmap,omitempty" jsonschema:"description=Memory-map the model file. On by default without a GPU"`
+	MLock         *bool    `json:"mlock,omitempty" jsonschema:"description=Lock the model in memory. On by default with a GPU"`
+	RopeScaling   string   `json:"rope_scaling,omitempty" jsonschema:"description=RoPE frequency scaling method,enum=none,enum=linear,enum=yarn"`
+	RopeFreqScale float64  `json:"rope_freq_scale,omitempty" jsonschema:"description=RoPE frequency scaling factor,minimum=0,example=0.25"`
+	ChatTemplate  string   `json:"chat_template,omitempty" jsonschema:"description=Name of a built-in chat template or path of a Jinja template file,example=chatml"`
+	ExtraArgs     []string `json:"extra_args,omitempty" jsonschema:"description=More llama-server arguments,example=--flash-attn"`
+}
+
+// LimitOptions caps what the agent may use before it pauses and asks the
+// user whether to continue.
+type LimitOptions struct {
+	Run     *Limits `json:"run,omitempty" jsonschema:"description=Limits for a single prompt and the tool calls that follow it"`
+	Session *Limits `json:"session,omitempty" jsonschema:"description=Limits for all prompts of a session"`
+}
+
+// Limits are maximums, zero means no limit.
+type Limits struct {
+	ToolIterations int     `json:"tool_iterations,omitempty" jsonschema:"description=Maximum number of times the model answers with tool calls,minimum=0,example=25"`
+	InputTokens    int64   `json:"input_tokens,omitempty" jsonschema:"description=Maximum number of input tokens including cached ones,minimum=0,example=2000000"`
+	OutputTokens   int64   `json:"output_tokens,omitempty" jsonschema:"description=Maximum number of output tokens,minimum=0,example=100000"`
+	Cost           float64 `json:"cost,omitempty" jsonschema:"description=Maximum cost in dollars computed from the model pricing,minimum=0,example=2"`
+}
+
+type WebShareOptions struct {
+	ExpiryHours int            `json:"expiry_hours,omitempty" jsonschema:"description=Hours after which share links stop working,default=24,minimum=1"`
+	Port        int            `json:"port,omitempty" jsonschema:"description=Port the share server listens on. A free port is picked by default,minimum=0,maximum=65535"`
+	Tunnel      *TunnelOptions `json:"tunnel,omitempty" jsonschema:"description=How shares are exposed outside of this machine"`
+}
+
+// TunnelProvider exposes a share outside of this machine.
+type TunnelProvider string
+
+const (
+	TunnelNgrok       TunnelProvider = "ngrok"
+	TunnelCloudflared TunnelProvider = "cloudflared"
+	TunnelSSH         TunnelProvider = "ssh"
+	// TunnelLAN serves the share on the local network only.
+	TunnelLAN TunnelProvider = "lan"
+)
+
+type TunnelOptions struct {
+	Provider    TunnelProvider      `json:"provider,omitempty" jsonschema:"description=Tunnel used to share sessions,enum=ngrok,enum=cloudflared,enum=ssh,enum=lan,default=ngrok"`
+	Ngrok       *NgrokOptions       `json:"ngrok,omitempty" jsonschema:"description=Options of the ngrok tunnel"`
+	Cloudflared *CloudflaredOptions `json:"cloudflared,omitempty" jsonschema:"description=Options of the cloudflared tunnel"`
+	SSH         *SSHTunnelOptions   `json:"ssh,omitempty" jsonschema:"description=Options of the ssh -R tunnel"`
+	LAN         *LANOptions         `json:"lan,omitempty" jsonschema:"description=Options of LAN only shares"`
+}
+
+type NgrokOptions struct {
+	AuthToken string `json:"authtoken,omitempty" jsonschema:"description=Ngrok authtoken. Defaults to $NGROK_AUTHTOKEN or the token of the ngrok config"`
+}
+
+type CloudflaredOptions struct {
+	Token string `json:"token,omitempty" jsonschema:"description=Token of a named tunnel that routes to the share port. A quick tunnel is used without it"`
+	URL   string `json:"url,omitempty" jsonschema:"description=Public URL of the named tunnel,example=https://share.example.com"`
+}
+
+type SSHTunnelOptions struct {
+	Host         string `json:"host,omitempty" jsonschema:"description=Host that forwards its port to the share,example=me@example.com"`
+	Port         int    `json:"port,omitempty" jsonschema:"description=SSH port of the host,default=22"`
+	RemotePort   int    `json:"remote_port,omitempty" jsonschema:"description=Port the host listens on. The host picks one by default"`
+	IdentityFile string `json:"identity_file,omitempty" jsonschema:"description=Private key used to log in"`
+	URL          string `json:"url,omitempty" jsonschema:"description=Public URL of the forwarded port such as a reverse proxy in front of it. Defaults to http://<host>:<remote port>"`
+}
+
+type LANOptions struct {
+	Interface string `json:"interface,omitempty" jsonschema:"description=Network interface to serve the share on. Defaults to the first one with an IPv4 address,example=en0"`
+}
+
+type SandboxOptions struct {
+	Enabled        bool     `json:"enabled,omitempty" jsonschema:"description=Run bash commands in a bubblewrap sandbox (Linux only),default=false"`
+	Network        bool     `json:"network,omitempty" jsonschema:"description=Allow network access without asking,default=false"`
+	WritablePaths  []string `json:"writable_paths,omitempty" jsonschema:"description=Paths commands can write to besides the working directory,example=~/.cache/go-build"`
+	CPUSeconds     int      `json:"cpu_seconds,omitempty" jsonschema:"description=CPU time limit of a command in seconds,minimum=0"`
+	MemoryMB       int      `json:"memory_mb,omitempty" jsonschema:"description=Memory limit of a command in megabytes,minimum=0"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty" jsonschema:"description=Time limit of a command in seconds,minimum=0"`
 }
 
 type MCPs map[string]MCPConfig
@@ -216,13 +344,16 @@ func (m MCPConfig) ResolvedHeaders() map[string]string {
 }
 
 type Agent struct {
-	ID          string `json:"id,omitempty"`
-	Name        string `json:"name,omitempty"`
-	Description string `json:"description,omitempty"`
-	// This is the id of the system prompt used by the agent
-	Disabled bool `json:"disabled,omitempty"`
+	ID          string `json:"id,omitempty" jsonschema:"description=Unique identifier for the agent, defaults to its key in the agents map"`
+	Name        string `json:"name,omitempty" jsonschema:"description=Display name of the agent"`
+	Description string `json:"description,omitempty" jsonschema:"description=What the agent is for"`
+	Disabled    bool   `json:"disabled,omitempty" jsonschema:"description=Whether the agent is disabled,default=false"`
+
+	// Path to a file holding the system prompt. Relative paths are resolved
+	// against the working directory. When empty the built-in prompt is used.
+	Prompt string `json:"prompt,omitempty" jsonschema:"description=Path to a file containing the system prompt for the agent,example=.toke/agents/reviewer.md"`
 
-	Model SelectedModelType `json:"model" jsonschema:"required,description=The model type to use for this agent,enum=large,enum=small,default=large"`
+	Model SelectedModelType `json:"model,omitempty" jsonschema:"description=The model type to use for this agent,enum=large,enum=small,default=large"`
 
 	// The available tools for the agent
 	//  if this is nil, all tools are available
@@ -249,6 +380,10 @@ type Config struct {
 	// We currently only support large/small as values here.
 	Models map[SelectedModelType]SelectedModel `json:"models,omitempty" jsonschema:"description=Model configurations for different model types,example={\"large\":{\"model\":\"gpt-4o\",\"provider\":\"openai\"}}"`
 
+	// Fallbacks are tried in order, for one turn, when the model of the same
+	// type keeps failing.
+	Fallbacks map[SelectedModelType][]SelectedModel `json:"fallbacks,omitempty" jsonschema:"description=Models to switch to in order when the model of a type keeps failing"`
+
 	// The providers that are configured
 	Providers *csync.Map[string, ProviderConfig] `json:"providers,omitempty" jsonschema:"description=AI provider configurations"`
 
@@ -260,10 +395,10 @@ type Config struct {
 
 	Permissions *Permissions `json:"permissions,omitempty" jsonschema:"description=Permission settings for tool usage"`
 
+	Agents map[string]Agent `json:"agents,omitempty" jsonschema:"description=Custom agent definitions, merged with the built-in coder and task agents"`
+
 	// Internal
 	workingDir string `json:"-"`
-	// TODO: most likely remove this concept when I come back to it
-	Agents map[string]Agent `json:"-"`
 	// TODO: find a better way to do this this should probably not be part of the config
 	resolver       VariableResolver
 	dataConfigDir  string             `json:"-"`
@@ -343,6 +478,18 @@ func (c *Config) SetCompactMode(enabled bool) error {
 	return c.SetConfigField("options.tui.compact_mode", enabled)
 }
 
+// SetTunnelOptions saves how shares are exposed to the global data config.
+func (c *Config) SetTunnelOptions(tunnel TunnelOptions) error {
+	if err := c.SetConfigField("options.web_share.tunnel", tunnel); err != nil {
+		return fmt.Errorf("failed to save tunnel options: %w", err)
+	}
+	if c.Options.WebShare == nil {
+		c.Options.WebShare = &WebShareOptions{}
+	}
+	c.Options.WebShare.Tunnel = &tunnel
+	return nil
+}
+
 func (c *Config) Resolve(key string) (string, error) {
 	if c.resolver == nil {
 		return "", fmt.Errorf("no variable resolver configured")
@@ -350,6 +497,30 @@ func (c *Config) Resolve(key string) (string, error) {
 	return c.resolver.ResolveValue(key)
 }
 
+// Secrets returns the resolved API keys of the providers and the tunnel
+// tokens, so that they can be kept out of exported transcripts.
+func (c *Config) Secrets() []string {
+	var secrets []string
+	for p := range c.Providers.Seq() {
+		if p.APIKey == "" {
+			continue
+		}
+		if key, err := c.Resolve(p.APIKey); err == nil && key != "" {
+			secrets = append(secrets, key)
+		}
+	}
+	if c.Options != nil && c.Options.WebShare != nil && c.Options.WebShare.Tunnel != nil {
+		tunnel := c.Options.WebShare.Tunnel
+		if tunnel.Ngrok != nil && tunnel.Ngrok.AuthToken != "" {
+			secrets = append(secrets, tunnel.Ngrok.AuthToken)
+		}
+		if tunnel.Cloudflared != nil && tunnel.Cloudflared.Token != "" {
+			secrets = append(secrets, tunnel.Cloudflared.Token)
+		}
+	}
+	return secrets
+}
+
 func (c *Config) UpdatePreferredModel(modelType SelectedModelType, model SelectedModel) error {
 	c.Models[modelType] = model
 	if err := c.SetConfigField(fmt.Sprintf("models.%s", modelType), model); err != nil {
@@ -422,18 +593,27 @@ func (c *Config) SetProviderAPIKey(providerID, apiKey string) error {
 	return nil
 }
 
+// Built-in agent IDs.
+const (
+	AgentCoder = "coder"
+	AgentTask  = "task"
+)
+
+// SetupAgents merges the agents defined in the config with the built-in
+// coder and task agents. A definition that reuses a built-in ID overrides
+// the fields it sets.
 func (c *Config) SetupAgents() {
 	agents := map[string]Agent{
-		"coder": {
-			ID:           "coder",
+		AgentCoder: {
+			ID:           AgentCoder,
 			Name:         "Coder",
 			Description:  "An agent that helps with executing coding tasks.",
 			Model:        SelectedModelTypeLarge,
 			ContextPaths: c.Options.ContextPaths,
 			// All tools allowed
 		},
-		"task": {
-			ID:           "task",
+		AgentTask: {
+			ID:           AgentTask,
 			Name:         "Task",
 			Description:  "An agent that helps with searching for context and finding implementation details.",
 			Model:        SelectedModelTypeLarge,
@@ -450,9 +630,80 @@ func (c *Config) SetupAgents() {
 			AllowedLSP: []string{},
 		},
 	}
+
+	for id, custom := range c.Agents {
+		agent, ok := agents[id]
+		if !ok {
+			agent = Agent{
+				Name:         id,
+				Model:        SelectedModelTypeLarge,
+				ContextPaths: c.Options.ContextPaths,
+			}
+		}
+		agent.ID = id
+		agent.Disabled = custom.Disabled
+		if custom.Name != "" {
+			agent.Name = custom.Name
+		}
+		if custom.Description != "" {
+			agent.Description = custom.Description
+		}
+		if custom.Prompt != "" {
+			agent.Prompt = custom.Prompt
+		}
+		if custom.Model != "" {
+			agent.Model = custom.Model
+		}
+		if custom.AllowedTools != nil {
+			agent.AllowedTools = custom.AllowedTools
+		}
+		if custom.AllowedMCP != nil {
+			agent.AllowedMCP = custom.AllowedMCP
+		}
+		if custom.AllowedLSP != nil {
+			agent.AllowedLSP = custom.AllowedLSP
+		}
+		if custom.ContextPaths != nil {
+			agent.ContextPaths = custom.ContextPaths
+		}
+		if agent.Model != SelectedModelTypeLarge && agent.Model != SelectedModelTypeSmall {
+			slog.Warn("Unknown model type for agent, using large", "agent", id, "model", agent.Model)
+			agent.Model = SelectedModelTypeLarge
+		}
+		agents[id] = agent
+	}
+
+	// The coder agent drives the TUI and can't be turned off.
+	coder := agents[AgentCoder]
+	coder.Disabled = false
+	agents[AgentCoder] = coder
+
 	c.Agents = agents
 }
 
+// EnabledAgents returns the agents that are not disabled, with the coder
+// agent first and the rest sorted by ID.
+func (c *Config) EnabledAgents() []Agent {
+	var enabled []Agent
+	for _, agent := range c.Agents {
+		if !agent.Disabled {
+			enabled = append(enabled, agent)
+		}
+	}
+	slices.SortFunc(enabled, func(a, b Agent) int {
+		switch {
+		case a.ID == b.ID:
+			return 0
+		case a.ID == AgentCoder:
+			return -1
+		case b.ID == AgentCoder:
+			return 1
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return enabled
+}
+
 func (c *Config) Resolver() VariableResolver {
 	return c.resolver
 }
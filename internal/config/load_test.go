@@ -1184,3 +1184,52 @@ func TestConfig_configureSelectedModels(t *testing.T) {
 		require.Equal(t, int64(100), large.MaxTokens)
 	})
 }
+
+func TestConfig_SetupAgents(t *testing.T) {
+	cfg, err := loadFromReaders([]io.Reader{strings.NewReader(`{
+		"agents": {
+			"reviewer": {
+				"description": "Reviews changes",
+				"prompt": ".toke/agents/reviewer.md",
+				"model": "small",
+				"allowed_tools": ["view", "grep"],
+				"allowed_mcp": {"github": ["get_pull_request"]},
+				"allowed_lsp": []
+			},
+			"task": {"allowed_tools": ["view"]},
+			"coder": {"disabled": true},
+			"docs": {"disabled": true}
+		}
+	}`)})
+	require.NoError(t, err)
+	cfg.setDefaults("/tmp")
+
+	cfg.SetupAgents()
+	// Setting up twice, as the splash screen does, must not change anything.
+	cfg.SetupAgents()
+
+	reviewer := cfg.Agents["reviewer"]
+	require.Equal(t, "reviewer", reviewer.ID)
+	require.Equal(t, "reviewer", reviewer.Name)
+	require.Equal(t, "Reviews changes", reviewer.Description)
+	require.Equal(t, ".toke/agents/reviewer.md", reviewer.Prompt)
+	require.Equal(t, SelectedModelTypeSmall, reviewer.Model)
+	require.Equal(t, []string{"view", "grep"}, reviewer.AllowedTools)
+	require.Equal(t, map[string][]string{"github": {"get_pull_request"}}, reviewer.AllowedMCP)
+	require.NotNil(t, reviewer.AllowedLSP)
+	require.Empty(t, reviewer.AllowedLSP)
+	require.Equal(t, cfg.Options.ContextPaths, reviewer.ContextPaths)
+
+	task := cfg.Agents[AgentTask]
+	require.Equal(t, "Task", task.Name)
+	require.Equal(t, SelectedModelTypeLarge, task.Model)
+	require.Equal(t, []string{"view"}, task.AllowedTools)
+
+	require.False(t, cfg.Agents[AgentCoder].Disabled)
+
+	var ids []string
+	for _, a := range cfg.EnabledAgents() {
+		ids = append(ids, a.ID)
+	}
+	require.Equal(t, []string{AgentCoder, "reviewer", AgentTask}, ids)
+}
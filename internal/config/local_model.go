@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/chasedut/toke/internal/backend"
@@ -26,6 +28,7 @@ func (c *Config) ConfigureLocalModel(model *backend.ModelOption) error {
 	}
 	
 	// Create provider configuration for local model
+	contextWindow := c.LocalContextWindow(model)
 	localProvider := ProviderConfig{
 		ID:      "local",
 		Name:    "Local Model",
@@ -37,8 +40,8 @@ func (c *Config) ConfigureLocalModel(model *backend.ModelOption) error {
 			{
 				ID:               model.ID,
 				Name:             model.Name,
-				ContextWindow:    8192,
-				DefaultMaxTokens: 4096,
+				ContextWindow:    contextWindow,
+				DefaultMaxTokens: min(4096, contextWindow/2),
 				CostPer1MIn:      0, // Free!
 				CostPer1MOut:     0, // Free!
 			},
@@ -49,6 +52,15 @@ func (c *Config) ConfigureLocalModel(model *backend.ModelOption) error {
 	if c.Providers == nil {
 		c.Providers = csync.NewMap[string, ProviderConfig]()
 	}
+	// Keep the other downloaded models, they can be selected for the other
+	// model type and are served from the same pool.
+	if existing, ok := c.Providers.Get("local"); ok {
+		for _, m := range existing.Models {
+			if m.ID != model.ID {
+				localProvider.Models = append(localProvider.Models, m)
+			}
+		}
+	}
 	c.Providers.Set("local", localProvider)
 	
 	// Set as default model for both large and small
@@ -154,4 +166,100 @@ func (c *Config) DisableLocalModel() error {
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// LocalServerProvider returns the provider config for a discovered local
+// server.
+func LocalServerProvider(server backend.LocalServer) ProviderConfig {
+	models := make([]catwalk.Model, len(server.Models))
+	for i, m := range server.Models {
+		models[i] = m.Model
+	}
+	return ProviderConfig{
+		ID:      server.ID,
+		Name:    server.Name,
+		BaseURL: server.BaseURL,
+		Type:    catwalk.TypeOpenAI,
+		Models:  models,
+	}
+}
+
+// AddLocalServer saves a discovered local server as a provider so its
+// models can be selected.
+func (c *Config) AddLocalServer(server backend.LocalServer) error {
+	providerConfig := LocalServerProvider(server)
+	if c.Providers == nil {
+		c.Providers = csync.NewMap[string, ProviderConfig]()
+	}
+	c.Providers.Set(server.ID, providerConfig)
+	if err := c.SetConfigField("providers."+server.ID, providerConfig); err != nil {
+		return fmt.Errorf("failed to save local server %s: %w", server.ID, err)
+	}
+	return nil
+}
+
+// LocalModelIDs returns the downloaded models selected for a model type.
+func (c *Config) LocalModelIDs() []string {
+	var ids []string
+	for _, tp := range []SelectedModelType{SelectedModelTypeLarge, SelectedModelTypeSmall} {
+		if m, ok := c.Models[tp]; ok && m.Provider == "local" && !slices.Contains(ids, m.Model) {
+			ids = append(ids, m.Model)
+		}
+	}
+	return ids
+}
+
+// LocalPoolOptions returns the options of the pool that runs the downloaded
+// models.
+func (c *Config) LocalPoolOptions() backend.PoolOptions {
+	var opts backend.PoolOptions
+	if c.Options == nil || c.Options.LocalModels == nil {
+		return opts
+	}
+	o := c.Options.LocalModels
+	opts.MemoryLimit = int64(o.MemoryLimitGB * 1024 * 1024 * 1024)
+	if len(o.Profiles) > 0 {
+		opts.Profiles = make(map[string]backend.LaunchProfile, len(o.Profiles))
+		for id, p := range o.Profiles {
+			opts.Profiles[id] = backend.LaunchProfile(p)
+		}
+	}
+	switch {
+	case o.IdleMinutes < 0:
+		opts.IdleTimeout = -1
+	case o.IdleMinutes > 0:
+		opts.IdleTimeout = time.Duration(o.IdleMinutes) * time.Minute
+	}
+	return opts
+}
+
+// LocalContextWindow returns the context size a downloaded model is run
+// with: the one of its launch profile, or the one tuned to the machine.
+func (c *Config) LocalContextWindow(model *backend.ModelOption) int64 {
+	if model.Provider != "llamacpp" {
+		return 8192
+	}
+	var profile LaunchProfile
+	if c.Options != nil && c.Options.LocalModels != nil {
+		profile = c.Options.LocalModels.Profiles[model.ID]
+	}
+	return int64(backend.ResolveProfile(backend.LaunchProfile(profile), model.Size).ContextSize)
+}
+
+// SyncLocalContextWindows sets the context window of the models of the
+// local provider to the context size they are run with, which may have
+// changed since they were configured.
+func (c *Config) SyncLocalContextWindows() {
+	localProvider, ok := c.Providers.Get("local")
+	if !ok {
+		return
+	}
+	for i, m := range localProvider.Models {
+		if model := backend.GetModelByID(m.ID); model != nil {
+			contextWindow := c.LocalContextWindow(model)
+			localProvider.Models[i].ContextWindow = contextWindow
+			localProvider.Models[i].DefaultMaxTokens = min(4096, contextWindow/2)
+		}
+	}
+	c.Providers.Set("local", localProvider)
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PermissionDecision is what a permission rule does with a matching request.
+type PermissionDecision string
+
+const (
+	// PermissionRuleAllow grants the request without asking.
+	PermissionRuleAllow PermissionDecision = "allow"
+	// PermissionRuleDeny refuses the request without asking.
+	PermissionRuleDeny PermissionDecision = "deny"
+	// PermissionRuleAsk always asks the user, even for allowed tools or
+	// permissions granted for the session.
+	PermissionRuleAsk PermissionDecision = "ask"
+)
+
+// PermissionRule decides tool permission requests that match it. Empty
+// fields match anything. Rules can also be written as a string of the form
+// `<decision> <tool>[:<action>] ["<pattern>"]`, e.g. `allow bash "go test ./..."`
+// or `deny edit "**/*.pem"`, where the pattern is the command for bash and the
+// path otherwise.
+type PermissionRule struct {
+	Decision PermissionDecision `json:"decision" jsonschema:"required,enum=allow,enum=deny,enum=ask"`
+	// Tool is the tool name. It may contain * wildcards.
+	Tool string `json:"tool,omitempty"`
+	// Action is the action the tool asks permission for, e.g. execute or write.
+	Action string `json:"action,omitempty"`
+	// Path is a glob matched against the file, directory or URL of the request.
+	// ** matches any number of directories.
+	Path string `json:"path,omitempty"`
+	// Command is matched against bash commands. * matches anything.
+	Command string `json:"command,omitempty"`
+}
+
+// ParsePermissionRule parses the string form of a rule.
+func ParsePermissionRule(s string) (PermissionRule, error) {
+	s = strings.TrimSpace(s)
+	decision, rest, _ := strings.Cut(s, " ")
+	rule := PermissionRule{Decision: PermissionDecision(decision)}
+	switch rule.Decision {
+	case PermissionRuleAllow, PermissionRuleDeny, PermissionRuleAsk:
+	default:
+		return PermissionRule{}, fmt.Errorf("invalid permission rule %q: decision must be allow, deny or ask", s)
+	}
+
+	tool, pattern, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	if tool == "" {
+		return PermissionRule{}, fmt.Errorf("invalid permission rule %q: missing tool", s)
+	}
+	rule.Tool, rule.Action, _ = strings.Cut(tool, ":")
+
+	pattern = strings.TrimSpace(pattern)
+	if strings.HasPrefix(pattern, `"`) {
+		unquoted, err := strconv.Unquote(pattern)
+		if err != nil {
+			return PermissionRule{}, fmt.Errorf("invalid permission rule %q: %w", s, err)
+		}
+		pattern = unquoted
+	}
+	if rule.Tool == "bash" {
+		rule.Command = pattern
+	} else {
+		rule.Path = pattern
+	}
+	return rule, nil
+}
+
+// String returns the rule in its string form, or as JSON when it has no
+// string form.
+func (r PermissionRule) String() string {
+	if s, ok := r.shorthand(); ok {
+		return s
+	}
+	data, _ := json.Marshal(plainPermissionRule(r))
+	return string(data)
+}
+
+func (r PermissionRule) shorthand() (string, bool) {
+	if r.Tool == "" || (r.Path != "" && r.Command != "") ||
+		(r.Tool == "bash" && r.Path != "") || (r.Tool != "bash" && r.Command != "") {
+		return "", false
+	}
+	tool := r.Tool
+	if r.Action != "" {
+		tool += ":" + r.Action
+	}
+	pattern := r.Path
+	if r.Tool == "bash" {
+		pattern = r.Command
+	}
+	if pattern == "" {
+		return fmt.Sprintf("%s %s", r.Decision, tool), true
+	}
+	return fmt.Sprintf("%s %s %s", r.Decision, tool, strconv.Quote(pattern)), true
+}
+
+type plainPermissionRule PermissionRule
+
+func (r PermissionRule) MarshalJSON() ([]byte, error) {
+	if s, ok := r.shorthand(); ok {
+		return json.Marshal(s)
+	}
+	return json.Marshal(plainPermissionRule(r))
+}
+
+func (r *PermissionRule) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		rule, err := ParsePermissionRule(s)
+		if err != nil {
+			return err
+		}
+		*r = rule
+		return nil
+	}
+
+	var rule plainPermissionRule
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+	switch rule.Decision {
+	case PermissionRuleAllow, PermissionRuleDeny, PermissionRuleAsk:
+	default:
+		return fmt.Errorf("invalid permission rule decision %q", rule.Decision)
+	}
+	*r = PermissionRule(rule)
+	return nil
+}
+
+// AddPermissionRule puts a rule in front of the configured rules and saves it
+// to the global data config so that it applies to later runs too.
+func (c *Config) AddPermissionRule(rule PermissionRule) error {
+	var saved struct {
+		Permissions struct {
+			Rules []PermissionRule `json:"rules"`
+		} `json:"permissions"`
+	}
+	data, err := os.ReadFile(c.dataConfigDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("failed to read saved permission rules: %w", err)
+		}
+	}
+	rules := slices.Insert(saved.Permissions.Rules, 0, rule)
+	if err := c.SetConfigField("permissions.rules", rules); err != nil {
+		return err
+	}
+
+	if c.Permissions == nil {
+		c.Permissions = &Permissions{}
+	}
+	c.Permissions.Rules = slices.Insert(c.Permissions.Rules, 0, rule)
+	return nil
+}
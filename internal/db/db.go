@@ -24,6 +24,9 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.createAuditEntryStmt, err = db.PrepareContext(ctx, createAuditEntry); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateAuditEntry: %w", err)
+	}
 	if q.createFileStmt, err = db.PrepareContext(ctx, createFile); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateFile: %w", err)
 	}
@@ -33,6 +36,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.createSessionStmt, err = db.PrepareContext(ctx, createSession); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateSession: %w", err)
 	}
+	if q.createUsageStmt, err = db.PrepareContext(ctx, createUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateUsage: %w", err)
+	}
 	if q.deleteFileStmt, err = db.PrepareContext(ctx, deleteFile); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteFile: %w", err)
 	}
@@ -60,6 +66,15 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getSessionByIDStmt, err = db.PrepareContext(ctx, getSessionByID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetSessionByID: %w", err)
 	}
+	if q.importMessageStmt, err = db.PrepareContext(ctx, importMessage); err != nil {
+		return nil, fmt.Errorf("error preparing query ImportMessage: %w", err)
+	}
+	if q.listAuditEntriesStmt, err = db.PrepareContext(ctx, listAuditEntries); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAuditEntries: %w", err)
+	}
+	if q.listAuditEntriesBySessionStmt, err = db.PrepareContext(ctx, listAuditEntriesBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListAuditEntriesBySession: %w", err)
+	}
 	if q.listFilesByPathStmt, err = db.PrepareContext(ctx, listFilesByPath); err != nil {
 		return nil, fmt.Errorf("error preparing query ListFilesByPath: %w", err)
 	}
@@ -78,6 +93,18 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listSessionsStmt, err = db.PrepareContext(ctx, listSessions); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessions: %w", err)
 	}
+	if q.listUsageStmt, err = db.PrepareContext(ctx, listUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUsage: %w", err)
+	}
+	if q.searchMessagesStmt, err = db.PrepareContext(ctx, searchMessages); err != nil {
+		return nil, fmt.Errorf("error preparing query SearchMessages: %w", err)
+	}
+	if q.sumSessionUsageStmt, err = db.PrepareContext(ctx, sumSessionUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query SumSessionUsage: %w", err)
+	}
+	if q.sumUsageCostStmt, err = db.PrepareContext(ctx, sumUsageCost); err != nil {
+		return nil, fmt.Errorf("error preparing query SumUsageCost: %w", err)
+	}
 	if q.updateMessageStmt, err = db.PrepareContext(ctx, updateMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateMessage: %w", err)
 	}
@@ -89,6 +116,11 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 
 func (q *Queries) Close() error {
 	var err error
+	if q.createAuditEntryStmt != nil {
+		if cerr := q.createAuditEntryStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createAuditEntryStmt: %w", cerr)
+		}
+	}
 	if q.createFileStmt != nil {
 		if cerr := q.createFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createFileStmt: %w", cerr)
@@ -104,6 +136,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing createSessionStmt: %w", cerr)
 		}
 	}
+	if q.createUsageStmt != nil {
+		if cerr := q.createUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createUsageStmt: %w", cerr)
+		}
+	}
 	if q.deleteFileStmt != nil {
 		if cerr := q.deleteFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteFileStmt: %w", cerr)
@@ -149,6 +186,21 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getSessionByIDStmt: %w", cerr)
 		}
 	}
+	if q.importMessageStmt != nil {
+		if cerr := q.importMessageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing importMessageStmt: %w", cerr)
+		}
+	}
+	if q.listAuditEntriesStmt != nil {
+		if cerr := q.listAuditEntriesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAuditEntriesStmt: %w", cerr)
+		}
+	}
+	if q.listAuditEntriesBySessionStmt != nil {
+		if cerr := q.listAuditEntriesBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listAuditEntriesBySessionStmt: %w", cerr)
+		}
+	}
 	if q.listFilesByPathStmt != nil {
 		if cerr := q.listFilesByPathStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listFilesByPathStmt: %w", cerr)
@@ -179,6 +231,26 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listSessionsStmt: %w", cerr)
 		}
 	}
+	if q.listUsageStmt != nil {
+		if cerr := q.listUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUsageStmt: %w", cerr)
+		}
+	}
+	if q.searchMessagesStmt != nil {
+		if cerr := q.searchMessagesStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing searchMessagesStmt: %w", cerr)
+		}
+	}
+	if q.sumSessionUsageStmt != nil {
+		if cerr := q.sumSessionUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing sumSessionUsageStmt: %w", cerr)
+		}
+	}
+	if q.sumUsageCostStmt != nil {
+		if cerr := q.sumUsageCostStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing sumUsageCostStmt: %w", cerr)
+		}
+	}
 	if q.updateMessageStmt != nil {
 		if cerr := q.updateMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateMessageStmt: %w", cerr)
@@ -226,53 +298,71 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                          DBTX
-	tx                          *sql.Tx
-	createFileStmt              *sql.Stmt
-	createMessageStmt           *sql.Stmt
-	createSessionStmt           *sql.Stmt
-	deleteFileStmt              *sql.Stmt
-	deleteMessageStmt           *sql.Stmt
-	deleteSessionStmt           *sql.Stmt
-	deleteSessionFilesStmt      *sql.Stmt
-	deleteSessionMessagesStmt   *sql.Stmt
-	getFileStmt                 *sql.Stmt
-	getFileByPathAndSessionStmt *sql.Stmt
-	getMessageStmt              *sql.Stmt
-	getSessionByIDStmt          *sql.Stmt
-	listFilesByPathStmt         *sql.Stmt
-	listFilesBySessionStmt      *sql.Stmt
-	listLatestSessionFilesStmt  *sql.Stmt
-	listMessagesBySessionStmt   *sql.Stmt
-	listNewFilesStmt            *sql.Stmt
-	listSessionsStmt            *sql.Stmt
-	updateMessageStmt           *sql.Stmt
-	updateSessionStmt           *sql.Stmt
+	db                            DBTX
+	tx                            *sql.Tx
+	createAuditEntryStmt          *sql.Stmt
+	createFileStmt                *sql.Stmt
+	createMessageStmt             *sql.Stmt
+	createSessionStmt             *sql.Stmt
+	createUsageStmt               *sql.Stmt
+	deleteFileStmt                *sql.Stmt
+	deleteMessageStmt             *sql.Stmt
+	deleteSessionStmt             *sql.Stmt
+	deleteSessionFilesStmt        *sql.Stmt
+	deleteSessionMessagesStmt     *sql.Stmt
+	getFileStmt                   *sql.Stmt
+	getFileByPathAndSessionStmt   *sql.Stmt
+	getMessageStmt                *sql.Stmt
+	getSessionByIDStmt            *sql.Stmt
+	importMessageStmt             *sql.Stmt
+	listAuditEntriesStmt          *sql.Stmt
+	listAuditEntriesBySessionStmt *sql.Stmt
+	listFilesByPathStmt           *sql.Stmt
+	listFilesBySessionStmt        *sql.Stmt
+	listLatestSessionFilesStmt    *sql.Stmt
+	listMessagesBySessionStmt     *sql.Stmt
+	listNewFilesStmt              *sql.Stmt
+	listSessionsStmt              *sql.Stmt
+	listUsageStmt                 *sql.Stmt
+	searchMessagesStmt            *sql.Stmt
+	sumSessionUsageStmt           *sql.Stmt
+	sumUsageCostStmt              *sql.Stmt
+	updateMessageStmt             *sql.Stmt
+	updateSessionStmt             *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                          tx,
-		tx:                          tx,
-		createFileStmt:              q.createFileStmt,
-		createMessageStmt:           q.createMessageStmt,
-		createSessionStmt:           q.createSessionStmt,
-		deleteFileStmt:              q.deleteFileStmt,
-		deleteMessageStmt:           q.deleteMessageStmt,
-		deleteSessionStmt:           q.deleteSessionStmt,
-		deleteSessionFilesStmt:      q.deleteSessionFilesStmt,
-		deleteSessionMessagesStmt:   q.deleteSessionMessagesStmt,
-		getFileStmt:                 q.getFileStmt,
-		getFileByPathAndSessionStmt: q.getFileByPathAndSessionStmt,
-		getMessageStmt:              q.getMessageStmt,
-		getSessionByIDStmt:          q.getSessionByIDStmt,
-		listFilesByPathStmt:         q.listFilesByPathStmt,
-		listFilesBySessionStmt:      q.listFilesBySessionStmt,
-		listLatestSessionFilesStmt:  q.listLatestSessionFilesStmt,
-		listMessagesBySessionStmt:   q.listMessagesBySessionStmt,
-		listNewFilesStmt:            q.listNewFilesStmt,
-		listSessionsStmt:            q.listSessionsStmt,
-		updateMessageStmt:           q.updateMessageStmt,
-		updateSessionStmt:           q.updateSessionStmt,
+		db:                            tx,
+		tx:                            tx,
+		createAuditEntryStmt:          q.createAuditEntryStmt,
+		createFileStmt:                q.createFileStmt,
+		createMessageStmt:             q.createMessageStmt,
+		createSessionStmt:             q.createSessionStmt,
+		createUsageStmt:               q.createUsageStmt,
+		deleteFileStmt:                q.deleteFileStmt,
+		deleteMessageStmt:             q.deleteMessageStmt,
+		deleteSessionStmt:             q.deleteSessionStmt,
+		deleteSessionFilesStmt:        q.deleteSessionFilesStmt,
+		deleteSessionMessagesStmt:     q.deleteSessionMessagesStmt,
+		getFileStmt:                   q.getFileStmt,
+		getFileByPathAndSessionStmt:   q.getFileByPathAndSessionStmt,
+		getMessageStmt:                q.getMessageStmt,
+		getSessionByIDStmt:            q.getSessionByIDStmt,
+		importMessageStmt:             q.importMessageStmt,
+		listAuditEntriesStmt:          q.listAuditEntriesStmt,
+		listAuditEntriesBySessionStmt: q.listAuditEntriesBySessionStmt,
+		listFilesByPathStmt:           q.listFilesByPathStmt,
+		listFilesBySessionStmt:        q.listFilesBySessionStmt,
+		listLatestSessionFilesStmt:    q.listLatestSessionFilesStmt,
+		listMessagesBySessionStmt:     q.listMessagesBySessionStmt,
+		listNewFilesStmt:              q.listNewFilesStmt,
+		listSessionsStmt:              q.listSessionsStmt,
+		listUsageStmt:                 q.listUsageStmt,
+		searchMessagesStmt:            q.searchMessagesStmt,
+		sumSessionUsageStmt:           q.sumSessionUsageStmt,
+		sumUsageCostStmt:              q.sumUsageCostStmt,
+		updateMessageStmt:             q.updateMessageStmt,
+		updateSessionStmt:             q.updateSessionStmt,
 	}
 }
@@ -18,12 +18,13 @@ INSERT INTO messages (
     parts,
     model,
     provider,
+    author,
     created_at,
     updated_at
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+    ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
 )
-RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, author
 `
 
 type CreateMessageParams struct {
@@ -33,6 +34,7 @@ type CreateMessageParams struct {
 	Parts     string         `json:"parts"`
 	Model     sql.NullString `json:"model"`
 	Provider  sql.NullString `json:"provider"`
+	Author    string         `json:"author"`
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
@@ -43,6 +45,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		arg.Parts,
 		arg.Model,
 		arg.Provider,
+		arg.Author,
 	)
 	var i Message
 	err := row.Scan(
@@ -55,6 +58,7 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.UpdatedAt,
 		&i.FinishedAt,
 		&i.Provider,
+		&i.Author,
 	)
 	return i, err
 }
@@ -80,7 +84,7 @@ func (q *Queries) DeleteSessionMessages(ctx context.Context, sessionID string) e
 }
 
 const getMessage = `-- name: GetMessage :one
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, author
 FROM messages
 WHERE id = ? LIMIT 1
 `
@@ -98,12 +102,73 @@ func (q *Queries) GetMessage(ctx context.Context, id string) (Message, error) {
 		&i.UpdatedAt,
 		&i.FinishedAt,
 		&i.Provider,
+		&i.Author,
+	)
+	return i, err
+}
+
+const importMessage = `-- name: ImportMessage :one
+INSERT INTO messages (
+    id,
+    session_id,
+    role,
+    parts,
+    model,
+    provider,
+    author,
+    created_at,
+    updated_at,
+    finished_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+RETURNING id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, author
+`
+
+type ImportMessageParams struct {
+	ID         string         `json:"id"`
+	SessionID  string         `json:"session_id"`
+	Role       string         `json:"role"`
+	Parts      string         `json:"parts"`
+	Model      sql.NullString `json:"model"`
+	Provider   sql.NullString `json:"provider"`
+	Author     string         `json:"author"`
+	CreatedAt  int64          `json:"created_at"`
+	UpdatedAt  int64          `json:"updated_at"`
+	FinishedAt sql.NullInt64  `json:"finished_at"`
+}
+
+func (q *Queries) ImportMessage(ctx context.Context, arg ImportMessageParams) (Message, error) {
+	row := q.queryRow(ctx, q.importMessageStmt, importMessage,
+		arg.ID,
+		arg.SessionID,
+		arg.Role,
+		arg.Parts,
+		arg.Model,
+		arg.Provider,
+		arg.Author,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.FinishedAt,
+	)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.Role,
+		&i.Parts,
+		&i.Model,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.FinishedAt,
+		&i.Provider,
+		&i.Author,
 	)
 	return i, err
 }
 
 const listMessagesBySession = `-- name: ListMessagesBySession :many
-SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider
+SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider, author
 FROM messages
 WHERE session_id = ?
 ORDER BY created_at ASC
@@ -128,6 +193,71 @@ func (q *Queries) ListMessagesBySession(ctx context.Context, sessionID string) (
 			&i.UpdatedAt,
 			&i.FinishedAt,
 			&i.Provider,
+			&i.Author,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchMessages = `-- name: SearchMessages :many
+SELECT
+    m.id,
+    m.session_id,
+    CAST(COALESCE(s.parent_session_id, '') AS TEXT) AS parent_session_id,
+    COALESCE(p.title, s.title) AS session_title,
+    m.role,
+    m.created_at,
+    CAST(snippet(messages_fts, 0, char(2), char(3), '…', 16) AS TEXT) AS snippet
+FROM messages_fts
+JOIN messages AS m ON m.rowid = messages_fts.rowid
+JOIN sessions AS s ON s.id = m.session_id
+LEFT JOIN sessions AS p ON p.id = s.parent_session_id
+WHERE messages_fts MATCH ?
+ORDER BY rank
+LIMIT ?
+`
+
+type SearchMessagesParams struct {
+	Query string `json:"query"`
+	Limit int64  `json:"limit"`
+}
+
+type SearchMessagesRow struct {
+	ID              string `json:"id"`
+	SessionID       string `json:"session_id"`
+	ParentSessionID string `json:"parent_session_id"`
+	SessionTitle    string `json:"session_title"`
+	Role            string `json:"role"`
+	CreatedAt       int64  `json:"created_at"`
+	Snippet         string `json:"snippet"`
+}
+
+func (q *Queries) SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]SearchMessagesRow, error) {
+	rows, err := q.query(ctx, q.searchMessagesStmt, searchMessages, arg.Query, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SearchMessagesRow{}
+	for rows.Next() {
+		var i SearchMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.ParentSessionID,
+			&i.SessionTitle,
+			&i.Role,
+			&i.CreatedAt,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -147,17 +277,27 @@ UPDATE messages
 SET
     parts = ?,
     finished_at = ?,
+    model = ?,
+    provider = ?,
     updated_at = strftime('%s', 'now')
 WHERE id = ?
 `
 
 type UpdateMessageParams struct {
-	Parts      string        `json:"parts"`
-	FinishedAt sql.NullInt64 `json:"finished_at"`
-	ID         string        `json:"id"`
+	Parts      string         `json:"parts"`
+	FinishedAt sql.NullInt64  `json:"finished_at"`
+	Model      sql.NullString `json:"model"`
+	Provider   sql.NullString `json:"provider"`
+	ID         string         `json:"id"`
 }
 
 func (q *Queries) UpdateMessage(ctx context.Context, arg UpdateMessageParams) error {
-	_, err := q.exec(ctx, q.updateMessageStmt, updateMessage, arg.Parts, arg.FinishedAt, arg.ID)
+	_, err := q.exec(ctx, q.updateMessageStmt, updateMessage,
+		arg.Parts,
+		arg.FinishedAt,
+		arg.Model,
+		arg.Provider,
+		arg.ID,
+	)
 	return err
 }
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Audit log of tool calls and permission decisions. Entries are kept when
+-- their session is deleted.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    message_id TEXT NOT NULL DEFAULT '',
+    tool_call_id TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL,  -- tool or permission
+    tool_name TEXT NOT NULL,
+    action TEXT NOT NULL DEFAULT '',
+    input TEXT NOT NULL DEFAULT '',
+    output TEXT NOT NULL DEFAULT '',
+    is_error INTEGER NOT NULL DEFAULT 0,
+    duration_ms INTEGER,
+    exit_code INTEGER,
+    decision TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',  -- why a permission was decided that way
+    created_at INTEGER NOT NULL  -- Unix timestamp in milliseconds
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_session_id ON audit_log (session_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_audit_log_created_at;
+DROP INDEX IF EXISTS idx_audit_log_session_id;
+DROP TABLE IF EXISTS audit_log;
+-- +goose StatementEnd
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add author column to messages table, set for prompts proposed by buddies
+ALTER TABLE messages ADD COLUMN author TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Remove author column from messages table
+ALTER TABLE messages DROP COLUMN author;
+-- +goose StatementEnd
@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Full-text index over the text, tool call inputs and tool results of
+-- messages. Rows share the rowid of their message.
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(content);
+
+INSERT INTO messages_fts (rowid, content)
+SELECT m.rowid, (
+    SELECT group_concat(
+        CASE json_extract(p.value, '$.type')
+            WHEN 'text' THEN json_extract(p.value, '$.data.text')
+            WHEN 'tool_call' THEN json_extract(p.value, '$.data.name') || ' ' || json_extract(p.value, '$.data.input')
+            WHEN 'tool_result' THEN json_extract(p.value, '$.data.content')
+        END,
+        char(10)
+    )
+    FROM json_each(m.parts) AS p
+)
+FROM messages AS m;
+
+CREATE TRIGGER IF NOT EXISTS index_messages_fts_on_insert
+AFTER INSERT ON messages
+BEGIN
+INSERT INTO messages_fts (rowid, content)
+SELECT new.rowid, group_concat(
+    CASE json_extract(p.value, '$.type')
+        WHEN 'text' THEN json_extract(p.value, '$.data.text')
+        WHEN 'tool_call' THEN json_extract(p.value, '$.data.name') || ' ' || json_extract(p.value, '$.data.input')
+        WHEN 'tool_result' THEN json_extract(p.value, '$.data.content')
+    END,
+    char(10)
+)
+FROM json_each(new.parts) AS p;
+END;
+
+CREATE TRIGGER IF NOT EXISTS index_messages_fts_on_update
+AFTER UPDATE OF parts ON messages
+BEGIN
+DELETE FROM messages_fts WHERE rowid = old.rowid;
+INSERT INTO messages_fts (rowid, content)
+SELECT new.rowid, group_concat(
+    CASE json_extract(p.value, '$.type')
+        WHEN 'text' THEN json_extract(p.value, '$.data.text')
+        WHEN 'tool_call' THEN json_extract(p.value, '$.data.name') || ' ' || json_extract(p.value, '$.data.input')
+        WHEN 'tool_result' THEN json_extract(p.value, '$.data.content')
+    END,
+    char(10)
+)
+FROM json_each(new.parts) AS p;
+END;
+
+CREATE TRIGGER IF NOT EXISTS index_messages_fts_on_delete
+AFTER DELETE ON messages
+BEGIN
+DELETE FROM messages_fts WHERE rowid = old.rowid;
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS index_messages_fts_on_delete;
+DROP TRIGGER IF EXISTS index_messages_fts_on_update;
+DROP TRIGGER IF EXISTS index_messages_fts_on_insert;
+DROP TABLE IF EXISTS messages_fts;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Session a session was forked from, if any. Unlike parent_session_id, forks
+-- are listed as sessions of their own.
+ALTER TABLE sessions ADD COLUMN forked_from_session_id TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN forked_from_session_id;
+-- +goose StatementEnd
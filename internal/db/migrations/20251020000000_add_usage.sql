@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Token usage and cost of every request to a model. Entries are kept when
+-- their session is deleted so that reports and budgets stay accurate.
+CREATE TABLE IF NOT EXISTS usage (
+    id TEXT PRIMARY KEY,
+    project TEXT NOT NULL DEFAULT '',  -- working directory, for the global database
+    session_id TEXT NOT NULL,
+    message_id TEXT NOT NULL DEFAULT '',
+    model TEXT NOT NULL DEFAULT '',
+    provider TEXT NOT NULL DEFAULT '',
+    input_tokens INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_creation_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+    cost REAL NOT NULL DEFAULT 0.0,
+    created_at INTEGER NOT NULL  -- Unix timestamp in milliseconds
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_created_at ON usage (created_at);
+
+-- Sessions from before usage was recorded only know their total cost. It is
+-- kept as a single entry with the model of their last response.
+INSERT INTO usage (id, session_id, model, provider, cost, created_at)
+SELECT
+    'session-' || s.id,
+    s.id,
+    COALESCE((SELECT m.model FROM messages m WHERE m.session_id = s.id AND m.role = 'assistant' ORDER BY m.created_at DESC LIMIT 1), ''),
+    COALESCE((SELECT m.provider FROM messages m WHERE m.session_id = s.id AND m.role = 'assistant' ORDER BY m.created_at DESC LIMIT 1), ''),
+    s.cost,
+    s.updated_at * 1000
+FROM sessions s
+WHERE s.parent_session_id IS NULL AND s.cost > 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_usage_created_at;
+DROP TABLE IF EXISTS usage;
+-- +goose StatementEnd
@@ -8,6 +8,24 @@ import (
 	"database/sql"
 )
 
+type AuditLog struct {
+	ID         string        `json:"id"`
+	SessionID  string        `json:"session_id"`
+	MessageID  string        `json:"message_id"`
+	ToolCallID string        `json:"tool_call_id"`
+	Kind       string        `json:"kind"`
+	ToolName   string        `json:"tool_name"`
+	Action     string        `json:"action"`
+	Input      string        `json:"input"`
+	Output     string        `json:"output"`
+	IsError    int64         `json:"is_error"`
+	DurationMs sql.NullInt64 `json:"duration_ms"`
+	ExitCode   sql.NullInt64 `json:"exit_code"`
+	Decision   string        `json:"decision"`
+	Reason     string        `json:"reason"`
+	CreatedAt  int64         `json:"created_at"`
+}
+
 type File struct {
 	ID        string `json:"id"`
 	SessionID string `json:"session_id"`
@@ -28,17 +46,34 @@ type Message struct {
 	UpdatedAt  int64          `json:"updated_at"`
 	FinishedAt sql.NullInt64  `json:"finished_at"`
 	Provider   sql.NullString `json:"provider"`
+	Author     string         `json:"author"`
 }
 
 type Session struct {
-	ID               string         `json:"id"`
-	ParentSessionID  sql.NullString `json:"parent_session_id"`
-	Title            string         `json:"title"`
-	MessageCount     int64          `json:"message_count"`
-	PromptTokens     int64          `json:"prompt_tokens"`
-	CompletionTokens int64          `json:"completion_tokens"`
-	Cost             float64        `json:"cost"`
-	UpdatedAt        int64          `json:"updated_at"`
-	CreatedAt        int64          `json:"created_at"`
-	SummaryMessageID sql.NullString `json:"summary_message_id"`
+	ID                  string         `json:"id"`
+	ParentSessionID     sql.NullString `json:"parent_session_id"`
+	Title               string         `json:"title"`
+	MessageCount        int64          `json:"message_count"`
+	PromptTokens        int64          `json:"prompt_tokens"`
+	CompletionTokens    int64          `json:"completion_tokens"`
+	Cost                float64        `json:"cost"`
+	UpdatedAt           int64          `json:"updated_at"`
+	CreatedAt           int64          `json:"created_at"`
+	SummaryMessageID    sql.NullString `json:"summary_message_id"`
+	ForkedFromSessionID sql.NullString `json:"forked_from_session_id"`
+}
+
+type Usage struct {
+	ID                  string  `json:"id"`
+	Project             string  `json:"project"`
+	SessionID           string  `json:"session_id"`
+	MessageID           string  `json:"message_id"`
+	Model               string  `json:"model"`
+	Provider            string  `json:"provider"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	Cost                float64 `json:"cost"`
+	CreatedAt           int64   `json:"created_at"`
 }
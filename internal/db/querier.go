@@ -9,9 +9,11 @@ import (
 )
 
 type Querier interface {
+	CreateAuditEntry(ctx context.Context, arg CreateAuditEntryParams) error
 	CreateFile(ctx context.Context, arg CreateFileParams) (File, error)
 	CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateUsage(ctx context.Context, arg CreateUsageParams) error
 	DeleteFile(ctx context.Context, id string) error
 	DeleteMessage(ctx context.Context, id string) error
 	DeleteSession(ctx context.Context, id string) error
@@ -21,12 +23,19 @@ type Querier interface {
 	GetFileByPathAndSession(ctx context.Context, arg GetFileByPathAndSessionParams) (File, error)
 	GetMessage(ctx context.Context, id string) (Message, error)
 	GetSessionByID(ctx context.Context, id string) (Session, error)
+	ImportMessage(ctx context.Context, arg ImportMessageParams) (Message, error)
+	ListAuditEntries(ctx context.Context, createdAt int64) ([]AuditLog, error)
+	ListAuditEntriesBySession(ctx context.Context, arg ListAuditEntriesBySessionParams) ([]AuditLog, error)
 	ListFilesByPath(ctx context.Context, path string) ([]File, error)
 	ListFilesBySession(ctx context.Context, sessionID string) ([]File, error)
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error)
 	ListNewFiles(ctx context.Context) ([]File, error)
 	ListSessions(ctx context.Context) ([]Session, error)
+	ListUsage(ctx context.Context, createdAt int64) ([]Usage, error)
+	SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]SearchMessagesRow, error)
+	SumSessionUsage(ctx context.Context, arg SumSessionUsageParams) (SumSessionUsageRow, error)
+	SumUsageCost(ctx context.Context, createdAt int64) (float64, error)
 	UpdateMessage(ctx context.Context, arg UpdateMessageParams) error
 	UpdateSession(ctx context.Context, arg UpdateSessionParams) (Session, error)
 }
@@ -0,0 +1,32 @@
+-- name: CreateAuditEntry :exec
+INSERT INTO audit_log (
+    id,
+    session_id,
+    message_id,
+    tool_call_id,
+    kind,
+    tool_name,
+    action,
+    input,
+    output,
+    is_error,
+    duration_ms,
+    exit_code,
+    decision,
+    reason,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListAuditEntries :many
+SELECT *
+FROM audit_log
+WHERE created_at >= ?
+ORDER BY created_at ASC;
+
+-- name: ListAuditEntriesBySession :many
+SELECT *
+FROM audit_log
+WHERE session_id = ? AND created_at >= ?
+ORDER BY created_at ASC;
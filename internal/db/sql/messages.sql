@@ -17,10 +17,28 @@ INSERT INTO messages (
     parts,
     model,
     provider,
+    author,
     created_at,
     updated_at
 ) VALUES (
-    ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+    ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: ImportMessage :one
+INSERT INTO messages (
+    id,
+    session_id,
+    role,
+    parts,
+    model,
+    provider,
+    author,
+    created_at,
+    updated_at,
+    finished_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 )
 RETURNING *;
 
@@ -29,6 +47,8 @@ UPDATE messages
 SET
     parts = ?,
     finished_at = ?,
+    model = ?,
+    provider = ?,
     updated_at = strftime('%s', 'now')
 WHERE id = ?;
 
@@ -40,3 +60,20 @@ WHERE id = ?;
 -- name: DeleteSessionMessages :exec
 DELETE FROM messages
 WHERE session_id = ?;
+
+-- name: SearchMessages :many
+SELECT
+    m.id,
+    m.session_id,
+    CAST(COALESCE(s.parent_session_id, '') AS TEXT) AS parent_session_id,
+    COALESCE(p.title, s.title) AS session_title,
+    m.role,
+    m.created_at,
+    CAST(snippet(messages_fts, 0, char(2), char(3), '…', 16) AS TEXT) AS snippet
+FROM messages_fts
+JOIN messages AS m ON m.rowid = messages_fts.rowid
+JOIN sessions AS s ON s.id = m.session_id
+LEFT JOIN sessions AS p ON p.id = s.parent_session_id
+WHERE messages_fts MATCH sqlc.arg(query)
+ORDER BY rank
+LIMIT sqlc.arg(limit);
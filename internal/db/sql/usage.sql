@@ -0,0 +1,42 @@
+-- name: CreateUsage :exec
+INSERT INTO usage (
+    id,
+    project,
+    session_id,
+    message_id,
+    model,
+    provider,
+    input_tokens,
+    output_tokens,
+    cache_creation_tokens,
+    cache_read_tokens,
+    cost,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListUsage :many
+SELECT *
+FROM usage
+WHERE created_at >= ?
+ORDER BY created_at ASC;
+
+-- name: SumUsageCost :one
+SELECT CAST(COALESCE(SUM(cost), 0.0) AS REAL) AS cost
+FROM usage
+WHERE created_at >= ?;
+
+-- name: SumSessionUsage :one
+SELECT
+    COUNT(*) AS requests,
+    CAST(COALESCE(SUM(input_tokens), 0) AS INTEGER) AS input_tokens,
+    CAST(COALESCE(SUM(output_tokens), 0) AS INTEGER) AS output_tokens,
+    CAST(COALESCE(SUM(cache_creation_tokens), 0) AS INTEGER) AS cache_creation_tokens,
+    CAST(COALESCE(SUM(cache_read_tokens), 0) AS INTEGER) AS cache_read_tokens,
+    CAST(COALESCE(SUM(cost), 0.0) AS REAL) AS cost
+FROM usage
+WHERE session_id IN (
+    SELECT id FROM sessions WHERE sqlc.arg(session_id) IN (id, parent_session_id)
+)
+AND created_at >= sqlc.arg(created_at);
@@ -0,0 +1,139 @@
+// Package fork copies a session up to one of its messages into a new session,
+// so that a conversation can branch off in another direction while the
+// original is kept.
+package fork
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/session"
+)
+
+// Service forks sessions.
+type Service struct {
+	sessions session.Service
+	messages message.Service
+	files    history.Service
+}
+
+func NewService(sessions session.Service, messages message.Service, files history.Service) *Service {
+	return &Service{sessions: sessions, messages: messages, files: files}
+}
+
+// Fork creates a session with the history of another one up to the given
+// message, along with the versions of files recorded until then. Forking at
+// a user message stops just before it, so that another prompt can be tried
+// from the same context; forking at an assistant message keeps it and the
+// results of its tool calls. An empty messageID copies the whole session.
+//
+// Sub-agent sessions are not copied, so their tool calls only show their
+// results in the fork.
+func (s *Service) Fork(ctx context.Context, sessionID, messageID string) (session.Session, error) {
+	original, err := s.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to get session %s: %w", sessionID, err)
+	}
+	msgs, err := s.messages.List(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to list messages: %w", err)
+	}
+	end, err := forkPoint(msgs, messageID)
+	if err != nil {
+		return session.Session{}, err
+	}
+	files, err := s.files.ListBySession(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to list file history: %w", err)
+	}
+	if end < len(msgs) {
+		// Tool calls write their files before their results are saved, so
+		// the versions are bounded by the last message kept.
+		files = filesUntil(files, msgs[end-1].CreatedAt)
+	}
+
+	forked, err := s.sessions.CreateFork(ctx, sessionID, original.Title+" (fork)")
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	if err := s.copy(ctx, original, &forked, msgs[:end], files); err != nil {
+		// Messages and files are removed by the foreign key cascade
+		_ = s.sessions.Delete(ctx, forked.ID)
+		return session.Session{}, err
+	}
+	saved, err := s.sessions.Save(ctx, forked)
+	if err != nil {
+		_ = s.sessions.Delete(ctx, forked.ID)
+		return session.Session{}, fmt.Errorf("failed to save session: %w", err)
+	}
+	return saved, nil
+}
+
+func (s *Service) copy(ctx context.Context, original session.Session, forked *session.Session, msgs []message.Message, files []history.File) error {
+	for _, m := range msgs {
+		copied, err := s.messages.Import(ctx, forked.ID, m)
+		if err != nil {
+			return fmt.Errorf("failed to copy message %s: %w", m.ID, err)
+		}
+		if m.ID == original.SummaryMessageID {
+			forked.SummaryMessageID = copied.ID
+		}
+	}
+
+	// Versions are numbered per path across sessions, so only their order
+	// is kept
+	seen := make(map[string]bool)
+	for _, f := range files {
+		var err error
+		if seen[f.Path] {
+			_, err = s.files.CreateVersion(ctx, forked.ID, f.Path, f.Content)
+		} else {
+			_, err = s.files.Create(ctx, forked.ID, f.Path, f.Content)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy the history of %s: %w", f.Path, err)
+		}
+		seen[f.Path] = true
+	}
+	return nil
+}
+
+// forkPoint returns the number of messages a fork at the given message
+// keeps.
+func forkPoint(msgs []message.Message, messageID string) (int, error) {
+	if messageID == "" {
+		return len(msgs), nil
+	}
+	for i, m := range msgs {
+		if m.ID != messageID {
+			continue
+		}
+		if m.Role == message.User {
+			return i, nil
+		}
+		end := i + 1
+		// Tool calls need all their results for the conversation to continue
+		if m.Role == message.Tool || len(m.ToolCalls()) > 0 {
+			for end < len(msgs) && msgs[end].Role == message.Tool {
+				end++
+			}
+		}
+		return end, nil
+	}
+	return 0, fmt.Errorf("message %s not found", messageID)
+}
+
+// filesUntil returns the file versions recorded until the given unix time.
+// Timestamps are in seconds, so versions recorded in the same second are
+// kept. files must be ordered by version.
+func filesUntil(files []history.File, until int64) []history.File {
+	var kept []history.File
+	for _, f := range files {
+		if f.CreatedAt <= until {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
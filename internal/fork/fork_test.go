@@ -0,0 +1,58 @@
+package fork
+
+import (
+	"testing"
+
+	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForkPoint(t *testing.T) {
+	t.Parallel()
+
+	msgs := []message.Message{
+		{ID: "u1", Role: message.User},
+		{ID: "a1", Role: message.Assistant, Parts: []message.ContentPart{
+			message.ToolCall{ID: "t1", Name: "ls"},
+			message.ToolCall{ID: "t2", Name: "view"},
+		}},
+		{ID: "r1", Role: message.Tool},
+		{ID: "r2", Role: message.Tool},
+		{ID: "a2", Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: "done"}}},
+		{ID: "u2", Role: message.User},
+	}
+
+	for _, tc := range []struct {
+		messageID string
+		want      int
+	}{
+		{"", 6},
+		{"u1", 0},
+		{"a1", 4},
+		{"r1", 4},
+		{"a2", 5},
+		{"u2", 5},
+	} {
+		got, err := forkPoint(msgs, tc.messageID)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, got, tc.messageID)
+	}
+
+	_, err := forkPoint(msgs, "missing")
+	require.Error(t, err)
+}
+
+func TestFilesUntil(t *testing.T) {
+	t.Parallel()
+
+	files := []history.File{
+		{Path: "/p/a.go", Version: 0, CreatedAt: 10},
+		{Path: "/p/a.go", Version: 1, CreatedAt: 100},
+		{Path: "/p/b.go", Version: 0, CreatedAt: 120},
+	}
+	require.Equal(t, files[:1], filesUntil(files, 99))
+	// Versions written by tool calls usually share the second of the tool
+	// results saved after them.
+	require.Equal(t, files[:2], filesUntil(files, 100))
+}
@@ -0,0 +1,68 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// OutputFormat controls how non-interactive runs report their progress.
+type OutputFormat string
+
+const (
+	// OutputText prints only the assistant's response text.
+	OutputText OutputFormat = "text"
+	// OutputJSON prints a single JSON summary object once the run ends.
+	OutputJSON OutputFormat = "json"
+	// OutputStreamJSON prints one JSON record per line (NDJSON) for every
+	// agent event, followed by the summary record.
+	OutputStreamJSON OutputFormat = "stream-json"
+)
+
+// SupportedOutputFormats lists the accepted values for --output-format.
+var SupportedOutputFormats = []OutputFormat{OutputText, OutputJSON, OutputStreamJSON}
+
+// ParseOutputFormat validates a user supplied output format.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	if s == "" {
+		return OutputText, nil
+	}
+	for _, f := range SupportedOutputFormats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	names := make([]string, len(SupportedOutputFormats))
+	for i, f := range SupportedOutputFormats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("invalid output format %q, must be one of: %s", s, strings.Join(names, ", "))
+}
+
+// IsJSON reports whether the format produces machine readable output.
+func (f OutputFormat) IsJSON() bool {
+	return f == OutputJSON || f == OutputStreamJSON
+}
+
+// JSONWriter writes newline delimited JSON records. It is safe for
+// concurrent use.
+type JSONWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONWriter returns a JSONWriter that writes to w.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &JSONWriter{enc: enc}
+}
+
+// Write encodes v as a single line of JSON.
+func (w *JSONWriter) Write(v any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(v)
+}
@@ -0,0 +1,57 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		expected OutputFormat
+	}{
+		{input: "", expected: OutputText},
+		{input: "text", expected: OutputText},
+		{input: "json", expected: OutputJSON},
+		{input: "stream-json", expected: OutputStreamJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := ParseOutputFormat(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, f)
+		})
+	}
+}
+
+func TestParseOutputFormatInvalid(t *testing.T) {
+	t.Parallel()
+
+	f, err := ParseOutputFormat("yaml")
+	require.EqualError(t, err, `invalid output format "yaml", must be one of: text, json, stream-json`)
+	require.Empty(t, f)
+}
+
+func TestOutputFormatIsJSON(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, OutputText.IsJSON())
+	require.True(t, OutputJSON.IsJSON())
+	require.True(t, OutputStreamJSON.IsJSON())
+}
+
+func TestJSONWriter(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w := NewJSONWriter(&buf)
+	require.NoError(t, w.Write(map[string]string{"a": "<b>"}))
+	require.NoError(t, w.Write([]int{1, 2}))
+	require.Equal(t, "{\"a\":\"<b>\"}\n[1,2]\n", buf.String())
+}
@@ -0,0 +1,178 @@
+// Package limits pauses agent runs that reach the tool iteration, token or
+// cost limits of the config until the user decides to continue or stop.
+package limits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/chasedut/toke/internal/config"
+	"github.com/chasedut/toke/internal/csync"
+	"github.com/chasedut/toke/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+// ErrLimitReached is returned by runs stopped at a limit.
+var ErrLimitReached = errors.New("limit reached")
+
+// Scope is what a limit counts.
+type Scope string
+
+const (
+	// ScopeRun counts a single prompt and the tool calls that follow it.
+	ScopeRun Scope = "run"
+	// ScopeSession counts all prompts of a session.
+	ScopeSession Scope = "session"
+)
+
+// Usage is what a run or a session used.
+type Usage struct {
+	ToolIterations int     `json:"tool_iterations"`
+	InputTokens    int64   `json:"input_tokens"`
+	OutputTokens   int64   `json:"output_tokens"`
+	Cost           float64 `json:"cost"`
+}
+
+func (u Usage) sub(o Usage) Usage {
+	return Usage{
+		ToolIterations: u.ToolIterations - o.ToolIterations,
+		InputTokens:    u.InputTokens - o.InputTokens,
+		OutputTokens:   u.OutputTokens - o.OutputTokens,
+		Cost:           u.Cost - o.Cost,
+	}
+}
+
+// Reached returns the first limit u reached, e.g. "25 tool iterations", or
+// an empty string.
+func Reached(l *config.Limits, u Usage) string {
+	switch {
+	case l == nil:
+		return ""
+	case l.ToolIterations > 0 && u.ToolIterations >= l.ToolIterations:
+		return fmt.Sprintf("%d tool iterations", l.ToolIterations)
+	case l.InputTokens > 0 && u.InputTokens >= l.InputTokens:
+		return fmt.Sprintf("%d input tokens", l.InputTokens)
+	case l.OutputTokens > 0 && u.OutputTokens >= l.OutputTokens:
+		return fmt.Sprintf("%d output tokens", l.OutputTokens)
+	case l.Cost > 0 && u.Cost >= l.Cost:
+		return fmt.Sprintf("$%.2f", l.Cost)
+	}
+	return ""
+}
+
+// Guard checks a run against the run and session limits. After the user
+// chose to continue, the limits count again from that point.
+type Guard struct {
+	opts *config.LimitOptions
+	// run and session are the usage when the user last continued.
+	run, session Usage
+}
+
+// NewGuard returns a guard for the given limits, which may be nil.
+func NewGuard(opts *config.LimitOptions) *Guard {
+	return &Guard{opts: opts}
+}
+
+// Enabled reports whether any limit is set.
+func (g *Guard) Enabled() bool {
+	return g.opts != nil && (g.opts.Run != nil || g.opts.Session != nil)
+}
+
+// Check returns the request to ask for the first limit reached by the run or
+// the session. It reports false when no limit is reached.
+func (g *Guard) Check(sessionID string, run, session Usage) (Request, bool) {
+	if !g.Enabled() {
+		return Request{}, false
+	}
+	if limit := Reached(g.opts.Run, run.sub(g.run)); limit != "" {
+		return Request{SessionID: sessionID, Scope: ScopeRun, Limit: limit, Usage: run}, true
+	}
+	if limit := Reached(g.opts.Session, session.sub(g.session)); limit != "" {
+		return Request{SessionID: sessionID, Scope: ScopeSession, Limit: limit, Usage: session}, true
+	}
+	return Request{}, false
+}
+
+// Continue makes the limits count from the given usage.
+func (g *Guard) Continue(run, session Usage) {
+	g.run = run
+	g.session = session
+}
+
+// Request asks the user whether a run that reached a limit goes on.
+type Request struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	Scope     Scope  `json:"scope"`
+	// Limit is the limit that was reached, e.g. "25 tool iterations".
+	Limit string `json:"limit"`
+	// Usage is what the run or the session used.
+	Usage Usage `json:"usage"`
+}
+
+func (r Request) String() string {
+	return fmt.Sprintf("%s limit of %s", r.Scope, r.Limit)
+}
+
+type Service interface {
+	pubsub.Suscriber[Request]
+	// Ask publishes the request and waits for the user to answer it. It
+	// reports whether the run continues.
+	Ask(ctx context.Context, req Request) bool
+	Continue(id string)
+	Stop(id string)
+	// SetAutoStop makes Ask stop runs without asking, for runs without a
+	// user to ask.
+	SetAutoStop(stop bool)
+}
+
+type service struct {
+	*pubsub.Broker[Request]
+	pending  *csync.Map[string, chan bool]
+	autoStop atomic.Bool
+}
+
+func NewService() Service {
+	return &service{
+		Broker:  pubsub.NewBroker[Request](),
+		pending: csync.NewMap[string, chan bool](),
+	}
+}
+
+func (s *service) Ask(ctx context.Context, req Request) bool {
+	if s.autoStop.Load() {
+		return false
+	}
+	req.ID = uuid.New().String()
+	respCh := make(chan bool, 1)
+	s.pending.Set(req.ID, respCh)
+	defer s.pending.Del(req.ID)
+
+	s.Publish(pubsub.CreatedEvent, req)
+	select {
+	case resume := <-respCh:
+		return resume
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *service) Continue(id string) {
+	s.answer(id, true)
+}
+
+func (s *service) Stop(id string) {
+	s.answer(id, false)
+}
+
+func (s *service) answer(id string, resume bool) {
+	if respCh, ok := s.pending.Get(id); ok {
+		respCh <- resume
+	}
+}
+
+func (s *service) SetAutoStop(stop bool) {
+	s.autoStop.Store(stop)
+}
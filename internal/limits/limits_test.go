@@ -0,0 +1,49 @@
+package limits
+
+import (
+	"testing"
+
+	"github.com/chasedut/toke/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuard(t *testing.T) {
+	g := NewGuard(&config.LimitOptions{
+		Run:     &config.Limits{ToolIterations: 3},
+		Session: &config.Limits{Cost: 2},
+	})
+
+	_, reached := g.Check("s", Usage{ToolIterations: 2}, Usage{Cost: 1})
+	require.False(t, reached)
+
+	req, reached := g.Check("s", Usage{ToolIterations: 3}, Usage{Cost: 1})
+	require.True(t, reached)
+	require.Equal(t, ScopeRun, req.Scope)
+	require.Equal(t, "run limit of 3 tool iterations", req.String())
+
+	req, reached = g.Check("s", Usage{ToolIterations: 1}, Usage{Cost: 2.5})
+	require.True(t, reached)
+	require.Equal(t, ScopeSession, req.Scope)
+	require.Equal(t, "$2.00", req.Limit)
+
+	g.Continue(Usage{ToolIterations: 3}, Usage{Cost: 2.5})
+	_, reached = g.Check("s", Usage{ToolIterations: 5}, Usage{Cost: 4})
+	require.False(t, reached, "limits count from where the user continued")
+	_, reached = g.Check("s", Usage{ToolIterations: 6}, Usage{Cost: 4})
+	require.True(t, reached)
+}
+
+func TestAsk(t *testing.T) {
+	ctx := t.Context()
+	s := NewService()
+	requests := s.Subscribe(ctx)
+
+	done := make(chan bool)
+	go func() { done <- s.Ask(ctx, Request{SessionID: "s"}) }()
+	event := <-requests
+	s.Continue(event.Payload.ID)
+	require.True(t, <-done)
+
+	s.SetAutoStop(true)
+	require.False(t, s.Ask(ctx, Request{SessionID: "s"}))
+}
@@ -2,27 +2,32 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"path/filepath"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/chasedut/toke/internal/audit"
 	"github.com/chasedut/toke/internal/config"
 	"github.com/chasedut/toke/internal/csync"
+	"github.com/chasedut/toke/internal/fsext"
 	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/limits"
 	"github.com/chasedut/toke/internal/llm/prompt"
 	"github.com/chasedut/toke/internal/llm/provider"
 	"github.com/chasedut/toke/internal/llm/tools"
 	"github.com/chasedut/toke/internal/log"
-	"github.com/chasedut/toke/internal/lsp"
 	"github.com/chasedut/toke/internal/message"
 	"github.com/chasedut/toke/internal/permission"
 	"github.com/chasedut/toke/internal/pubsub"
 	"github.com/chasedut/toke/internal/session"
 	"github.com/chasedut/toke/internal/shell"
+	"github.com/chasedut/toke/internal/usage"
 )
 
 // Common errors
@@ -31,6 +36,14 @@ var (
 	ErrSessionBusy      = errors.New("session is currently processing another request")
 )
 
+type authorKey struct{}
+
+// WithAuthor records author as the author of the user message created by Run
+// with the returned context, e.g. the buddy who proposed the prompt.
+func WithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
 type AgentEventType string
 
 const (
@@ -67,12 +80,20 @@ type agent struct {
 	agentCfg config.Agent
 	sessions session.Service
 	messages message.Service
+	auditLog audit.Service
+	usage    usage.Service
+	limits   limits.Service
 	mcpTools []McpTool
 
+	// workingDir is the directory the agent's tools operate in.
+	workingDir string
+
 	tools *csync.LazySlice[tools.BaseTool]
 
 	provider   provider.Provider
 	providerID string
+	// fallbacks are tried in order for a turn the provider fails to answer.
+	fallbacks []candidate
 
 	titleProvider       provider.Provider
 	summarizeProvider   provider.Provider
@@ -81,11 +102,6 @@ type agent struct {
 	activeRequests *csync.Map[string, context.CancelFunc]
 }
 
-var agentPromptMap = map[string]prompt.PromptID{
-	"coder": prompt.PromptCoder,
-	"task":  prompt.PromptTask,
-}
-
 func NewAgent(
 	ctx context.Context,
 	agentCfg config.Agent,
@@ -94,17 +110,23 @@ func NewAgent(
 	sessions session.Service,
 	messages message.Service,
 	history history.Service,
-	lspClients map[string]*lsp.Client,
+	auditLog audit.Service,
+	usage usage.Service,
+	limits limits.Service,
+	lspClients tools.LSPClients,
+	workingDir string,
 ) (Service, error) {
 	cfg := config.Get()
 
+	lspClients = allowedLSPClients(agentCfg, lspClients)
+
 	var agentTool tools.BaseTool
-	if agentCfg.ID == "coder" {
-		taskAgentCfg := config.Get().Agents["task"]
+	if agentCfg.ID != config.AgentTask && toolAllowed(agentCfg, AgentToolName) {
+		taskAgentCfg := config.Get().Agents[config.AgentTask]
 		if taskAgentCfg.ID == "" {
 			return nil, fmt.Errorf("task agent not found in config")
 		}
-		taskAgent, err := NewAgent(ctx, taskAgentCfg, permissions, sessions, messages, history, lspClients)
+		taskAgent, err := NewAgent(ctx, taskAgentCfg, permissions, sessions, messages, history, auditLog, usage, limits, lspClients, workingDir)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create task agent: %w", err)
 		}
@@ -122,19 +144,21 @@ func NewAgent(
 		return nil, fmt.Errorf("model not found for agent %s", agentCfg.Name)
 	}
 
-	promptID := agentPromptMap[agentCfg.ID]
-	if promptID == "" {
-		promptID = prompt.PromptDefault
+	systemMessage, err := systemPrompt(agentCfg, providerCfg.ID, workingDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt for agent %s: %w", agentCfg.Name, err)
 	}
 	opts := []provider.ProviderClientOption{
 		provider.WithModel(agentCfg.Model),
-		provider.WithSystemMessage(prompt.GetPrompt(promptID, providerCfg.ID, config.Get().Options.ContextPaths...)),
+		provider.WithSystemMessage(systemMessage),
 	}
 	agentProvider, err := provider.NewProvider(*providerCfg, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	fallbacks := newFallbacks(cfg, agentCfg, workingDir)
+
 	smallModelCfg := cfg.Models[config.SelectedModelTypeSmall]
 	var smallModelProviderCfg *config.ProviderConfig
 	if smallModelCfg.Provider == providerCfg.ID {
@@ -175,15 +199,18 @@ func NewAgent(
 			slog.Info("Initialized agent tools", "agent", agentCfg.ID)
 		}()
 
-		cwd := cfg.WorkingDir()
+		cwd := workingDir
 		allTools := []tools.BaseTool{
-			tools.NewBashTool(permissions, cwd),
+			tools.NewBashTool(permissions, cwd, newSandbox(cfg, cwd)),
 			tools.NewDownloadTool(permissions, cwd),
 			tools.NewEditTool(lspClients, permissions, history, cwd),
 			tools.NewMultiEditTool(lspClients, permissions, history, cwd),
 			tools.NewFetchTool(permissions, cwd),
 			tools.NewGlobTool(cwd),
 			tools.NewGrepTool(cwd),
+			tools.NewJobKillTool(cwd),
+			tools.NewJobOutputTool(cwd),
+			tools.NewJobStatusTool(cwd),
 			tools.NewLsTool(permissions, cwd),
 			tools.NewSourcegraphTool(),
 			tools.NewViewTool(lspClients, permissions, cwd),
@@ -193,9 +220,9 @@ func NewAgent(
 		mcpToolsOnce.Do(func() {
 			mcpTools = doGetMCPTools(ctx, permissions, cfg)
 		})
-		allTools = append(allTools, mcpTools...)
+		allTools = append(allTools, allowedMCPTools(agentCfg, mcpTools)...)
 
-		if len(lspClients) > 0 {
+		if usesLSP(cfg, agentCfg) {
 			allTools = append(allTools, tools.NewDiagnosticsTool(lspClients))
 		}
 
@@ -219,10 +246,15 @@ func NewAgent(
 	return &agent{
 		Broker:              pubsub.NewBroker[AgentEvent](),
 		agentCfg:            agentCfg,
+		workingDir:          workingDir,
 		provider:            agentProvider,
 		providerID:          string(providerCfg.ID),
+		fallbacks:           fallbacks,
 		messages:            messages,
 		sessions:            sessions,
+		auditLog:            auditLog,
+		usage:               usage,
+		limits:              limits,
 		titleProvider:       titleProvider,
 		summarizeProvider:   summarizeProvider,
 		summarizeProviderID: string(providerCfg.ID),
@@ -231,6 +263,41 @@ func NewAgent(
 	}, nil
 }
 
+// candidate is a provider a turn can be sent to.
+type candidate struct {
+	provider.Provider
+	id string
+}
+
+// newFallbacks creates the providers of the fallback models configured for
+// the model type of the agent. Fallbacks that cannot be used are skipped.
+func newFallbacks(cfg *config.Config, agentCfg config.Agent, workingDir string) []candidate {
+	var fallbacks []candidate
+	for _, model := range cfg.Fallbacks[agentCfg.Model] {
+		providerCfg, ok := cfg.Providers.Get(model.Provider)
+		if !ok || providerCfg.Disable || cfg.GetModel(model.Provider, model.Model) == nil {
+			slog.Warn("Fallback model not found", "provider", model.Provider, "model", model.Model)
+			continue
+		}
+		systemMessage, err := systemPrompt(agentCfg, providerCfg.ID, workingDir)
+		if err != nil {
+			slog.Warn("Failed to load prompt for fallback", "provider", model.Provider, "error", err)
+			continue
+		}
+		p, err := provider.NewProvider(providerCfg,
+			provider.WithModel(agentCfg.Model),
+			provider.WithSelectedModel(model),
+			provider.WithSystemMessage(systemMessage),
+		)
+		if err != nil {
+			slog.Warn("Failed to create fallback provider", "provider", model.Provider, "error", err)
+			continue
+		}
+		fallbacks = append(fallbacks, candidate{Provider: p, id: providerCfg.ID})
+	}
+	return fallbacks
+}
+
 func (a *agent) Model() catwalk.Model {
 	return *config.Get().GetModelByType(a.agentCfg.Model)
 }
@@ -329,6 +396,11 @@ func (a *agent) Run(ctx context.Context, sessionID string, content string, attac
 	if a.IsSessionBusy(sessionID) {
 		return nil, ErrSessionBusy
 	}
+	if a.usage != nil {
+		if err := a.usage.CheckBudget(ctx); err != nil {
+			return nil, err
+		}
+	}
 
 	genCtx, cancel := context.WithCancel(ctx)
 
@@ -378,6 +450,14 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	if err != nil {
 		return a.err(fmt.Errorf("failed to get session: %w", err))
 	}
+	// The session limits also count the tool iterations before this run.
+	guard := limits.NewGuard(cfg.Options.Limits)
+	runStart := time.Now()
+	sessionIterations := countToolIterations(msgs)
+	runIterations := 0
+	if err := a.checkLimits(ctx, guard, sessionID, runStart, runIterations, sessionIterations); err != nil {
+		return a.err(err)
+	}
 	if session.SummaryMessageID != "" {
 		summaryMsgInex := -1
 		for i, msg := range msgs {
@@ -422,6 +502,10 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 		if (agentMessage.FinishReason() == message.FinishReasonToolUse) && toolResults != nil {
 			// We are not done, we need to respond with the tool response
 			msgHistory = append(msgHistory, agentMessage, *toolResults)
+			runIterations++
+			if err := a.checkLimits(ctx, guard, sessionID, runStart, runIterations, sessionIterations); err != nil {
+				return a.err(err)
+			}
 			continue
 		}
 		if agentMessage.FinishReason() == "" {
@@ -438,12 +522,63 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	}
 }
 
+// countToolIterations counts the answers of the model that asked for tools.
+func countToolIterations(msgs []message.Message) int {
+	n := 0
+	for _, msg := range msgs {
+		if msg.Role == message.Assistant && msg.FinishReason() == message.FinishReasonToolUse {
+			n++
+		}
+	}
+	return n
+}
+
+// checkLimits pauses a run that reached a limit until the user decides
+// whether it continues. It returns ErrLimitReached when the run stops.
+func (a *agent) checkLimits(ctx context.Context, guard *limits.Guard, sessionID string, runStart time.Time, runIterations, sessionIterations int) error {
+	if !guard.Enabled() {
+		return nil
+	}
+	run := limits.Usage{ToolIterations: runIterations}
+	sess := limits.Usage{ToolIterations: sessionIterations + runIterations}
+	if a.usage != nil {
+		runTotal, err := a.usage.SessionTotal(ctx, sessionID, runStart)
+		if err != nil {
+			return fmt.Errorf("failed to read usage: %w", err)
+		}
+		sessTotal, err := a.usage.SessionTotal(ctx, sessionID, time.Time{})
+		if err != nil {
+			return fmt.Errorf("failed to read usage: %w", err)
+		}
+		run.InputTokens = runTotal.InputTokens + runTotal.CacheCreationTokens + runTotal.CacheReadTokens
+		run.OutputTokens = runTotal.OutputTokens
+		run.Cost = runTotal.Cost
+		sess.InputTokens = sessTotal.InputTokens + sessTotal.CacheCreationTokens + sessTotal.CacheReadTokens
+		sess.OutputTokens = sessTotal.OutputTokens
+		sess.Cost = sessTotal.Cost
+	}
+	req, reached := guard.Check(sessionID, run, sess)
+	if !reached {
+		return nil
+	}
+	if a.limits == nil || !a.limits.Ask(ctx, req) {
+		if ctx.Err() != nil {
+			return ErrRequestCancelled
+		}
+		return fmt.Errorf("%w: %s", limits.ErrLimitReached, req)
+	}
+	guard.Continue(run, sess)
+	return nil
+}
+
 func (a *agent) createUserMessage(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) (message.Message, error) {
 	parts := []message.ContentPart{message.TextContent{Text: content}}
 	parts = append(parts, attachmentParts...)
+	author, _ := ctx.Value(authorKey{}).(string)
 	return a.messages.Create(ctx, sessionID, message.CreateMessageParams{
-		Role:  message.User,
-		Parts: parts,
+		Role:   message.User,
+		Parts:  parts,
+		Author: author,
 	})
 }
 
@@ -462,25 +597,32 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 	}
 
 	// Now collect tools (which may block on MCP initialization)
-	eventChan := a.provider.StreamResponse(ctx, msgHistory, slices.Collect(a.tools.Seq()))
+	agentTools := slices.Collect(a.tools.Seq())
 
 	// Add the session and message ID into the context if needed by tools.
 	ctx = context.WithValue(ctx, tools.MessageIDContextKey, assistantMsg.ID)
 
-	// Process each event in the stream.
-	for event := range eventChan {
-		if processErr := a.processEvent(ctx, sessionID, &assistantMsg, event); processErr != nil {
-			if errors.Is(processErr, context.Canceled) {
-				a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-			} else {
-				a.finishMessage(ctx, &assistantMsg, message.FinishReasonError, "API Error", processErr.Error())
-			}
-			return assistantMsg, nil, processErr
+	current := candidate{Provider: a.provider, id: a.providerID}
+	err = a.streamEvents(ctx, sessionID, &assistantMsg, current, msgHistory, agentTools)
+	for _, next := range a.fallbacks {
+		// Only switch before anything was answered, the turn starts over.
+		if !provider.ShouldFallback(err) || assistantMsg.Content().Text != "" || len(assistantMsg.ToolCalls()) > 0 {
+			break
 		}
-		if ctx.Err() != nil {
+		slog.Warn("Falling back to another provider", "from", current.id, "to", next.id, "model", next.Model().ID, "error", err)
+		current = next
+		assistantMsg.Parts = []message.ContentPart{}
+		assistantMsg.Model = current.Model().ID
+		assistantMsg.Provider = current.id
+		err = a.streamEvents(ctx, sessionID, &assistantMsg, current, msgHistory, agentTools)
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) || ctx.Err() != nil {
 			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-			return assistantMsg, nil, ctx.Err()
+		} else {
+			a.finishMessage(ctx, &assistantMsg, message.FinishReasonError, "API Error", err.Error())
 		}
+		return assistantMsg, nil, err
 	}
 
 	toolResults := make([]message.ToolResult, len(assistantMsg.ToolCalls()))
@@ -525,6 +667,7 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 			}
 			resultChan := make(chan toolExecResult, 1)
 
+			start := time.Now()
 			go func() {
 				response, err := tool.Run(ctx, tools.ToolCall{
 					ID:    toolCall.ID,
@@ -553,6 +696,7 @@ func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msg
 				toolResponse = result.response
 				toolErr = result.err
 			}
+			a.recordToolCall(ctx, sessionID, assistantMsg.ID, toolCall, toolResponse, toolErr, time.Since(start))
 
 			if toolErr != nil {
 				slog.Error("Tool execution error", "toolCall", toolCall.ID, "error", toolErr)
@@ -601,12 +745,106 @@ out:
 	return assistantMsg, &msg, err
 }
 
+// newSandbox returns the sandbox configured for bash commands run in
+// workingDir, or nil when sandboxing is disabled.
+func newSandbox(cfg *config.Config, workingDir string) *shell.Sandbox {
+	opts := cfg.Options.Sandbox
+	if opts == nil || !opts.Enabled {
+		return nil
+	}
+	writable := []string{workingDir}
+	if workingDir != cfg.WorkingDir() {
+		// Sessions in a worktree commit to the repository they came from.
+		writable = append(writable, filepath.Join(cfg.WorkingDir(), ".git"))
+	}
+	for _, p := range opts.WritablePaths {
+		expanded, err := fsext.Expand(p)
+		if err != nil {
+			slog.Warn("Ignoring invalid sandbox writable path", "path", p, "error", err)
+			continue
+		}
+		if !filepath.IsAbs(expanded) {
+			expanded = filepath.Join(workingDir, expanded)
+		}
+		writable = append(writable, expanded)
+	}
+	return &shell.Sandbox{
+		WritableDirs: writable,
+		Network:      opts.Network,
+		CPUTime:      time.Duration(opts.CPUSeconds) * time.Second,
+		Memory:       int64(opts.MemoryMB) << 20,
+		Timeout:      time.Duration(opts.TimeoutSeconds) * time.Second,
+	}
+}
+
+// recordToolCall adds a tool call to the audit log.
+func (a *agent) recordToolCall(ctx context.Context, sessionID, messageID string, call message.ToolCall, response tools.ToolResponse, err error, duration time.Duration) {
+	if a.auditLog == nil {
+		return
+	}
+	entry := audit.Entry{
+		SessionID:  sessionID,
+		MessageID:  messageID,
+		ToolCallID: call.ID,
+		Kind:       audit.KindTool,
+		ToolName:   call.Name,
+		Input:      call.Input,
+		Output:     response.Content,
+		IsError:    response.IsError,
+		Duration:   duration,
+	}
+	if err != nil {
+		entry.Output = err.Error()
+		entry.IsError = true
+	}
+	if call.Name == tools.BashToolName && response.Metadata != "" {
+		var metadata tools.BashResponseMetadata
+		if json.Unmarshal([]byte(response.Metadata), &metadata) == nil {
+			entry.ExitCode = &metadata.ExitCode
+		}
+	}
+	if err := a.auditLog.Record(context.WithoutCancel(ctx), entry); err != nil {
+		slog.Error("Failed to record tool call", "tool", call.Name, "error", err)
+	}
+}
+
+// streamEvents sends the history to a provider and processes the events of
+// the response into the assistant message.
+func (a *agent) streamEvents(ctx context.Context, sessionID string, assistantMsg *message.Message, p candidate, msgHistory []message.Message, agentTools []tools.BaseTool) error {
+	eventChan := p.StreamResponse(ctx, historyFor(p.id, msgHistory), agentTools)
+	for event := range eventChan {
+		if err := a.processEvent(ctx, sessionID, assistantMsg, p, event); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// historyFor converts the history for a provider. Reasoning is signed by
+// the provider that produced it, so other providers get messages without it.
+func historyFor(providerID string, msgs []message.Message) []message.Message {
+	converted := make([]message.Message, len(msgs))
+	for i, msg := range msgs {
+		if msg.Role == message.Assistant && msg.Provider != "" && msg.Provider != providerID {
+			msg.Parts = slices.DeleteFunc(slices.Clone(msg.Parts), func(part message.ContentPart) bool {
+				_, ok := part.(message.ReasoningContent)
+				return ok
+			})
+		}
+		converted[i] = msg
+	}
+	return converted
+}
+
 func (a *agent) finishMessage(ctx context.Context, msg *message.Message, finishReason message.FinishReason, message, details string) {
 	msg.AddFinish(finishReason, message, details)
 	_ = a.messages.Update(ctx, *msg)
 }
 
-func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg *message.Message, event provider.ProviderEvent) error {
+func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg *message.Message, p candidate, event provider.ProviderEvent) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -646,13 +884,13 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 		if err := a.messages.Update(ctx, *assistantMsg); err != nil {
 			return fmt.Errorf("failed to update message: %w", err)
 		}
-		return a.TrackUsage(ctx, sessionID, a.Model(), event.Response.Usage)
+		return a.TrackUsage(ctx, sessionID, assistantMsg.ID, p.Model(), p.id, event.Response.Usage)
 	}
 
 	return nil
 }
 
-func (a *agent) TrackUsage(ctx context.Context, sessionID string, model catwalk.Model, usage provider.TokenUsage) error {
+func (a *agent) TrackUsage(ctx context.Context, sessionID, messageID string, model catwalk.Model, providerID string, usage provider.TokenUsage) error {
 	sess, err := a.sessions.Get(ctx, sessionID)
 	if err != nil {
 		return fmt.Errorf("failed to get session: %w", err)
@@ -671,9 +909,31 @@ func (a *agent) TrackUsage(ctx context.Context, sessionID string, model catwalk.
 	if err != nil {
 		return fmt.Errorf("failed to save session: %w", err)
 	}
+	a.recordUsage(ctx, sessionID, messageID, model.ID, providerID, usage, cost)
 	return nil
 }
 
+// recordUsage adds the usage of a request to the usage log.
+func (a *agent) recordUsage(ctx context.Context, sessionID, messageID, model, providerID string, tokens provider.TokenUsage, cost float64) {
+	if a.usage == nil {
+		return
+	}
+	err := a.usage.Record(context.WithoutCancel(ctx), usage.Record{
+		SessionID:           sessionID,
+		MessageID:           messageID,
+		Model:               model,
+		Provider:            providerID,
+		InputTokens:         tokens.InputTokens,
+		OutputTokens:        tokens.OutputTokens,
+		CacheCreationTokens: tokens.CacheCreationTokens,
+		CacheReadTokens:     tokens.CacheReadTokens,
+		Cost:                cost,
+	})
+	if err != nil {
+		slog.Error("Failed to record usage", "error", err)
+	}
+}
+
 func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 	if a.summarizeProvider == nil {
 		return fmt.Errorf("summarize provider not available")
@@ -777,7 +1037,7 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 			a.Publish(pubsub.CreatedEvent, event)
 			return
 		}
-		shell := shell.GetPersistentShell(config.Get().WorkingDir())
+		shell := shell.GetPersistentShell(a.workingDir)
 		summary += "\n\n**Current working directory of the persistent shell**\n\n" + shell.GetWorkingDir()
 		event = AgentEvent{
 			Type:     AgentEventTypeSummarize,
@@ -829,6 +1089,7 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 			model.CostPer1MIn/1e6*float64(usage.InputTokens) +
 			model.CostPer1MOut/1e6*float64(usage.OutputTokens)
 		oldSession.Cost += cost
+		a.recordUsage(summarizeCtx, oldSession.ID, msg.ID, model.ID, a.summarizeProviderID, usage, cost)
 		_, err = a.sessions.Save(summarizeCtx, oldSession)
 		if err != nil {
 			event = AgentEvent{
@@ -888,14 +1149,14 @@ func (a *agent) UpdateModel() error {
 			return fmt.Errorf("model not found for agent %s", a.agentCfg.Name)
 		}
 
-		promptID := agentPromptMap[a.agentCfg.ID]
-		if promptID == "" {
-			promptID = prompt.PromptDefault
+		systemMessage, err := systemPrompt(a.agentCfg, currentProviderCfg.ID, a.workingDir)
+		if err != nil {
+			return fmt.Errorf("failed to load prompt for agent %s: %w", a.agentCfg.Name, err)
 		}
 
 		opts := []provider.ProviderClientOption{
 			provider.WithModel(a.agentCfg.Model),
-			provider.WithSystemMessage(prompt.GetPrompt(promptID, currentProviderCfg.ID, cfg.Options.ContextPaths...)),
+			provider.WithSystemMessage(systemMessage),
 		}
 
 		newProvider, err := provider.NewProvider(*currentProviderCfg, opts...)
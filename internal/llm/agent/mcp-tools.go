@@ -144,6 +144,7 @@ func (b *McpTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolRes
 	p := b.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
+			MessageID:   messageID,
 			ToolCallID:  params.ID,
 			Path:        b.workingDir,
 			ToolName:    b.Info().Name,
@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/chasedut/toke/internal/audit"
+	"github.com/chasedut/toke/internal/config"
+	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/limits"
+	"github.com/chasedut/toke/internal/llm/prompt"
+	"github.com/chasedut/toke/internal/llm/tools"
+	"github.com/chasedut/toke/internal/lsp"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/permission"
+	"github.com/chasedut/toke/internal/session"
+	"github.com/chasedut/toke/internal/usage"
+)
+
+// Workspace is a working tree other than the project directory that agents
+// can be bound to, such as a session's git worktree.
+type Workspace struct {
+	Dir string
+	// LSPClients returns the LSP clients started for the workspace so far.
+	LSPClients tools.LSPClients
+}
+
+// Registry creates agents from the definitions in the config on first use
+// and keeps them around for the lifetime of the app, or of the workspace
+// they are bound to.
+type Registry struct {
+	ctx         context.Context
+	permissions permission.Service
+	sessions    session.Service
+	messages    message.Service
+	history     history.Service
+	auditLog    audit.Service
+	usage       usage.Service
+	limits      limits.Service
+	lspClients  tools.LSPClients
+
+	// onCreate is called once for every agent the registry creates, with
+	// the key it is stored under.
+	onCreate func(key string, agent Service)
+
+	mu     sync.Mutex
+	agents map[string]Service
+}
+
+func NewRegistry(
+	ctx context.Context,
+	permissions permission.Service,
+	sessions session.Service,
+	messages message.Service,
+	history history.Service,
+	auditLog audit.Service,
+	usage usage.Service,
+	limits limits.Service,
+	lspClients tools.LSPClients,
+	onCreate func(key string, agent Service),
+) *Registry {
+	return &Registry{
+		ctx:         ctx,
+		permissions: permissions,
+		sessions:    sessions,
+		messages:    messages,
+		history:     history,
+		auditLog:    auditLog,
+		usage:       usage,
+		limits:      limits,
+		lspClients:  lspClients,
+		onCreate:    onCreate,
+		agents:      make(map[string]Service),
+	}
+}
+
+// Get returns the agent with the given ID, creating it if needed.
+func (r *Registry) Get(id string) (Service, error) {
+	return r.GetIn(id, nil)
+}
+
+// GetIn returns the agent with the given ID bound to a workspace, creating it
+// if needed. A nil workspace is the project directory.
+func (r *Registry) GetIn(id string, ws *Workspace) (Service, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := id
+	workingDir := config.Get().WorkingDir()
+	lspClients := r.lspClients
+	if ws != nil {
+		key = id + "@" + ws.Dir
+		workingDir = ws.Dir
+		lspClients = ws.LSPClients
+	}
+	if a, ok := r.agents[key]; ok {
+		return a, nil
+	}
+
+	agentCfg, ok := config.Get().Agents[id]
+	if !ok {
+		return nil, fmt.Errorf("agent %q not found", id)
+	}
+	if agentCfg.Disabled {
+		return nil, fmt.Errorf("agent %q is disabled", id)
+	}
+
+	a, err := NewAgent(r.ctx, agentCfg, r.permissions, r.sessions, r.messages, r.history, r.auditLog, r.usage, r.limits, lspClients, workingDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create agent %s: %w", id, err)
+	}
+	r.agents[key] = a
+	if r.onCreate != nil {
+		r.onCreate(key, a)
+	}
+	return a, nil
+}
+
+// Release cancels and forgets the agents bound to a workspace, once it is
+// gone.
+func (r *Registry) Release(ws *Workspace) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	suffix := "@" + ws.Dir
+	for key, a := range r.agents {
+		if strings.HasSuffix(key, suffix) {
+			a.CancelAll()
+			delete(r.agents, key)
+		}
+	}
+}
+
+// Loaded returns the agents created so far, sorted by ID.
+func (r *Registry) Loaded() []Service {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded := make([]Service, 0, len(r.agents))
+	for _, id := range slices.Sorted(maps.Keys(r.agents)) {
+		loaded = append(loaded, r.agents[id])
+	}
+	return loaded
+}
+
+// IsBusy reports whether any of the loaded agents is processing a request.
+func (r *Registry) IsBusy() bool {
+	for _, a := range r.Loaded() {
+		if a.IsBusy() {
+			return true
+		}
+	}
+	return false
+}
+
+// systemPrompt returns the system prompt for an agent: the contents of its
+// prompt file when it has one, otherwise the built-in prompt for its ID.
+func systemPrompt(agentCfg config.Agent, providerID string, workingDir string) (string, error) {
+	if agentCfg.Prompt != "" {
+		return prompt.CustomPrompt(agentCfg.Prompt, workingDir, agentCfg.ContextPaths...)
+	}
+	promptID := prompt.PromptCoder
+	if agentCfg.ID == config.AgentTask {
+		promptID = prompt.PromptTask
+	}
+	return prompt.GetPromptForDir(promptID, providerID, workingDir, agentCfg.ContextPaths...), nil
+}
+
+func toolAllowed(agentCfg config.Agent, name string) bool {
+	return agentCfg.AllowedTools == nil || slices.Contains(agentCfg.AllowedTools, name)
+}
+
+// allowedMCPTools filters MCP tools by the agent's MCP allow-list. A nil
+// allow-list allows every server; a server mapped to a nil tool list allows
+// all of its tools.
+func allowedMCPTools(agentCfg config.Agent, mcpTools []tools.BaseTool) []tools.BaseTool {
+	if agentCfg.AllowedMCP == nil {
+		return mcpTools
+	}
+	var allowed []tools.BaseTool
+	for _, t := range mcpTools {
+		mcpTool, ok := t.(*McpTool)
+		if !ok {
+			continue
+		}
+		names, ok := agentCfg.AllowedMCP[mcpTool.mcpName]
+		if !ok {
+			continue
+		}
+		if names == nil || slices.Contains(names, mcpTool.tool.Name) {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
+
+// allowedLSPClients filters LSP clients by the agent's LSP allow-list. The
+// clients are filtered on every lookup, so clients that start later are
+// picked up too.
+func allowedLSPClients(agentCfg config.Agent, lspClients tools.LSPClients) tools.LSPClients {
+	if agentCfg.AllowedLSP == nil {
+		return lspClients
+	}
+	return func() map[string]*lsp.Client {
+		allowed := make(map[string]*lsp.Client)
+		for name, client := range lspClients() {
+			if slices.Contains(agentCfg.AllowedLSP, name) {
+				allowed[name] = client
+			}
+		}
+		return allowed
+	}
+}
+
+// usesLSP reports whether the agent is allowed any of the configured LSP
+// servers, running or not.
+func usesLSP(cfg *config.Config, agentCfg config.Agent) bool {
+	for name := range cfg.LSP {
+		if agentCfg.AllowedLSP == nil || slices.Contains(agentCfg.AllowedLSP, name) {
+			return true
+		}
+	}
+	return false
+}
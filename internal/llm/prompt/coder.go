@@ -14,7 +14,7 @@ import (
 	"github.com/chasedut/toke/internal/llm/tools"
 )
 
-func CoderPrompt(p string, contextFiles ...string) string {
+func CoderPrompt(p string, workingDir string, contextFiles ...string) string {
 	var basePrompt string
 
 	basePrompt = string(anthropicCoderPrompt)
@@ -28,11 +28,11 @@ func CoderPrompt(p string, contextFiles ...string) string {
 	if ok, _ := strconv.ParseBool(os.Getenv("TOKE_CODER_V2")); ok {
 		basePrompt = string(coderV2Prompt)
 	}
-	envInfo := getEnvironmentInfo()
+	envInfo := getEnvironmentInfo(workingDir)
 
 	basePrompt = fmt.Sprintf("%s\n\n%s\n%s", basePrompt, envInfo, lspInformation())
 
-	contextContent := getContextFromPaths(config.Get().WorkingDir(), contextFiles)
+	contextContent := getContextFromPaths(workingDir, contextFiles)
 	if contextContent != "" {
 		return fmt.Sprintf("%s\n\n# Project-Specific Context\n Make sure to follow the instructions in the context below\n%s", basePrompt, contextContent)
 	}
@@ -51,8 +51,7 @@ var openaiCoderPrompt []byte
 //go:embed v2.md
 var coderV2Prompt []byte
 
-func getEnvironmentInfo() string {
-	cwd := config.Get().WorkingDir()
+func getEnvironmentInfo(cwd string) string {
 	isGit := isGitRepo(cwd)
 	platform := runtime.GOOS
 	date := time.Now().Format("1/2/2006")
@@ -0,0 +1,33 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chasedut/toke/internal/config"
+)
+
+// CustomPrompt builds a system prompt from a user supplied file, adding the
+// same environment and project context the built-in coder prompt gets.
+// Relative paths are resolved against the project directory; the environment
+// and context files are taken from workingDir.
+func CustomPrompt(path string, workingDir string, contextPaths ...string) (string, error) {
+	path = expandPath(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(config.Get().WorkingDir(), path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt file: %w", err)
+	}
+
+	basePrompt := fmt.Sprintf("%s\n\n%s\n%s", strings.TrimSpace(string(content)), getEnvironmentInfo(workingDir), lspInformation())
+
+	contextContent := getContextFromPaths(workingDir, contextPaths)
+	if contextContent != "" {
+		return fmt.Sprintf("%s\n\n# Project-Specific Context\n Make sure to follow the instructions in the context below\n%s", basePrompt, contextContent), nil
+	}
+	return basePrompt, nil
+}
@@ -22,14 +22,20 @@ const (
 )
 
 func GetPrompt(promptID PromptID, provider string, contextPaths ...string) string {
+	return GetPromptForDir(promptID, provider, config.Get().WorkingDir(), contextPaths...)
+}
+
+// GetPromptForDir is like GetPrompt, but describes the given working
+// directory to the model instead of the project's.
+func GetPromptForDir(promptID PromptID, provider string, workingDir string, contextPaths ...string) string {
 	basePrompt := ""
 	switch promptID {
 	case PromptCoder:
-		basePrompt = CoderPrompt(provider, contextPaths...)
+		basePrompt = CoderPrompt(provider, workingDir, contextPaths...)
 	case PromptTitle:
 		basePrompt = TitlePrompt()
 	case PromptTask:
-		basePrompt = TaskPrompt()
+		basePrompt = TaskPrompt(workingDir)
 	case PromptSummarizer:
 		basePrompt = SummarizerPrompt()
 	default:
@@ -32,14 +32,15 @@ func newBedrockClient(opts providerClientOptions) BedrockClient {
 		}
 	}
 
+	baseModel := opts.model
 	opts.model = func(modelType config.SelectedModelType) catwalk.Model {
-		model := config.Get().GetModelByType(modelType)
+		model := baseModel(modelType)
 
 		// Prefix the model name with region
 		regionPrefix := region[:2]
 		modelName := model.ID
 		model.ID = fmt.Sprintf("%s.%s", regionPrefix, modelName)
-		return *model
+		return model
 	}
 
 	model := opts.model(opts.modelType)
@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/openai/openai-go"
+)
+
+// ErrRetriesExhausted is returned when a rate limited or overloaded request
+// still fails after maxRetries attempts.
+var ErrRetriesExhausted = errors.New("maximum retry attempts reached")
+
+// ShouldFallback reports whether a request that failed with err may succeed
+// with another provider: the retries ran out, the credentials were refused
+// or the conversation does not fit in the context window of the model.
+func ShouldFallback(err error) bool {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrRetriesExhausted):
+		return true
+	}
+
+	var (
+		anthropicErr *anthropic.Error
+		openaiErr    *openai.Error
+		status       int
+	)
+	switch {
+	case errors.As(err, &anthropicErr):
+		status = anthropicErr.StatusCode
+	case errors.As(err, &openaiErr):
+		status = openaiErr.StatusCode
+	}
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return true
+	}
+	return isContextLengthError(err)
+}
+
+// isContextLengthError reports whether err says the prompt is longer than
+// the context window. Providers only tell in the message.
+func isContextLengthError(err error) bool {
+	return contains(err.Error(),
+		"prompt is too long",
+		"context length",
+		"context_length_exceeded",
+		"context window",
+		"context size",
+		"maximum number of tokens",
+	)
+}
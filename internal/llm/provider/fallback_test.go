@@ -0,0 +1,22 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldFallback(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, ShouldFallback(fmt.Errorf("%w for rate limit: %d retries", ErrRetriesExhausted, maxRetries)))
+	require.True(t, ShouldFallback(errors.New("This model's maximum context length is 128000 tokens")))
+	require.True(t, ShouldFallback(errors.New("prompt is too long: 210000 tokens > 200000 maximum")))
+
+	require.False(t, ShouldFallback(nil))
+	require.False(t, ShouldFallback(context.Canceled))
+	require.False(t, ShouldFallback(errors.New("invalid tool schema")))
+}
@@ -2,39 +2,30 @@ package provider
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/chasedut/toke/internal/backend"
 	"github.com/chasedut/toke/internal/config"
 )
 
-// NewLocalProvider creates a provider for local llama.cpp server
-func NewLocalProvider(modelID string, port int, opts ...ProviderClientOption) Provider {
-	// Create provider config for OpenAI-compatible local server
-	cfg := config.ProviderConfig{
-		ID:      "local",
-		Name:    "Local Model",
-		Type:    catwalk.TypeOpenAI, // llama.cpp serves OpenAI-compatible API
-		BaseURL: fmt.Sprintf("http://localhost:%d/v1", port),
-		APIKey:  "", // No API key needed
-		Models: []catwalk.Model{
-			{
-				ID:               modelID,
-				Name:             modelID,
-				ContextWindow:    8192,
-				DefaultMaxTokens: 4096,
-				CostPer1MIn:      0, // Free!
-				CostPer1MOut:     0, // Free!
-			},
-		},
+// NewLocalProvider creates a provider for a model of a local
+// OpenAI-compatible server found by backend.DiscoverServers
+func NewLocalProvider(server backend.LocalServer, modelID string, opts ...ProviderClientOption) (Provider, error) {
+	cfg := config.LocalServerProvider(server)
+	i := slices.IndexFunc(cfg.Models, func(m catwalk.Model) bool { return m.ID == modelID })
+	if i < 0 {
+		return nil, fmt.Errorf("model %s not served by %s", modelID, server.Name)
 	}
-	
+	model := cfg.Models[i]
+
 	// Build provider options
 	clientOptions := providerClientOptions{
 		baseURL: cfg.BaseURL,
 		config:  cfg,
 		apiKey:  "",
 		model: func(tp config.SelectedModelType) catwalk.Model {
-			return cfg.Models[0]
+			return model
 		},
 	}
 	
@@ -47,5 +38,5 @@ func NewLocalProvider(modelID string, port int, opts ...ProviderClientOption) Pr
 	return &baseProvider[OpenAIClient]{
 		options: clientOptions,
 		client:  newOpenAIClient(clientOptions),
-	}
+	}, nil
 }
\ No newline at end of file
@@ -222,12 +222,7 @@ func (o *openaiClient) finishReason(reason string) message.FinishReason {
 
 func (o *openaiClient) preparedParams(messages []openai.ChatCompletionMessageParamUnion, tools []openai.ChatCompletionToolParam) openai.ChatCompletionNewParams {
 	model := o.providerOptions.model(o.providerOptions.modelType)
-	cfg := config.Get()
-
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if o.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := o.providerOptions.modelConfig()
 
 	reasoningEffort := modelConfig.ReasoningEffort
 
@@ -488,7 +483,7 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 
 func (o *openaiClient) shouldRetry(attempts int, err error) (bool, int64, error) {
 	if attempts > maxRetries {
-		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
+		return false, 0, fmt.Errorf("%w for rate limit: %d retries", ErrRetriesExhausted, maxRetries)
 	}
 	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return false, 0, err
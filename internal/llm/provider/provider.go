@@ -67,6 +67,7 @@ type providerClientOptions struct {
 	apiKey             string
 	modelType          config.SelectedModelType
 	model              func(config.SelectedModelType) catwalk.Model
+	selectedModel      *config.SelectedModel
 	disableCache       bool
 	systemMessage      string
 	systemPromptPrefix string
@@ -78,6 +79,18 @@ type providerClientOptions struct {
 
 type ProviderClientOption func(*providerClientOptions)
 
+// modelConfig returns the configuration of the model the client talks to.
+func (o providerClientOptions) modelConfig() config.SelectedModel {
+	if o.selectedModel != nil {
+		return *o.selectedModel
+	}
+	cfg := config.Get()
+	if o.modelType == config.SelectedModelTypeSmall {
+		return cfg.Models[config.SelectedModelTypeSmall]
+	}
+	return cfg.Models[config.SelectedModelTypeLarge]
+}
+
 type ProviderClient interface {
 	send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
 	stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
@@ -121,6 +134,20 @@ func WithModel(model config.SelectedModelType) ProviderClientOption {
 	}
 }
 
+// WithSelectedModel makes the client talk to the given model instead of the
+// one selected for its model type, e.g. to fall back to it.
+func WithSelectedModel(model config.SelectedModel) ProviderClientOption {
+	return func(options *providerClientOptions) {
+		options.selectedModel = &model
+		options.model = func(config.SelectedModelType) catwalk.Model {
+			if m := config.Get().GetModel(model.Provider, model.Model); m != nil {
+				return *m
+			}
+			return catwalk.Model{ID: model.Model, Name: model.Model}
+		}
+	}
+}
+
 func WithDisableCache(disableCache bool) ProviderClientOption {
 	return func(options *providerClientOptions) {
 		options.disableCache = disableCache
@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -12,13 +13,19 @@ import (
 )
 
 type BashParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command         string `json:"command"`
+	Timeout         int    `json:"timeout"`
+	Network         bool   `json:"network,omitempty"`
+	RunInBackground bool   `json:"run_in_background,omitempty"`
 }
 
 type BashPermissionsParams struct {
 	Command string `json:"command"`
 	Timeout int    `json:"timeout"`
+	// Sandbox describes the sandbox the command runs in, if any.
+	Sandbox string `json:"sandbox,omitempty"`
+	// Network is set when a sandboxed command asks for network access.
+	Network bool `json:"network,omitempty"`
 }
 
 type BashResponseMetadata struct {
@@ -26,10 +33,14 @@ type BashResponseMetadata struct {
 	EndTime          int64  `json:"end_time"`
 	Output           string `json:"output"`
 	WorkingDirectory string `json:"working_directory"`
+	ExitCode         int    `json:"exit_code"`
+	// JobID is set for commands run in the background.
+	JobID string `json:"job_id,omitempty"`
 }
 type bashTool struct {
 	permissions permission.Service
 	workingDir  string
+	sandbox     *shell.Sandbox
 }
 
 const (
@@ -152,6 +163,7 @@ Before executing the command, please follow these steps:
 Usage notes:
 - The command argument is required.
 - You can specify an optional timeout in milliseconds (up to 600000ms / 10 minutes). If not specified, commands will timeout after 30 minutes.
+- Set run_in_background to true for commands that run for long or don't end on their own, like dev servers, watchers and long builds. The command runs as a background job and you get its job ID right away. Read its output with the job_output tool, list jobs with job_status and stop them with job_kill. Background jobs don't change the shell's directory or environment. Don't append '&' to commands instead.
 - VERY IMPORTANT: You MUST avoid using search commands like 'find' and 'grep'. Instead use Grep, Glob, or Agent tools to search. You MUST avoid read tools like 'cat', 'head', 'tail', and 'ls', and use FileRead and LS tools to read files.
 - When issuing multiple commands, use the ';' or '&&' operator to separate them. DO NOT use newlines (newlines are ok in quoted strings).
 - IMPORTANT: All commands share the same shell session. Shell state (environment variables, virtual environments, current directory, etc.) persist between commands. For example, if you set an environment variable as part of a command, the environment variable will persist for subsequent commands.
@@ -272,6 +284,19 @@ Important:
 - Never update git config`, bannedCommandsStr, MaxOutputLength)
 }
 
+func sandboxDescription(sandbox *shell.Sandbox) string {
+	network := "The network is not available; set network to true to ask the user for it when a command needs it, e.g. to download dependencies."
+	if sandbox.Network {
+		network = "The network is available."
+	}
+	return fmt.Sprintf(`
+
+SANDBOX:
+* Commands run in a sandbox (%s).
+* Writing outside the writable directories fails with a read-only file system error. Don't try to work around it; tell the user instead.
+* %s`, sandbox, network)
+}
+
 func blockFuncs() []shell.BlockFunc {
 	return []shell.BlockFunc{
 		shell.CommandsBlocker(bannedCommands),
@@ -303,14 +328,18 @@ func blockFuncs() []shell.BlockFunc {
 	}
 }
 
-func NewBashTool(permission permission.Service, workingDir string) BaseTool {
+// NewBashTool returns the bash tool. When sandbox is not nil, commands run
+// inside it.
+func NewBashTool(permission permission.Service, workingDir string, sandbox *shell.Sandbox) BaseTool {
 	// Set up command blocking on the persistent shell
 	persistentShell := shell.GetPersistentShell(workingDir)
 	persistentShell.SetBlockFuncs(blockFuncs())
+	persistentShell.SetSandbox(sandbox)
 
 	return &bashTool{
 		permissions: permission,
 		workingDir:  workingDir,
+		sandbox:     sandbox,
 	}
 }
 
@@ -319,20 +348,35 @@ func (b *bashTool) Name() string {
 }
 
 func (b *bashTool) Info() ToolInfo {
+	parameters := map[string]any{
+		"command": map[string]any{
+			"type":        "string",
+			"description": "The command to execute",
+		},
+		"timeout": map[string]any{
+			"type":        "number",
+			"description": "Optional timeout in milliseconds (max 600000)",
+		},
+		"run_in_background": map[string]any{
+			"type":        "boolean",
+			"description": "Run the command as a background job without a timeout and return its job ID right away",
+		},
+	}
+	description := bashDescription()
+	if b.sandbox != nil {
+		description += sandboxDescription(b.sandbox)
+		if !b.sandbox.Network {
+			parameters["network"] = map[string]any{
+				"type":        "boolean",
+				"description": "Ask the user for network access for this command, which the sandbox blocks otherwise",
+			}
+		}
+	}
 	return ToolInfo{
 		Name:        BashToolName,
-		Description: bashDescription(),
-		Parameters: map[string]any{
-			"command": map[string]any{
-				"type":        "string",
-				"description": "The command to execute",
-			},
-			"timeout": map[string]any{
-				"type":        "number",
-				"description": "Optional timeout in milliseconds (max 600000)",
-			},
-		},
-		Required: []string{"command"},
+		Description: description,
+		Parameters:  parameters,
+		Required:    []string{"command"},
 	}
 }
 
@@ -347,6 +391,17 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	} else if params.Timeout <= 0 {
 		params.Timeout = DefaultTimeout
 	}
+	var sandbox string
+	if b.sandbox != nil {
+		if limit := int(b.sandbox.Timeout.Milliseconds()); limit > 0 && params.Timeout > limit {
+			params.Timeout = limit
+		}
+		sandbox = b.sandbox.String()
+		// Network access is granted per command, so it always needs asking.
+		params.Network = params.Network && !b.sandbox.Network
+	} else {
+		params.Network = false
+	}
 
 	if params.Command == "" {
 		return NewTextErrorResponse("missing command"), nil
@@ -368,17 +423,26 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
 	}
-	if !isSafeReadOnly {
+	if !isSafeReadOnly || params.Network {
+		action, description := "execute", fmt.Sprintf("Execute command: %s", params.Command)
+		if params.Network {
+			// A separate action keeps session grants for commands from
+			// granting network access.
+			action, description = "execute_network", fmt.Sprintf("Execute command with network access: %s", params.Command)
+		}
 		p := b.permissions.Request(
 			permission.CreatePermissionRequest{
 				SessionID:   sessionID,
+				MessageID:   messageID,
 				Path:        b.workingDir,
 				ToolCallID:  call.ID,
 				ToolName:    BashToolName,
-				Action:      "execute",
-				Description: fmt.Sprintf("Execute command: %s", params.Command),
+				Action:      action,
+				Description: description,
 				Params: BashPermissionsParams{
 					Command: params.Command,
+					Sandbox: sandbox,
+					Network: params.Network,
 				},
 			},
 		)
@@ -392,9 +456,18 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		ctx, cancel = context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Millisecond)
 		defer cancel()
 	}
+	if params.Network {
+		ctx = shell.WithNetwork(ctx)
+	}
 
 	persistentShell := shell.GetPersistentShell(b.workingDir)
+	if params.RunInBackground {
+		return b.runInBackground(ctx, persistentShell, sessionID, params.Command, startTime)
+	}
 	stdout, stderr, err := persistentShell.Exec(ctx, params.Command)
+	if errors.Is(err, shell.ErrSandboxUnavailable) {
+		return NewTextErrorResponse(fmt.Sprintf("The command was not run: %s. Ask the user to install bubblewrap or disable the sandbox.", err)), nil
+	}
 
 	// Get the current working directory after command execution
 	currentWorkingDir := persistentShell.GetWorkingDir()
@@ -439,6 +512,7 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		EndTime:          time.Now().UnixMilli(),
 		Output:           stdout,
 		WorkingDirectory: currentWorkingDir,
+		ExitCode:         exitCode,
 	}
 	if stdout == "" {
 		return WithResponseMetadata(NewTextResponse(BashNoOutput), metadata), nil
@@ -447,6 +521,26 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	return WithResponseMetadata(NewTextResponse(stdout), metadata), nil
 }
 
+// runInBackground starts a command as a background job of the shell.
+func (b *bashTool) runInBackground(ctx context.Context, persistentShell *shell.PersistentShell, sessionID, command string, startTime time.Time) (ToolResponse, error) {
+	job, err := persistentShell.Start(ctx, sessionID, command)
+	if errors.Is(err, shell.ErrSandboxUnavailable) {
+		return NewTextErrorResponse(fmt.Sprintf("The command was not run: %s. Ask the user to install bubblewrap or disable the sandbox.", err)), nil
+	}
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("error starting command: %w", err)
+	}
+	info := job.Info()
+	output := fmt.Sprintf("Started %s in the background. Use %s to read its output and status and %s to stop it.", info.ID, JobOutputToolName, JobKillToolName)
+	return WithResponseMetadata(NewTextResponse(output), BashResponseMetadata{
+		StartTime:        startTime.UnixMilli(),
+		EndTime:          time.Now().UnixMilli(),
+		Output:           output,
+		WorkingDirectory: info.Dir,
+		JobID:            info.ID,
+	}), nil
+}
+
 func truncateOutput(content string) string {
 	if len(content) <= MaxOutputLength {
 		return content
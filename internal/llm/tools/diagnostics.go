@@ -18,9 +18,13 @@ type DiagnosticsParams struct {
 	FilePath string `json:"file_path"`
 }
 type diagnosticsTool struct {
-	lspClients map[string]*lsp.Client
+	lspClients LSPClients
 }
 
+// LSPClients returns the running LSP clients. Tools call it on every use, so
+// clients that start after the tools are created are picked up.
+type LSPClients func() map[string]*lsp.Client
+
 const (
 	DiagnosticsToolName    = "diagnostics"
 	diagnosticsDescription = `Get diagnostics for a file and/or project.
@@ -46,7 +50,7 @@ TIPS:
 `
 )
 
-func NewDiagnosticsTool(lspClients map[string]*lsp.Client) BaseTool {
+func NewDiagnosticsTool(lspClients LSPClients) BaseTool {
 	return &diagnosticsTool{
 		lspClients,
 	}
@@ -76,7 +80,7 @@ func (b *diagnosticsTool) Run(ctx context.Context, call ToolCall) (ToolResponse,
 		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
 	}
 
-	lsps := b.lspClients
+	lsps := b.lspClients()
 
 	if len(lsps) == 0 {
 		return NewTextErrorResponse("no LSP clients available"), nil
@@ -140,6 +140,7 @@ func (t *downloadTool) Run(ctx context.Context, call ToolCall) (ToolResponse, er
 	p := t.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
+			MessageID:   messageID,
 			Path:        filePath,
 			ToolName:    DownloadToolName,
 			Action:      "download",
@@ -14,7 +14,6 @@ import (
 	"github.com/chasedut/toke/internal/fsext"
 	"github.com/chasedut/toke/internal/history"
 
-	"github.com/chasedut/toke/internal/lsp"
 	"github.com/chasedut/toke/internal/permission"
 )
 
@@ -39,7 +38,7 @@ type EditResponseMetadata struct {
 }
 
 type editTool struct {
-	lspClients  map[string]*lsp.Client
+	lspClients  LSPClients
 	permissions permission.Service
 	files       history.Service
 	workingDir  string
@@ -103,7 +102,7 @@ WINDOWS NOTES:
 Remember: when making multiple file edits in a row to the same file, you should prefer to send all edits in a single message with multiple calls to this tool, rather than multiple messages with a single call each.`
 )
 
-func NewEditTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+func NewEditTool(lspClients LSPClients, permissions permission.Service, files history.Service, workingDir string) BaseTool {
 	return &editTool{
 		lspClients:  lspClients,
 		permissions: permissions,
@@ -183,9 +182,9 @@ func (e *editTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		return response, nil
 	}
 
-	waitForLspDiagnostics(ctx, params.FilePath, e.lspClients)
+	waitForLspDiagnostics(ctx, params.FilePath, e.lspClients())
 	text := fmt.Sprintf("<result>\n%s\n</result>\n", response.Content)
-	text += getDiagnostics(params.FilePath, e.lspClients)
+	text += getDiagnostics(params.FilePath, e.lspClients())
 	response.Content = text
 	return response, nil
 }
@@ -219,6 +218,7 @@ func (e *editTool) createNewFile(ctx context.Context, filePath, content string,
 	p := e.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
+			MessageID:   messageID,
 			Path:        fsext.PathOrPrefix(filePath, e.workingDir),
 			ToolCallID:  call.ID,
 			ToolName:    EditToolName,
@@ -340,6 +340,7 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 	p := e.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
+			MessageID:   messageID,
 			Path:        fsext.PathOrPrefix(filePath, e.workingDir),
 			ToolCallID:  call.ID,
 			ToolName:    EditToolName,
@@ -471,6 +472,7 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 	p := e.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
+			MessageID:   messageID,
 			Path:        fsext.PathOrPrefix(filePath, e.workingDir),
 			ToolCallID:  call.ID,
 			ToolName:    EditToolName,
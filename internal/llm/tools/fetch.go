@@ -135,6 +135,7 @@ func (t *fetchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	p := t.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
+			MessageID:   messageID,
 			Path:        t.workingDir,
 			ToolCallID:  call.ID,
 			ToolName:    FetchToolName,
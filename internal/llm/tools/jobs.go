@@ -0,0 +1,249 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chasedut/toke/internal/shell"
+)
+
+const (
+	JobOutputToolName    = "job_output"
+	jobOutputDescription = `Reads the output of a background job started with the bash tool's run_in_background parameter.
+
+HOW TO USE:
+- Provide the job ID returned by the bash tool
+- Only the output written since the previous read is returned, followed by the status of the job
+- Set wait to a number of seconds to wait for the job to end first, e.g. for a build to finish
+
+TIPS:
+- Poll this tool instead of sleeping in bash
+- Output beyond 1 MB per job is dropped, oldest first`
+
+	JobStatusToolName    = "job_status"
+	jobStatusDescription = `Lists the background jobs of this session with their status, or shows a single job.
+
+HOW TO USE:
+- Leave job_id empty to list every job started with the bash tool's run_in_background parameter
+- Provide a job ID to see the status of a single job`
+
+	JobKillToolName    = "job_kill"
+	jobKillDescription = `Stops a background job started with the bash tool's run_in_background parameter.
+
+HOW TO USE:
+- Provide the job ID returned by the bash tool
+- Returns the output the job wrote since it was last read`
+
+	// maxJobWait caps how long job_output waits for a job to end.
+	maxJobWait = 10 * time.Minute
+)
+
+type JobOutputParams struct {
+	JobID string `json:"job_id"`
+	Wait  int    `json:"wait"`
+}
+
+type JobStatusParams struct {
+	JobID string `json:"job_id"`
+}
+
+type JobKillParams struct {
+	JobID string `json:"job_id"`
+}
+
+type JobResponseMetadata struct {
+	JobID    string `json:"job_id"`
+	Command  string `json:"command"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+}
+
+type jobOutputTool struct {
+	workingDir string
+}
+
+type jobStatusTool struct {
+	workingDir string
+}
+
+type jobKillTool struct {
+	workingDir string
+}
+
+func NewJobOutputTool(workingDir string) BaseTool {
+	return &jobOutputTool{workingDir: workingDir}
+}
+
+func NewJobStatusTool(workingDir string) BaseTool {
+	return &jobStatusTool{workingDir: workingDir}
+}
+
+func NewJobKillTool(workingDir string) BaseTool {
+	return &jobKillTool{workingDir: workingDir}
+}
+
+// sessionJob returns the job with the given ID if it belongs to the session
+// of the tool call.
+func sessionJob(ctx context.Context, workingDir, id string) (*shell.Job, error) {
+	if id == "" {
+		return nil, fmt.Errorf("job_id is required")
+	}
+	sessionID, _ := GetContextValues(ctx)
+	job, ok := shell.GetPersistentShell(workingDir).Job(id)
+	if !ok || job.Info().SessionID != sessionID {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+	return job, nil
+}
+
+func formatJobStatus(info shell.JobInfo) string {
+	end := info.EndedAt
+	if end.IsZero() {
+		end = time.Now()
+	}
+	elapsed := end.Sub(info.StartedAt).Round(time.Second)
+	switch info.Status {
+	case shell.JobRunning:
+		return fmt.Sprintf("running for %s", elapsed)
+	case shell.JobExited:
+		return fmt.Sprintf("exited with code %d after %s", info.ExitCode, elapsed)
+	default:
+		return fmt.Sprintf("killed after %s", elapsed)
+	}
+}
+
+func jobResponse(job *shell.Job) ToolResponse {
+	output := truncateOutput(job.ReadOutput())
+	info := job.Info()
+	if output == "" {
+		output = "no new output"
+	}
+	text := fmt.Sprintf("%s\n\n<job id=%q status=%q>%s</job>", output, info.ID, info.Status, formatJobStatus(info))
+	return WithResponseMetadata(NewTextResponse(text), JobResponseMetadata{
+		JobID:    info.ID,
+		Command:  info.Command,
+		Status:   string(info.Status),
+		ExitCode: info.ExitCode,
+	})
+}
+
+func (t *jobOutputTool) Name() string {
+	return JobOutputToolName
+}
+
+func (t *jobOutputTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        JobOutputToolName,
+		Description: jobOutputDescription,
+		Parameters: map[string]any{
+			"job_id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the job",
+			},
+			"wait": map[string]any{
+				"type":        "number",
+				"description": "Optional number of seconds to wait for the job to end (max 600)",
+			},
+		},
+		Required: []string{"job_id"},
+	}
+}
+
+func (t *jobOutputTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobOutputParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("invalid parameters"), nil
+	}
+	job, err := sessionJob(ctx, t.workingDir, params.JobID)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if params.Wait > 0 {
+		select {
+		case <-job.Done():
+		case <-time.After(min(time.Duration(params.Wait)*time.Second, maxJobWait)):
+		case <-ctx.Done():
+			return ToolResponse{}, ctx.Err()
+		}
+	}
+	return jobResponse(job), nil
+}
+
+func (t *jobStatusTool) Name() string {
+	return JobStatusToolName
+}
+
+func (t *jobStatusTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        JobStatusToolName,
+		Description: jobStatusDescription,
+		Parameters: map[string]any{
+			"job_id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the job, or empty for every job of the session",
+			},
+		},
+		Required: []string{},
+	}
+}
+
+func (t *jobStatusTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobStatusParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("invalid parameters"), nil
+	}
+	var jobs []*shell.Job
+	if params.JobID != "" {
+		job, err := sessionJob(ctx, t.workingDir, params.JobID)
+		if err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
+		jobs = append(jobs, job)
+	} else {
+		sessionID, _ := GetContextValues(ctx)
+		jobs = shell.GetPersistentShell(t.workingDir).Jobs(sessionID)
+	}
+	if len(jobs) == 0 {
+		return NewTextResponse("No background jobs"), nil
+	}
+	var sb strings.Builder
+	for _, job := range jobs {
+		info := job.Info()
+		fmt.Fprintf(&sb, "%s: %s (%s)\n", info.ID, info.Command, formatJobStatus(info))
+	}
+	return NewTextResponse(strings.TrimSuffix(sb.String(), "\n")), nil
+}
+
+func (t *jobKillTool) Name() string {
+	return JobKillToolName
+}
+
+func (t *jobKillTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        JobKillToolName,
+		Description: jobKillDescription,
+		Parameters: map[string]any{
+			"job_id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the job to stop",
+			},
+		},
+		Required: []string{"job_id"},
+	}
+}
+
+func (t *jobKillTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobKillParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("invalid parameters"), nil
+	}
+	job, err := sessionJob(ctx, t.workingDir, params.JobID)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	job.Kill()
+	return jobResponse(job), nil
+}
@@ -153,6 +153,7 @@ func (l *lsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
 		granted := l.permissions.Request(
 			permission.CreatePermissionRequest{
 				SessionID:   sessionID,
+				MessageID:   messageID,
 				Path:        absSearchPath,
 				ToolCallID:  call.ID,
 				ToolName:    LSToolName,
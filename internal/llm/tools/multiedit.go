@@ -13,7 +13,6 @@ import (
 	"github.com/chasedut/toke/internal/diff"
 	"github.com/chasedut/toke/internal/fsext"
 	"github.com/chasedut/toke/internal/history"
-	"github.com/chasedut/toke/internal/lsp"
 	"github.com/chasedut/toke/internal/permission"
 )
 
@@ -43,7 +42,7 @@ type MultiEditResponseMetadata struct {
 }
 
 type multiEditTool struct {
-	lspClients  map[string]*lsp.Client
+	lspClients  LSPClients
 	permissions permission.Service
 	files       history.Service
 	workingDir  string
@@ -95,7 +94,7 @@ If you want to create a new file, use:
 - Subsequent edits: normal edit operations on the created content`
 )
 
-func NewMultiEditTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+func NewMultiEditTool(lspClients LSPClients, permissions permission.Service, files history.Service, workingDir string) BaseTool {
 	return &multiEditTool{
 		lspClients:  lspClients,
 		permissions: permissions,
@@ -189,9 +188,9 @@ func (m *multiEditTool) Run(ctx context.Context, call ToolCall) (ToolResponse, e
 	}
 
 	// Wait for LSP diagnostics and add them to the response
-	waitForLspDiagnostics(ctx, params.FilePath, m.lspClients)
+	waitForLspDiagnostics(ctx, params.FilePath, m.lspClients())
 	text := fmt.Sprintf("<result>\n%s\n</result>\n", response.Content)
-	text += getDiagnostics(params.FilePath, m.lspClients)
+	text += getDiagnostics(params.FilePath, m.lspClients())
 	response.Content = text
 	return response, nil
 }
@@ -253,6 +252,7 @@ func (m *multiEditTool) processMultiEditWithCreation(ctx context.Context, params
 
 	p := m.permissions.Request(permission.CreatePermissionRequest{
 		SessionID:   sessionID,
+		MessageID:   messageID,
 		Path:        fsext.PathOrPrefix(params.FilePath, m.workingDir),
 		ToolCallID:  call.ID,
 		ToolName:    MultiEditToolName,
@@ -362,6 +362,7 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 	_, additions, removals := diff.GenerateDiff(oldContent, currentContent, strings.TrimPrefix(params.FilePath, m.workingDir))
 	p := m.permissions.Request(permission.CreatePermissionRequest{
 		SessionID:   sessionID,
+		MessageID:   messageID,
 		Path:        fsext.PathOrPrefix(params.FilePath, m.workingDir),
 		ToolCallID:  call.ID,
 		ToolName:    MultiEditToolName,
@@ -11,7 +11,6 @@ import (
 	"strings"
 	"unicode/utf8"
 
-	"github.com/chasedut/toke/internal/lsp"
 	"github.com/chasedut/toke/internal/permission"
 )
 
@@ -28,7 +27,7 @@ type ViewPermissionsParams struct {
 }
 
 type viewTool struct {
-	lspClients  map[string]*lsp.Client
+	lspClients  LSPClients
 	workingDir  string
 	permissions permission.Service
 }
@@ -81,7 +80,7 @@ TIPS:
 - When viewing large files, use the offset parameter to read specific sections`
 )
 
-func NewViewTool(lspClients map[string]*lsp.Client, permissions permission.Service, workingDir string) BaseTool {
+func NewViewTool(lspClients LSPClients, permissions permission.Service, workingDir string) BaseTool {
 	return &viewTool{
 		lspClients:  lspClients,
 		workingDir:  workingDir,
@@ -154,6 +153,7 @@ func (v *viewTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		granted := v.permissions.Request(
 			permission.CreatePermissionRequest{
 				SessionID:   sessionID,
+				MessageID:   messageID,
 				Path:        absFilePath,
 				ToolCallID:  call.ID,
 				ToolName:    ViewToolName,
@@ -233,7 +233,7 @@ func (v *viewTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		return ToolResponse{}, fmt.Errorf("error reading file: %w", err)
 	}
 
-	notifyLspOpenFile(ctx, filePath, v.lspClients)
+	notifyLspOpenFile(ctx, filePath, v.lspClients())
 	output := "<file>\n"
 	// Format the output with line numbers
 	output += addLineNumbers(content, params.Offset+1)
@@ -244,7 +244,7 @@ func (v *viewTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 			params.Offset+len(strings.Split(content, "\n")))
 	}
 	output += "\n</file>\n"
-	output += getDiagnostics(filePath, v.lspClients)
+	output += getDiagnostics(filePath, v.lspClients())
 	recordFileRead(filePath)
 	return WithResponseMetadata(
 		NewTextResponse(output),
@@ -14,7 +14,6 @@ import (
 	"github.com/chasedut/toke/internal/fsext"
 	"github.com/chasedut/toke/internal/history"
 
-	"github.com/chasedut/toke/internal/lsp"
 	"github.com/chasedut/toke/internal/permission"
 )
 
@@ -30,7 +29,7 @@ type WritePermissionsParams struct {
 }
 
 type writeTool struct {
-	lspClients  map[string]*lsp.Client
+	lspClients  LSPClients
 	permissions permission.Service
 	files       history.Service
 	workingDir  string
@@ -78,7 +77,7 @@ TIPS:
 - Always include descriptive comments when making changes to existing code`
 )
 
-func NewWriteTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+func NewWriteTool(lspClients LSPClients, permissions permission.Service, files history.Service, workingDir string) BaseTool {
 	return &writeTool{
 		lspClients:  lspClients,
 		permissions: permissions,
@@ -176,6 +175,7 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	p := w.permissions.Request(
 		permission.CreatePermissionRequest{
 			SessionID:   sessionID,
+			MessageID:   messageID,
 			Path:        fsext.PathOrPrefix(filePath, w.workingDir),
 			ToolCallID:  call.ID,
 			ToolName:    WriteToolName,
@@ -221,11 +221,11 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 
 	recordFileWrite(filePath)
 	recordFileRead(filePath)
-	waitForLspDiagnostics(ctx, filePath, w.lspClients)
+	waitForLspDiagnostics(ctx, filePath, w.lspClients())
 
 	result := fmt.Sprintf("File successfully written: %s", filePath)
 	result = fmt.Sprintf("<result>\n%s\n</result>", result)
-	result += getDiagnostics(filePath, w.lspClients)
+	result += getDiagnostics(filePath, w.lspClients())
 	return WithResponseMetadata(NewTextResponse(result),
 		WriteResponseMetadata{
 			Diff:      diff,
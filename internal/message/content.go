@@ -120,6 +120,9 @@ type Message struct {
 	Parts     []ContentPart
 	Model     string
 	Provider  string
+	// Author is the name of the buddy who proposed the message, empty when
+	// the user wrote it.
+	Author    string
 	CreatedAt int64
 	UpdatedAt int64
 }
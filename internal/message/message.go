@@ -17,16 +17,22 @@ type CreateMessageParams struct {
 	Parts    []ContentPart
 	Model    string
 	Provider string
+	// Author is the name of the buddy who proposed a user message.
+	Author string
 }
 
 type Service interface {
 	pubsub.Suscriber[Message]
 	Create(ctx context.Context, sessionID string, params CreateMessageParams) (Message, error)
+	// Import adds a message from another session or machine to the session,
+	// under a new ID but with its parts and timestamps unchanged.
+	Import(ctx context.Context, sessionID string, message Message) (Message, error)
 	Update(ctx context.Context, message Message) error
 	Get(ctx context.Context, id string) (Message, error)
 	List(ctx context.Context, sessionID string) ([]Message, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionMessages(ctx context.Context, sessionID string) error
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
 }
 
 type service struct {
@@ -71,6 +77,7 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 		Parts:     string(partsJSON),
 		Model:     sql.NullString{String: string(params.Model), Valid: true},
 		Provider:  sql.NullString{String: params.Provider, Valid: params.Provider != ""},
+		Author:    params.Author,
 	})
 	if err != nil {
 		return Message{}, err
@@ -83,6 +90,39 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 	return message, nil
 }
 
+func (s *service) Import(ctx context.Context, sessionID string, message Message) (Message, error) {
+	partsJSON, err := marshallParts(message.Parts)
+	if err != nil {
+		return Message{}, err
+	}
+	finishedAt := sql.NullInt64{}
+	if f := message.FinishPart(); f != nil {
+		finishedAt.Int64 = f.Time
+		finishedAt.Valid = true
+	}
+	dbMessage, err := s.q.ImportMessage(ctx, db.ImportMessageParams{
+		ID:         uuid.New().String(),
+		SessionID:  sessionID,
+		Role:       string(message.Role),
+		Parts:      string(partsJSON),
+		Model:      sql.NullString{String: message.Model, Valid: true},
+		Provider:   sql.NullString{String: message.Provider, Valid: message.Provider != ""},
+		Author:     message.Author,
+		CreatedAt:  message.CreatedAt,
+		UpdatedAt:  message.UpdatedAt,
+		FinishedAt: finishedAt,
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	message, err = s.fromDBItem(dbMessage)
+	if err != nil {
+		return Message{}, err
+	}
+	s.Publish(pubsub.CreatedEvent, message)
+	return message, nil
+}
+
 func (s *service) DeleteSessionMessages(ctx context.Context, sessionID string) error {
 	messages, err := s.List(ctx, sessionID)
 	if err != nil {
@@ -113,6 +153,8 @@ func (s *service) Update(ctx context.Context, message Message) error {
 		ID:         message.ID,
 		Parts:      string(parts),
 		FinishedAt: finishedAt,
+		Model:      sql.NullString{String: message.Model, Valid: true},
+		Provider:   sql.NullString{String: message.Provider, Valid: message.Provider != ""},
 	})
 	if err != nil {
 		return err
@@ -157,6 +199,7 @@ func (s *service) fromDBItem(item db.Message) (Message, error) {
 		Parts:     parts,
 		Model:     item.Model.String,
 		Provider:  item.Provider.String,
+		Author:    item.Author,
 		CreatedAt: item.CreatedAt,
 		UpdatedAt: item.UpdatedAt,
 	}, nil
@@ -179,6 +222,17 @@ type partWrapper struct {
 	Data ContentPart `json:"data"`
 }
 
+// MarshalParts encodes content parts in the same tagged format used for
+// storage, so they can be decoded again with UnmarshalParts.
+func MarshalParts(parts []ContentPart) ([]byte, error) {
+	return marshallParts(parts)
+}
+
+// UnmarshalParts decodes content parts encoded with MarshalParts.
+func UnmarshalParts(data []byte) ([]ContentPart, error) {
+	return unmarshallParts(data)
+}
+
 func marshallParts(parts []ContentPart) ([]byte, error) {
 	wrappedParts := make([]partWrapper, len(parts))
 
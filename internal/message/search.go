@@ -0,0 +1,105 @@
+package message
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/chasedut/toke/internal/db"
+)
+
+// Snippets of search results wrap matches in MatchStart and MatchEnd.
+const (
+	MatchStart = "\x02"
+	MatchEnd   = "\x03"
+)
+
+// SearchResult is a message that matches a search.
+type SearchResult struct {
+	MessageID string
+	// SessionID is the session the message is shown in. Messages of
+	// sub-agents are shown in the session that ran them, under the tool call
+	// ToolCallID.
+	SessionID    string
+	ToolCallID   string
+	SessionTitle string
+	Role         MessageRole
+	CreatedAt    int64
+	// Snippet is the text around the matches, which are wrapped in MatchStart
+	// and MatchEnd.
+	Snippet string
+}
+
+// HighlightSnippet returns the snippet on a single line, with its matches
+// passed through highlight.
+func (r SearchResult) HighlightSnippet(highlight func(string) string) string {
+	var b strings.Builder
+	rest := strings.Join(strings.Fields(r.Snippet), " ")
+	for {
+		start := strings.Index(rest, MatchStart)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start:], MatchEnd)
+		if end < 0 {
+			break
+		}
+		b.WriteString(rest[:start])
+		b.WriteString(highlight(rest[start+len(MatchStart) : start+end]))
+		rest = rest[start+end+len(MatchEnd):]
+	}
+	b.WriteString(strings.NewReplacer(MatchStart, "", MatchEnd, "").Replace(rest))
+	return b.String()
+}
+
+// Search finds messages of all sessions whose text, tool call inputs or tool
+// results contain every word of the query, best matches first. The last word
+// also matches as a prefix.
+func (s *service) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	match := searchQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	rows, err := s.q.SearchMessages(ctx, db.SearchMessagesParams{
+		Query: match,
+		Limit: int64(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, len(rows))
+	for i, row := range rows {
+		results[i] = SearchResult{
+			MessageID:    row.ID,
+			SessionID:    row.SessionID,
+			SessionTitle: row.SessionTitle,
+			Role:         MessageRole(row.Role),
+			CreatedAt:    row.CreatedAt,
+			Snippet:      row.Snippet,
+		}
+		// Sub-agent sessions are named after the tool call that ran them
+		if row.ParentSessionID != "" {
+			results[i].SessionID = row.ParentSessionID
+			results[i].ToolCallID = row.SessionID
+		}
+	}
+	return results, nil
+}
+
+// searchQuery turns the words of a query into an FTS5 query, quoting them so
+// that punctuation such as in "go test ./..." is not read as query syntax.
+func searchQuery(query string) string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		// Words without letters or digits have no tokens to match
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"`)
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	terms[len(terms)-1] += "*"
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,23 @@
+package message
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchQuery(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, `"go" "test"*`, searchQuery("go test ./..."))
+	require.Equal(t, `"say" """hi"""*`, searchQuery(`say "hi"`))
+	require.Equal(t, "", searchQuery(" -- "))
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	t.Parallel()
+
+	r := SearchResult{Snippet: "run \x02go\x03\n\x02test\x03 …"}
+	require.Equal(t, "run GO TEST …", r.HighlightSnippet(strings.ToUpper))
+}
@@ -8,6 +8,7 @@ import (
 	"slices"
 	"sync"
 
+	"github.com/chasedut/toke/internal/config"
 	"github.com/chasedut/toke/internal/csync"
 	"github.com/chasedut/toke/internal/pubsub"
 	"github.com/google/uuid"
@@ -15,8 +16,18 @@ import (
 
 var ErrorPermissionDenied = errors.New("permission denied")
 
+// Reasons for deciding a request without asking the user.
+const (
+	ReasonRule         = "rule"
+	ReasonYolo         = "yolo"
+	ReasonAllowedTools = "allowed_tools"
+	ReasonAutoApprove  = "auto_approve"
+	ReasonSession      = "session"
+)
+
 type CreatePermissionRequest struct {
 	SessionID   string `json:"session_id"`
+	MessageID   string `json:"message_id"`
 	ToolCallID  string `json:"tool_call_id"`
 	ToolName    string `json:"tool_name"`
 	Description string `json:"description"`
@@ -26,20 +37,34 @@ type CreatePermissionRequest struct {
 }
 
 type PermissionNotification struct {
+	SessionID  string `json:"session_id"`
+	MessageID  string `json:"message_id"`
 	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
+	Action     string `json:"action"`
 	Granted    bool   `json:"granted"`
 	Denied     bool   `json:"denied"`
+	// Auto is set when the request was decided without asking the user,
+	// e.g. by the allowlist, an auto-approved session or yolo mode.
+	Auto bool `json:"auto"`
+	// Reason tells why a request was decided without asking the user.
+	Reason string `json:"reason,omitempty"`
+	// Rule is the permission rule that decided the request, if any.
+	Rule string `json:"rule,omitempty"`
 }
 
 type PermissionRequest struct {
 	ID          string `json:"id"`
 	SessionID   string `json:"session_id"`
+	MessageID   string `json:"message_id"`
 	ToolCallID  string `json:"tool_call_id"`
 	ToolName    string `json:"tool_name"`
 	Description string `json:"description"`
 	Action      string `json:"action"`
 	Params      any    `json:"params"`
 	Path        string `json:"path"`
+	// Rule is the ask rule that matched the request, if any.
+	Rule string `json:"rule,omitempty"`
 }
 
 type Service interface {
@@ -52,12 +77,18 @@ type Service interface {
 	SetSkipRequests(skip bool)
 	SkipRequests() bool
 	SubscribeNotifications(ctx context.Context) <-chan pubsub.Event[PermissionNotification]
+	// OnDecision calls fn for every request granted or denied, before the
+	// decision is published. Unlike subscribers, fn misses none of them.
+	OnDecision(fn func(PermissionNotification))
+	// AddRule puts a rule in front of the rules evaluated for requests.
+	AddRule(rule config.PermissionRule)
 }
 
 type permissionService struct {
 	*pubsub.Broker[PermissionRequest]
 
 	notificationBroker    *pubsub.Broker[PermissionNotification]
+	decisionHandlers      *csync.Slice[func(PermissionNotification)]
 	workingDir            string
 	sessionPermissions    []PermissionRequest
 	sessionPermissionsMu  sync.RWMutex
@@ -66,6 +97,8 @@ type permissionService struct {
 	autoApproveSessionsMu sync.RWMutex
 	skip                  bool
 	allowedTools          []string
+	rules                 []config.PermissionRule
+	rulesMu               sync.RWMutex
 
 	// used to make sure we only process one request at a time
 	requestMu     sync.Mutex
@@ -73,8 +106,12 @@ type permissionService struct {
 }
 
 func (s *permissionService) GrantPersistent(permission PermissionRequest) {
-	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+	s.notify(PermissionNotification{
+		SessionID:  permission.SessionID,
+		MessageID:  permission.MessageID,
 		ToolCallID: permission.ToolCallID,
+		ToolName:   permission.ToolName,
+		Action:     permission.Action,
 		Granted:    true,
 	})
 	respCh, ok := s.pendingRequests.Get(permission.ID)
@@ -92,8 +129,12 @@ func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 }
 
 func (s *permissionService) Grant(permission PermissionRequest) {
-	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+	s.notify(PermissionNotification{
+		SessionID:  permission.SessionID,
+		MessageID:  permission.MessageID,
 		ToolCallID: permission.ToolCallID,
+		ToolName:   permission.ToolName,
+		Action:     permission.Action,
 		Granted:    true,
 	})
 	respCh, ok := s.pendingRequests.Get(permission.ID)
@@ -107,8 +148,12 @@ func (s *permissionService) Grant(permission PermissionRequest) {
 }
 
 func (s *permissionService) Deny(permission PermissionRequest) {
-	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+	s.notify(PermissionNotification{
+		SessionID:  permission.SessionID,
+		MessageID:  permission.MessageID,
 		ToolCallID: permission.ToolCallID,
+		ToolName:   permission.ToolName,
+		Action:     permission.Action,
 		Granted:    false,
 		Denied:     true,
 	})
@@ -123,20 +168,52 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 }
 
 func (s *permissionService) Request(opts CreatePermissionRequest) bool {
+	s.rulesMu.RLock()
+	rule, matched := matchRule(s.rules, s.workingDir, opts)
+	s.rulesMu.RUnlock()
+
+	// deny rules apply even when requests are skipped
+	if matched && rule.Decision == config.PermissionRuleDeny {
+		s.notify(PermissionNotification{
+			SessionID:  opts.SessionID,
+			MessageID:  opts.MessageID,
+			ToolCallID: opts.ToolCallID,
+			ToolName:   opts.ToolName,
+			Action:     opts.Action,
+			Denied:     true,
+			Auto:       true,
+			Reason:     ReasonRule,
+			Rule:       rule.String(),
+		})
+		return false
+	}
+
 	if s.skip {
+		s.notifyAutoGranted(opts, ReasonYolo, "")
 		return true
 	}
 
+	if matched && rule.Decision == config.PermissionRuleAllow {
+		s.notifyAutoGranted(opts, ReasonRule, rule.String())
+		return true
+	}
+	ask := matched && rule.Decision == config.PermissionRuleAsk
+
 	// tell the UI that a permission was requested
-	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+	s.notify(PermissionNotification{
+		SessionID:  opts.SessionID,
+		MessageID:  opts.MessageID,
 		ToolCallID: opts.ToolCallID,
+		ToolName:   opts.ToolName,
+		Action:     opts.Action,
 	})
 	s.requestMu.Lock()
 	defer s.requestMu.Unlock()
 
 	// Check if the tool/action combination is in the allowlist
 	commandKey := opts.ToolName + ":" + opts.Action
-	if slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName) {
+	if !ask && (slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName)) {
+		s.notifyAutoGranted(opts, ReasonAllowedTools, "")
 		return true
 	}
 
@@ -145,6 +222,7 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	s.autoApproveSessionsMu.RUnlock()
 
 	if autoApprove {
+		s.notifyAutoGranted(opts, ReasonAutoApprove, "")
 		return true
 	}
 
@@ -165,30 +243,20 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 		ID:          uuid.New().String(),
 		Path:        dir,
 		SessionID:   opts.SessionID,
+		MessageID:   opts.MessageID,
 		ToolCallID:  opts.ToolCallID,
 		ToolName:    opts.ToolName,
 		Description: opts.Description,
 		Action:      opts.Action,
 		Params:      opts.Params,
 	}
-
-	s.sessionPermissionsMu.RLock()
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			s.sessionPermissionsMu.RUnlock()
-			return true
-		}
-	}
-	s.sessionPermissionsMu.RUnlock()
-
-	s.sessionPermissionsMu.RLock()
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			s.sessionPermissionsMu.RUnlock()
-			return true
-		}
+	if ask {
+		// ask rules take precedence over permissions granted for the session
+		permission.Rule = rule.String()
+	} else if s.grantedForSession(permission) {
+		s.notifyAutoGranted(opts, ReasonSession, "")
+		return true
 	}
-	s.sessionPermissionsMu.RUnlock()
 
 	s.activeRequest = &permission
 
@@ -202,6 +270,49 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	return <-respCh
 }
 
+// grantedForSession reports whether the user allowed requests like this one
+// for the rest of the session.
+func (s *permissionService) grantedForSession(permission PermissionRequest) bool {
+	s.sessionPermissionsMu.RLock()
+	defer s.sessionPermissionsMu.RUnlock()
+	for _, p := range s.sessionPermissions {
+		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyAutoGranted tells subscribers that a request was granted without
+// asking the user, and why.
+func (s *permissionService) notifyAutoGranted(opts CreatePermissionRequest, reason, rule string) {
+	s.notify(PermissionNotification{
+		SessionID:  opts.SessionID,
+		MessageID:  opts.MessageID,
+		ToolCallID: opts.ToolCallID,
+		ToolName:   opts.ToolName,
+		Action:     opts.Action,
+		Granted:    true,
+		Auto:       true,
+		Reason:     reason,
+		Rule:       rule,
+	})
+}
+
+// notify passes decisions to the decision handlers and publishes n.
+func (s *permissionService) notify(n PermissionNotification) {
+	if n.Granted || n.Denied {
+		for fn := range s.decisionHandlers.Seq() {
+			fn(n)
+		}
+	}
+	s.notificationBroker.Publish(pubsub.CreatedEvent, n)
+}
+
+func (s *permissionService) OnDecision(fn func(PermissionNotification)) {
+	s.decisionHandlers.Append(fn)
+}
+
 func (s *permissionService) AutoApproveSession(sessionID string) {
 	s.autoApproveSessionsMu.Lock()
 	s.autoApproveSessions[sessionID] = true
@@ -212,6 +323,12 @@ func (s *permissionService) SubscribeNotifications(ctx context.Context) <-chan p
 	return s.notificationBroker.Subscribe(ctx)
 }
 
+func (s *permissionService) AddRule(rule config.PermissionRule) {
+	s.rulesMu.Lock()
+	s.rules = slices.Insert(s.rules, 0, rule)
+	s.rulesMu.Unlock()
+}
+
 func (s *permissionService) SetSkipRequests(skip bool) {
 	s.skip = skip
 }
@@ -220,15 +337,17 @@ func (s *permissionService) SkipRequests() bool {
 	return s.skip
 }
 
-func NewPermissionService(workingDir string, skip bool, allowedTools []string) Service {
+func NewPermissionService(workingDir string, skip bool, allowedTools []string, rules []config.PermissionRule) Service {
 	return &permissionService{
 		Broker:              pubsub.NewBroker[PermissionRequest](),
 		notificationBroker:  pubsub.NewBroker[PermissionNotification](),
+		decisionHandlers:    csync.NewSlice[func(PermissionNotification)](),
 		workingDir:          workingDir,
 		sessionPermissions:  make([]PermissionRequest, 0),
 		autoApproveSessions: make(map[string]bool),
 		skip:                skip,
 		allowedTools:        allowedTools,
+		rules:               slices.Clone(rules),
 		pendingRequests:     csync.NewMap[string, chan bool](),
 	}
 }
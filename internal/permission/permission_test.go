@@ -54,7 +54,7 @@ func TestPermissionService_AllowedCommands(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := NewPermissionService("/tmp", false, tt.allowedTools)
+			service := NewPermissionService("/tmp", false, tt.allowedTools, nil)
 
 			// Create a channel to capture the permission request
 			// Since we're testing the allowlist logic, we need to simulate the request
@@ -79,7 +79,7 @@ func TestPermissionService_AllowedCommands(t *testing.T) {
 }
 
 func TestPermissionService_SkipMode(t *testing.T) {
-	service := NewPermissionService("/tmp", true, []string{})
+	service := NewPermissionService("/tmp", true, []string{}, nil)
 
 	result := service.Request(CreatePermissionRequest{
 		SessionID:   "test-session",
@@ -96,7 +96,7 @@ func TestPermissionService_SkipMode(t *testing.T) {
 
 func TestPermissionService_SequentialProperties(t *testing.T) {
 	t.Run("Sequential permission requests with persistent grants", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, nil)
 
 		req1 := CreatePermissionRequest{
 			SessionID:   "session1",
@@ -140,7 +140,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.True(t, result2, "Second request should be auto-approved")
 	})
 	t.Run("Sequential requests with temporary grants", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, nil)
 
 		req := CreatePermissionRequest{
 			SessionID:   "session2",
@@ -184,7 +184,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.False(t, result2, "Second request should be denied")
 	})
 	t.Run("Concurrent requests with different outcomes", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, nil)
 
 		events := service.Subscribe(t.Context())
 
@@ -249,3 +249,29 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.True(t, result, "Repeated request should be auto-approved due to persistent permission")
 	})
 }
+
+func TestPermissionService_OnDecision(t *testing.T) {
+	service := NewPermissionService("/tmp", false, []string{"view"}, nil)
+	var decisions []PermissionNotification
+	service.OnDecision(func(n PermissionNotification) {
+		decisions = append(decisions, n)
+	})
+
+	assert.True(t, service.Request(CreatePermissionRequest{SessionID: "s1", ToolName: "view", Action: "read", Path: "/tmp"}))
+
+	events := service.Subscribe(t.Context())
+	done := make(chan bool)
+	go func() {
+		done <- service.Request(CreatePermissionRequest{SessionID: "s1", ToolName: "bash", Action: "execute", Path: "/tmp"})
+	}()
+	service.Deny((<-events).Payload)
+	assert.False(t, <-done)
+
+	if !assert.Len(t, decisions, 2, "requests are only recorded once decided") {
+		return
+	}
+	assert.True(t, decisions[0].Granted)
+	assert.Equal(t, ReasonAllowedTools, decisions[0].Reason)
+	assert.Equal(t, "bash", decisions[1].ToolName)
+	assert.True(t, decisions[1].Denied)
+}
@@ -0,0 +1,173 @@
+package permission
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/chasedut/toke/internal/config"
+)
+
+// subject is what a rule is matched against, taken from the parameters of a
+// request.
+type subject struct {
+	Command  string `json:"command"`
+	FilePath string `json:"file_path"`
+	Path     string `json:"path"`
+	URL      string `json:"url"`
+}
+
+func requestSubject(params any) subject {
+	var sub subject
+	if params == nil {
+		return sub
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return sub
+	}
+	_ = json.Unmarshal(data, &sub)
+	return sub
+}
+
+// matchRule returns the first rule that matches the request.
+func matchRule(rules []config.PermissionRule, workingDir string, opts CreatePermissionRequest) (config.PermissionRule, bool) {
+	sub := requestSubject(opts.Params)
+	for _, rule := range rules {
+		if ruleMatches(rule, workingDir, opts, sub) {
+			return rule, true
+		}
+	}
+	return config.PermissionRule{}, false
+}
+
+func ruleMatches(rule config.PermissionRule, workingDir string, opts CreatePermissionRequest, sub subject) bool {
+	if rule.Tool != "" && rule.Tool != "*" {
+		if ok, _ := path.Match(rule.Tool, opts.ToolName); !ok {
+			return false
+		}
+	}
+	if rule.Action != "" && rule.Action != "*" && rule.Action != opts.Action {
+		return false
+	}
+	if rule.Command != "" && !commandMatches(rule.Command, sub.Command, rule.Decision == config.PermissionRuleDeny) {
+		return false
+	}
+	if rule.Path != "" && !pathMatches(rule.Path, workingDir, sub.FilePath, sub.Path, sub.URL, opts.Path) {
+		return false
+	}
+	return true
+}
+
+func isWildcard(pattern string) bool {
+	return pattern == "*" || pattern == "**"
+}
+
+// pathMatches reports whether the pattern matches the first non-empty
+// candidate. Paths in the working directory can be matched by their relative
+// path as well as their absolute one, and patterns without a slash match the
+// base name like in .gitignore files.
+func pathMatches(pattern, workingDir string, candidates ...string) bool {
+	if isWildcard(pattern) {
+		return true
+	}
+	var target string
+	for _, c := range candidates {
+		if c != "" {
+			target = c
+			break
+		}
+	}
+	if target == "" {
+		return false
+	}
+	if strings.Contains(target, "://") {
+		ok, _ := doublestar.Match(pattern, target)
+		return ok
+	}
+
+	target = filepath.ToSlash(target)
+	names := []string{target}
+	if rel, err := filepath.Rel(workingDir, filepath.FromSlash(target)); err == nil && !strings.HasPrefix(rel, "..") {
+		names = append(names, filepath.ToSlash(rel))
+	}
+	if !strings.Contains(pattern, "/") {
+		names = append(names, path.Base(target))
+	}
+	for _, name := range names {
+		if ok, _ := doublestar.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// shellOperators split a command line into several commands.
+var shellOperators = regexp.MustCompile("&&|\\|\\||[;|&\n`<>]|\\$\\(")
+
+// commandMatches reports whether a bash command matches the pattern, where *
+// matches anything. Commands that chain, pipe or redirect only match a
+// pattern that spells them out exactly, so that allowing "go test *" doesn't
+// allow "go test ./...; rm -rf ~". With
+// anySegment, which deny rules use, it is enough for one of the chained
+// commands to match.
+func commandMatches(pattern, command string, anySegment bool) bool {
+	if isWildcard(pattern) {
+		return true
+	}
+	command = strings.TrimSpace(command)
+	if command == strings.TrimSpace(pattern) {
+		return true
+	}
+	re, err := regexp.Compile("^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSpace(pattern)), `\*`, ".*") + "$")
+	if err != nil {
+		return false
+	}
+	if !shellOperators.MatchString(command) {
+		return re.MatchString(command)
+	}
+	if !anySegment {
+		return false
+	}
+	if re.MatchString(command) {
+		return true
+	}
+	for _, segment := range shellOperators.Split(command, -1) {
+		if re.MatchString(strings.TrimSpace(segment)) {
+			return true
+		}
+	}
+	return false
+}
+
+// SuggestRule returns an allow rule for requests like the given one: the
+// exact command for bash, the file or URL for tools that work on one, and
+// the tool and action otherwise.
+func SuggestRule(req PermissionRequest, workingDir string) config.PermissionRule {
+	rule := config.PermissionRule{
+		Decision: config.PermissionRuleAllow,
+		Tool:     req.ToolName,
+	}
+	sub := requestSubject(req.Params)
+	switch {
+	case sub.Command != "":
+		rule.Command = sub.Command
+	case sub.FilePath != "" || sub.Path != "":
+		p := sub.FilePath
+		if p == "" {
+			p = sub.Path
+		}
+		if rel, err := filepath.Rel(workingDir, p); err == nil && !strings.HasPrefix(rel, "..") {
+			p = rel
+		}
+		rule.Path = filepath.ToSlash(p)
+	case sub.URL != "":
+		rule.Path = sub.URL
+	default:
+		rule.Action = req.Action
+	}
+	return rule
+}
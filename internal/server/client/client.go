@@ -0,0 +1,242 @@
+// Package client is a Go client for the API served by "toke serve".
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/chasedut/toke/internal/permission"
+	"github.com/chasedut/toke/internal/server"
+	"github.com/chasedut/toke/internal/transcript"
+)
+
+// Client talks to a toke API server.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// Error is returned for non-2xx responses.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("toke api: %d %s", e.StatusCode, e.Message)
+}
+
+// New creates a client for the server at baseURL, e.g.
+// "http://127.0.0.1:7777". token may be empty if the server doesn't require
+// one.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// WithHTTPClient returns a copy of c that uses hc for requests.
+func (c *Client) WithHTTPClient(hc *http.Client) *Client {
+	cp := *c
+	cp.httpClient = hc
+	return &cp
+}
+
+// ListSessions returns all sessions.
+func (c *Client) ListSessions(ctx context.Context) ([]transcript.Session, error) {
+	var out []transcript.Session
+	err := c.do(ctx, http.MethodGet, "/v1/sessions", nil, &out)
+	return out, err
+}
+
+// CreateSession creates a session with the given title.
+func (c *Client) CreateSession(ctx context.Context, title string) (transcript.Session, error) {
+	var out transcript.Session
+	err := c.do(ctx, http.MethodPost, "/v1/sessions", server.CreateSessionRequest{Title: title}, &out)
+	return out, err
+}
+
+// GetSession returns a single session.
+func (c *Client) GetSession(ctx context.Context, id string) (transcript.Session, error) {
+	var out transcript.Session
+	err := c.do(ctx, http.MethodGet, "/v1/sessions/"+url.PathEscape(id), nil, &out)
+	return out, err
+}
+
+// DeleteSession deletes a session and its messages.
+func (c *Client) DeleteSession(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/sessions/"+url.PathEscape(id), nil, nil)
+}
+
+// ListMessages returns the messages of a session.
+func (c *Client) ListMessages(ctx context.Context, sessionID string) ([]transcript.Message, error) {
+	var out []transcript.Message
+	err := c.do(ctx, http.MethodGet, "/v1/sessions/"+url.PathEscape(sessionID)+"/messages", nil, &out)
+	return out, err
+}
+
+// Run sends a prompt to the agent. With wait set the call blocks until the
+// agent is done; otherwise it returns as soon as the run has started.
+func (c *Client) Run(ctx context.Context, sessionID, prompt string, wait bool) (server.RunResponse, error) {
+	var out server.RunResponse
+	err := c.do(ctx, http.MethodPost, "/v1/sessions/"+url.PathEscape(sessionID)+"/run", server.RunRequest{Prompt: prompt, Wait: wait}, &out)
+	return out, err
+}
+
+// Cancel cancels the running request of a session, if any.
+func (c *Client) Cancel(ctx context.Context, sessionID string) error {
+	return c.do(ctx, http.MethodPost, "/v1/sessions/"+url.PathEscape(sessionID)+"/cancel", nil, nil)
+}
+
+// Summarize starts summarizing a session.
+func (c *Client) Summarize(ctx context.Context, sessionID string) error {
+	return c.do(ctx, http.MethodPost, "/v1/sessions/"+url.PathEscape(sessionID)+"/summarize", nil, nil)
+}
+
+// ListPermissions returns the pending permission requests, optionally
+// limited to one session.
+func (c *Client) ListPermissions(ctx context.Context, sessionID string) ([]permission.PermissionRequest, error) {
+	path := "/v1/permissions"
+	if sessionID != "" {
+		path += "?session_id=" + url.QueryEscape(sessionID)
+	}
+	var out []permission.PermissionRequest
+	err := c.do(ctx, http.MethodGet, path, nil, &out)
+	return out, err
+}
+
+// DecidePermission answers a pending permission request with one of
+// server.DecisionAllow, server.DecisionAllowSession or server.DecisionDeny.
+func (c *Client) DecidePermission(ctx context.Context, id, decision string) error {
+	return c.do(ctx, http.MethodPost, "/v1/permissions/"+url.PathEscape(id), server.PermissionDecision{Decision: decision}, nil)
+}
+
+// Events streams server events until ctx is cancelled or the connection
+// drops, at which point the returned channel is closed. A non-empty
+// sessionID limits the stream to that session.
+func (c *Client) Events(ctx context.Context, sessionID string) (<-chan server.Event, error) {
+	path := "/v1/events"
+	if sessionID != "" {
+		path += "?session_id=" + url.QueryEscape(sessionID)
+	}
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, readError(resp)
+	}
+
+	ch := make(chan server.Event, 64)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+		readEvents(ctx, resp.Body, ch)
+	}()
+	return ch, nil
+}
+
+func readEvents(ctx context.Context, r io.Reader, ch chan<- server.Event) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var event server.Event
+			err := json.Unmarshal(data.Bytes(), &event)
+			data.Reset()
+			if err != nil {
+				continue
+			}
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+				return
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return readError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func readError(resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+		return &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: body.Error}
+}
+
+// IsNotFound reports whether err is a 404 from the server.
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
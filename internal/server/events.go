@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/chasedut/toke/internal/llm/agent"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/permission"
+	"github.com/chasedut/toke/internal/pubsub"
+	"github.com/chasedut/toke/internal/session"
+	"github.com/chasedut/toke/internal/transcript"
+)
+
+// Event types sent over the /v1/events stream.
+const (
+	EventSessionCreated         = "session.created"
+	EventSessionUpdated         = "session.updated"
+	EventSessionDeleted         = "session.deleted"
+	EventMessageCreated         = "message.created"
+	EventMessageUpdated         = "message.updated"
+	EventMessageDeleted         = "message.deleted"
+	EventPermissionRequest      = "permission.request"
+	EventPermissionNotification = "permission.notification"
+	EventAgentResponse          = "agent.response"
+	EventAgentError             = "agent.error"
+	EventAgentSummarize         = "agent.summarize"
+)
+
+// Event is a single server-sent event. Data holds the JSON payload, whose
+// shape depends on Type.
+type Event struct {
+	Type      string          `json:"type"`
+	SessionID string          `json:"session_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// AgentEventPayload is the data of the agent.* events.
+type AgentEventPayload struct {
+	SessionID string              `json:"session_id,omitempty"`
+	Message   *transcript.Message `json:"message,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	Progress  string              `json:"progress,omitempty"`
+	Done      bool                `json:"done"`
+}
+
+func newEvent(typ, sessionID string, payload any) (Event, bool) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, false
+	}
+	return Event{Type: typ, SessionID: sessionID, Data: data}, true
+}
+
+func eventName(prefix string, t pubsub.EventType) string {
+	return prefix + "." + string(t)
+}
+
+func sessionEvent(e pubsub.Event[session.Session]) (Event, bool) {
+	return newEvent(eventName("session", e.Type), e.Payload.ID, transcript.FromSession(e.Payload))
+}
+
+func messageEvent(e pubsub.Event[message.Message]) (Event, bool) {
+	m, err := transcript.FromMessage(e.Payload)
+	if err != nil {
+		return Event{}, false
+	}
+	return newEvent(eventName("message", e.Type), e.Payload.SessionID, m)
+}
+
+func permissionRequestEvent(e pubsub.Event[permission.PermissionRequest]) (Event, bool) {
+	return newEvent(EventPermissionRequest, e.Payload.SessionID, e.Payload)
+}
+
+func permissionNotificationEvent(e pubsub.Event[permission.PermissionNotification]) (Event, bool) {
+	return newEvent(EventPermissionNotification, e.Payload.SessionID, e.Payload)
+}
+
+func agentEvent(e pubsub.Event[agent.AgentEvent]) (Event, bool) {
+	payload := AgentEventPayload{
+		SessionID: e.Payload.SessionID,
+		Progress:  e.Payload.Progress,
+		Done:      e.Payload.Done,
+	}
+	if e.Payload.Error != nil {
+		payload.Error = e.Payload.Error.Error()
+	}
+	if e.Payload.Message.ID != "" {
+		m, err := transcript.FromMessage(e.Payload.Message)
+		if err == nil {
+			payload.Message = &m
+		}
+		if payload.SessionID == "" {
+			payload.SessionID = e.Payload.Message.SessionID
+		}
+	}
+
+	var typ string
+	switch e.Payload.Type {
+	case agent.AgentEventTypeResponse:
+		typ = EventAgentResponse
+	case agent.AgentEventTypeSummarize:
+		typ = EventAgentSummarize
+	default:
+		typ = EventAgentError
+	}
+	return newEvent(typ, payload.SessionID, payload)
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/chasedut/toke/internal/llm/agent"
+	"github.com/chasedut/toke/internal/permission"
+	"github.com/chasedut/toke/internal/transcript"
+)
+
+// CreateSessionRequest is the body of POST /v1/sessions.
+type CreateSessionRequest struct {
+	Title string `json:"title"`
+}
+
+// RunRequest is the body of POST /v1/sessions/{id}/run.
+type RunRequest struct {
+	Prompt string `json:"prompt"`
+	// Wait blocks the request until the agent finishes and returns the
+	// final message. Otherwise the run continues in the background and
+	// progress can be followed on the event stream.
+	Wait bool `json:"wait,omitempty"`
+}
+
+// RunResponse is returned by POST /v1/sessions/{id}/run.
+type RunResponse struct {
+	SessionID string              `json:"session_id"`
+	Status    string              `json:"status"`
+	Message   *transcript.Message `json:"message,omitempty"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// PermissionDecision is the body of POST /v1/permissions/{id}.
+type PermissionDecision struct {
+	// Decision is one of "allow", "allow_session" or "deny".
+	Decision string `json:"decision"`
+}
+
+// Permission decisions accepted by POST /v1/permissions/{id}.
+const (
+	DecisionAllow        = "allow"
+	DecisionAllowSession = "allow_session"
+	DecisionDeny         = "deny"
+)
+
+// Run statuses reported in RunResponse.
+const (
+	RunStatusStarted   = "started"
+	RunStatusCompleted = "completed"
+	RunStatusCancelled = "cancelled"
+	RunStatusFailed    = "failed"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /v1/health", s.handleHealth)
+	s.mux.HandleFunc("GET /v1/sessions", s.handleListSessions)
+	s.mux.HandleFunc("POST /v1/sessions", s.handleCreateSession)
+	s.mux.HandleFunc("GET /v1/sessions/{id}", s.handleGetSession)
+	s.mux.HandleFunc("DELETE /v1/sessions/{id}", s.handleDeleteSession)
+	s.mux.HandleFunc("GET /v1/sessions/{id}/messages", s.handleListMessages)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/run", s.handleRun)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/cancel", s.handleCancel)
+	s.mux.HandleFunc("POST /v1/sessions/{id}/summarize", s.handleSummarize)
+	s.mux.HandleFunc("GET /v1/permissions", s.handleListPermissions)
+	s.mux.HandleFunc("POST /v1/permissions/{id}", s.handlePermissionDecision)
+	s.mux.HandleFunc("GET /v1/events", s.handleEvents)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status": "ok",
+		"agent":  s.services.Agent != nil,
+	})
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.services.Sessions.List(r.Context())
+	if err != nil {
+		logRequestError(r, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := make([]transcript.Session, len(sessions))
+	for i, sess := range sessions {
+		out[i] = transcript.FromSession(sess)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req CreateSessionRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Title == "" {
+		req.Title = "New Session"
+	}
+	sess, err := s.services.Sessions.Create(r.Context(), req.Title)
+	if err != nil {
+		logRequestError(r, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, transcript.FromSession(sess))
+}
+
+func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.services.Sessions.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transcript.FromSession(sess))
+}
+
+func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if s.services.Agent != nil && s.agentFor(id).IsSessionBusy(id) {
+		writeError(w, http.StatusConflict, agent.ErrSessionBusy)
+		return
+	}
+	if err := s.services.Sessions.Delete(r.Context(), id); err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListMessages(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.services.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+	msgs, err := s.services.Messages.List(r.Context(), id)
+	if err != nil {
+		logRequestError(r, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := make([]transcript.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		m, err := transcript.FromMessage(msg)
+		if err != nil {
+			logRequestError(r, err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		out = append(out, m)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAgent(w) {
+		return
+	}
+	id := r.PathValue("id")
+	var req RunRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("prompt is required"))
+		return
+	}
+	if _, err := s.services.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+
+	// A background run must outlive this request.
+	runCtx := context.WithoutCancel(r.Context())
+	if req.Wait {
+		runCtx = r.Context()
+	}
+	runAgent := s.agentFor(id)
+	done, err := runAgent.Run(runCtx, id, req.Prompt)
+	if err != nil {
+		if errors.Is(err, agent.ErrSessionBusy) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		logRequestError(r, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if runAgent != s.services.Agent {
+		done = s.publishAgentEvents(done)
+	}
+
+	if !req.Wait {
+		go func() {
+			for range done {
+			}
+		}()
+		writeJSON(w, http.StatusAccepted, RunResponse{SessionID: id, Status: RunStatusStarted})
+		return
+	}
+
+	result := <-done
+	resp := RunResponse{SessionID: id, Status: RunStatusCompleted}
+	if result.Message.ID != "" {
+		if m, err := transcript.FromMessage(result.Message); err == nil {
+			resp.Message = &m
+		}
+	}
+	if result.Error != nil {
+		resp.Error = result.Error.Error()
+		resp.Status = RunStatusFailed
+		if errors.Is(result.Error, agent.ErrRequestCancelled) || errors.Is(result.Error, context.Canceled) {
+			resp.Status = RunStatusCancelled
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAgent(w) {
+		return
+	}
+	id := r.PathValue("id")
+	s.agentFor(id).Cancel(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleSummarize(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAgent(w) {
+		return
+	}
+	id := r.PathValue("id")
+	if _, err := s.services.Sessions.Get(r.Context(), id); err != nil {
+		writeLookupError(w, r, err)
+		return
+	}
+	if err := s.agentFor(id).Summarize(context.WithoutCancel(r.Context()), id); err != nil {
+		if errors.Is(err, agent.ErrSessionBusy) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		logRequestError(r, err)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleListPermissions(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.URL.Query().Get("session_id")
+	pending := make([]permission.PermissionRequest, 0)
+	for req := range s.pending.Seq() {
+		if sessionID == "" || req.SessionID == sessionID {
+			pending = append(pending, req)
+		}
+	}
+	slices.SortFunc(pending, func(a, b permission.PermissionRequest) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	writeJSON(w, http.StatusOK, pending)
+}
+
+func (s *Server) handlePermissionDecision(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req PermissionDecision
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	perm, ok := s.pending.Take(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no pending permission request %s", id))
+		return
+	}
+	switch req.Decision {
+	case DecisionAllow:
+		s.services.Permissions.Grant(perm)
+	case DecisionAllowSession:
+		s.services.Permissions.GrantPersistent(perm)
+	case DecisionDeny:
+		s.services.Permissions.Deny(perm)
+	default:
+		s.pending.Set(id, perm)
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid decision %q", req.Decision))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+	sessionID := r.URL.Query().Get("session_id")
+
+	ctx := r.Context()
+	events := s.events.Subscribe(ctx)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	// Let the client know the stream is open before the first event.
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if sessionID != "" && event.Payload.SessionID != sessionID {
+				continue
+			}
+			data, err := json.Marshal(event.Payload)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Payload.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) requireAgent(w http.ResponseWriter) bool {
+	if s.services.Agent == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("no providers configured - run 'toke' to set up a provider"))
+		return false
+	}
+	return true
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	// Web pages can send other content types without a preflight request.
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("request body must be application/json"))
+		return false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 10<<20)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, errors.New("session not found"))
+		return
+	}
+	logRequestError(r, err)
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
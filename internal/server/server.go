@@ -0,0 +1,300 @@
+// Package server exposes the agent, sessions and permission flow over a
+// local HTTP/JSON API with Server-Sent Events for streaming updates.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chasedut/toke/internal/csync"
+	"github.com/chasedut/toke/internal/llm/agent"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/permission"
+	"github.com/chasedut/toke/internal/pubsub"
+	"github.com/chasedut/toke/internal/session"
+)
+
+// Services are the application services the server exposes.
+type Services struct {
+	Sessions    session.Service
+	Messages    message.Service
+	Permissions permission.Service
+	Agent       agent.Service
+	// AgentForSession returns the agent that handles a session, running in
+	// the session's worktree if it has one. Agent is used when it is nil.
+	AgentForSession func(sessionID string) agent.Service
+}
+
+// Options configures the server.
+type Options struct {
+	// Addr is the address to listen on, e.g. "127.0.0.1:7777".
+	Addr string
+	// Token, when set, must be sent as a bearer token with every request.
+	// "toke serve" generates one when none is given.
+	Token string
+}
+
+// GenerateToken returns a random token for Options.Token.
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Server is the headless HTTP API.
+type Server struct {
+	services Services
+	opts     Options
+	mux      *http.ServeMux
+	events   *pubsub.Broker[Event]
+
+	// pending holds permission requests waiting for a decision, keyed by
+	// request ID.
+	pending *csync.Map[string, permission.PermissionRequest]
+
+	httpServer *http.Server
+	wg         sync.WaitGroup
+}
+
+// New creates a server for the given services.
+func New(services Services, opts Options) *Server {
+	s := &Server{
+		services: services,
+		opts:     opts,
+		mux:      http.NewServeMux(),
+		events:   pubsub.NewBroker[Event](),
+		pending:  csync.NewMap[string, permission.PermissionRequest](),
+	}
+	s.routes()
+	return s
+}
+
+// Handler returns the HTTP handler serving the API.
+func (s *Server) Handler() http.Handler {
+	return s.checkOrigin(s.authenticate(s.mux))
+}
+
+// Start forwards service events to API subscribers. It must be called
+// before serving requests and stops when ctx is cancelled.
+func (s *Server) Start(ctx context.Context) {
+	forward(ctx, &s.wg, s.services.Sessions.Subscribe, s.events, sessionEvent)
+	forward(ctx, &s.wg, s.services.Messages.Subscribe, s.events, messageEvent)
+	forward(ctx, &s.wg, s.services.Permissions.Subscribe, s.events, func(e pubsub.Event[permission.PermissionRequest]) (Event, bool) {
+		s.pending.Set(e.Payload.ID, e.Payload)
+		return permissionRequestEvent(e)
+	})
+	forward(ctx, &s.wg, s.services.Permissions.SubscribeNotifications, s.events, func(e pubsub.Event[permission.PermissionNotification]) (Event, bool) {
+		if e.Payload.Granted || e.Payload.Denied {
+			s.clearPending(e.Payload.ToolCallID)
+		}
+		return permissionNotificationEvent(e)
+	})
+	if s.services.Agent != nil {
+		forward(ctx, &s.wg, s.services.Agent.Subscribe, s.events, agentEvent)
+	}
+}
+
+// ListenAndServe starts the event forwarding and serves the API until ctx
+// is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve is like ListenAndServe but uses an existing listener.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.Start(ctx)
+	s.httpServer = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.httpServer.Serve(ln)
+	}()
+
+	select {
+	case <-ctx.Done():
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		err := s.httpServer.Shutdown(shutdownCtx)
+		s.events.Shutdown()
+		s.wg.Wait()
+		return err
+	case err := <-errCh:
+		cancel()
+		s.events.Shutdown()
+		s.wg.Wait()
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.opts.Token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.opts.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		// EventSource in browsers can't set headers, so accept the token
+		// as a query parameter too.
+		if len(got) == 0 {
+			if token := r.URL.Query().Get("token"); token != "" {
+				got = []byte("Bearer " + token)
+			}
+		}
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkOrigin rejects requests sent by web pages, either cross-origin or
+// through a DNS name rebound to this machine.
+func (s *Server) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowedHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q not allowed", r.Host))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || !strings.EqualFold(u.Host, r.Host) {
+				writeError(w, http.StatusForbidden, fmt.Errorf("origin %q not allowed", origin))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether the Host header of a request names this
+// server: an IP address, localhost or the host it listens on. Any other
+// name may have been rebound to this machine by a web page.
+func (s *Server) allowedHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if net.ParseIP(host) != nil || strings.EqualFold(host, "localhost") {
+		return true
+	}
+	listenHost, _, err := net.SplitHostPort(s.opts.Addr)
+	return err == nil && listenHost != "" && strings.EqualFold(host, listenHost)
+}
+
+// agentFor returns the agent that handles a session.
+func (s *Server) agentFor(sessionID string) agent.Service {
+	if s.services.AgentForSession != nil {
+		if a := s.services.AgentForSession(sessionID); a != nil {
+			return a
+		}
+	}
+	return s.services.Agent
+}
+
+// publishAgentEvents publishes the events of a run of an agent other than
+// the default one, whose events Start doesn't forward.
+func (s *Server) publishAgentEvents(done <-chan agent.AgentEvent) <-chan agent.AgentEvent {
+	out := make(chan agent.AgentEvent, 1)
+	go func() {
+		defer close(out)
+		for event := range done {
+			if converted, ok := agentEvent(pubsub.Event[agent.AgentEvent]{Type: pubsub.CreatedEvent, Payload: event}); ok {
+				s.events.Publish(pubsub.CreatedEvent, converted)
+			}
+			out <- event
+		}
+	}()
+	return out
+}
+
+func (s *Server) clearPending(toolCallID string) {
+	for id, req := range s.pending.Seq2() {
+		if req.ToolCallID == toolCallID {
+			s.pending.Del(id)
+		}
+	}
+}
+
+// forward subscribes to a service broker and republishes its events, after
+// conversion, on the server's own broker.
+func forward[T any](
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	subscribe func(context.Context) <-chan pubsub.Event[T],
+	out *pubsub.Broker[Event],
+	convert func(pubsub.Event[T]) (Event, bool),
+) {
+	ch := subscribe(ctx)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case event, ok := <-ch:
+				if !ok {
+					return
+				}
+				converted, ok := convert(event)
+				if !ok {
+					continue
+				}
+				out.Publish(pubsub.CreatedEvent, converted)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Warnings returns configuration problems worth telling the user about.
+func (o Options) Warnings() []string {
+	var warnings []string
+	if !isLoopback(o.Addr) && o.Token == "" {
+		warnings = append(warnings, "listening on a non-loopback address without a token; anyone on the network can drive the agent")
+	}
+	return warnings
+}
+
+func logRequestError(r *http.Request, err error) {
+	slog.Error("API request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+}
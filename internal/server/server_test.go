@@ -0,0 +1,194 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/llm/agent"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/permission"
+	"github.com/chasedut/toke/internal/pubsub"
+	"github.com/chasedut/toke/internal/server"
+	"github.com/chasedut/toke/internal/server/client"
+	"github.com/chasedut/toke/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAgent asks for a single permission and then replies with its outcome.
+type fakeAgent struct {
+	*pubsub.Broker[agent.AgentEvent]
+	messages    message.Service
+	permissions permission.Service
+}
+
+func (a *fakeAgent) Model() catwalk.Model { return catwalk.Model{} }
+
+func (a *fakeAgent) Run(ctx context.Context, sessionID string, content string, _ ...message.Attachment) (<-chan agent.AgentEvent, error) {
+	done := make(chan agent.AgentEvent, 1)
+	go func() {
+		defer close(done)
+		granted := a.permissions.Request(permission.CreatePermissionRequest{
+			SessionID:  sessionID,
+			ToolCallID: "call-1",
+			ToolName:   "bash",
+			Action:     "execute",
+			Path:       "/tmp",
+		})
+		reply := "denied"
+		if granted {
+			reply = "granted"
+		}
+		msg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+			Role:  message.Assistant,
+			Parts: []message.ContentPart{message.TextContent{Text: reply}},
+		})
+		event := agent.AgentEvent{Type: agent.AgentEventTypeResponse, Message: msg, Error: err}
+		a.Publish(pubsub.CreatedEvent, event)
+		done <- event
+	}()
+	return done, nil
+}
+
+func (a *fakeAgent) Cancel(string)                           {}
+func (a *fakeAgent) CancelAll()                              {}
+func (a *fakeAgent) IsSessionBusy(string) bool               { return false }
+func (a *fakeAgent) IsBusy() bool                            { return false }
+func (a *fakeAgent) UpdateModel() error                      { return nil }
+func (a *fakeAgent) Summarize(context.Context, string) error { return nil }
+
+func setup(t *testing.T, token string) (*client.Client, string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	conn, err := db.Connect(ctx, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	q := db.New(conn)
+	messages := message.NewService(q)
+	permissions := permission.NewPermissionService(t.TempDir(), false, nil, nil)
+	srv := server.New(server.Services{
+		Sessions:    session.NewService(q),
+		Messages:    messages,
+		Permissions: permissions,
+		Agent: &fakeAgent{
+			Broker:      pubsub.NewBroker[agent.AgentEvent](),
+			messages:    messages,
+			permissions: permissions,
+		},
+	}, server.Options{Token: token})
+	srv.Start(ctx)
+
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return client.New(ts.URL, token), ts.URL
+}
+
+func waitFor(t *testing.T, events <-chan server.Event, typ string) server.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			require.True(t, ok, "event stream closed waiting for %s", typ)
+			if e.Type == typ {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s", typ)
+		}
+	}
+}
+
+func TestServerRunWithPermission(t *testing.T) {
+	c, _ := setup(t, "secret")
+	ctx := t.Context()
+
+	sess, err := c.CreateSession(ctx, "api")
+	require.NoError(t, err)
+
+	events, err := c.Events(ctx, sess.ID)
+	require.NoError(t, err)
+
+	resp, err := c.Run(ctx, sess.ID, "list files", false)
+	require.NoError(t, err)
+	require.Equal(t, server.RunStatusStarted, resp.Status)
+
+	e := waitFor(t, events, server.EventPermissionRequest)
+	var req permission.PermissionRequest
+	require.NoError(t, json.Unmarshal(e.Data, &req))
+	require.Equal(t, "bash", req.ToolName)
+
+	pending, err := c.ListPermissions(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	require.NoError(t, c.DecidePermission(ctx, req.ID, server.DecisionAllow))
+
+	e = waitFor(t, events, server.EventAgentResponse)
+	var payload server.AgentEventPayload
+	require.NoError(t, json.Unmarshal(e.Data, &payload))
+	require.NotNil(t, payload.Message)
+
+	parts, err := payload.Message.ContentParts()
+	require.NoError(t, err)
+	require.Equal(t, []message.ContentPart{message.TextContent{Text: "granted"}}, parts)
+
+	msgs, err := c.ListMessages(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	pending, err = c.ListPermissions(ctx, sess.ID)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
+
+func TestServerErrors(t *testing.T) {
+	c, url := setup(t, "secret")
+	ctx := t.Context()
+
+	_, err := client.New(url, "wrong").ListSessions(ctx)
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	_, err = c.GetSession(ctx, "missing")
+	require.True(t, client.IsNotFound(err))
+
+	require.True(t, client.IsNotFound(c.DecidePermission(ctx, "missing", server.DecisionDeny)))
+}
+
+func TestServerRejectsBrowserRequests(t *testing.T) {
+	_, url := setup(t, "secret")
+
+	post := func(contentType string, modify func(*http.Request)) int {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, url+"/v1/sessions", strings.NewReader(`{"title":"api"}`))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Content-Type", contentType)
+		if modify != nil {
+			modify(req)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusCreated, post("application/json", nil))
+	require.Equal(t, http.StatusUnsupportedMediaType, post("text/plain", nil), "web pages can send text/plain without a preflight")
+	require.Equal(t, http.StatusForbidden, post("application/json", func(r *http.Request) {
+		r.Header.Set("Origin", "https://example.com")
+	}))
+	require.Equal(t, http.StatusForbidden, post("application/json", func(r *http.Request) {
+		r.Host = "rebound.example.com"
+	}), "a DNS name rebound to the loopback address")
+}
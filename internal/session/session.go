@@ -20,6 +20,8 @@ type Session struct {
 	Cost             float64
 	CreatedAt        int64
 	UpdatedAt        int64
+	// ForkedFromID is the session this one was forked from, if any.
+	ForkedFromID string
 }
 
 type Service interface {
@@ -27,6 +29,7 @@ type Service interface {
 	Create(ctx context.Context, title string) (Session, error)
 	CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error)
 	CreateTaskSession(ctx context.Context, toolCallID, parentSessionID, title string) (Session, error)
+	CreateFork(ctx context.Context, forkedFromID, title string) (Session, error)
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
 	Save(ctx context.Context, session Session) (Session, error)
@@ -65,6 +68,20 @@ func (s *service) CreateTaskSession(ctx context.Context, toolCallID, parentSessi
 	return session, nil
 }
 
+func (s *service) CreateFork(ctx context.Context, forkedFromID, title string) (Session, error) {
+	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
+		ID:                  uuid.New().String(),
+		Title:               title,
+		ForkedFromSessionID: sql.NullString{String: forkedFromID, Valid: true},
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	session := s.fromDBItem(dbSession)
+	s.Publish(pubsub.CreatedEvent, session)
+	return session, nil
+}
+
 func (s *service) CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error) {
 	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
 		ID:              "title-" + parentSessionID,
@@ -141,6 +158,7 @@ func (s service) fromDBItem(item db.Session) Session {
 		PromptTokens:     item.PromptTokens,
 		CompletionTokens: item.CompletionTokens,
 		SummaryMessageID: item.SummaryMessageID.String,
+		ForkedFromID:     item.ForkedFromSessionID.String,
 		Cost:             item.Cost,
 		CreatedAt:        item.CreatedAt,
 		UpdatedAt:        item.UpdatedAt,
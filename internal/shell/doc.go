@@ -16,7 +16,7 @@ package shell
 //	shell.Exec(ctx, "export FOO=bar")
 //	shell.Exec(ctx, "echo $FOO")  // Will print "bar"
 //
-// 3. For the singleton persistent shell (used by tools):
+// 3. For the persistent shell of a working directory (used by tools):
 //
 //	shell := shell.GetPersistentShell("/path/to/cwd")
 //	stdout, stderr, err := shell.Exec(ctx, "ls -la")
@@ -0,0 +1,250 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chasedut/toke/internal/pubsub"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// JobStatus is the state of a background job.
+type JobStatus string
+
+const (
+	JobRunning JobStatus = "running"
+	JobExited  JobStatus = "exited"
+	JobKilled  JobStatus = "killed"
+)
+
+// MaxJobOutput is the number of bytes of output a background job keeps.
+// Older output is dropped.
+const MaxJobOutput = 1 << 20
+
+// maxFinishedJobs is the number of ended background jobs a shell keeps, with
+// their output, for the job tools to report on.
+const maxFinishedJobs = 16
+
+// jobKillTimeout is how long Kill waits for a job to stop.
+const jobKillTimeout = 5 * time.Second
+
+// JobInfo is a snapshot of a background job.
+type JobInfo struct {
+	ID        string
+	SessionID string
+	Command   string
+	Dir       string
+	Status    JobStatus
+	// ExitCode is set once the job exited.
+	ExitCode  int
+	StartedAt time.Time
+	EndedAt   time.Time
+}
+
+// Job is a command running in the background of a shell.
+type Job struct {
+	mu     sync.Mutex
+	info   JobInfo
+	output []byte
+	// written and read count the bytes of output written by the command and
+	// returned by ReadOutput, including the ones that were dropped.
+	written int64
+	read    int64
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+var (
+	jobCounter atomic.Int64
+	jobBroker  = pubsub.NewBroker[JobInfo]()
+)
+
+// SubscribeJobs returns the events of background jobs of every shell: one
+// when a job starts and one when it ends.
+func SubscribeJobs(ctx context.Context) <-chan pubsub.Event[JobInfo] {
+	return jobBroker.Subscribe(ctx)
+}
+
+// Write appends the output of the command.
+func (j *Job) Write(p []byte) (int, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.output = append(j.output, p...)
+	if over := len(j.output) - MaxJobOutput; over > 0 {
+		j.output = slices.Delete(j.output, 0, over)
+	}
+	j.written += int64(len(p))
+	return len(p), nil
+}
+
+// Info returns a snapshot of the job.
+func (j *Job) Info() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.info
+}
+
+// ReadOutput returns the output written since the previous call.
+func (j *Job) ReadOutput() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var sb strings.Builder
+	start := j.written - int64(len(j.output))
+	if j.read < start {
+		fmt.Fprintf(&sb, "[%d bytes of output dropped]\n", start-j.read)
+		j.read = start
+	}
+	sb.Write(j.output[j.read-start:])
+	j.read = j.written
+	return sb.String()
+}
+
+// Done is closed when the job ends.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Kill stops the job and waits for it to end.
+func (j *Job) Kill() {
+	j.mu.Lock()
+	if j.info.Status == JobRunning {
+		j.info.Status = JobKilled
+	}
+	j.mu.Unlock()
+	j.cancel()
+	select {
+	case <-j.done:
+	case <-time.After(jobKillTimeout):
+	}
+}
+
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	if j.info.Status == JobRunning {
+		j.info.Status = JobExited
+	}
+	j.info.ExitCode = ExitCode(err)
+	j.info.EndedAt = time.Now()
+	info := j.info
+	j.mu.Unlock()
+	close(j.done)
+	jobBroker.Publish(pubsub.UpdatedEvent, info)
+}
+
+// Start runs a command in the background, in the current directory and
+// environment of the shell, for the given session. The command keeps running
+// after ctx is done, until it exits or is killed. Unlike Exec, it doesn't
+// change the directory or environment of the shell.
+func (s *Shell) Start(ctx context.Context, sessionID, command string) (*Job, error) {
+	s.mu.Lock()
+	dir, env := s.cwd, slices.Clone(s.env)
+	s.mu.Unlock()
+
+	if s.sandbox != nil {
+		if err := s.sandbox.Check(); err != nil {
+			return nil, err
+		}
+	}
+	line, err := syntax.NewParser().Parse(strings.NewReader(command), "")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse command: %w", err)
+	}
+
+	job := &Job{
+		info: JobInfo{
+			ID:        fmt.Sprintf("job-%d", jobCounter.Add(1)),
+			SessionID: sessionID,
+			Command:   command,
+			Dir:       dir,
+			Status:    JobRunning,
+			StartedAt: time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+	runner, err := s.newRunner(job, job, dir, env)
+	if err != nil {
+		return nil, fmt.Errorf("could not run command: %w", err)
+	}
+
+	ctx, job.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	s.jobsMu.Lock()
+	if s.jobs == nil {
+		s.jobs = make(map[string]*Job)
+	}
+	s.pruneJobs()
+	s.jobs[job.info.ID] = job
+	s.jobsMu.Unlock()
+	jobBroker.Publish(pubsub.CreatedEvent, job.Info())
+
+	go func() {
+		err := runner.Run(ctx, line)
+		s.logger.InfoPersist("Background command finished", "job", job.info.ID, "command", command, "err", err)
+		job.finish(err)
+	}()
+	return job, nil
+}
+
+// pruneJobs forgets the jobs that ended first, beyond maxFinishedJobs. It
+// must be called with jobsMu held.
+func (s *Shell) pruneJobs() {
+	var finished []*Job
+	for _, job := range s.jobs {
+		select {
+		case <-job.done:
+			finished = append(finished, job)
+		default:
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	slices.SortFunc(finished, func(a, b *Job) int {
+		return a.Info().EndedAt.Compare(b.Info().EndedAt)
+	})
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(s.jobs, job.info.ID)
+	}
+}
+
+// Job returns the background job with the given ID.
+func (s *Shell) Job(id string) (*Job, bool) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// Jobs returns the background jobs of a session, oldest first, or of every
+// session when sessionID is empty.
+func (s *Shell) Jobs(sessionID string) []*Job {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	var jobs []*Job
+	for _, job := range s.jobs {
+		if sessionID == "" || job.info.SessionID == sessionID {
+			jobs = append(jobs, job)
+		}
+	}
+	slices.SortFunc(jobs, func(a, b *Job) int {
+		return a.info.StartedAt.Compare(b.info.StartedAt)
+	})
+	return jobs
+}
+
+// KillJobs kills every background job of the shell that is still running.
+func (s *Shell) KillJobs() {
+	var wg sync.WaitGroup
+	for _, job := range s.Jobs("") {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job.Kill()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package shell
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobReadOutput(t *testing.T) {
+	job := &Job{}
+	job.Write([]byte("hello "))
+	job.Write([]byte("world"))
+	if got := job.ReadOutput(); got != "hello world" {
+		t.Fatalf("expected all output, got %q", got)
+	}
+	if got := job.ReadOutput(); got != "" {
+		t.Fatalf("expected no new output, got %q", got)
+	}
+
+	job.Write([]byte(strings.Repeat("x", MaxJobOutput+10)))
+	got := job.ReadOutput()
+	if !strings.HasPrefix(got, "[10 bytes of output dropped]\n") {
+		t.Fatalf("expected dropped output to be reported, got %q", got[:40])
+	}
+	if len(job.output) != MaxJobOutput {
+		t.Fatalf("expected output to be capped, got %d bytes", len(job.output))
+	}
+}
+
+func TestBackgroundJob(t *testing.T) {
+	shell := NewShell(&Options{WorkingDir: t.TempDir()})
+
+	job, err := shell.Start(context.Background(), "session", "echo started; sleep 60")
+	if err != nil {
+		t.Fatalf("failed to start job: %v", err)
+	}
+	if jobs := shell.Jobs("session"); len(jobs) != 1 || jobs[0] != job {
+		t.Fatalf("expected the job to be listed, got %v", jobs)
+	}
+	if jobs := shell.Jobs("other"); len(jobs) != 0 {
+		t.Fatalf("expected no jobs for another session, got %v", jobs)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var output string
+	for !strings.Contains(output, "started") && time.Now().Before(deadline) {
+		output += job.ReadOutput()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(output, "started") {
+		t.Fatalf("expected output from the job, got %q", output)
+	}
+
+	job.Kill()
+	if info := job.Info(); info.Status != JobKilled {
+		t.Fatalf("expected the job to be killed, got %s", info.Status)
+	}
+
+	job, err = shell.Start(context.Background(), "session", "exit 3")
+	if err != nil {
+		t.Fatalf("failed to start job: %v", err)
+	}
+	<-job.Done()
+	if info := job.Info(); info.Status != JobExited || info.ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %s %d", info.Status, info.ExitCode)
+	}
+}
+
+func TestFinishedJobsArePruned(t *testing.T) {
+	shell := NewShell(&Options{WorkingDir: t.TempDir()})
+
+	var first *Job
+	for i := range maxFinishedJobs + 1 {
+		job, err := shell.Start(context.Background(), "session", "true")
+		if err != nil {
+			t.Fatalf("failed to start job: %v", err)
+		}
+		if i == 0 {
+			first = job
+		}
+		<-job.Done()
+	}
+	if _, err := shell.Start(context.Background(), "session", "true"); err != nil {
+		t.Fatalf("failed to start job: %v", err)
+	}
+
+	if jobs := shell.Jobs("session"); len(jobs) != maxFinishedJobs+1 {
+		t.Fatalf("expected %d jobs to be kept, got %d", maxFinishedJobs+1, len(jobs))
+	}
+	if _, ok := shell.Job(first.Info().ID); ok {
+		t.Fatal("expected the job that ended first to be forgotten")
+	}
+}
@@ -2,31 +2,81 @@ package shell
 
 import (
 	"log/slog"
+	"maps"
+	"slices"
 	"sync"
 )
 
-// PersistentShell is a singleton shell instance that maintains state across the application
+// PersistentShell is a shell instance that maintains state across the
+// application. There is one per root working directory, so sessions running
+// in their own worktree don't share a current directory or environment.
 type PersistentShell struct {
 	*Shell
 }
 
 var (
-	once          sync.Once
-	shellInstance *PersistentShell
+	mu             sync.Mutex
+	shellInstances = make(map[string]*PersistentShell)
 )
 
-// GetPersistentShell returns the singleton persistent shell instance
-// This maintains backward compatibility with the existing API
+// GetPersistentShell returns the persistent shell instance rooted at cwd,
+// creating it on first use.
 func GetPersistentShell(cwd string) *PersistentShell {
-	once.Do(func() {
-		shellInstance = &PersistentShell{
-			Shell: NewShell(&Options{
-				WorkingDir: cwd,
-				Logger:     &loggingAdapter{},
-			}),
+	mu.Lock()
+	defer mu.Unlock()
+
+	if s, ok := shellInstances[cwd]; ok {
+		return s
+	}
+	s := &PersistentShell{
+		Shell: NewShell(&Options{
+			WorkingDir: cwd,
+			Logger:     &loggingAdapter{},
+		}),
+	}
+	shellInstances[cwd] = s
+	return s
+}
+
+// ReleasePersistentShell forgets the persistent shell rooted at cwd, e.g.
+// when its worktree is removed, and kills its background jobs.
+func ReleasePersistentShell(cwd string) {
+	mu.Lock()
+	s, ok := shellInstances[cwd]
+	delete(shellInstances, cwd)
+	mu.Unlock()
+	if ok {
+		s.KillJobs()
+	}
+}
+
+// ListJobs returns the background jobs of a session in every persistent
+// shell.
+func ListJobs(sessionID string) []JobInfo {
+	mu.Lock()
+	shells := slices.Collect(maps.Values(shellInstances))
+	mu.Unlock()
+	var jobs []JobInfo
+	for _, s := range shells {
+		for _, job := range s.Jobs(sessionID) {
+			jobs = append(jobs, job.Info())
 		}
+	}
+	slices.SortFunc(jobs, func(a, b JobInfo) int {
+		return a.StartedAt.Compare(b.StartedAt)
 	})
-	return shellInstance
+	return jobs
+}
+
+// KillAllJobs kills the background jobs of every persistent shell, e.g. on
+// shutdown.
+func KillAllJobs() {
+	mu.Lock()
+	shells := slices.Collect(maps.Values(shellInstances))
+	mu.Unlock()
+	for _, s := range shells {
+		s.KillJobs()
+	}
 }
 
 // slog.dapter adapts the internal slog.package to the Logger interface
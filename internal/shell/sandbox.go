@@ -0,0 +1,173 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"mvdan.cc/sh/v3/interp"
+)
+
+// ErrSandboxUnavailable is returned when commands should run in a sandbox
+// but this system can't provide one.
+var ErrSandboxUnavailable = errors.New("sandbox unavailable")
+
+// Sandbox jails the commands a shell runs with bubblewrap: the file system is
+// read-only except for the writable directories and the temporary directory,
+// there is no network unless it is granted, and CPU time and memory are
+// limited. It only works on Linux.
+type Sandbox struct {
+	// WritableDirs are bind-mounted read-write, usually the project directory.
+	WritableDirs []string
+	// Network allows network access for every command.
+	Network bool
+	// CPUTime limits the CPU time of every command. Zero means no limit.
+	CPUTime time.Duration
+	// Memory limits the virtual memory of every command, in bytes. Zero means
+	// no limit.
+	Memory int64
+	// Timeout limits how long a command can run. Zero means no limit.
+	Timeout time.Duration
+}
+
+type networkKey struct{}
+
+// WithNetwork grants network access to the sandboxed commands run with the
+// returned context.
+func WithNetwork(ctx context.Context) context.Context {
+	return context.WithValue(ctx, networkKey{}, true)
+}
+
+func networkGranted(ctx context.Context) bool {
+	granted, _ := ctx.Value(networkKey{}).(bool)
+	return granted
+}
+
+// Check returns an error wrapping ErrSandboxUnavailable when the sandbox
+// can't be used on this system.
+func (sb *Sandbox) Check() error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("%w: sandboxing is only supported on Linux", ErrSandboxUnavailable)
+	}
+	if _, err := exec.LookPath("bwrap"); err != nil {
+		return fmt.Errorf("%w: bubblewrap (bwrap) is not installed", ErrSandboxUnavailable)
+	}
+	return nil
+}
+
+// String describes the restrictions of the sandbox.
+func (sb *Sandbox) String() string {
+	parts := []string{"read-only root"}
+	if len(sb.WritableDirs) > 0 {
+		parts = append(parts, "writable "+strings.Join(sb.WritableDirs, ", "))
+	}
+	if sb.Network {
+		parts = append(parts, "network")
+	} else {
+		parts = append(parts, "no network")
+	}
+	if sb.CPUTime > 0 {
+		parts = append(parts, fmt.Sprintf("%s CPU", sb.CPUTime))
+	}
+	if sb.Memory > 0 {
+		parts = append(parts, fmt.Sprintf("%d MB memory", sb.Memory>>20))
+	}
+	if sb.Timeout > 0 {
+		parts = append(parts, fmt.Sprintf("%s timeout", sb.Timeout))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Args returns the command line that runs args inside the sandbox, starting
+// in dir.
+func (sb *Sandbox) Args(args []string, dir string, network bool) []string {
+	wrapped := []string{
+		"bwrap",
+		"--die-with-parent",
+		"--new-session",
+		"--unshare-pid",
+		"--unshare-ipc",
+		"--unshare-uts",
+	}
+	if !sb.Network && !network {
+		wrapped = append(wrapped, "--unshare-net")
+	}
+	wrapped = append(wrapped,
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+	)
+	for _, d := range sb.writableDirs() {
+		wrapped = append(wrapped, "--bind", d, d)
+	}
+	wrapped = append(wrapped, "--chdir", dir, "--")
+
+	var limits []string
+	if sb.CPUTime > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", max(int(sb.CPUTime.Seconds()), 1)))
+	}
+	if sb.Memory > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", max(sb.Memory>>10, 1)))
+	}
+	if len(limits) > 0 {
+		script := strings.Join(limits, " && ") + ` && exec "$@"`
+		wrapped = append(wrapped, "/bin/sh", "-c", script, "sh")
+	}
+	return append(wrapped, args...)
+}
+
+func (sb *Sandbox) writableDirs() []string {
+	return append([]string{os.TempDir()}, sb.WritableDirs...)
+}
+
+// writable reports whether the sandbox lets commands write to path.
+func (sb *Sandbox) writable(path string) bool {
+	if path == os.DevNull {
+		return true
+	}
+	for _, d := range sb.writableDirs() {
+		rel, err := filepath.Rel(d, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sandboxHandler runs every command that isn't a shell builtin inside the
+// sandbox, including the ones coreutils would otherwise run in-process.
+func (s *Shell) sandboxHandler() func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+		return func(ctx context.Context, args []string) error {
+			if s.sandbox == nil || len(args) == 0 {
+				return next(ctx, args)
+			}
+			dir := interp.HandlerCtx(ctx).Dir
+			return next(ctx, s.sandbox.Args(args, dir, networkGranted(ctx)))
+		}
+	}
+}
+
+// openHandler keeps redirections, which the interpreter performs itself,
+// from writing outside the sandbox.
+func (s *Shell) openHandler() interp.OpenHandlerFunc {
+	open := interp.DefaultOpenHandler()
+	return func(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+		if s.sandbox != nil && flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC) != 0 {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(interp.HandlerCtx(ctx).Dir, path)
+			}
+			if !s.sandbox.writable(filepath.Clean(path)) {
+				return nil, fmt.Errorf("%s: read-only file system (sandbox)", path)
+			}
+		}
+		return open(ctx, path, flag, perm)
+	}
+}
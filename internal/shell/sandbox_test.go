@@ -0,0 +1,78 @@
+package shell
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSandboxArgs(t *testing.T) {
+	sb := &Sandbox{WritableDirs: []string{"/project"}}
+	args := sb.Args([]string{"go", "test"}, "/project/cmd", false)
+
+	if args[0] != "bwrap" {
+		t.Fatalf("expected bwrap, got %v", args)
+	}
+	if !slices.Contains(args, "--unshare-net") {
+		t.Errorf("expected the network to be unshared: %v", args)
+	}
+	if !strings.Contains(strings.Join(args, " "), "--bind /project /project") {
+		t.Errorf("expected the project to be writable: %v", args)
+	}
+	if got := args[len(args)-3:]; !slices.Equal(got, []string{"--", "go", "test"}) {
+		t.Errorf("expected the command at the end, got %v", got)
+	}
+
+	if slices.Contains(sb.Args([]string{"curl"}, "/project", true), "--unshare-net") {
+		t.Error("expected granted network access")
+	}
+
+	sb.CPUTime = 30 * time.Second
+	sb.Memory = 512 << 20
+	limited := strings.Join(sb.Args([]string{"make"}, "/project", false), " ")
+	if !strings.Contains(limited, "ulimit -t 30 && ulimit -v 524288") {
+		t.Errorf("expected limits, got %s", limited)
+	}
+}
+
+func TestSandboxWritable(t *testing.T) {
+	sb := &Sandbox{WritableDirs: []string{"/project"}}
+	tests := map[string]bool{
+		"/project":                          true,
+		"/project/main.go":                  true,
+		"/project/../etc/passwd":            false,
+		"/projects/other":                   false,
+		"/etc/passwd":                       false,
+		os.DevNull:                          true,
+		filepath.Join(os.TempDir(), "x.go"): true,
+	}
+	for path, want := range tests {
+		if got := sb.writable(filepath.Clean(path)); got != want {
+			t.Errorf("writable(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestSandboxBlocksRedirectsOutside(t *testing.T) {
+	dir := t.TempDir()
+	// Outside both the project and the temporary directory.
+	outside := filepath.Join(string(filepath.Separator), "toke-sandbox-test")
+	shell := NewShell(&Options{WorkingDir: dir})
+	shell.sandbox = &Sandbox{WritableDirs: []string{dir}}
+
+	// Run the open handler directly, as commands need bubblewrap.
+	open := shell.openHandler()
+	ctx := context.Background()
+	if _, err := open(ctx, filepath.Join(outside, "out.txt"), os.O_WRONLY|os.O_CREATE, 0o644); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("expected a read-only error, got %v", err)
+	}
+	f, err := open(ctx, filepath.Join(dir, "out.txt"), os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatalf("expected to write in the project: %v", err)
+	}
+	f.Close()
+}
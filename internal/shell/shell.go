@@ -15,6 +15,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"sync"
@@ -54,6 +55,10 @@ type Shell struct {
 	mu         sync.Mutex
 	logger     Logger
 	blockFuncs []BlockFunc
+	sandbox    *Sandbox
+
+	jobsMu sync.Mutex
+	jobs   map[string]*Job
 }
 
 // Options for creating a new shell
@@ -62,6 +67,8 @@ type Options struct {
 	Env        []string
 	Logger     Logger
 	BlockFuncs []BlockFunc
+	// Sandbox, if set, jails every command the shell runs.
+	Sandbox *Sandbox
 }
 
 // NewShell creates a new shell instance with the given options
@@ -90,6 +97,7 @@ func NewShell(opts *Options) *Shell {
 		env:        env,
 		logger:     logger,
 		blockFuncs: opts.BlockFuncs,
+		sandbox:    opts.Sandbox,
 	}
 }
 
@@ -155,6 +163,14 @@ func (s *Shell) SetBlockFuncs(blockFuncs []BlockFunc) {
 	s.blockFuncs = blockFuncs
 }
 
+// SetSandbox sets the sandbox commands run in, or turns sandboxing off when
+// sandbox is nil.
+func (s *Shell) SetSandbox(sandbox *Sandbox) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sandbox = sandbox
+}
+
 // CommandsBlocker creates a BlockFunc that blocks exact command matches
 func CommandsBlocker(bannedCommands []string) BlockFunc {
 	bannedSet := make(map[string]bool)
@@ -211,19 +227,19 @@ func (s *Shell) blockHandler() func(next interp.ExecHandlerFunc) interp.ExecHand
 
 // execPOSIX executes commands using POSIX shell emulation (cross-platform)
 func (s *Shell) execPOSIX(ctx context.Context, command string) (string, string, error) {
+	if s.sandbox != nil {
+		if err := s.sandbox.Check(); err != nil {
+			return "", "", err
+		}
+	}
+
 	line, err := syntax.NewParser().Parse(strings.NewReader(command), "")
 	if err != nil {
 		return "", "", fmt.Errorf("could not parse command: %w", err)
 	}
 
 	var stdout, stderr bytes.Buffer
-	runner, err := interp.New(
-		interp.StdIO(nil, &stdout, &stderr),
-		interp.Interactive(false),
-		interp.Env(expand.ListEnviron(s.env...)),
-		interp.Dir(s.cwd),
-		interp.ExecHandlers(s.blockHandler(), coreutils.ExecHandler),
-	)
+	runner, err := s.newRunner(&stdout, &stderr, s.cwd, s.env)
 	if err != nil {
 		return "", "", fmt.Errorf("could not run command: %w", err)
 	}
@@ -238,6 +254,17 @@ func (s *Shell) execPOSIX(ctx context.Context, command string) (string, string,
 	return stdout.String(), stderr.String(), err
 }
 
+func (s *Shell) newRunner(stdout, stderr io.Writer, dir string, env []string) (*interp.Runner, error) {
+	return interp.New(
+		interp.StdIO(nil, stdout, stderr),
+		interp.Interactive(false),
+		interp.Env(expand.ListEnviron(env...)),
+		interp.Dir(dir),
+		interp.ExecHandlers(s.blockHandler(), s.sandboxHandler(), coreutils.ExecHandler),
+		interp.OpenHandler(s.openHandler()),
+	)
+}
+
 // IsInterrupt checks if an error is due to interruption
 func IsInterrupt(err error) bool {
 	return errors.Is(err, context.Canceled) ||
@@ -0,0 +1,88 @@
+package transcript
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/chasedut/toke/internal/diff"
+	"github.com/chasedut/toke/internal/history"
+)
+
+// FileDiff is the change a session made to a file, from the first version it
+// recorded to the last one.
+type FileDiff struct {
+	Path      string `json:"path"`
+	Diff      string `json:"diff"`
+	Additions int    `json:"additions"`
+	Removals  int    `json:"removals"`
+}
+
+// LoadFiles adds the file history of the session, with paths relative to
+// workingDir when they are inside it.
+func (t *Transcript) LoadFiles(ctx context.Context, files history.Service, workingDir string) error {
+	versions, err := files.ListBySession(ctx, t.Session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list file history: %w", err)
+	}
+	t.Files = make([]File, len(versions))
+	for i, f := range versions {
+		t.Files[i] = File{
+			Path:      relativePath(workingDir, f.Path),
+			Content:   f.Content,
+			Version:   f.Version,
+			CreatedAt: f.CreatedAt,
+		}
+	}
+	return nil
+}
+
+// relativePath returns path relative to dir if it is inside it.
+func relativePath(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// LoadDiffs adds the changes the session made to files, with paths relative
+// to workingDir when they are inside it. Files that ended up unchanged are
+// left out.
+func (t *Transcript) LoadDiffs(ctx context.Context, files history.Service, workingDir string) error {
+	versions, err := files.ListBySession(ctx, t.Session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list file history: %w", err)
+	}
+
+	// Versions are listed oldest first
+	first := make(map[string]history.File)
+	last := make(map[string]history.File)
+	for _, f := range versions {
+		if _, ok := first[f.Path]; !ok {
+			first[f.Path] = f
+		}
+		last[f.Path] = f
+	}
+
+	t.Diffs = nil
+	for path, before := range first {
+		after := last[path]
+		if before.Content == after.Content {
+			continue
+		}
+		path = relativePath(workingDir, path)
+		unified, additions, removals := diff.GenerateDiff(before.Content, after.Content, path)
+		t.Diffs = append(t.Diffs, FileDiff{
+			Path:      path,
+			Diff:      unified,
+			Additions: additions,
+			Removals:  removals,
+		})
+	}
+	slices.SortFunc(t.Diffs, func(a, b FileDiff) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return nil
+}
@@ -0,0 +1,79 @@
+package transcript
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+//go:embed transcript.html
+var htmlTemplate string
+
+// markdown renders the text of messages. Raw HTML in them is escaped, so
+// transcripts can be shared safely.
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+var htmlPage = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"markdown": func(s string) template.HTML {
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(s), &buf); err != nil {
+			return template.HTML("<pre>" + template.HTMLEscapeString(s) + "</pre>")
+		}
+		return template.HTML(buf.String())
+	},
+	"prettyJSON": prettyJSON,
+	"heading":    entryHeading,
+	"summary":    toolSummary,
+	"diffLines":  diffLines,
+}).Parse(htmlTemplate))
+
+// diffLine is a line of a unified diff with the class that colors it.
+type diffLine struct {
+	Class string
+	Text  string
+}
+
+func diffLines(diff string) []diffLine {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	out := make([]diffLine, len(lines))
+	for i, line := range lines {
+		class := ""
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			class = "file"
+		case strings.HasPrefix(line, "@@"):
+			class = "hunk"
+		case strings.HasPrefix(line, "+"):
+			class = "add"
+		case strings.HasPrefix(line, "-"):
+			class = "del"
+		}
+		out[i] = diffLine{Class: class, Text: line}
+	}
+	return out
+}
+
+// WriteHTML writes the transcript as a single HTML page without external
+// resources. Reasoning, tool calls and diffs are collapsible.
+func (t *Transcript) WriteHTML(w io.Writer) error {
+	entries, err := t.entries()
+	if err != nil {
+		return err
+	}
+	return htmlPage.Execute(w, struct {
+		Title      string
+		Transcript *Transcript
+		Created    string
+		Entries    []entry
+	}{
+		Title:      t.title(),
+		Transcript: t,
+		Created:    formatTime(t.Session.CreatedAt),
+		Entries:    entries,
+	})
+}
@@ -0,0 +1,157 @@
+package transcript
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/chasedut/toke/internal/history"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/session"
+)
+
+// Source is the assistant a conversation log comes from.
+type Source string
+
+const (
+	SourceToke       Source = "toke"
+	SourceClaudeCode Source = "claude-code"
+	SourceOpenAI     Source = "openai"
+)
+
+// Sources lists the supported sources.
+var Sources = []Source{SourceToke, SourceClaudeCode, SourceOpenAI}
+
+// Parse reads a toke JSON transcript, a Claude Code session log (JSONL) or
+// an OpenAI chat completion message list. An empty source detects it.
+func Parse(data []byte, source Source) (*Transcript, Source, error) {
+	if source == "" {
+		source = detectSource(data)
+	}
+	var (
+		t   *Transcript
+		err error
+	)
+	switch source {
+	case SourceToke:
+		t = &Transcript{}
+		if err = json.Unmarshal(data, t); err == nil && t.Version > Version {
+			err = fmt.Errorf("transcript version %d is newer than this toke supports (%d)", t.Version, Version)
+		}
+	case SourceClaudeCode:
+		t, err = parseClaudeCode(data)
+	case SourceOpenAI:
+		t, err = parseOpenAI(data)
+	default:
+		return nil, source, fmt.Errorf("unknown source %q, expected toke, claude-code or openai", source)
+	}
+	if err != nil {
+		return nil, source, fmt.Errorf("failed to read %s transcript: %w", source, err)
+	}
+	if len(t.Messages) == 0 {
+		return nil, source, errors.New("the transcript has no messages")
+	}
+	return t, source, nil
+}
+
+// detectSource guesses where a conversation log comes from.
+func detectSource(data []byte) Source {
+	data = bytes.TrimSpace(data)
+	var probe struct {
+		Version *int            `json:"version"`
+		Session json.RawMessage `json:"session"`
+	}
+	if json.Unmarshal(data, &probe) == nil && probe.Version != nil && probe.Session != nil {
+		return SourceToke
+	}
+	// A JSON array or object with messages, as opposed to JSON lines
+	if json.Valid(data) {
+		return SourceOpenAI
+	}
+	return SourceClaudeCode
+}
+
+// Save recreates the session of the transcript with its messages and file
+// history. The session and messages get new IDs so that a transcript can be
+// imported more than once. Relative file paths are resolved against
+// workingDir.
+func (t *Transcript) Save(ctx context.Context, sessions session.Service, messages message.Service, files history.Service, workingDir string) (session.Session, error) {
+	sess, err := sessions.Create(ctx, t.Session.Title)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	if err := t.save(ctx, &sess, messages, files, workingDir); err != nil {
+		// Messages and files are removed by the foreign key cascade
+		_ = sessions.Delete(ctx, sess.ID)
+		return session.Session{}, err
+	}
+	saved, err := sessions.Save(ctx, sess)
+	if err != nil {
+		_ = sessions.Delete(ctx, sess.ID)
+		return session.Session{}, fmt.Errorf("failed to save session: %w", err)
+	}
+	return saved, nil
+}
+
+func (t *Transcript) save(ctx context.Context, sess *session.Session, messages message.Service, files history.Service, workingDir string) error {
+	// Foreign logs may not have timestamps, keep their messages in order
+	// anyway
+	fallback := time.Now().Unix() - int64(len(t.Messages))
+	for i, m := range t.Messages {
+		parts, err := m.ContentParts()
+		if err != nil {
+			return fmt.Errorf("failed to decode message %s: %w", m.ID, err)
+		}
+		createdAt, updatedAt := m.CreatedAt, m.UpdatedAt
+		if createdAt == 0 {
+			createdAt = fallback + int64(i)
+		}
+		if updatedAt == 0 {
+			updatedAt = createdAt
+		}
+		msg, err := messages.Import(ctx, sess.ID, message.Message{
+			Role:      message.MessageRole(m.Role),
+			Parts:     parts,
+			Model:     m.Model,
+			Provider:  m.Provider,
+			Author:    m.Author,
+			CreatedAt: createdAt,
+			UpdatedAt: updatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to import message %s: %w", m.ID, err)
+		}
+		if m.ID != "" && m.ID == t.Session.SummaryMessageID {
+			sess.SummaryMessageID = msg.ID
+		}
+	}
+
+	// Versions are numbered per path across sessions, so only their order
+	// is kept
+	seen := make(map[string]bool)
+	for _, f := range t.Files {
+		path := f.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		var err error
+		if seen[path] {
+			_, err = files.CreateVersion(ctx, sess.ID, path, f.Content)
+		} else {
+			_, err = files.Create(ctx, sess.ID, path, f.Content)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to import the history of %s: %w", f.Path, err)
+		}
+		seen[path] = true
+	}
+
+	sess.PromptTokens = t.Session.PromptTokens
+	sess.CompletionTokens = t.Session.CompletionTokens
+	sess.Cost = t.Session.Cost
+	return nil
+}
@@ -0,0 +1,356 @@
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chasedut/toke/internal/message"
+)
+
+// builder collects the messages of a foreign conversation log. Tool results
+// are stored in tool messages of their own, like toke does.
+type builder struct {
+	t *Transcript
+	// toolNames maps tool call IDs to the name of the tool, which tool
+	// results repeat.
+	toolNames map[string]string
+}
+
+func newBuilder() *builder {
+	return &builder{t: &Transcript{Version: Version}, toolNames: make(map[string]string)}
+}
+
+// add appends a message. Messages of the user and tool results end with a
+// stop, like the ones toke creates.
+func (b *builder) add(role message.MessageRole, model, provider string, createdAt int64, parts []message.ContentPart) error {
+	if len(parts) == 0 {
+		return nil
+	}
+	for _, part := range parts {
+		if c, ok := part.(message.ToolCall); ok {
+			b.toolNames[c.ID] = c.Name
+		}
+	}
+	switch role {
+	case message.Assistant:
+		reason := message.FinishReasonEndTurn
+		for _, part := range parts {
+			if _, ok := part.(message.ToolCall); ok {
+				reason = message.FinishReasonToolUse
+			}
+		}
+		parts = append(parts, message.Finish{Reason: reason, Time: createdAt})
+	default:
+		parts = append(parts, message.Finish{Reason: "stop"})
+	}
+	m, err := FromMessage(message.Message{
+		ID:        fmt.Sprintf("%d", len(b.t.Messages)+1),
+		Role:      role,
+		Parts:     parts,
+		Model:     model,
+		Provider:  provider,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	})
+	if err != nil {
+		return err
+	}
+	b.t.Messages = append(b.t.Messages, m)
+	return nil
+}
+
+// finish sets the title of the session, taken from the first prompt unless
+// the log has one.
+func (b *builder) finish(title string) *Transcript {
+	if title == "" {
+		for _, m := range b.t.Messages {
+			if m.Role != string(message.User) {
+				continue
+			}
+			parts, _ := m.ContentParts()
+			for _, part := range parts {
+				if text, ok := part.(message.TextContent); ok && strings.TrimSpace(text.Text) != "" {
+					title = strings.TrimSpace(text.Text)
+					break
+				}
+			}
+			if title != "" {
+				break
+			}
+		}
+		if i := strings.IndexByte(title, '\n'); i >= 0 {
+			title = title[:i]
+		}
+		if r := []rune(title); len(r) > 80 {
+			title = string(r[:79]) + "…"
+		}
+	}
+	b.t.Session.Title = title
+	b.t.ExportedAt = time.Now().Unix()
+	return b.t
+}
+
+// claudeLine is a line of a Claude Code session log.
+type claudeLine struct {
+	Type      string `json:"type"`
+	Summary   string `json:"summary"`
+	IsMeta    bool   `json:"isMeta"`
+	Timestamp string `json:"timestamp"`
+	Message   struct {
+		ID      string          `json:"id"`
+		Role    string          `json:"role"`
+		Model   string          `json:"model"`
+		Content json.RawMessage `json:"content"`
+		Usage   struct {
+			InputTokens              int64 `json:"input_tokens"`
+			OutputTokens             int64 `json:"output_tokens"`
+			CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+// claudeBlock is a content block of the Anthropic messages API.
+type claudeBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	Thinking  string          `json:"thinking"`
+	Signature string          `json:"signature"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+// claudeBlocks decodes content that is either a string or a list of blocks.
+func claudeBlocks(content json.RawMessage) ([]claudeBlock, error) {
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return []claudeBlock{{Type: "text", Text: text}}, nil
+	}
+	var blocks []claudeBlock
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &blocks); err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
+
+// blocksText joins the text of tool result content.
+func blocksText(content json.RawMessage) string {
+	blocks, err := claudeBlocks(content)
+	if err != nil {
+		return string(content)
+	}
+	var texts []string
+	for _, b := range blocks {
+		if b.Type == "text" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// parseClaudeCode reads a Claude Code session log, one JSON object per line.
+// Assistant messages are logged once per content block, the blocks of a
+// message are merged again.
+func parseClaudeCode(data []byte) (*Transcript, error) {
+	b := newBuilder()
+	var (
+		title   string
+		pending struct {
+			id, model string
+			createdAt int64
+			parts     []message.ContentPart
+		}
+		usage = make(map[string][2]int64)
+	)
+	flush := func() error {
+		err := b.add(message.Assistant, pending.model, "anthropic", pending.createdAt, pending.parts)
+		pending.id, pending.parts = "", nil
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line claudeLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if line.Type == "summary" && title == "" {
+			title = line.Summary
+		}
+		if (line.Type != "user" && line.Type != "assistant") || line.IsMeta {
+			continue
+		}
+		var createdAt int64
+		if ts, err := time.Parse(time.RFC3339, line.Timestamp); err == nil {
+			createdAt = ts.Unix()
+		}
+		blocks, err := claudeBlocks(line.Message.Content)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		if line.Type == "assistant" {
+			if pending.id != line.Message.ID || pending.id == "" {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				pending.id, pending.model, pending.createdAt = line.Message.ID, line.Message.Model, createdAt
+			}
+			u := line.Message.Usage
+			usage[line.Message.ID] = [2]int64{u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens, u.OutputTokens}
+			for _, block := range blocks {
+				switch block.Type {
+				case "text":
+					pending.parts = append(pending.parts, message.TextContent{Text: block.Text})
+				case "thinking":
+					pending.parts = append(pending.parts, message.ReasoningContent{Thinking: block.Thinking, Signature: block.Signature})
+				case "tool_use":
+					pending.parts = append(pending.parts, message.ToolCall{ID: block.ID, Name: block.Name, Input: string(block.Input), Type: "tool_use", Finished: true})
+				}
+			}
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		var text, results []message.ContentPart
+		for _, block := range blocks {
+			switch block.Type {
+			case "text":
+				text = append(text, message.TextContent{Text: block.Text})
+			case "tool_result":
+				results = append(results, message.ToolResult{
+					ToolCallID: block.ToolUseID,
+					Name:       b.toolNames[block.ToolUseID],
+					Content:    blocksText(block.Content),
+					IsError:    block.IsError,
+				})
+			}
+		}
+		if err := b.add(message.Tool, "", "", createdAt, results); err != nil {
+			return nil, err
+		}
+		if err := b.add(message.User, "", "", createdAt, text); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	for _, u := range usage {
+		b.t.Session.PromptTokens += u[0]
+		b.t.Session.CompletionTokens += u[1]
+	}
+	return b.finish(title), nil
+}
+
+// openAIMessage is a message of the OpenAI chat completions API.
+type openAIMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	Reasoning  string          `json:"reasoning_content"`
+	ToolCallID string          `json:"tool_call_id"`
+	Name       string          `json:"name"`
+	ToolCalls  []struct {
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
+
+// openAIText returns the text of content that is either a string or a list
+// of parts.
+func openAIText(content json.RawMessage) string {
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return text
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	_ = json.Unmarshal(content, &parts)
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// parseOpenAI reads a list of chat completion messages, either on its own or
+// in the messages of a request.
+func parseOpenAI(data []byte) (*Transcript, error) {
+	var request struct {
+		Model    string          `json:"model"`
+		Messages []openAIMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &request.Messages); err != nil {
+		if err := json.Unmarshal(data, &request); err != nil {
+			return nil, err
+		}
+	}
+
+	b := newBuilder()
+	for _, m := range request.Messages {
+		var (
+			role  message.MessageRole
+			parts []message.ContentPart
+		)
+		switch m.Role {
+		case "user":
+			role = message.User
+			if text := openAIText(m.Content); text != "" {
+				parts = append(parts, message.TextContent{Text: text})
+			}
+		case "assistant":
+			role = message.Assistant
+			if m.Reasoning != "" {
+				parts = append(parts, message.ReasoningContent{Thinking: m.Reasoning})
+			}
+			if text := openAIText(m.Content); text != "" {
+				parts = append(parts, message.TextContent{Text: text})
+			}
+			for _, c := range m.ToolCalls {
+				parts = append(parts, message.ToolCall{ID: c.ID, Name: c.Function.Name, Input: c.Function.Arguments, Type: "function", Finished: true})
+			}
+		case "tool", "function":
+			role = message.Tool
+			name := m.Name
+			if name == "" {
+				name = b.toolNames[m.ToolCallID]
+			}
+			parts = append(parts, message.ToolResult{ToolCallID: m.ToolCallID, Name: name, Content: openAIText(m.Content)})
+		default:
+			// System prompts are toke's own
+			continue
+		}
+		if err := b.add(role, request.Model, "", 0, parts); err != nil {
+			return nil, err
+		}
+	}
+	return b.finish(""), nil
+}
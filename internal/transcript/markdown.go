@@ -0,0 +1,120 @@
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// backticks matches the runs of backticks that could close a code fence.
+var backticks = regexp.MustCompile("`{3,}")
+
+// WriteMarkdown writes the transcript as Markdown. Reasoning, tool calls and
+// diffs are folded in <details> blocks, which GitHub renders collapsed.
+func (t *Transcript) WriteMarkdown(w io.Writer) error {
+	entries, err := t.entries()
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n\n", t.title())
+	fmt.Fprintf(bw, "| | |\n|---|---|\n")
+	fmt.Fprintf(bw, "| Session | `%s` |\n", t.Session.ID)
+	if created := formatTime(t.Session.CreatedAt); created != "" {
+		fmt.Fprintf(bw, "| Created | %s |\n", created)
+	}
+	fmt.Fprintf(bw, "| Messages | %d |\n", len(t.Messages))
+	fmt.Fprintf(bw, "| Tokens | %d prompt, %d completion |\n", t.Session.PromptTokens, t.Session.CompletionTokens)
+	fmt.Fprintf(bw, "| Cost | $%.4f |\n", t.Session.Cost)
+
+	for _, e := range entries {
+		fmt.Fprintf(bw, "\n## %s\n\n", entryHeading(e))
+		for _, b := range e.Blocks {
+			switch b.Kind {
+			case "text":
+				fmt.Fprintf(bw, "%s\n\n", b.Text)
+			case "reasoning":
+				fmt.Fprintf(bw, "<details>\n<summary>Thinking</summary>\n\n%s\n\n</details>\n\n", b.Text)
+			case "tool":
+				fmt.Fprintf(bw, "<details>\n<summary>%s</summary>\n\n", toolSummary(b))
+				if b.Input != "" {
+					fmt.Fprintf(bw, "**Input**\n\n%s\n\n", codeBlock(prettyJSON(b.Input), "json"))
+				}
+				if !b.Pending {
+					fmt.Fprintf(bw, "**Output**\n\n%s\n\n", codeBlock(b.Output, ""))
+				}
+				fmt.Fprintf(bw, "</details>\n\n")
+			case "attachment":
+				fmt.Fprintf(bw, "📎 `%s`\n\n", b.Text)
+			case "error":
+				fmt.Fprintf(bw, "> **Error:** %s\n\n", strings.ReplaceAll(b.Text, "\n", "\n> "))
+			}
+		}
+	}
+
+	if len(t.Diffs) > 0 {
+		fmt.Fprintf(bw, "\n## Changes\n\n")
+		for _, d := range t.Diffs {
+			fmt.Fprintf(bw, "<details>\n<summary><code>%s</code> +%d −%d</summary>\n\n%s\n\n</details>\n\n",
+				d.Path, d.Additions, d.Removals, codeBlock(d.Diff, "diff"))
+		}
+	}
+	return bw.Flush()
+}
+
+// entryHeading returns the heading of a message, such as
+// "Assistant · gpt-4o · 2025-01-02 15:04".
+func entryHeading(e entry) string {
+	role := e.Role
+	if role != "" {
+		role = strings.ToUpper(role[:1]) + role[1:]
+	}
+	parts := []string{role}
+	if e.Author != "" {
+		parts[0] += " (proposed by " + e.Author + ")"
+	}
+	if e.Model != "" {
+		parts = append(parts, e.Model)
+	}
+	if e.Time != "" {
+		parts = append(parts, e.Time)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// toolSummary returns the one line shown for a folded tool call.
+func toolSummary(b block) string {
+	switch {
+	case b.Pending:
+		return "⏳ " + b.Name
+	case b.IsError:
+		return "✗ " + b.Name
+	}
+	return "🔧 " + b.Name
+}
+
+// codeBlock fences s with more backticks than it contains in a row.
+func codeBlock(s, lang string) string {
+	fence := "```"
+	for _, run := range backticks.FindAllString(s, -1) {
+		if len(run) >= len(fence) {
+			fence = strings.Repeat("`", len(run)+1)
+		}
+	}
+	return fence + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + fence
+}
+
+// prettyJSON indents tool inputs, which are JSON objects, and returns other
+// input unchanged.
+func prettyJSON(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String()
+}
@@ -0,0 +1,98 @@
+package transcript
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/chasedut/toke/internal/message"
+)
+
+// Redacted replaces the secrets removed by Redact.
+const Redacted = "[REDACTED]"
+
+// secretPatterns match well-known credential formats. The first group of a
+// pattern, if any, is kept so that the name of a redacted setting stays
+// readable.
+var secretPatterns = []*regexp.Regexp{
+	// Private keys
+	regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`),
+	// AWS access keys
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	// GitHub tokens
+	regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+	// OpenAI, Anthropic and other sk- keys
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),
+	// Slack tokens
+	regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),
+	// Google API keys
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),
+	// JSON web tokens
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`),
+	// Bearer tokens in headers
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{16,}`),
+	// Assignments of secret-looking settings, such as API_KEY=... or
+	// "password": "..."
+	regexp.MustCompile(`(?i)((?:api[_-]?key|secret|token|passw(?:or)?d|credentials?)["']?\s*[:=]\s*["']?)[^\s"',;]{8,}`),
+}
+
+// Redact replaces credentials in the messages and files of the transcript,
+// along with every occurrence of the given secrets, such as the API keys of
+// the configured providers.
+func (t *Transcript) Redact(secrets ...string) error {
+	redact := func(s string) string {
+		for _, secret := range secrets {
+			// Short values would redact unrelated text
+			if len(secret) >= 8 {
+				s = strings.ReplaceAll(s, secret, Redacted)
+			}
+		}
+		for _, re := range secretPatterns {
+			if re.NumSubexp() > 0 {
+				s = re.ReplaceAllString(s, "${1}"+Redacted)
+			} else {
+				s = re.ReplaceAllLiteralString(s, Redacted)
+			}
+		}
+		return s
+	}
+
+	t.Session.Title = redact(t.Session.Title)
+	for i, m := range t.Messages {
+		parts, err := m.ContentParts()
+		if err != nil {
+			return fmt.Errorf("failed to decode message %s: %w", m.ID, err)
+		}
+		for j, part := range parts {
+			switch p := part.(type) {
+			case message.TextContent:
+				p.Text = redact(p.Text)
+				parts[j] = p
+			case message.ReasoningContent:
+				p.Thinking = redact(p.Thinking)
+				parts[j] = p
+			case message.ToolCall:
+				p.Input = redact(p.Input)
+				parts[j] = p
+			case message.ToolResult:
+				p.Content = redact(p.Content)
+				p.Metadata = redact(p.Metadata)
+				parts[j] = p
+			case message.Finish:
+				p.Message = redact(p.Message)
+				p.Details = redact(p.Details)
+				parts[j] = p
+			}
+		}
+		if t.Messages[i].Parts, err = message.MarshalParts(parts); err != nil {
+			return fmt.Errorf("failed to encode message %s: %w", m.ID, err)
+		}
+	}
+	for i := range t.Diffs {
+		t.Diffs[i].Diff = redact(t.Diffs[i].Diff)
+	}
+	for i := range t.Files {
+		t.Files[i].Content = redact(t.Files[i].Content)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package transcript
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/chasedut/toke/internal/message"
+)
+
+// Format is a format a transcript can be written in.
+type Format string
+
+const (
+	FormatJSON     Format = "json"
+	FormatMarkdown Format = "md"
+	FormatHTML     Format = "html"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{FormatHTML, FormatMarkdown, FormatJSON}
+
+// ParseFormat returns the format with the given name or file extension.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "json":
+		return FormatJSON, nil
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	case "html", "htm":
+		return FormatHTML, nil
+	}
+	return "", fmt.Errorf("unknown format %q, expected html, md or json", s)
+}
+
+// Write writes the transcript in the given format.
+func (t *Transcript) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSON:
+		return t.WriteJSON(w)
+	case FormatMarkdown:
+		return t.WriteMarkdown(w)
+	case FormatHTML:
+		return t.WriteHTML(w)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// entry is a message as it is shown in a document. Tool results are shown
+// with the tool call they answer instead of as messages of their own.
+type entry struct {
+	Role   string
+	Model  string
+	Author string
+	Time   string
+	Blocks []block
+}
+
+// block is a part of an entry.
+type block struct {
+	// Kind is text, reasoning, tool, attachment or error.
+	Kind string
+	Text string
+	// Tool calls only
+	Name    string
+	Input   string
+	Output  string
+	IsError bool
+	Pending bool
+}
+
+// entries returns the messages of the transcript as they are shown in a
+// document.
+func (t *Transcript) entries() ([]entry, error) {
+	decoded := make([][]message.ContentPart, len(t.Messages))
+	results := make(map[string]message.ToolResult)
+	for i, m := range t.Messages {
+		parts, err := m.ContentParts()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode message %s: %w", m.ID, err)
+		}
+		decoded[i] = parts
+		for _, part := range parts {
+			if r, ok := part.(message.ToolResult); ok {
+				results[r.ToolCallID] = r
+			}
+		}
+	}
+
+	var entries []entry
+	for i, m := range t.Messages {
+		if m.Role == string(message.Tool) {
+			continue
+		}
+		e := entry{
+			Role:   m.Role,
+			Model:  m.Model,
+			Author: m.Author,
+			Time:   formatTime(m.CreatedAt),
+		}
+		for _, part := range decoded[i] {
+			switch p := part.(type) {
+			case message.TextContent:
+				if text := strings.TrimSpace(p.Text); text != "" {
+					e.Blocks = append(e.Blocks, block{Kind: "text", Text: text})
+				}
+			case message.ReasoningContent:
+				if text := strings.TrimSpace(p.Thinking); text != "" {
+					e.Blocks = append(e.Blocks, block{Kind: "reasoning", Text: text})
+				}
+			case message.ToolCall:
+				b := block{Kind: "tool", Name: p.Name, Input: p.Input, Pending: true}
+				if r, ok := results[p.ID]; ok {
+					b.Output, b.IsError, b.Pending = r.Content, r.IsError, false
+				}
+				e.Blocks = append(e.Blocks, b)
+			case message.BinaryContent:
+				e.Blocks = append(e.Blocks, block{Kind: "attachment", Text: p.Path})
+			case message.ImageURLContent:
+				e.Blocks = append(e.Blocks, block{Kind: "attachment", Text: p.URL})
+			case message.Finish:
+				if p.Reason == message.FinishReasonError || p.Reason == message.FinishReasonCanceled {
+					text := strings.TrimSpace(p.Message + "\n" + p.Details)
+					if text == "" {
+						text = string(p.Reason)
+					}
+					e.Blocks = append(e.Blocks, block{Kind: "error", Text: text})
+				}
+			}
+		}
+		if len(e.Blocks) > 0 {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// formatTime formats a Unix timestamp for documents.
+func formatTime(ts int64) string {
+	if ts == 0 {
+		return ""
+	}
+	return time.Unix(ts, 0).Format("2006-01-02 15:04")
+}
+
+// title returns the title of the session, or a placeholder for untitled
+// ones.
+func (t *Transcript) title() string {
+	if t.Session.Title != "" {
+		return t.Session.Title
+	}
+	return "Untitled session"
+}
+
+// FileName returns a name for the transcript file, such as
+// "fix-the-build-3f2c1a2b.html".
+func (t *Transcript) FileName(format Format) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(t.Session.Title) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if slug.Len() >= 40 {
+			break
+		}
+	}
+	id := t.Session.ID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	if slug.Len() == 0 {
+		return fmt.Sprintf("session-%s.%s", id, format)
+	}
+	return fmt.Sprintf("%s-%s.%s", slug.String(), id, format)
+}
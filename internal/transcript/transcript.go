@@ -0,0 +1,141 @@
+// Package transcript converts sessions and their messages to and from
+// portable, self-contained documents.
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/session"
+)
+
+// Version is the current version of the JSON transcript format.
+const Version = 1
+
+// Transcript is a session together with its full message history.
+type Transcript struct {
+	Version    int       `json:"version"`
+	ExportedAt int64     `json:"exported_at"`
+	Session    Session   `json:"session"`
+	Messages   []Message `json:"messages"`
+	// Diffs are the changes the session made to files, when they were
+	// loaded with LoadDiffs.
+	Diffs []FileDiff `json:"diffs,omitempty"`
+	// Files are the versions of the files the session changed, when they
+	// were loaded with LoadFiles, so that importing the transcript restores
+	// its file history.
+	Files []File `json:"files,omitempty"`
+}
+
+// Session mirrors session.Session with stable JSON names.
+type Session struct {
+	ID               string  `json:"id"`
+	ParentSessionID  string  `json:"parent_session_id,omitempty"`
+	Title            string  `json:"title"`
+	MessageCount     int64   `json:"message_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	SummaryMessageID string  `json:"summary_message_id,omitempty"`
+	Cost             float64 `json:"cost"`
+	CreatedAt        int64   `json:"created_at"`
+	UpdatedAt        int64   `json:"updated_at"`
+}
+
+// Message mirrors message.Message. Parts are kept in the tagged storage
+// format so that every content part type survives a round trip.
+type Message struct {
+	ID        string          `json:"id"`
+	Role      string          `json:"role"`
+	Model     string          `json:"model,omitempty"`
+	Provider  string          `json:"provider,omitempty"`
+	Author    string          `json:"author,omitempty"`
+	Parts     json.RawMessage `json:"parts"`
+	CreatedAt int64           `json:"created_at"`
+	UpdatedAt int64           `json:"updated_at"`
+}
+
+// File mirrors history.File. Paths inside the working directory are relative
+// to it.
+type File struct {
+	Path      string `json:"path"`
+	Content   string `json:"content"`
+	Version   int64  `json:"version"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Load builds the transcript of a session.
+func Load(ctx context.Context, sessions session.Service, messages message.Service, sessionID string) (*Transcript, error) {
+	sess, err := sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session %s: %w", sessionID, err)
+	}
+	msgs, err := messages.List(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	t := &Transcript{
+		Version:    Version,
+		ExportedAt: time.Now().Unix(),
+		Session:    FromSession(sess),
+		Messages:   make([]Message, 0, len(msgs)),
+	}
+	for _, msg := range msgs {
+		m, err := FromMessage(msg)
+		if err != nil {
+			return nil, err
+		}
+		t.Messages = append(t.Messages, m)
+	}
+	return t, nil
+}
+
+// FromSession converts a session.
+func FromSession(s session.Session) Session {
+	return Session{
+		ID:               s.ID,
+		ParentSessionID:  s.ParentSessionID,
+		Title:            s.Title,
+		MessageCount:     s.MessageCount,
+		PromptTokens:     s.PromptTokens,
+		CompletionTokens: s.CompletionTokens,
+		SummaryMessageID: s.SummaryMessageID,
+		Cost:             s.Cost,
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+	}
+}
+
+// FromMessage converts a message.
+func FromMessage(m message.Message) (Message, error) {
+	parts, err := message.MarshalParts(m.Parts)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to encode message %s: %w", m.ID, err)
+	}
+	return Message{
+		ID:        m.ID,
+		Role:      string(m.Role),
+		Model:     m.Model,
+		Provider:  m.Provider,
+		Author:    m.Author,
+		Parts:     parts,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}, nil
+}
+
+// ContentParts decodes the message parts.
+func (m Message) ContentParts() ([]message.ContentPart, error) {
+	return message.UnmarshalParts(m.Parts)
+}
+
+// WriteJSON writes the transcript as indented JSON.
+func (t *Transcript) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
@@ -29,6 +29,13 @@ type SessionSelectedMsg = session.Session
 
 type SessionClearedMsg struct{}
 
+// MessageSelectedMsg opens a session and selects one of its messages or tool
+// calls, such as a search result.
+type MessageSelectedMsg struct {
+	Session   session.Session
+	MessageID string
+}
+
 type SelectionCopyMsg struct {
 	clickCount   int
 	endSelection bool
@@ -48,6 +55,7 @@ type MessageListCmp interface {
 	layout.Help
 
 	SetSession(session.Session) tea.Cmd
+	SelectMessage(id string) tea.Cmd
 	GoToBottom() tea.Cmd
 	GetSelectedText() string
 	CopySelectedText(bool) tea.Cmd
@@ -208,6 +216,10 @@ func (m *messageListCmp) View() string {
 }
 
 func (m *messageListCmp) handlePermissionRequest(permission permission.PermissionNotification) tea.Cmd {
+	// Automatic grants never prompted the user, so there is nothing to show.
+	if permission.Auto {
+		return nil
+	}
 	items := m.listCmp.Items()
 	if toolCallIndex := m.findToolCallByID(items, permission.ToolCallID); toolCallIndex != NotFound {
 		toolCall := items[toolCallIndex].(messages.ToolCallCmp)
@@ -302,6 +314,12 @@ func (m *messageListCmp) handleMessageEvent(event pubsub.Event[message.Message])
 		case message.Tool:
 			return m.handleToolMessage(event.Payload)
 		}
+	case pubsub.DeletedEvent:
+		// Messages are only deleted when a session is rewound, which can
+		// remove any number of them, so reload the whole list.
+		if event.Payload.SessionID == m.session.ID && m.messageExists(event.Payload.ID) {
+			return m.loadMessages()
+		}
 	}
 	return nil
 }
@@ -506,7 +524,12 @@ func (m *messageListCmp) SetSession(session session.Session) tea.Cmd {
 	}
 
 	m.session = session
-	sessionMessages, err := m.app.Messages.List(context.Background(), session.ID)
+	return m.loadMessages()
+}
+
+// loadMessages replaces the list with the messages of the current session.
+func (m *messageListCmp) loadMessages() tea.Cmd {
+	sessionMessages, err := m.app.Messages.List(context.Background(), m.session.ID)
 	if err != nil {
 		return util.ReportError(err)
 	}
@@ -643,6 +666,24 @@ func (m *messageListCmp) Bindings() []key.Binding {
 	return m.defaultListKeyMap.KeyBindings()
 }
 
+// SelectMessage selects the item showing a message or tool call of the
+// current session. Tool results are shown with their tool call, and messages
+// that only call tools with their first tool call.
+func (m *messageListCmp) SelectMessage(id string) tea.Cmd {
+	if msg, err := m.app.Messages.Get(context.Background(), id); err == nil && msg.Role == message.Tool {
+		if results := msg.ToolResults(); len(results) > 0 {
+			id = results[0].ToolCallID
+		}
+	}
+	for _, item := range m.listCmp.Items() {
+		toolCall, isToolCall := item.(messages.ToolCallCmp)
+		if item.ID() == id || (isToolCall && toolCall.ParentMessageID() == id) {
+			return m.listCmp.SetSelected(item.ID())
+		}
+	}
+	return util.ReportWarn("The message is no longer in this session")
+}
+
 func (m *messageListCmp) GoToBottom() tea.Cmd {
 	return m.listCmp.GoToBottom()
 }
@@ -21,6 +21,10 @@ type Header interface {
 	SetSession(session session.Session) tea.Cmd
 	SetWidth(width int) tea.Cmd
 	SetDetailsOpen(open bool)
+	// SetAgent sets the agent handling the session.
+	SetAgent(agentID string)
+	// SetWorktree sets the branch of the session's worktree, if it has one.
+	SetWorktree(branch string)
 	ShowingDetails() bool
 }
 
@@ -29,6 +33,8 @@ type header struct {
 	session     session.Session
 	lspClients  map[string]*lsp.Client
 	detailsOpen bool
+	agentID     string
+	branch      string
 }
 
 func New(lspClients map[string]*lsp.Client) Header {
@@ -90,6 +96,9 @@ func (h *header) details() string {
 	parts := []string{
 		t.S().Muted.Render(cwd),
 	}
+	if h.branch != "" {
+		parts = append(parts, t.S().Subtle.Render(h.branch))
+	}
 
 	errorCount := 0
 	for _, l := range h.lspClients {
@@ -106,7 +115,14 @@ func (h *header) details() string {
 		parts = append(parts, t.S().Error.Render(fmt.Sprintf("%s%d", styles.ErrorIcon, errorCount)))
 	}
 
-	agentCfg := config.Get().Agents["coder"]
+	agentCfg, ok := config.Get().Agents[h.agentID]
+	if !ok {
+		agentCfg = config.Get().Agents[config.AgentCoder]
+	}
+	if agentCfg.ID != config.AgentCoder {
+		parts = append(parts, t.S().Subtle.Render(agentCfg.Name))
+	}
+
 	model := config.Get().GetModelByType(agentCfg.Model)
 	percentage := (float64(h.session.CompletionTokens+h.session.PromptTokens) / float64(model.ContextWindow)) * 100
 	formattedPercentage := t.S().Muted.Render(fmt.Sprintf("%d%%", int(percentage)))
@@ -125,6 +141,16 @@ func (h *header) SetDetailsOpen(open bool) {
 	h.detailsOpen = open
 }
 
+// SetAgent implements Header.
+func (h *header) SetAgent(agentID string) {
+	h.agentID = agentID
+}
+
+// SetWorktree implements Header.
+func (h *header) SetWorktree(branch string) {
+	h.branch = branch
+}
+
 // SetSession implements Header.
 func (h *header) SetSession(session session.Session) tea.Cmd {
 	h.session = session
@@ -28,6 +28,26 @@ import (
 // CopyKey is the key binding for copying message content to the clipboard.
 var CopyKey = key.NewBinding(key.WithKeys("c", "y", "C", "Y"), key.WithHelp("c/y", "copy"))
 
+// RewindKey is the key binding for rewinding the session to a user message.
+var RewindKey = key.NewBinding(key.WithKeys("r", "R"), key.WithHelp("r", "rewind to here"))
+
+// RewindMsg asks to rewind a session to the state before one of its user
+// messages was sent.
+type RewindMsg struct {
+	SessionID string
+	MessageID string
+}
+
+// ForkKey is the key binding for forking the session at a message.
+var ForkKey = key.NewBinding(key.WithKeys("s", "S"), key.WithHelp("s", "fork from here"))
+
+// ForkMsg asks to fork a session at one of its messages. An empty SessionID
+// is the current session.
+type ForkMsg struct {
+	SessionID string
+	MessageID string
+}
+
 // ClearSelectionKey is the key binding for clearing the current selection in the chat interface.
 var ClearSelectionKey = key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "clear selection"))
 
@@ -113,6 +133,18 @@ func (m *messageCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				util.ReportInfo("Message copied to clipboard"),
 			)
 		}
+		if key.Matches(msg, RewindKey) && m.message.Role == message.User {
+			return m, util.CmdHandler(RewindMsg{
+				SessionID: m.message.SessionID,
+				MessageID: m.message.ID,
+			})
+		}
+		if key.Matches(msg, ForkKey) {
+			return m, util.CmdHandler(ForkMsg{
+				SessionID: m.message.SessionID,
+				MessageID: m.message.ID,
+			})
+		}
 	}
 	return m, nil
 }
@@ -241,6 +273,10 @@ func (m *messageCmp) renderUserMessage() string {
 		parts = append(parts, "", strings.Join(attachments, ""))
 	}
 
+	if m.message.Author != "" {
+		parts = append(parts, "", t.S().Subtle.Render("Proposed by "+m.message.Author))
+	}
+
 	joined := lipgloss.JoinVertical(lipgloss.Left, parts...)
 	return m.style().Render(joined)
 }
@@ -172,6 +172,9 @@ func init() {
 	registry.register(tools.GlobToolName, func() renderer { return globRenderer{} })
 	registry.register(tools.GrepToolName, func() renderer { return grepRenderer{} })
 	registry.register(tools.LSToolName, func() renderer { return lsRenderer{} })
+	registry.register(tools.JobOutputToolName, func() renderer { return jobRenderer{} })
+	registry.register(tools.JobStatusToolName, func() renderer { return jobRenderer{} })
+	registry.register(tools.JobKillToolName, func() renderer { return jobRenderer{} })
 	registry.register(tools.SourcegraphToolName, func() renderer { return sourcegraphRenderer{} })
 	registry.register(tools.DiagnosticsToolName, func() renderer { return diagnosticsRenderer{} })
 	registry.register(agent.AgentToolName, func() renderer { return agentRenderer{} })
@@ -211,7 +214,11 @@ func (br bashRenderer) Render(v *toolCallCmp) string {
 
 	cmd := strings.ReplaceAll(params.Command, "\n", " ")
 	cmd = strings.ReplaceAll(cmd, "\t", "    ")
-	args := newParamBuilder().addMain(cmd).build()
+	args := newParamBuilder().
+		addMain(cmd).
+		addFlag("background", params.RunInBackground).
+		addFlag("network", params.Network).
+		build()
 
 	return br.renderWithParams(v, "Bash", args, func() string {
 		var meta tools.BashResponseMetadata
@@ -545,6 +552,35 @@ func (lr lsRenderer) Render(v *toolCallCmp) string {
 	})
 }
 
+// -----------------------------------------------------------------------------
+//  Job renderer
+// -----------------------------------------------------------------------------
+
+// jobRenderer handles the tools that manage background jobs
+type jobRenderer struct {
+	baseRenderer
+}
+
+// Render displays the job ID and the output of the job
+func (jr jobRenderer) Render(v *toolCallCmp) string {
+	var params tools.JobOutputParams
+	var args []string
+	if err := jr.unmarshalParams(v.call.Input, &params); err == nil {
+		jobID := params.JobID
+		if jobID == "" {
+			jobID = "all"
+		}
+		args = newParamBuilder().
+			addMain(jobID).
+			addKeyValue("wait", formatNonZero(params.Wait)).
+			build()
+	}
+
+	return jr.renderWithParams(v, prettifyToolName(v.call.Name), args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
 // -----------------------------------------------------------------------------
 //  Sourcegraph renderer
 // -----------------------------------------------------------------------------
@@ -880,6 +916,12 @@ func prettifyToolName(name string) string {
 		return "Grep"
 	case tools.LSToolName:
 		return "List"
+	case tools.JobOutputToolName:
+		return "Job Output"
+	case tools.JobStatusToolName:
+		return "Job Status"
+	case tools.JobKillToolName:
+		return "Kill Job"
 	case tools.SourcegraphToolName:
 		return "Sourcegraph"
 	case tools.ViewToolName:
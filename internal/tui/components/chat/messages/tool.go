@@ -168,6 +168,10 @@ func (m *toolCallCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if key.Matches(msg, CopyKey) {
 			return m, m.copyTool()
 		}
+		// Sub-agent messages belong to another session
+		if key.Matches(msg, ForkKey) && !m.isNested {
+			return m, util.CmdHandler(ForkMsg{MessageID: m.parentMessageID})
+		}
 	}
 	return m, nil
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/chasedut/toke/internal/lsp"
 	"github.com/chasedut/toke/internal/pubsub"
 	"github.com/chasedut/toke/internal/session"
+	"github.com/chasedut/toke/internal/shell"
 	"github.com/chasedut/toke/internal/tui/components/chat"
 	"github.com/chasedut/toke/internal/tui/components/core"
 	"github.com/chasedut/toke/internal/tui/components/core/layout"
@@ -61,7 +62,7 @@ type Sidebar interface {
 	layout.Sizeable
 	SetSession(session session.Session) tea.Cmd
 	SetCompactMode(bool)
-	SetWebShareURLs(localURL, ngrokURL string)
+	SetWebShareURLs(localURL, publicURL string)
 	SetBuddyInfo(count int, names []string)
 }
 
@@ -74,8 +75,9 @@ type sidebarCmp struct {
 	compactMode     bool
 	history         history.Service
 	files           *csync.Map[string, SessionFile]
+	jobs            *csync.Map[string, shell.JobInfo]
 	webShareLocalURL string
-	webShareNgrokURL string
+	webSharePublicURL string
 	buddyCount      int
 	buddyNames      []string
 }
@@ -86,6 +88,7 @@ func New(history history.Service, lspClients map[string]*lsp.Client, compact boo
 		history:     history,
 		compactMode: compact,
 		files:       csync.NewMap[string, SessionFile](),
+		jobs:        csync.NewMap[string, shell.JobInfo](),
 	}
 }
 
@@ -104,6 +107,17 @@ func (m *sidebarCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case chat.SessionClearedMsg:
 		m.session = session.Session{}
+		m.jobs = csync.NewMap[string, shell.JobInfo]()
+	case pubsub.Event[shell.JobInfo]:
+		job := msg.Payload
+		if job.SessionID != m.session.ID {
+			return m, nil
+		}
+		if job.Status == shell.JobRunning {
+			m.jobs.Set(job.ID, job)
+		} else {
+			m.jobs.Del(job.ID)
+		}
 	case pubsub.Event[history.File]:
 		return m, m.handleFileHistoryEvent(msg)
 	case pubsub.Event[session.Session]:
@@ -156,7 +170,7 @@ func (m *sidebarCmp) View() string {
 	)
 
 	// Add web share URLs if active
-	if m.webShareLocalURL != "" || m.webShareNgrokURL != "" {
+	if m.webShareLocalURL != "" || m.webSharePublicURL != "" {
 		parts = append(parts, "", m.webShareBlock())
 	}
 
@@ -172,6 +186,9 @@ func (m *sidebarCmp) View() string {
 		if m.session.ID != "" {
 			parts = append(parts, "", m.filesBlock())
 		}
+		if m.jobs.Len() > 0 {
+			parts = append(parts, "", m.jobsBlock())
+		}
 		parts = append(parts,
 			"",
 			m.lspBlock(),
@@ -513,6 +530,30 @@ func (m *sidebarCmp) mcpBlock() string {
 	}, true)
 }
 
+// jobsBlock lists the background jobs of the session that are running.
+func (m *sidebarCmp) jobsBlock() string {
+	t := styles.CurrentTheme()
+	maxWidth := m.getMaxWidth()
+	jobs := slices.SortedFunc(m.jobs.Seq(), func(a, b shell.JobInfo) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	parts := []string{t.S().Subtle.Render(core.Section("Jobs", maxWidth)), ""}
+	for _, job := range jobs {
+		parts = append(parts, core.Status(
+			core.StatusOpts{
+				Icon:         t.ItemBusyIcon.String(),
+				Title:        job.ID,
+				Description:  strings.ReplaceAll(job.Command, "\n", " "),
+				ExtraContent: t.S().Subtle.Render("since " + job.StartedAt.Format("15:04")),
+			},
+			maxWidth,
+		))
+	}
+	return lipgloss.NewStyle().Width(maxWidth).Render(
+		lipgloss.JoinVertical(lipgloss.Left, parts...),
+	)
+}
+
 func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
 	t := styles.CurrentTheme()
 	// Format tokens in human-readable format (e.g., 110K, 1.2M)
@@ -577,40 +618,40 @@ func (m *sidebarCmp) webShareBlock() string {
 		parts = append(parts, fmt.Sprintf("  %s %s", localLabel, localURL))
 	}
 	
-	// Ngrok URL
-	if m.webShareNgrokURL != "" {
-		ngrokLabel := t.S().Base.Foreground(t.FgMuted).Render("Public:")
+	// Public URL
+	if m.webSharePublicURL != "" {
+		publicLabel := t.S().Base.Foreground(t.FgMuted).Render("Public:")
 		
-		// Extract just the domain from ngrok URL for display
-		ngrokDisplay := m.webShareNgrokURL
-		if strings.Contains(ngrokDisplay, "//") {
-			parts := strings.Split(ngrokDisplay, "//")
+		// Extract just the domain from the public URL for display
+		publicDisplay := m.webSharePublicURL
+		if strings.Contains(publicDisplay, "//") {
+			parts := strings.Split(publicDisplay, "//")
 			if len(parts) > 1 {
-				ngrokDisplay = parts[1]
+				publicDisplay = parts[1]
 				// Remove path if any
-				if idx := strings.Index(ngrokDisplay, "/"); idx > 0 {
-					ngrokDisplay = ngrokDisplay[:idx]
+				if idx := strings.Index(publicDisplay, "/"); idx > 0 {
+					publicDisplay = publicDisplay[:idx]
 				}
 			}
 		}
 		
-		ngrokURL := t.S().Base.Foreground(t.Success).Render(ngrokDisplay)
+		publicURL := t.S().Base.Foreground(t.Success).Render(publicDisplay)
 		
 		// Truncate if still too long
-		maxURLWidth := maxWidth - lipgloss.Width(ngrokLabel) - 2
-		if lipgloss.Width(ngrokURL) > maxURLWidth {
+		maxURLWidth := maxWidth - lipgloss.Width(publicLabel) - 2
+		if lipgloss.Width(publicURL) > maxURLWidth {
 			// Show ellipsis for very long domains
-			if len(ngrokDisplay) > maxURLWidth {
-				ngrokDisplay = ngrokDisplay[:maxURLWidth-3] + "..."
-				ngrokURL = t.S().Base.Foreground(t.Success).Render(ngrokDisplay)
+			if len(publicDisplay) > maxURLWidth {
+				publicDisplay = publicDisplay[:maxURLWidth-3] + "..."
+				publicURL = t.S().Base.Foreground(t.Success).Render(publicDisplay)
 			}
 		}
 		
-		parts = append(parts, fmt.Sprintf("  %s %s", ngrokLabel, ngrokURL))
+		parts = append(parts, fmt.Sprintf("  %s %s", publicLabel, publicURL))
 	} else {
-		// Show ngrok not available message
-		noNgrok := t.S().Base.Foreground(t.FgHalfMuted).Italic(true).Render("  (ngrok not available)")
-		parts = append(parts, noNgrok)
+		// The tunnel is still opening or failed to
+		noPublic := t.S().Base.Foreground(t.FgHalfMuted).Italic(true).Render("  (no public link yet)")
+		parts = append(parts, noPublic)
 	}
 	
 	// Status indicator
@@ -703,6 +744,12 @@ func (s *sidebarCmp) currentModelBlock() string {
 // SetSession implements Sidebar.
 func (m *sidebarCmp) SetSession(session session.Session) tea.Cmd {
 	m.session = session
+	m.jobs = csync.NewMap[string, shell.JobInfo]()
+	for _, job := range shell.ListJobs(session.ID) {
+		if job.Status == shell.JobRunning {
+			m.jobs.Set(job.ID, job)
+		}
+	}
 	return m.loadSessionFiles
 }
 
@@ -712,9 +759,9 @@ func (m *sidebarCmp) SetCompactMode(compact bool) {
 }
 
 // SetWebShareURLs sets the web share URLs for display
-func (m *sidebarCmp) SetWebShareURLs(localURL, ngrokURL string) {
+func (m *sidebarCmp) SetWebShareURLs(localURL, publicURL string) {
 	m.webShareLocalURL = localURL
-	m.webShareNgrokURL = ngrokURL
+	m.webSharePublicURL = publicURL
 }
 
 func (m *sidebarCmp) SetBuddyInfo(count int, names []string) {
@@ -594,10 +594,10 @@ func (m Model) startDownload() tea.Cmd {
 			m.orchestrator = backend.NewOrchestrator(dataDir)
 		}
 		
-		// Setup the model with progress callback
-		err := m.orchestrator.SetupModel(
+		// Setup the model in the download queue, where it can be paused
+		err := backend.Downloads.Download(
 			ctx,
-			m.selectedModel,
+			m.orchestrator.DownloadTask(*m.selectedModel),
 			func(downloaded, total int64) {
 				// Update global progress
 				downloadProgress.downloaded = downloaded
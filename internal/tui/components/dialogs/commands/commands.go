@@ -11,6 +11,7 @@ import (
 
 	"github.com/chasedut/toke/internal/config"
 	"github.com/chasedut/toke/internal/llm/prompt"
+	"github.com/chasedut/toke/internal/transcript"
 	"github.com/chasedut/toke/internal/tui/components/chat"
 	"github.com/chasedut/toke/internal/tui/components/core"
 	"github.com/chasedut/toke/internal/tui/components/dialogs"
@@ -73,16 +74,33 @@ type (
 	ToggleThinkingMsg     struct{}
 	OpenExternalEditorMsg struct{}
 	ToggleYoloModeMsg     struct{}
+	BuddyProposalsMsg     struct{}
+	ShowUsageMsg          struct{}
+	ShowLocalModelsMsg    struct{}
+	ShowDownloadsMsg      struct{}
 	InviteBuddyMsg        struct {
 		SessionID string
 	}
 	CompactMsg struct {
 		SessionID string
 	}
+	// ExportSessionMsg writes a transcript of the session, with secrets
+	// redacted, to the working directory.
+	ExportSessionMsg struct {
+		SessionID string
+		Format    transcript.Format
+	}
+	SwitchAgentMsg struct {
+		AgentID string
+	}
+	NewWorktreeSessionMsg struct{}
+	FinishWorktreeMsg     struct {
+		SessionID string
+	}
 	WebShareStartedMsg struct {
-		LocalURL string
-		NgrokURL string
-		WebShare interface{} // The webshare.SessionShare instance
+		LocalURL  string
+		PublicURL string
+		WebShare  interface{} // The webshare.SessionShare instance
 	}
 	WebShareStoppedMsg struct{}
 )
@@ -298,6 +316,14 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				return util.CmdHandler(NewSessionsMsg{})
 			},
 		},
+		{
+			ID:          "new_worktree_session",
+			Title:       "New Session in Worktree",
+			Description: "start a new session on its own git branch and worktree",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(NewWorktreeSessionMsg{})
+			},
+		},
 		{
 			ID:          "switch_session",
 			Title:       "Switch Session",
@@ -323,6 +349,43 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				return util.CmdHandler(AddNewModelsMsg{})
 			},
 		},
+		{
+			ID:          "show_usage",
+			Title:       "Show Usage",
+			Description: "Tokens and cost by model, day and session, and budgets",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ShowUsageMsg{})
+			},
+		},
+		{
+			ID:          "show_local_models",
+			Title:       "Show Local Models",
+			Description: "Downloaded models loaded in memory and the memory they use",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ShowLocalModelsMsg{})
+			},
+		},
+		{
+			ID:          "show_downloads",
+			Title:       "Show Downloads",
+			Description: "Pause, resume or cancel local model downloads",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ShowDownloadsMsg{})
+			},
+		},
+	}
+
+	if agents := config.Get().EnabledAgents(); len(agents) > 1 {
+		for _, agentCfg := range agents {
+			commands = append(commands, Command{
+				ID:          "switch_agent_" + agentCfg.ID,
+				Title:       "Use Agent: " + agentCfg.Name,
+				Description: agentCfg.Description,
+				Handler: func(cmd Command) tea.Cmd {
+					return util.CmdHandler(SwitchAgentMsg{AgentID: agentCfg.ID})
+				},
+			})
+		}
 	}
 
 	// Only show session-specific commands if there's an active session
@@ -340,6 +403,50 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 			},
 		})
 
+		commands = append(commands, Command{
+			ID:          "buddy_proposals",
+			Title:       "Buddy Proposals",
+			Description: "Review the prompts your buddies proposed",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(BuddyProposalsMsg{})
+			},
+		})
+
+		commands = append(commands, Command{
+			ID:          "finish_worktree",
+			Title:       "Finish Worktree",
+			Description: "Merge back, open a PR for or discard the session's worktree",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(FinishWorktreeMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
+
+		commands = append(commands, Command{
+			ID:          "export_session_html",
+			Title:       "Export Session as HTML",
+			Description: "Write a redacted single-page transcript to the working directory",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ExportSessionMsg{
+					SessionID: c.sessionID,
+					Format:    transcript.FormatHTML,
+				})
+			},
+		})
+
+		commands = append(commands, Command{
+			ID:          "export_session_markdown",
+			Title:       "Export Session as Markdown",
+			Description: "Write a redacted Markdown transcript to the working directory",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ExportSessionMsg{
+					SessionID: c.sessionID,
+					Format:    transcript.FormatMarkdown,
+				})
+			},
+		})
+
 		commands = append(commands, Command{
 			ID:          "Summarize",
 			Title:       "Summarize Session",
@@ -0,0 +1,182 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/chasedut/toke/internal/backend"
+	"github.com/chasedut/toke/internal/tui/components/core"
+	"github.com/chasedut/toke/internal/tui/components/dialogs"
+	"github.com/chasedut/toke/internal/tui/styles"
+	"github.com/chasedut/toke/internal/tui/util"
+)
+
+const (
+	DownloadsDialogID dialogs.DialogID = "downloads"
+
+	defaultWidth    = 72
+	refreshInterval = 500 * time.Millisecond
+)
+
+// refreshMsg updates the dialog with the state of the queue.
+type refreshMsg struct{}
+
+// DownloadsDialog shows the model downloads of the queue.
+type DownloadsDialog interface {
+	dialogs.DialogModel
+}
+
+type downloadsDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	queue     *backend.DownloadQueue
+	downloads []backend.Download
+	selected  int
+	keymap    KeyMap
+	help      help.Model
+}
+
+// NewDownloadsDialog creates a dialog that shows the downloads of queue and
+// lets the user pause, resume and cancel them.
+func NewDownloadsDialog(queue *backend.DownloadQueue) DownloadsDialog {
+	t := styles.CurrentTheme()
+	h := help.New()
+	h.Styles = t.S().Help
+	return &downloadsDialogCmp{
+		queue:     queue,
+		downloads: queue.Downloads(),
+		keymap:    DefaultKeymap(),
+		help:      h,
+	}
+}
+
+func (d *downloadsDialogCmp) Init() tea.Cmd {
+	return refresh()
+}
+
+func refresh() tea.Cmd {
+	return tea.Tick(refreshInterval, func(time.Time) tea.Msg { return refreshMsg{} })
+}
+
+// Update handles keyboard input and refreshes the downloads.
+func (d *downloadsDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		d.wWidth = msg.Width
+		d.wHeight = msg.Height
+	case refreshMsg:
+		d.setDownloads(d.queue.Downloads())
+		return d, refresh()
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, d.keymap.Up):
+			d.selected = max(d.selected-1, 0)
+		case key.Matches(msg, d.keymap.Down):
+			d.selected = min(d.selected+1, max(len(d.downloads)-1, 0))
+		case key.Matches(msg, d.keymap.Pause):
+			if len(d.downloads) == 0 {
+				return d, nil
+			}
+			dl := d.downloads[d.selected]
+			if dl.State == backend.DownloadPaused {
+				d.queue.Resume(dl.Model.ID)
+			} else {
+				d.queue.Pause(dl.Model.ID)
+			}
+			d.setDownloads(d.queue.Downloads())
+		case key.Matches(msg, d.keymap.Cancel):
+			if len(d.downloads) == 0 || d.downloads[d.selected].Finished() {
+				return d, nil
+			}
+			dl := d.downloads[d.selected]
+			d.queue.Cancel(dl.Model.ID)
+			d.setDownloads(d.queue.Downloads())
+			return d, util.ReportInfo(fmt.Sprintf("Cancelled the download of %s", dl.Model.Name))
+		case key.Matches(msg, d.keymap.Clear):
+			d.queue.Clear()
+			d.setDownloads(d.queue.Downloads())
+		case key.Matches(msg, d.keymap.Close):
+			return d, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return d, nil
+}
+
+func (d *downloadsDialogCmp) setDownloads(downloads []backend.Download) {
+	d.downloads = downloads
+	d.selected = min(d.selected, max(len(downloads)-1, 0))
+}
+
+// View renders the downloads and their progress.
+func (d *downloadsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	lines := []string{
+		core.Title("Downloads", defaultWidth-4),
+		"",
+	}
+	if len(d.downloads) == 0 {
+		lines = append(lines, t.S().Muted.Render("No download. Local models are downloaded when you select them."))
+	} else {
+		header := fmt.Sprintf("  %-30s %-11s %21s", "MODEL", "STATUS", "PROGRESS")
+		lines = append(lines, t.S().Subtle.Render(header))
+		for i, dl := range d.downloads {
+			row := row(dl)
+			if i == d.selected {
+				lines = append(lines, t.S().Text.Foreground(t.Primary).Bold(true).Render("→ "+row))
+			} else {
+				lines = append(lines, t.S().Text.Render("  "+row))
+			}
+			if dl.State == backend.DownloadFailed && dl.Err != nil {
+				lines = append(lines, t.S().Error.Render("    "+truncate(dl.Err.Error(), defaultWidth-8)))
+			}
+		}
+	}
+	lines = append(lines, "", d.help.View(d.keymap))
+
+	return baseStyle.
+		Width(defaultWidth).
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func row(dl backend.Download) string {
+	status := string(dl.State)
+	if errors.Is(dl.Err, backend.ErrChecksumMismatch) {
+		status = "corrupted"
+	}
+	progress := backend.FormatSize(dl.Downloaded)
+	if dl.Total > 0 {
+		progress = fmt.Sprintf("%s/%s %3d%%", backend.FormatSize(dl.Downloaded), backend.FormatSize(dl.Total), 100*dl.Downloaded/dl.Total)
+	}
+	return fmt.Sprintf("%-30s %-11s %21s", truncate(dl.Model.Name, 30), status, progress)
+}
+
+func (d *downloadsDialogCmp) Position() (int, int) {
+	view := d.View()
+	row := (d.wHeight - lipgloss.Height(view)) / 2
+	col := (d.wWidth - lipgloss.Width(view)) / 2
+	return max(row, 0), max(col, 0)
+}
+
+func (d *downloadsDialogCmp) ID() dialogs.DialogID {
+	return DownloadsDialogID
+}
+
+func truncate(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
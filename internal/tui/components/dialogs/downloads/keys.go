@@ -0,0 +1,66 @@
+package downloads
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the keyboard bindings for the downloads dialog.
+type KeyMap struct {
+	Up,
+	Down,
+	Pause,
+	Cancel,
+	Clear,
+	Close key.Binding
+}
+
+func DefaultKeymap() KeyMap {
+	return KeyMap{
+		Up: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑", "previous"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓", "next"),
+		),
+		Pause: key.NewBinding(
+			key.WithKeys("p", "space"),
+			key.WithHelp("p", "pause/resume"),
+		),
+		Cancel: key.NewBinding(
+			key.WithKeys("x", "delete"),
+			key.WithHelp("x", "cancel"),
+		),
+		Clear: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "clear finished"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc", "q"),
+			key.WithHelp("esc", "close"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.Up,
+		k.Down,
+		k.Pause,
+		k.Cancel,
+		k.Clear,
+		k.Close,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.KeyBindings()}
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return k.KeyBindings()
+}
@@ -0,0 +1,54 @@
+package limits
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the keyboard bindings for the limit dialog.
+type KeyMap struct {
+	LeftRight,
+	Enter,
+	Continue,
+	Stop key.Binding
+}
+
+func DefaultKeymap() KeyMap {
+	return KeyMap{
+		LeftRight: key.NewBinding(
+			key.WithKeys("left", "right", "tab"),
+			key.WithHelp("←/→", "switch options"),
+		),
+		Enter: key.NewBinding(
+			key.WithKeys("enter", " "),
+			key.WithHelp("enter", "confirm"),
+		),
+		Continue: key.NewBinding(
+			key.WithKeys("c", "y"),
+			key.WithHelp("c", "continue"),
+		),
+		Stop: key.NewBinding(
+			key.WithKeys("s", "n", "esc"),
+			key.WithHelp("s/esc", "stop"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.LeftRight,
+		k.Enter,
+		k.Continue,
+		k.Stop,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.KeyBindings()}
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return k.KeyBindings()
+}
@@ -0,0 +1,141 @@
+package limits
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/chasedut/toke/internal/limits"
+	"github.com/chasedut/toke/internal/tui/components/core"
+	"github.com/chasedut/toke/internal/tui/components/dialogs"
+	"github.com/chasedut/toke/internal/tui/styles"
+	"github.com/chasedut/toke/internal/tui/util"
+)
+
+const (
+	LimitDialogID dialogs.DialogID = "limit"
+
+	defaultWidth = 60
+)
+
+// LimitResponseMsg tells whether the run that reached a limit continues.
+type LimitResponseMsg struct {
+	Request  limits.Request
+	Continue bool
+}
+
+// LimitDialog asks whether a run that reached a limit continues.
+type LimitDialog interface {
+	dialogs.DialogModel
+}
+
+type limitDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	request limits.Request
+	// stop is set when the Stop button is selected.
+	stop   bool
+	keymap KeyMap
+	help   help.Model
+}
+
+// NewLimitDialog creates a dialog for a request of the limits service.
+func NewLimitDialog(request limits.Request) LimitDialog {
+	t := styles.CurrentTheme()
+	h := help.New()
+	h.Styles = t.S().Help
+	return &limitDialogCmp{
+		request: request,
+		keymap:  DefaultKeymap(),
+		help:    h,
+	}
+}
+
+func (l *limitDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles keyboard input for the limit dialog.
+func (l *limitDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		l.wWidth = msg.Width
+		l.wHeight = msg.Height
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, l.keymap.LeftRight):
+			l.stop = !l.stop
+		case key.Matches(msg, l.keymap.Enter):
+			return l, l.respond(!l.stop)
+		case key.Matches(msg, l.keymap.Continue):
+			return l, l.respond(true)
+		case key.Matches(msg, l.keymap.Stop):
+			return l, l.respond(false)
+		}
+	}
+	return l, nil
+}
+
+func (l *limitDialogCmp) respond(resume bool) tea.Cmd {
+	return tea.Batch(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(LimitResponseMsg{Request: l.request, Continue: resume}),
+	)
+}
+
+// View renders the limit that was reached, what was used and the buttons.
+func (l *limitDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	u := l.request.Usage
+	used := fmt.Sprintf("%d tool iterations · %d input · %d output tokens · $%.2f",
+		u.ToolIterations, u.InputTokens, u.OutputTokens, u.Cost)
+
+	continueStyle := t.S().Text.Background(t.BgSubtle)
+	stopStyle := t.S().Text.Background(t.BgSubtle)
+	if l.stop {
+		stopStyle = stopStyle.Foreground(t.White).Background(t.Secondary)
+	} else {
+		continueStyle = continueStyle.Foreground(t.White).Background(t.Secondary)
+	}
+	const horizontalPadding = 2
+	buttons := lipgloss.JoinHorizontal(lipgloss.Center,
+		continueStyle.Padding(0, horizontalPadding).Render("Continue"),
+		" ",
+		stopStyle.Padding(0, horizontalPadding).Render("Stop"),
+	)
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		core.Title("Limit Reached", defaultWidth-4),
+		"",
+		t.S().Warning.Render(fmt.Sprintf("%s The agent reached its %s.", styles.WarningIcon, l.request)),
+		t.S().Muted.Width(defaultWidth-4).Render("Used: "+used),
+		"",
+		buttons,
+		"",
+		l.help.View(l.keymap),
+	)
+
+	return baseStyle.
+		Width(defaultWidth).
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(content)
+}
+
+func (l *limitDialogCmp) Position() (int, int) {
+	view := l.View()
+	row := (l.wHeight - lipgloss.Height(view)) / 2
+	col := (l.wWidth - lipgloss.Width(view)) / 2
+	return max(row, 0), max(col, 0)
+}
+
+func (l *limitDialogCmp) ID() dialogs.DialogID {
+	return LimitDialogID
+}
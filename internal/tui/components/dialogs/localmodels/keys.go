@@ -0,0 +1,54 @@
+package localmodels
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the keyboard bindings for the local models dialog.
+type KeyMap struct {
+	Up,
+	Down,
+	Unload,
+	Close key.Binding
+}
+
+func DefaultKeymap() KeyMap {
+	return KeyMap{
+		Up: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑", "previous"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓", "next"),
+		),
+		Unload: key.NewBinding(
+			key.WithKeys("x", "delete"),
+			key.WithHelp("x", "unload"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc", "q"),
+			key.WithHelp("esc", "close"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.Up,
+		k.Down,
+		k.Unload,
+		k.Close,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.KeyBindings()}
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return k.KeyBindings()
+}
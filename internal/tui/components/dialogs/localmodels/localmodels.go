@@ -0,0 +1,176 @@
+package localmodels
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/chasedut/toke/internal/backend"
+	"github.com/chasedut/toke/internal/tui/components/core"
+	"github.com/chasedut/toke/internal/tui/components/dialogs"
+	"github.com/chasedut/toke/internal/tui/styles"
+	"github.com/chasedut/toke/internal/tui/util"
+)
+
+const (
+	LocalModelsDialogID dialogs.DialogID = "local_models"
+
+	defaultWidth    = 72
+	refreshInterval = time.Second
+)
+
+// refreshMsg updates the dialog with the state of the pool.
+type refreshMsg struct{}
+
+// LocalModelsDialog shows the models loaded in the local model pool.
+type LocalModelsDialog interface {
+	dialogs.DialogModel
+}
+
+type localModelsDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	pool     *backend.Pool
+	loaded   []backend.LoadedModel
+	selected int
+	keymap   KeyMap
+	help     help.Model
+}
+
+// NewLocalModelsDialog creates a dialog that shows the models of pool that
+// are loaded and lets the user unload them.
+func NewLocalModelsDialog(pool *backend.Pool) LocalModelsDialog {
+	t := styles.CurrentTheme()
+	h := help.New()
+	h.Styles = t.S().Help
+	return &localModelsDialogCmp{
+		pool:   pool,
+		loaded: pool.Loaded(),
+		keymap: DefaultKeymap(),
+		help:   h,
+	}
+}
+
+func (l *localModelsDialogCmp) Init() tea.Cmd {
+	return refresh()
+}
+
+func refresh() tea.Cmd {
+	return tea.Tick(refreshInterval, func(time.Time) tea.Msg { return refreshMsg{} })
+}
+
+// Update handles keyboard input and refreshes the loaded models.
+func (l *localModelsDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		l.wWidth = msg.Width
+		l.wHeight = msg.Height
+	case refreshMsg:
+		l.setLoaded(l.pool.Loaded())
+		return l, refresh()
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, l.keymap.Up):
+			l.selected = max(l.selected-1, 0)
+		case key.Matches(msg, l.keymap.Down):
+			l.selected = min(l.selected+1, max(len(l.loaded)-1, 0))
+		case key.Matches(msg, l.keymap.Unload):
+			if len(l.loaded) == 0 {
+				return l, nil
+			}
+			m := l.loaded[l.selected].Model
+			if err := l.pool.Unload(m.ID); err != nil {
+				return l, util.ReportWarn(fmt.Sprintf("Can't unload %s: %v", m.Name, err))
+			}
+			l.setLoaded(l.pool.Loaded())
+			return l, util.ReportInfo(fmt.Sprintf("Unloaded %s", m.Name))
+		case key.Matches(msg, l.keymap.Close):
+			return l, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return l, nil
+}
+
+func (l *localModelsDialogCmp) setLoaded(loaded []backend.LoadedModel) {
+	l.loaded = loaded
+	l.selected = min(l.selected, max(len(loaded)-1, 0))
+}
+
+// View renders the loaded models and the memory they use.
+func (l *localModelsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	lines := []string{
+		core.Title("Local Models", defaultWidth-4),
+		"",
+	}
+	if len(l.loaded) == 0 {
+		lines = append(lines, t.S().Muted.Render("No model loaded. Models load on their first request."))
+	} else {
+		header := fmt.Sprintf("  %-30s %6s %9s %-10s %8s", "MODEL", "PORT", "MEMORY", "STATUS", "IDLE")
+		lines = append(lines, t.S().Subtle.Render(header))
+		for i, m := range l.loaded {
+			row := l.row(m)
+			if i == l.selected {
+				lines = append(lines, t.S().Text.Foreground(t.Primary).Bold(true).Render("→ "+row))
+			} else {
+				lines = append(lines, t.S().Text.Render("  "+row))
+			}
+		}
+	}
+
+	var used int64
+	for _, m := range l.loaded {
+		used += m.Model.Memory
+	}
+	memory := "Memory: " + backend.FormatSize(used)
+	if limit := l.pool.MemoryLimit(); limit > 0 {
+		memory += " of " + backend.FormatSize(limit)
+	}
+	lines = append(lines, "", t.S().Muted.Render(memory), "", l.help.View(l.keymap))
+
+	return baseStyle.
+		Width(defaultWidth).
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func (l *localModelsDialogCmp) row(m backend.LoadedModel) string {
+	status := "starting"
+	idle := "-"
+	switch {
+	case m.Active > 0:
+		status = fmt.Sprintf("%d active", m.Active)
+	case m.Ready:
+		status = "ready"
+		idle = time.Since(m.LastUsed).Truncate(time.Second).String()
+	}
+	return fmt.Sprintf("%-30s %6d %9s %-10s %8s", truncate(m.Model.Name, 30), m.Port, backend.FormatSize(m.Model.Memory), status, idle)
+}
+
+func (l *localModelsDialogCmp) Position() (int, int) {
+	view := l.View()
+	row := (l.wHeight - lipgloss.Height(view)) / 2
+	col := (l.wWidth - lipgloss.Width(view)) / 2
+	return max(row, 0), max(col, 0)
+}
+
+func (l *localModelsDialogCmp) ID() dialogs.DialogID {
+	return LocalModelsDialogID
+}
+
+func truncate(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
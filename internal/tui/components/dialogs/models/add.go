@@ -1,8 +1,10 @@
 package models
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea/v2"
@@ -18,11 +20,16 @@ import (
 
 // AddOption represents a model add option
 type AddOption struct {
-	Type        string // "download_local", "setup_api", "download_mlx", "download_gguf"
+	Type        string // "download_local", "setup_api", "download_mlx", "download_gguf", "local_server"
 	Title       string
 	Description string
 	ModelID     string // For specific models
-	Provider    string // For API setup
+	Provider    string // For API setup and local servers
+}
+
+// localServersMsg carries the local servers found when the dialog opened.
+type localServersMsg struct {
+	servers []backend.LocalServer
 }
 
 type AddModelsCmp struct {
@@ -32,6 +39,7 @@ type AddModelsCmp struct {
 	selectedIdx  int
 	items        []AddOption
 	groups       []list.Group[list.CompletionItem[AddOption]]
+	servers      []backend.LocalServer
 }
 
 func NewAddModelsCmp() *AddModelsCmp {
@@ -42,16 +50,22 @@ func NewAddModelsCmp() *AddModelsCmp {
 		selectedIdx: 0,
 	}
 
-	m.groups = m.buildOptions()
-	
+	m.setGroups(m.buildOptions())
+
+	return m
+}
+
+func (m *AddModelsCmp) setGroups(groups []list.Group[list.CompletionItem[AddOption]]) {
+	m.groups = groups
+
 	// Build flat items list for easier navigation
+	m.items = nil
 	for _, group := range m.groups {
 		for _, item := range group.Items {
 			m.items = append(m.items, item.Value())
 		}
 	}
-
-	return m
+	m.selectedIdx = min(m.selectedIdx, len(m.items)-1)
 }
 
 func (m *AddModelsCmp) buildOptions() []list.Group[list.CompletionItem[AddOption]] {
@@ -114,6 +128,34 @@ func (m *AddModelsCmp) buildOptions() []list.Group[list.CompletionItem[AddOption
 
 	groups = append(groups, localGroup)
 
+	// Models of servers already running, e.g. Ollama or LM Studio
+	for _, server := range m.servers {
+		serverSection := list.NewItemSection("📡 " + server.Name)
+		serverSection.SetInfo("Running")
+		serverGroup := list.Group[list.CompletionItem[AddOption]]{
+			Section: serverSection,
+		}
+		for _, model := range server.Models {
+			desc := fmt.Sprintf("%dK context", model.ContextWindow/1024)
+			if !model.SupportsTools {
+				desc += ", no tool calling"
+			}
+			item := list.NewCompletionItem(
+				model.Name,
+				AddOption{
+					Type:        "local_server",
+					Title:       model.Name,
+					Description: desc,
+					ModelID:     model.ID,
+					Provider:    server.ID,
+				},
+				list.WithCompletionID(fmt.Sprintf("%s:%s", server.ID, model.ID)),
+			)
+			serverGroup.Items = append(serverGroup.Items, item)
+		}
+		groups = append(groups, serverGroup)
+	}
+
 	// MLX Models section (Apple Silicon only)
 	if backend.IsAppleSilicon() {
 		mlxSection := list.NewItemSection("🍎 MLX Models (Apple Silicon)")
@@ -174,7 +216,13 @@ func (m *AddModelsCmp) buildOptions() []list.Group[list.CompletionItem[AddOption
 }
 
 func (m *AddModelsCmp) Init() tea.Cmd {
-	return nil
+	var urls []string
+	if cfg := config.Get(); cfg.Options != nil {
+		urls = cfg.Options.LocalServers
+	}
+	return func() tea.Msg {
+		return localServersMsg{servers: backend.DiscoverServers(context.Background(), urls)}
+	}
 }
 
 func (m *AddModelsCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -184,6 +232,11 @@ func (m *AddModelsCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		return m, nil
 
+	case localServersMsg:
+		m.servers = msg.servers
+		m.setGroups(m.buildOptions())
+		return m, nil
+
 	case tea.KeyMsg:
 		// Handle navigation and selection
 		switch msg.String() {
@@ -249,6 +302,25 @@ func (m *AddModelsCmp) handleSelection(option AddOption) (tea.Model, tea.Cmd) {
 		// Switch to the download dialog
 		return m, util.CmdHandler(dialogs.OpenDialogMsg{Model: backendDialog})
 
+	case "local_server":
+		i := slices.IndexFunc(m.servers, func(s backend.LocalServer) bool { return s.ID == option.Provider })
+		if i < 0 {
+			return m, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+		if err := config.Get().AddLocalServer(m.servers[i]); err != nil {
+			return m, util.ReportError(err)
+		}
+		return m, tea.Sequence(
+			util.CmdHandler(dialogs.CloseDialogMsg{}),
+			util.CmdHandler(ModelSelectedMsg{
+				Model: config.SelectedModel{
+					Model:    option.ModelID,
+					Provider: option.Provider,
+				},
+				ModelType: config.SelectedModelTypeLarge,
+			}),
+		)
+
 	case "browse_hf":
 		// Open the new HF Browse dialog as a modal
 		hfDialog := NewHFBrowseCmp()
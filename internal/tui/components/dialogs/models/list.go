@@ -147,15 +147,27 @@ func (m *ModelListComponent) SetModelType(modelType int) tea.Cmd {
 				Section: localSection,
 			}
 			
-			localModelItem := list.NewCompletionItem(
-				fmt.Sprintf("✓ %s (Active)", localConfig.ModelID),
-				ModelOption{
-					Provider: catwalk.Provider{ID: "local", Name: "Local Model"},
-					Model:    catwalk.Model{ID: localConfig.ModelID, Name: localConfig.ModelID},
-				},
-				list.WithCompletionID(fmt.Sprintf("local:%s", localConfig.ModelID)),
-			)
-			localGroup.Items = append(localGroup.Items, localModelItem)
+			// Every downloaded model can be selected, the large and small
+			// models each get their own server in the local model pool.
+			localModels := []catwalk.Model{{ID: localConfig.ModelID, Name: localConfig.ModelID}}
+			if localProvider, ok := cfg.Providers.Get("local"); ok && len(localProvider.Models) > 0 {
+				localModels = localProvider.Models
+			}
+			for _, model := range localModels {
+				name := model.Name
+				if model.ID == currentModel.Model && currentModel.Provider == "local" {
+					name = fmt.Sprintf("✓ %s (Active)", name)
+				}
+				localModelItem := list.NewCompletionItem(
+					name,
+					ModelOption{
+						Provider: catwalk.Provider{ID: "local", Name: "Local Model"},
+						Model:    model,
+					},
+					list.WithCompletionID(fmt.Sprintf("local:%s", model.ID)),
+				)
+				localGroup.Items = append(localGroup.Items, localModelItem)
+			}
 			
 			// Mark if this is the currently selected model (but don't auto-focus)
 			// Just for tracking, not for selection when currentModel.Provider == "local"
@@ -11,6 +11,7 @@ type KeyMap struct {
 	Select,
 	Allow,
 	AllowSession,
+	SaveRule,
 	Deny,
 	ToggleDiffMode,
 	ScrollDown,
@@ -41,6 +42,10 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("s", "S", "ctrl+s"),
 			key.WithHelp("s", "allow session"),
 		),
+		SaveRule: key.NewBinding(
+			key.WithKeys("r", "R"),
+			key.WithHelp("r", "allow & save rule"),
+		),
 		Deny: key.NewBinding(
 			key.WithKeys("d", "D", "ctrl+d", "esc"),
 			key.WithHelp("d", "deny"),
@@ -81,6 +86,7 @@ func (k KeyMap) KeyBindings() []key.Binding {
 		k.Select,
 		k.Allow,
 		k.AllowSession,
+		k.SaveRule,
 		k.Deny,
 		k.ToggleDiffMode,
 		k.ScrollDown,
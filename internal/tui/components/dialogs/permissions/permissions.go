@@ -26,6 +26,9 @@ const (
 	PermissionAllow           PermissionAction = "allow"
 	PermissionAllowForSession PermissionAction = "allow_session"
 	PermissionDeny            PermissionAction = "deny"
+	// PermissionAllowAndSaveRule allows the request and saves an allow rule
+	// for requests like it.
+	PermissionAllowAndSaveRule PermissionAction = "allow_save_rule"
 
 	PermissionsDialogID dialogs.DialogID = "permissions"
 )
@@ -36,6 +39,12 @@ type PermissionResponseMsg struct {
 	Action     PermissionAction
 }
 
+// VotesMsg shows how the buddies of a shared session voted on a tool call.
+type VotesMsg struct {
+	ToolCallID string
+	Votes      string
+}
+
 // PermissionDialogCmp interface for permission dialog component
 type PermissionDialogCmp interface {
 	dialogs.DialogModel
@@ -49,7 +58,8 @@ type permissionDialogCmp struct {
 	height          int
 	permission      permission.PermissionRequest
 	contentViewPort viewport.Model
-	selectedOption  int // 0: Allow, 1: Allow for session, 2: Deny
+	selectedOption  int // 0: Allow, 1: Allow for session, 2: Allow & save rule, 3: Deny
+	votes           string
 
 	// Diff view state
 	defaultDiffSplitMode bool  // true for split, false for unified
@@ -99,13 +109,17 @@ func (p *permissionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		p.contentDirty = true // Mark content as dirty on window resize
 		cmd := p.SetSize()
 		cmds = append(cmds, cmd)
+	case VotesMsg:
+		if msg.ToolCallID == p.permission.ToolCallID {
+			p.votes = msg.Votes
+		}
 	case tea.KeyPressMsg:
 		switch {
 		case key.Matches(msg, p.keyMap.Right) || key.Matches(msg, p.keyMap.Tab):
-			p.selectedOption = (p.selectedOption + 1) % 3
+			p.selectedOption = (p.selectedOption + 1) % 4
 			return p, nil
 		case key.Matches(msg, p.keyMap.Left):
-			p.selectedOption = (p.selectedOption + 2) % 3
+			p.selectedOption = (p.selectedOption + 3) % 4
 		case key.Matches(msg, p.keyMap.Select):
 			return p, p.selectCurrentOption()
 		case key.Matches(msg, p.keyMap.Allow):
@@ -118,6 +132,11 @@ func (p *permissionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				util.CmdHandler(dialogs.CloseDialogMsg{}),
 				util.CmdHandler(PermissionResponseMsg{Action: PermissionAllowForSession, Permission: p.permission}),
 			)
+		case key.Matches(msg, p.keyMap.SaveRule):
+			return p, tea.Batch(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(PermissionResponseMsg{Action: PermissionAllowAndSaveRule, Permission: p.permission}),
+			)
 		case key.Matches(msg, p.keyMap.Deny):
 			return p, tea.Batch(
 				util.CmdHandler(dialogs.CloseDialogMsg{}),
@@ -222,6 +241,8 @@ func (p *permissionDialogCmp) selectCurrentOption() tea.Cmd {
 	case 1:
 		action = PermissionAllowForSession
 	case 2:
+		action = PermissionAllowAndSaveRule
+	case 3:
 		action = PermissionDeny
 	}
 
@@ -246,10 +267,15 @@ func (p *permissionDialogCmp) renderButtons() string {
 			UnderlineIndex: 10, // "S" in "Session"
 			Selected:       p.selectedOption == 1,
 		},
+		{
+			Text:           "Allow & Save Rule",
+			UnderlineIndex: 13, // "R" in "Rule"
+			Selected:       p.selectedOption == 2,
+		},
 		{
 			Text:           "Deny",
 			UnderlineIndex: 0, // "D"
-			Selected:       p.selectedOption == 2,
+			Selected:       p.selectedOption == 3,
 		},
 	}
 
@@ -294,9 +320,57 @@ func (p *permissionDialogCmp) renderHeader() string {
 		baseStyle.Render(strings.Repeat(" ", p.width)),
 	}
 
+	if p.permission.Rule != "" {
+		ruleKey := t.S().Muted.Render("Rule")
+		ruleValue := t.S().Text.
+			Width(p.width - lipgloss.Width(ruleKey)).
+			Render(fmt.Sprintf(" %s", p.permission.Rule))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				ruleKey,
+				ruleValue,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
+	}
+
+	if p.votes != "" {
+		votesKey := t.S().Muted.Render("Votes")
+		votesValue := t.S().Text.
+			Width(p.width - lipgloss.Width(votesKey)).
+			Render(fmt.Sprintf(" %s", p.votes))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				votesKey,
+				votesValue,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
+	}
+
 	// Add tool-specific header information
 	switch p.permission.ToolName {
 	case tools.BashToolName:
+		if params, ok := p.permission.Params.(tools.BashPermissionsParams); ok && params.Sandbox != "" {
+			sandbox := params.Sandbox
+			if params.Network {
+				sandbox += ", network requested"
+			}
+			sandboxKey := t.S().Muted.Render("Sandbox")
+			sandboxValue := t.S().Text.
+				Width(p.width - lipgloss.Width(sandboxKey)).
+				Render(fmt.Sprintf(" %s", sandbox))
+			headerParts = append(headerParts,
+				lipgloss.JoinHorizontal(
+					lipgloss.Left,
+					sandboxKey,
+					sandboxValue,
+				),
+				baseStyle.Render(strings.Repeat(" ", p.width)),
+			)
+		}
 		headerParts = append(headerParts, t.S().Muted.Width(p.width).Render("Command"))
 	case tools.DownloadToolName:
 		params := p.permission.Params.(tools.DownloadPermissionsParams)
@@ -0,0 +1,71 @@
+package proposals
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the keyboard bindings for the proposals dialog.
+type KeyMap struct {
+	Up,
+	Down,
+	Accept,
+	Edit,
+	Dismiss,
+	Close key.Binding
+}
+
+func DefaultKeymap() KeyMap {
+	return KeyMap{
+		Up: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑", "previous"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓", "next"),
+		),
+		Accept: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "run"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "edit"),
+		),
+		Dismiss: key.NewBinding(
+			key.WithKeys("x", "d"),
+			key.WithHelp("x", "dismiss"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "close"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.Up,
+		k.Down,
+		k.Accept,
+		k.Edit,
+		k.Dismiss,
+		k.Close,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.KeyBindings()}
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{
+		k.Accept,
+		k.Edit,
+		k.Dismiss,
+		k.Close,
+	}
+}
@@ -0,0 +1,208 @@
+package proposals
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/bubbles/v2/textarea"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/chasedut/toke/internal/tui/components/dialogs"
+	"github.com/chasedut/toke/internal/tui/styles"
+	"github.com/chasedut/toke/internal/tui/util"
+	"github.com/chasedut/toke/internal/webshare"
+)
+
+const (
+	ProposalsDialogID dialogs.DialogID = "proposals"
+
+	// maxPromptLines caps how many lines of the selected prompt are shown.
+	maxPromptLines = 8
+)
+
+// AcceptedMsg is sent when the host accepts a prompt proposed by a buddy,
+// possibly after editing it.
+type AcceptedMsg struct {
+	Proposal webshare.Proposal
+	Prompt   string
+}
+
+// ProposalsDialog is the queue of prompts the buddies of a shared session
+// proposed.
+type ProposalsDialog interface {
+	dialogs.DialogModel
+}
+
+type proposalsDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	share    *webshare.SessionShare
+	selected int
+	// editing is the proposal whose prompt the host edits in input.
+	editing *webshare.Proposal
+	input   *textarea.Model
+	keymap  KeyMap
+}
+
+// NewProposalsDialog returns the queue of proposals of a share.
+func NewProposalsDialog(share *webshare.SessionShare) ProposalsDialog {
+	input := textarea.New()
+	input.SetStyles(styles.CurrentTheme().S().TextArea)
+	input.ShowLineNumbers = false
+	input.CharLimit = -1
+	return &proposalsDialogCmp{
+		share:  share,
+		input:  input,
+		keymap: DefaultKeymap(),
+	}
+}
+
+func (p *proposalsDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles keyboard input for the proposals dialog.
+func (p *proposalsDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		p.wWidth = msg.Width
+		p.wHeight = msg.Height
+		p.input.SetWidth(p.width() - 4)
+		p.input.SetHeight(maxPromptLines)
+	case tea.KeyPressMsg:
+		if p.editing != nil {
+			return p.updateEditing(msg)
+		}
+		proposals := p.share.Proposals()
+		switch {
+		case key.Matches(msg, p.keymap.Close):
+			return p, util.CmdHandler(dialogs.CloseDialogMsg{})
+		case key.Matches(msg, p.keymap.Up):
+			p.selected = max(p.selected-1, 0)
+		case key.Matches(msg, p.keymap.Down):
+			p.selected = min(p.selected+1, max(len(proposals)-1, 0))
+		case len(proposals) == 0:
+			return p, nil
+		case key.Matches(msg, p.keymap.Accept):
+			proposal := proposals[min(p.selected, len(proposals)-1)]
+			return p, p.accept(proposal, proposal.Prompt)
+		case key.Matches(msg, p.keymap.Edit):
+			proposal := proposals[min(p.selected, len(proposals)-1)]
+			p.editing = &proposal
+			p.input.SetValue(proposal.Prompt)
+			p.input.Focus()
+		case key.Matches(msg, p.keymap.Dismiss):
+			proposal := proposals[min(p.selected, len(proposals)-1)]
+			p.share.ResolveProposal(proposal.ID, false)
+			p.selected = max(min(p.selected, len(proposals)-2), 0)
+			return p, util.ReportInfo(fmt.Sprintf("Dismissed %s's prompt", proposal.BuddyName))
+		}
+	}
+	return p, nil
+}
+
+// updateEditing handles keys while the selected prompt is edited: enter runs
+// the edited prompt and esc goes back to the queue.
+func (p *proposalsDialogCmp) updateEditing(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, p.keymap.Close):
+		p.editing = nil
+		p.input.Blur()
+		return p, nil
+	case key.Matches(msg, p.keymap.Accept):
+		prompt := strings.TrimSpace(p.input.Value())
+		if prompt == "" {
+			return p, nil
+		}
+		return p, p.accept(*p.editing, prompt)
+	}
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return p, cmd
+}
+
+func (p *proposalsDialogCmp) accept(proposal webshare.Proposal, prompt string) tea.Cmd {
+	return tea.Sequence(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(AcceptedMsg{Proposal: proposal, Prompt: prompt}),
+	)
+}
+
+func (p *proposalsDialogCmp) width() int {
+	return min(max(p.wWidth*6/10, 40), 100)
+}
+
+// View renders the queue, with the selected prompt in full.
+func (p *proposalsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+	width := p.width()
+
+	lines := []string{t.S().Title.Render("Buddy Proposals"), ""}
+	proposals := p.share.Proposals()
+	if len(proposals) == 0 {
+		lines = append(lines, t.S().Muted.Render("No prompts waiting. Buddies propose them from the shared page."))
+	}
+	for i, proposal := range proposals {
+		line := fmt.Sprintf("  %s (%s): %s", proposal.BuddyName, proposal.CreatedAt.Format("15:04"), truncate(proposal.Prompt, width-20))
+		style := t.S().Muted
+		if i == min(p.selected, len(proposals)-1) {
+			line = "> " + line[2:]
+			style = t.S().Text.Foreground(t.Secondary)
+		}
+		lines = append(lines, style.Render(line))
+	}
+
+	if len(proposals) > 0 {
+		lines = append(lines, "")
+		if p.editing != nil {
+			lines = append(lines, p.input.View())
+		} else {
+			prompt := proposals[min(p.selected, len(proposals)-1)].Prompt
+			promptLines := strings.Split(baseStyle.Width(width-4).Render(prompt), "\n")
+			if len(promptLines) > maxPromptLines {
+				promptLines = append(promptLines[:maxPromptLines-1], "…")
+			}
+			lines = append(lines, promptLines...)
+		}
+	}
+
+	keymap := p.keymap
+	if p.editing != nil {
+		keymap.Up.SetEnabled(false)
+		keymap.Down.SetEnabled(false)
+		keymap.Edit.SetEnabled(false)
+		keymap.Dismiss.SetEnabled(false)
+		keymap.Close.SetHelp("esc", "stop editing")
+	}
+	lines = append(lines, "", help.New().View(keymap))
+
+	content := baseStyle.Width(width - 4).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	return baseStyle.
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(content)
+}
+
+func (p *proposalsDialogCmp) Position() (int, int) {
+	view := p.View()
+	row := (p.wHeight - lipgloss.Height(view)) / 2
+	col := (p.wWidth - lipgloss.Width(view)) / 2
+	return max(row, 0), max(col, 0)
+}
+
+func (p *proposalsDialogCmp) ID() dialogs.DialogID {
+	return ProposalsDialogID
+}
+
+func truncate(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) <= width {
+		return s
+	}
+	return string([]rune(s)[:max(width-1, 0)]) + "…"
+}
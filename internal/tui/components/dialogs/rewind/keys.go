@@ -0,0 +1,57 @@
+package rewind
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the keyboard bindings for the rewind dialog.
+type KeyMap struct {
+	LeftRight,
+	EnterSpace,
+	Tab,
+	Close key.Binding
+}
+
+func DefaultKeymap() KeyMap {
+	return KeyMap{
+		LeftRight: key.NewBinding(
+			key.WithKeys("left", "right"),
+			key.WithHelp("←/→", "switch options"),
+		),
+		EnterSpace: key.NewBinding(
+			key.WithKeys("enter", " "),
+			key.WithHelp("enter/space", "confirm"),
+		),
+		Tab: key.NewBinding(
+			key.WithKeys("tab"),
+			key.WithHelp("tab", "switch options"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc", "n", "N"),
+			key.WithHelp("esc", "cancel"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.LeftRight,
+		k.EnterSpace,
+		k.Tab,
+		k.Close,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.KeyBindings()}
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{
+		k.LeftRight,
+		k.EnterSpace,
+	}
+}
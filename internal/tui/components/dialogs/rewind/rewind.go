@@ -0,0 +1,168 @@
+package rewind
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/chasedut/toke/internal/checkpoint"
+	"github.com/chasedut/toke/internal/fsext"
+	"github.com/chasedut/toke/internal/tui/components/dialogs"
+	"github.com/chasedut/toke/internal/tui/styles"
+	"github.com/chasedut/toke/internal/tui/util"
+)
+
+const (
+	RewindDialogID dialogs.DialogID = "rewind"
+
+	// maxListedFiles caps how many modified files are listed by name.
+	maxListedFiles = 5
+)
+
+// ConfirmedMsg is sent when the user confirms the rewind.
+type ConfirmedMsg struct {
+	Plan    checkpoint.Plan
+	Options checkpoint.Options
+}
+
+// RewindDialog asks the user to confirm rewinding a session to a checkpoint.
+type RewindDialog interface {
+	dialogs.DialogModel
+}
+
+type rewindDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	plan           checkpoint.Plan
+	selectedOption int // 0: Files, 1: Files + Conversation, 2: Cancel
+	keymap         KeyMap
+}
+
+// NewRewindDialog creates a new rewind confirmation dialog for a plan.
+func NewRewindDialog(plan checkpoint.Plan) RewindDialog {
+	return &rewindDialogCmp{
+		plan:   plan,
+		keymap: DefaultKeymap(),
+	}
+}
+
+func (r *rewindDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles keyboard input for the rewind dialog.
+func (r *rewindDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		r.wWidth = msg.Width
+		r.wHeight = msg.Height
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, r.keymap.LeftRight, r.keymap.Tab):
+			r.selectedOption = (r.selectedOption + 1) % 3
+			return r, nil
+		case key.Matches(msg, r.keymap.EnterSpace):
+			if r.selectedOption == 2 {
+				return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+			}
+			// The dialog lists the modified files, so confirming is
+			// consent to overwrite them.
+			opts := checkpoint.Options{
+				Conversation: r.selectedOption == 1,
+				Force:        true,
+			}
+			return r, tea.Sequence(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(ConfirmedMsg{Plan: r.plan, Options: opts}),
+			)
+		case key.Matches(msg, r.keymap.Close):
+			return r, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return r, nil
+}
+
+// View renders the rewind dialog with a summary of the plan.
+func (r *rewindDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	var restore, remove int
+	for _, c := range r.plan.Changes {
+		if c.Delete {
+			remove++
+		} else {
+			restore++
+		}
+	}
+
+	lines := []string{
+		t.S().Title.Render("Rewind to before this message?"),
+		"",
+		t.S().Muted.Render(truncate(r.plan.Message.Content().Text, 50)),
+		"",
+	}
+	if len(r.plan.Changes) == 0 {
+		lines = append(lines, "No files changed since this message.")
+	} else {
+		lines = append(lines, fmt.Sprintf("%d file(s) will be restored, %d deleted.", restore, remove))
+	}
+	if modified := r.plan.Modified(); len(modified) > 0 {
+		warn := t.S().Warning
+		lines = append(lines, "", warn.Render("Modified outside toke and will be overwritten:"))
+		for i, c := range modified {
+			if i == maxListedFiles {
+				lines = append(lines, warn.Render(fmt.Sprintf("  …and %d more", len(modified)-i)))
+				break
+			}
+			lines = append(lines, warn.Render("  "+fsext.PrettyPath(c.Path)))
+		}
+	}
+
+	buttonStyle := t.S().Text
+	labels := []string{"Rewind Files", "Files + Conversation", "Cancel"}
+	buttons := make([]string, 0, len(labels)*2)
+	for i, label := range labels {
+		style := buttonStyle.Background(t.BgSubtle)
+		if i == r.selectedOption {
+			style = style.Foreground(t.White).Background(t.Secondary)
+		}
+		if i > 0 {
+			buttons = append(buttons, " ")
+		}
+		buttons = append(buttons, style.Padding(0, 2).Render(label))
+	}
+	lines = append(lines, "", baseStyle.Align(lipgloss.Center).Render(
+		lipgloss.JoinHorizontal(lipgloss.Center, buttons...),
+	))
+
+	content := baseStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	return baseStyle.
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(content)
+}
+
+func (r *rewindDialogCmp) Position() (int, int) {
+	view := r.View()
+	row := (r.wHeight - lipgloss.Height(view)) / 2
+	col := (r.wWidth - lipgloss.Width(view)) / 2
+	return max(row, 0), max(col, 0)
+}
+
+func (r *rewindDialogCmp) ID() dialogs.DialogID {
+	return RewindDialogID
+}
+
+func truncate(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
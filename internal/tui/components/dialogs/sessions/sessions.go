@@ -1,9 +1,15 @@
 package sessions
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"github.com/charmbracelet/bubbles/v2/help"
 	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/bubbles/v2/textinput"
 	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/chasedut/toke/internal/message"
 	"github.com/chasedut/toke/internal/session"
 	"github.com/chasedut/toke/internal/tui/components/chat"
 	"github.com/chasedut/toke/internal/tui/components/core"
@@ -16,6 +22,9 @@ import (
 
 const SessionsDialogID dialogs.DialogID = "sessions"
 
+// searchLimit is the number of messages shown in search mode.
+const searchLimit = 50
+
 // SessionDialog interface for the session switching dialog
 type SessionDialog interface {
 	dialogs.DialogModel
@@ -23,6 +32,16 @@ type SessionDialog interface {
 
 type SessionsList = list.FilterableList[list.CompletionItem[session.Session]]
 
+type ResultsList = list.List[list.CompletionItem[message.SearchResult]]
+
+// searchResultsMsg carries the results of the search with the given
+// sequence number, so that results of older queries are dropped.
+type searchResultsMsg struct {
+	seq     int
+	results []message.SearchResult
+	err     error
+}
+
 type sessionDialogCmp struct {
 	selectedInx       int
 	wWidth            int
@@ -32,10 +51,20 @@ type sessionDialogCmp struct {
 	keyMap            KeyMap
 	sessionsList      SessionsList
 	help              help.Model
+
+	// Search mode looks for messages across all sessions
+	messages    message.Service
+	sessions    map[string]session.Session
+	searching   bool
+	searchInput textinput.Model
+	searchSeq   int
+	query       string
+	resultsList ResultsList
 }
 
-// NewSessionDialogCmp creates a new session switching dialog
-func NewSessionDialogCmp(sessions []session.Session, selectedID string) SessionDialog {
+// NewSessionDialogCmp creates a new session switching dialog. Messages are
+// searched with the given service in search mode.
+func NewSessionDialogCmp(sessions []session.Session, selectedID string, messages message.Service) SessionDialog {
 	t := styles.CurrentTheme()
 	listKeyMap := list.DefaultKeyMap()
 	keyMap := DefaultKeyMap()
@@ -44,11 +73,15 @@ func NewSessionDialogCmp(sessions []session.Session, selectedID string) SessionD
 	listKeyMap.DownOneItem = keyMap.Next
 	listKeyMap.UpOneItem = keyMap.Previous
 
-	items := make([]list.CompletionItem[session.Session], len(sessions))
-	if len(sessions) > 0 {
-		for i, session := range sessions {
-			items[i] = list.NewCompletionItem(session.Title, session, list.WithCompletionID(session.ID))
+	items := make([]list.CompletionItem[session.Session], 0, len(sessions))
+	byID := make(map[string]session.Session, len(sessions))
+	for _, node := range sessionTree(sessions) {
+		title := node.session.Title
+		if node.depth > 0 {
+			title = strings.Repeat("  ", node.depth-1) + "└ " + title
 		}
+		items = append(items, list.NewCompletionItem(title, node.session, list.WithCompletionID(node.session.ID)))
+		byID[node.session.ID] = node.session
 	}
 
 	inputStyle := t.S().Base.PaddingLeft(1).PaddingBottom(1)
@@ -61,18 +94,68 @@ func NewSessionDialogCmp(sessions []session.Session, selectedID string) SessionD
 			list.WithWrapNavigation(),
 		),
 	)
+	searchInput := textinput.New()
+	searchInput.Placeholder = "Search messages, tool calls and results"
+	searchInput.SetVirtualCursor(false)
+	searchInput.SetStyles(t.S().TextInput)
+	resultsList := list.New(
+		[]list.CompletionItem[message.SearchResult]{},
+		list.WithKeyMap(listKeyMap),
+		list.WithWrapNavigation(),
+	)
+
 	help := help.New()
 	help.Styles = t.S().Help
 	s := &sessionDialogCmp{
 		selectedSessionID: selectedID,
-		keyMap:            DefaultKeyMap(),
+		keyMap:            keyMap,
 		sessionsList:      sessionsList,
 		help:              help,
+		messages:          messages,
+		sessions:          byID,
+		searchInput:       searchInput,
+		resultsList:       resultsList,
 	}
 
 	return s
 }
 
+type treeNode struct {
+	session session.Session
+	depth   int
+}
+
+// sessionTree orders sessions so that forks follow the session they were
+// forked from, keeping the order of the list otherwise.
+func sessionTree(sessions []session.Session) []treeNode {
+	listed := make(map[string]bool, len(sessions))
+	for _, s := range sessions {
+		listed[s.ID] = true
+	}
+	forks := make(map[string][]session.Session)
+	var roots []session.Session
+	for _, s := range sessions {
+		if s.ForkedFromID != "" && listed[s.ForkedFromID] {
+			forks[s.ForkedFromID] = append(forks[s.ForkedFromID], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	nodes := make([]treeNode, 0, len(sessions))
+	var add func(s session.Session, depth int)
+	add = func(s session.Session, depth int) {
+		nodes = append(nodes, treeNode{session: s, depth: depth})
+		for _, fork := range forks[s.ID] {
+			add(fork, depth+1)
+		}
+	}
+	for _, s := range roots {
+		add(s, 0)
+	}
+	return nodes
+}
+
 func (s *sessionDialogCmp) Init() tea.Cmd {
 	var cmds []tea.Cmd
 	cmds = append(cmds, s.sessionsList.Init())
@@ -89,12 +172,54 @@ func (s *sessionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		s.width = min(120, s.wWidth-8)
 		s.sessionsList.SetInputWidth(s.listWidth() - 2)
 		cmds = append(cmds, s.sessionsList.SetSize(s.listWidth(), s.listHeight()))
+		s.searchInput.SetWidth(s.listWidth() - 2)
+		cmds = append(cmds, s.resultsList.SetSize(s.listWidth(), s.listHeight()-s.searchInputHeight()))
 		if s.selectedSessionID != "" {
 			cmds = append(cmds, s.sessionsList.SetSelected(s.selectedSessionID))
 		}
 		return s, tea.Batch(cmds...)
+	case searchResultsMsg:
+		if msg.seq != s.searchSeq {
+			return s, nil
+		}
+		if msg.err != nil {
+			return s, util.ReportError(msg.err)
+		}
+		items := make([]list.CompletionItem[message.SearchResult], len(msg.results))
+		for i, r := range msg.results {
+			text, matches := resultText(r)
+			items[i] = list.NewCompletionItem(
+				text,
+				r,
+				list.WithCompletionID(r.MessageID),
+				list.WithCompletionMatchIndexes(matches...),
+				list.WithCompletionShortcut(truncate(r.SessionTitle, 24)),
+			)
+		}
+		return s, s.resultsList.SetItems(items)
 	case tea.KeyPressMsg:
 		switch {
+		case key.Matches(msg, s.keyMap.Search):
+			return s, s.toggleSearch()
+		case s.searching && key.Matches(msg, s.keyMap.Select):
+			selectedItem := s.resultsList.SelectedItem()
+			if selectedItem == nil {
+				return s, nil
+			}
+			result := (*selectedItem).Value()
+			sess, ok := s.sessions[result.SessionID]
+			if !ok {
+				return s, util.ReportWarn("The session of this message no longer exists")
+			}
+			// Messages of sub-agents are shown in their tool call
+			id := result.MessageID
+			if result.ToolCallID != "" {
+				id = result.ToolCallID
+			}
+			return s, tea.Sequence(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(chat.MessageSelectedMsg{Session: sess, MessageID: id}),
+			)
 		case key.Matches(msg, s.keyMap.Select):
 			selectedItem := s.sessionsList.SelectedItem()
 			if selectedItem != nil {
@@ -108,6 +233,18 @@ func (s *sessionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case key.Matches(msg, s.keyMap.Close):
 			return s, util.CmdHandler(dialogs.CloseDialogMsg{})
+		case s.searching && (key.Matches(msg, s.keyMap.Next) || key.Matches(msg, s.keyMap.Previous)):
+			u, cmd := s.resultsList.Update(msg)
+			s.resultsList = u.(ResultsList)
+			return s, cmd
+		case s.searching:
+			var cmd tea.Cmd
+			s.searchInput, cmd = s.searchInput.Update(msg)
+			if s.searchInput.Value() == s.query {
+				return s, cmd
+			}
+			s.query = s.searchInput.Value()
+			return s, tea.Batch(cmd, s.search(s.query))
 		default:
 			u, cmd := s.sessionsList.Update(msg)
 			s.sessionsList = u.(SessionsList)
@@ -117,12 +254,95 @@ func (s *sessionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return s, nil
 }
 
+// toggleSearch switches between filtering sessions and searching messages.
+func (s *sessionDialogCmp) toggleSearch() tea.Cmd {
+	s.searching = !s.searching
+	if s.searching {
+		s.keyMap.Search.SetHelp("ctrl+f", "sessions")
+		return tea.Batch(s.sessionsList.Blur(), s.searchInput.Focus(), s.resultsList.Focus())
+	}
+	s.keyMap.Search.SetHelp("ctrl+f", "search messages")
+	s.searchInput.Blur()
+	return tea.Batch(s.resultsList.Blur(), s.sessionsList.Focus())
+}
+
+// search looks for messages matching the query in the background.
+func (s *sessionDialogCmp) search(query string) tea.Cmd {
+	s.searchSeq++
+	seq := s.searchSeq
+	if strings.TrimSpace(query) == "" {
+		return util.CmdHandler(searchResultsMsg{seq: seq})
+	}
+	return func() tea.Msg {
+		results, err := s.messages.Search(context.Background(), query, searchLimit)
+		if err != nil {
+			err = fmt.Errorf("failed to search messages: %w", err)
+		}
+		return searchResultsMsg{seq: seq, results: results, err: err}
+	}
+}
+
+// resultText returns the snippet of a search result on a single line, with
+// the byte offsets of the characters of its matches.
+func resultText(r message.SearchResult) (string, []int) {
+	marked := r.HighlightSnippet(func(match string) string {
+		return message.MatchStart + match + message.MatchEnd
+	})
+	var (
+		text    strings.Builder
+		matches []int
+		inMatch bool
+	)
+	for _, c := range marked {
+		switch string(c) {
+		case message.MatchStart:
+			inMatch = true
+		case message.MatchEnd:
+			inMatch = false
+		default:
+			if inMatch {
+				matches = append(matches, text.Len())
+			}
+			text.WriteRune(c)
+		}
+	}
+	return text.String(), matches
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+func (s *sessionDialogCmp) searchInputHeight() int {
+	return lipgloss.Height(s.searchInputStyle().Render(s.searchInput.View()))
+}
+
+func (s *sessionDialogCmp) searchInputStyle() lipgloss.Style {
+	return styles.CurrentTheme().S().Base.PaddingLeft(1).PaddingBottom(1)
+}
+
 func (s *sessionDialogCmp) View() string {
 	t := styles.CurrentTheme()
+	title := "Switch Session"
 	listView := s.sessionsList.View()
+	if s.searching {
+		title = "Search Messages"
+		results := s.resultsList.View()
+		if len(s.resultsList.Items()) == 0 && strings.TrimSpace(s.query) != "" {
+			results = t.S().Muted.PaddingLeft(1).Render("No matching messages")
+		}
+		listView = lipgloss.JoinVertical(
+			lipgloss.Left,
+			s.searchInputStyle().Render(s.searchInput.View()),
+			results,
+		)
+	}
 	content := lipgloss.JoinVertical(
 		lipgloss.Left,
-		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Switch Session", s.width-4)),
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title(title, s.width-4)),
 		listView,
 		"",
 		t.S().Base.Width(s.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(s.help.View(s.keyMap)),
@@ -132,6 +352,12 @@ func (s *sessionDialogCmp) View() string {
 }
 
 func (s *sessionDialogCmp) Cursor() *tea.Cursor {
+	if s.searching {
+		if cursor := s.searchInput.Cursor(); cursor != nil {
+			return s.moveCursor(cursor)
+		}
+		return nil
+	}
 	if cursor, ok := s.sessionsList.(util.Cursor); ok {
 		cursor := cursor.Cursor()
 		if cursor != nil {
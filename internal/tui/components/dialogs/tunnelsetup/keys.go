@@ -0,0 +1,71 @@
+package tunnelsetup
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the keyboard bindings for the tunnel setup dialog.
+type KeyMap struct {
+	NextProvider,
+	PreviousProvider,
+	NextField,
+	PreviousField,
+	Save,
+	Close key.Binding
+}
+
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		NextProvider: key.NewBinding(
+			key.WithKeys("right"),
+			key.WithHelp("→", "next provider"),
+		),
+		PreviousProvider: key.NewBinding(
+			key.WithKeys("left"),
+			key.WithHelp("←", "previous provider"),
+		),
+		NextField: key.NewBinding(
+			key.WithKeys("tab", "down"),
+			key.WithHelp("tab", "next field"),
+		),
+		PreviousField: key.NewBinding(
+			key.WithKeys("shift+tab", "up"),
+			key.WithHelp("shift+tab", "previous field"),
+		),
+		Save: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "save"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "cancel"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.NextProvider,
+		k.PreviousProvider,
+		k.NextField,
+		k.PreviousField,
+		k.Save,
+		k.Close,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.KeyBindings()}
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{
+		k.NextProvider,
+		k.NextField,
+		k.Save,
+		k.Close,
+	}
+}
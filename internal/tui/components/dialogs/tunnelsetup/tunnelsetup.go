@@ -0,0 +1,424 @@
+package tunnelsetup
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/bubbles/v2/textinput"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/chasedut/toke/internal/config"
+	"github.com/chasedut/toke/internal/tui/components/dialogs"
+	"github.com/chasedut/toke/internal/tui/styles"
+	"github.com/chasedut/toke/internal/tui/util"
+	"github.com/chasedut/toke/internal/webshare"
+)
+
+const (
+	TunnelSetupDialogID dialogs.DialogID = "tunnel-setup"
+)
+
+// SetupDoneMsg is sent once the tunnel options are saved, to start sharing
+// the session again.
+type SetupDoneMsg struct {
+	SessionID string
+}
+
+// field is a setting of a tunnel provider.
+type field struct {
+	label       string
+	placeholder string
+	secret      bool
+	get         func(*config.TunnelOptions) string
+	set         func(*config.TunnelOptions, string) error
+}
+
+// provider is a tunnel provider the dialog sets up.
+type provider struct {
+	name   config.TunnelProvider
+	help   []string
+	fields []field
+}
+
+var providers = []provider{
+	{
+		name: config.TunnelNgrok,
+		help: []string{
+			"Public HTTPS link through ngrok.",
+			"Get an authtoken at https://dashboard.ngrok.com/get-started/your-authtoken",
+		},
+		fields: []field{
+			{
+				label:       "Authtoken",
+				placeholder: "Leave empty to use the ngrok config",
+				secret:      true,
+				get: func(o *config.TunnelOptions) string {
+					if o.Ngrok == nil {
+						return ""
+					}
+					return o.Ngrok.AuthToken
+				},
+				set: func(o *config.TunnelOptions, v string) error {
+					o.Ngrok = &config.NgrokOptions{AuthToken: v}
+					return nil
+				},
+			},
+		},
+	},
+	{
+		name: config.TunnelCloudflared,
+		help: []string{
+			"Public HTTPS link through Cloudflare.",
+			"Without a token a random trycloudflare.com link is used.",
+		},
+		fields: []field{
+			{
+				label:       "Tunnel token",
+				placeholder: "Leave empty for a quick tunnel",
+				secret:      true,
+				get: func(o *config.TunnelOptions) string {
+					if o.Cloudflared == nil {
+						return ""
+					}
+					return o.Cloudflared.Token
+				},
+				set: func(o *config.TunnelOptions, v string) error {
+					if o.Cloudflared == nil {
+						o.Cloudflared = &config.CloudflaredOptions{}
+					}
+					o.Cloudflared.Token = v
+					return nil
+				},
+			},
+			{
+				label:       "Public URL",
+				placeholder: "https://share.example.com",
+				get: func(o *config.TunnelOptions) string {
+					if o.Cloudflared == nil {
+						return ""
+					}
+					return o.Cloudflared.URL
+				},
+				set: func(o *config.TunnelOptions, v string) error {
+					if o.Cloudflared == nil {
+						o.Cloudflared = &config.CloudflaredOptions{}
+					}
+					o.Cloudflared.URL = v
+					return nil
+				},
+			},
+		},
+	},
+	{
+		name: config.TunnelSSH,
+		help: []string{
+			"Forwards a port of a host you can ssh into with ssh -R.",
+			"The host needs GatewayPorts enabled or a reverse proxy.",
+		},
+		fields: []field{
+			{
+				label:       "Host",
+				placeholder: "me@example.com",
+				get: func(o *config.TunnelOptions) string {
+					if o.SSH == nil {
+						return ""
+					}
+					return o.SSH.Host
+				},
+				set: func(o *config.TunnelOptions, v string) error {
+					if o.SSH == nil {
+						o.SSH = &config.SSHTunnelOptions{}
+					}
+					o.SSH.Host = v
+					return nil
+				},
+			},
+			{
+				label:       "Remote port",
+				placeholder: "Leave empty to let the host pick one",
+				get: func(o *config.TunnelOptions) string {
+					if o.SSH == nil || o.SSH.RemotePort == 0 {
+						return ""
+					}
+					return strconv.Itoa(o.SSH.RemotePort)
+				},
+				set: func(o *config.TunnelOptions, v string) error {
+					if o.SSH == nil {
+						o.SSH = &config.SSHTunnelOptions{}
+					}
+					if v == "" {
+						o.SSH.RemotePort = 0
+						return nil
+					}
+					port, err := strconv.Atoi(v)
+					if err != nil || port < 1 || port > 65535 {
+						return fmt.Errorf("invalid remote port %q", v)
+					}
+					o.SSH.RemotePort = port
+					return nil
+				},
+			},
+			{
+				label:       "Public URL",
+				placeholder: "Defaults to http://<host>:<remote port>",
+				get: func(o *config.TunnelOptions) string {
+					if o.SSH == nil {
+						return ""
+					}
+					return o.SSH.URL
+				},
+				set: func(o *config.TunnelOptions, v string) error {
+					if o.SSH == nil {
+						o.SSH = &config.SSHTunnelOptions{}
+					}
+					o.SSH.URL = v
+					return nil
+				},
+			},
+		},
+	},
+	{
+		name: config.TunnelLAN,
+		help: []string{
+			"Only buddies on your network can join, with a QR code.",
+		},
+		fields: []field{
+			{
+				label:       "Interface",
+				placeholder: "Leave empty for the first one with an IPv4 address",
+				get: func(o *config.TunnelOptions) string {
+					if o.LAN == nil {
+						return ""
+					}
+					return o.LAN.Interface
+				},
+				set: func(o *config.TunnelOptions, v string) error {
+					o.LAN = &config.LANOptions{Interface: v}
+					return nil
+				},
+			},
+		},
+	},
+}
+
+// TunnelSetupDialog picks the tunnel that shares sessions and saves its
+// settings in the config.
+type TunnelSetupDialog struct {
+	width     int
+	height    int
+	sessionID string
+	// reason is why the tunnel couldn't start, if it was opened for that.
+	reason   string
+	selected int
+	inputs   []textinput.Model
+	focus    int
+	keymap   KeyMap
+	err      error
+}
+
+// NewTunnelSetupDialog returns a dialog that sets up a tunnel, then shares
+// the session again. setupErr is the reason the configured tunnel couldn't
+// start, if any.
+func NewTunnelSetupDialog(setupErr *webshare.SetupError, sessionID string) *TunnelSetupDialog {
+	d := &TunnelSetupDialog{
+		sessionID: sessionID,
+		keymap:    DefaultKeyMap(),
+	}
+
+	current := config.TunnelNgrok
+	if opts := config.Get().Options.WebShare; opts != nil && opts.Tunnel != nil && opts.Tunnel.Provider != "" {
+		current = opts.Tunnel.Provider
+	}
+	if setupErr != nil {
+		current = setupErr.Provider
+		d.reason = setupErr.Reason
+	}
+	d.selected = max(slices.IndexFunc(providers, func(p provider) bool { return p.name == current }), 0)
+	d.resetInputs()
+	return d
+}
+
+// tunnelOptions returns the tunnel options of the config.
+func tunnelOptions() config.TunnelOptions {
+	if opts := config.Get().Options.WebShare; opts != nil && opts.Tunnel != nil {
+		return *opts.Tunnel
+	}
+	return config.TunnelOptions{}
+}
+
+// resetInputs shows the settings of the selected provider.
+func (d *TunnelSetupDialog) resetInputs() {
+	opts := tunnelOptions()
+	fields := providers[d.selected].fields
+	d.inputs = make([]textinput.Model, len(fields))
+	for i, f := range fields {
+		ti := textinput.New()
+		ti.Placeholder = f.placeholder
+		ti.CharLimit = 512
+		ti.SetWidth(50)
+		ti.Prompt = ""
+		if f.secret {
+			ti.EchoMode = textinput.EchoPassword
+			ti.EchoCharacter = '•'
+		}
+		ti.SetValue(f.get(&opts))
+		d.inputs[i] = ti
+	}
+	d.focus = 0
+	d.inputs[0].Focus()
+	d.err = nil
+}
+
+func (d *TunnelSetupDialog) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (d *TunnelSetupDialog) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		d.width = msg.Width
+		d.height = msg.Height
+		return d, nil
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, d.keymap.Close):
+			return d, util.CmdHandler(dialogs.CloseDialogMsg{})
+		case key.Matches(msg, d.keymap.Save):
+			return d, d.save()
+		case key.Matches(msg, d.keymap.NextProvider):
+			d.selected = (d.selected + 1) % len(providers)
+			d.resetInputs()
+			return d, nil
+		case key.Matches(msg, d.keymap.PreviousProvider):
+			d.selected = (d.selected + len(providers) - 1) % len(providers)
+			d.resetInputs()
+			return d, nil
+		case key.Matches(msg, d.keymap.NextField):
+			return d, d.focusField(d.focus + 1)
+		case key.Matches(msg, d.keymap.PreviousField):
+			return d, d.focusField(d.focus - 1)
+		}
+	}
+
+	var cmd tea.Cmd
+	d.inputs[d.focus], cmd = d.inputs[d.focus].Update(msg)
+	return d, cmd
+}
+
+// focusField moves the cursor to the field at index i, wrapping around.
+func (d *TunnelSetupDialog) focusField(i int) tea.Cmd {
+	d.inputs[d.focus].Blur()
+	d.focus = (i + len(d.inputs)) % len(d.inputs)
+	return d.inputs[d.focus].Focus()
+}
+
+// save stores the settings of the selected provider and makes it the tunnel
+// used to share sessions.
+func (d *TunnelSetupDialog) save() tea.Cmd {
+	p := providers[d.selected]
+	opts := tunnelOptions()
+	opts.Provider = p.name
+	for i, f := range p.fields {
+		if err := f.set(&opts, strings.TrimSpace(d.inputs[i].Value())); err != nil {
+			d.err = err
+			return nil
+		}
+	}
+	if err := config.Get().SetTunnelOptions(opts); err != nil {
+		d.err = err
+		return nil
+	}
+	return tea.Sequence(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.CmdHandler(SetupDoneMsg{SessionID: d.sessionID}),
+	)
+}
+
+func (d *TunnelSetupDialog) View() string {
+	if d.width == 0 || d.height == 0 {
+		return ""
+	}
+
+	t := styles.CurrentTheme()
+	var content strings.Builder
+
+	title := t.S().Base.Bold(true).Foreground(t.Primary).Render("Tunnel Setup")
+	content.WriteString(title)
+	content.WriteString("\n\n")
+
+	if d.reason != "" {
+		content.WriteString(t.S().Base.Foreground(t.Warning).Render("⚠ " + d.reason))
+		content.WriteString("\n\n")
+	}
+
+	// Providers, the selected one highlighted
+	tabs := make([]string, len(providers))
+	for i, p := range providers {
+		style := t.S().Base.Foreground(t.FgHalfMuted).Padding(0, 1)
+		if i == d.selected {
+			style = t.S().Base.Foreground(t.FgSelected).Background(t.Primary).Padding(0, 1)
+		}
+		tabs[i] = style.Render(string(p.name))
+	}
+	content.WriteString(lipgloss.JoinHorizontal(lipgloss.Left, tabs...))
+	content.WriteString("\n\n")
+
+	p := providers[d.selected]
+	for _, line := range p.help {
+		content.WriteString(t.S().Base.Foreground(t.FgMuted).Render(line))
+		content.WriteString("\n")
+	}
+	content.WriteString("\n")
+
+	for i, f := range p.fields {
+		label := t.S().Base.Foreground(t.FgMuted).Render(f.label + ":")
+		if i == d.focus {
+			label = t.S().Base.Foreground(t.Primary).Render(f.label + ":")
+		}
+		content.WriteString(label)
+		content.WriteString("\n")
+		content.WriteString(d.inputs[i].View())
+		content.WriteString("\n\n")
+	}
+
+	if d.err != nil {
+		content.WriteString(t.S().Base.Foreground(t.Error).Render("❌ " + d.err.Error()))
+		content.WriteString("\n\n")
+	}
+
+	footer := t.S().Base.Foreground(t.FgHalfMuted).Render("←/→ provider • tab field • enter save and share • esc cancel")
+	content.WriteString(footer)
+
+	dialog := t.S().Base.
+		Width(64).
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.Border).
+		Render(content.String())
+
+	return lipgloss.Place(
+		d.width,
+		d.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		dialog,
+	)
+}
+
+func (d *TunnelSetupDialog) SetSize(width, height int) tea.Cmd {
+	d.width = width
+	d.height = height
+	return nil
+}
+
+func (d *TunnelSetupDialog) ID() dialogs.DialogID {
+	return TunnelSetupDialogID
+}
+
+func (d *TunnelSetupDialog) Position() (int, int) {
+	// Return 0, 0 to center the dialog
+	return 0, 0
+}
@@ -0,0 +1,48 @@
+package usage
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+)
+
+// KeyMap defines the keyboard bindings for the usage dialog.
+type KeyMap struct {
+	Period,
+	GroupBy,
+	Close key.Binding
+}
+
+func DefaultKeymap() KeyMap {
+	return KeyMap{
+		Period: key.NewBinding(
+			key.WithKeys("left", "right"),
+			key.WithHelp("←/→", "period"),
+		),
+		GroupBy: key.NewBinding(
+			key.WithKeys("tab"),
+			key.WithHelp("tab", "group by"),
+		),
+		Close: key.NewBinding(
+			key.WithKeys("esc", "q"),
+			key.WithHelp("esc", "close"),
+		),
+	}
+}
+
+// KeyBindings implements layout.KeyMapProvider
+func (k KeyMap) KeyBindings() []key.Binding {
+	return []key.Binding{
+		k.Period,
+		k.GroupBy,
+		k.Close,
+	}
+}
+
+// FullHelp implements help.KeyMap.
+func (k KeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.KeyBindings()}
+}
+
+// ShortHelp implements help.KeyMap.
+func (k KeyMap) ShortHelp() []key.Binding {
+	return k.KeyBindings()
+}
@@ -0,0 +1,231 @@
+package usage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/chasedut/toke/internal/tui/components/core"
+	"github.com/chasedut/toke/internal/tui/components/dialogs"
+	"github.com/chasedut/toke/internal/tui/styles"
+	"github.com/chasedut/toke/internal/tui/util"
+	"github.com/chasedut/toke/internal/usage"
+)
+
+const (
+	UsageDialogID dialogs.DialogID = "usage"
+
+	defaultWidth = 72
+	// maxRows caps how many groups are listed before the total.
+	maxRows = 10
+)
+
+type period struct {
+	label string
+	start func(now time.Time) time.Time
+}
+
+var periods = []period{
+	{label: "Today", start: usage.Daily.Start},
+	{label: "This Month", start: usage.Monthly.Start},
+	{label: "All Time", start: func(time.Time) time.Time { return time.Time{} }},
+}
+
+var groups = []usage.GroupBy{usage.ByModel, usage.ByDay, usage.BySession}
+
+// UsageDialog shows the token usage and cost of the project.
+type UsageDialog interface {
+	dialogs.DialogModel
+}
+
+type usageDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	records []usage.Record
+	budgets []usage.Budget
+	// titles maps session IDs to their titles.
+	titles map[string]string
+
+	period int
+	group  int
+	keymap KeyMap
+	help   help.Model
+}
+
+// NewUsageDialog creates a dialog that reports the usage records of the
+// project and the state of the budgets.
+func NewUsageDialog(records []usage.Record, budgets []usage.Budget, titles map[string]string) UsageDialog {
+	t := styles.CurrentTheme()
+	h := help.New()
+	h.Styles = t.S().Help
+	return &usageDialogCmp{
+		records: records,
+		budgets: budgets,
+		titles:  titles,
+		period:  1,
+		keymap:  DefaultKeymap(),
+		help:    h,
+	}
+}
+
+func (u *usageDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles keyboard input for the usage dialog.
+func (u *usageDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		u.wWidth = msg.Width
+		u.wHeight = msg.Height
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, u.keymap.Period):
+			step := 1
+			if msg.String() == "left" {
+				step = len(periods) - 1
+			}
+			u.period = (u.period + step) % len(periods)
+		case key.Matches(msg, u.keymap.GroupBy):
+			u.group = (u.group + 1) % len(groups)
+		case key.Matches(msg, u.keymap.Close):
+			return u, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return u, nil
+}
+
+// View renders the usage report of the selected period.
+func (u *usageDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	since := periods[u.period].start(time.Now()).UnixMilli()
+	var records []usage.Record
+	for _, r := range u.records {
+		if r.CreatedAt >= since {
+			records = append(records, r)
+		}
+	}
+	group := groups[u.group]
+
+	lines := []string{
+		core.Title("Usage", defaultWidth-4),
+		"",
+		u.choices(periods[u.period].label, labels(periods, func(p period) string { return p.label })),
+		u.choices(string(group), labels(groups, func(g usage.GroupBy) string { return string(g) })),
+		"",
+	}
+	if len(records) == 0 {
+		lines = append(lines, t.S().Muted.Render("No usage recorded."))
+	} else {
+		rows := usage.Summarize(records, group)
+		header := fmt.Sprintf("%-36s %8s %10s %10s", strings.ToUpper(string(group)), "REQUESTS", "TOKENS", "COST")
+		lines = append(lines, t.S().Subtle.Render(header))
+		for i, row := range rows {
+			if i == maxRows {
+				lines = append(lines, t.S().Subtle.Render(fmt.Sprintf("…and %d more", len(rows)-i)))
+				break
+			}
+			lines = append(lines, u.row(u.groupLabel(group, row.Key), row))
+		}
+		lines = append(lines, t.S().Text.Bold(true).Render(u.row("Total", usage.Total(records))))
+	}
+
+	if len(u.budgets) > 0 {
+		lines = append(lines, "")
+		for _, b := range u.budgets {
+			if b.Exceeded() {
+				lines = append(lines, t.S().Warning.Render(styles.WarningIcon+" "+b.String()))
+			} else {
+				lines = append(lines, t.S().Muted.Render(b.String()))
+			}
+		}
+	}
+	lines = append(lines, "", u.help.View(u.keymap))
+
+	return baseStyle.
+		Width(defaultWidth).
+		Padding(0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+func (u *usageDialogCmp) row(label string, row usage.Row) string {
+	tokens := row.InputTokens + row.OutputTokens + row.CacheCreationTokens + row.CacheReadTokens
+	return fmt.Sprintf("%-36s %8d %10s %10s", truncate(label, 36), row.Requests, formatTokens(tokens), fmt.Sprintf("$%.2f", row.Cost))
+}
+
+// groupLabel returns the label of a group, the title for sessions.
+func (u *usageDialogCmp) groupLabel(group usage.GroupBy, groupKey string) string {
+	if group == usage.BySession {
+		if title, ok := u.titles[groupKey]; ok {
+			return title
+		}
+	}
+	if groupKey == "" {
+		return "-"
+	}
+	return groupKey
+}
+
+// choices renders the options of a setting with the selected one marked.
+func (u *usageDialogCmp) choices(selected string, options []string) string {
+	t := styles.CurrentTheme()
+	parts := make([]string, len(options))
+	for i, o := range options {
+		icon := "○"
+		if o == selected {
+			icon = "◉"
+		}
+		parts[i] = icon + " " + o
+	}
+	return t.S().Base.Foreground(t.FgHalfMuted).Render(strings.Join(parts, "  "))
+}
+
+func labels[T any](items []T, label func(T) string) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = label(item)
+	}
+	return out
+}
+
+func (u *usageDialogCmp) Position() (int, int) {
+	view := u.View()
+	row := (u.wHeight - lipgloss.Height(view)) / 2
+	col := (u.wWidth - lipgloss.Width(view)) / 2
+	return max(row, 0), max(col, 0)
+}
+
+func (u *usageDialogCmp) ID() dialogs.DialogID {
+	return UsageDialogID
+}
+
+// formatTokens formats a token count like 1.2M or 110K.
+func formatTokens(tokens int64) string {
+	var s string
+	switch {
+	case tokens >= 1_000_000:
+		s = fmt.Sprintf("%.1fM", float64(tokens)/1_000_000)
+	case tokens >= 1_000:
+		s = fmt.Sprintf("%.1fK", float64(tokens)/1_000)
+	default:
+		return fmt.Sprintf("%d", tokens)
+	}
+	return strings.Replace(s, ".0", "", 1)
+}
+
+func truncate(s string, width int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len([]rune(s)) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-1]) + "…"
+}
@@ -1,35 +1,44 @@
 package webshare
 
 import (
+	"fmt"
 	"os/exec"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/v2/key"
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/chasedut/toke/internal/config"
 	"github.com/chasedut/toke/internal/tui/components/dialogs"
+	"github.com/chasedut/toke/internal/tui/components/dialogs/tunnelsetup"
 	"github.com/chasedut/toke/internal/tui/styles"
 	"github.com/chasedut/toke/internal/tui/util"
 	"github.com/chasedut/toke/internal/webshare"
 )
 
 type ShareDialog struct {
-	width     int
-	height    int
-	localURL  string
-	ngrokURL  string
-	hasNgrok  bool
-	sessionID string
-	keymap    KeyMap
+	width  int
+	height int
+	share  *webshare.SessionShare
+	// selected is the index of the buddy to revoke.
+	selected int
+	keymap   KeyMap
 }
 
 type KeyMap struct {
-	Close     key.Binding
-	Enter     key.Binding
-	CopyLocal key.Binding
+	Close      key.Binding
+	Enter      key.Binding
+	CopyLocal  key.Binding
 	CopyPublic key.Binding
+	CopyViewer key.Binding
+	Rotate     key.Binding
+	Up         key.Binding
+	Down       key.Binding
+	Revoke     key.Binding
+	Tunnel     key.Binding
 }
 
 func DefaultKeyMap() KeyMap {
@@ -50,33 +59,49 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("p"),
 			key.WithHelp("p", "copy public URL"),
 		),
+		CopyViewer: key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", "copy read-only URL"),
+		),
+		Rotate: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "rotate links"),
+		),
+		Up: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑", "previous buddy"),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓", "next buddy"),
+		),
+		Revoke: key.NewBinding(
+			key.WithKeys("x"),
+			key.WithHelp("x", "revoke buddy"),
+		),
+		Tunnel: key.NewBinding(
+			key.WithKeys("t"),
+			key.WithHelp("t", "set up tunnel"),
+		),
 	}
 }
 
-func NewShareDialog(sessionID string, urls *webshare.ShareURLs) *ShareDialog {
-	hasNgrok := false
-	ngrokURL := ""
-	localURL := ""
-	
-	if urls != nil {
-		localURL = urls.LocalURL
-		if urls.NgrokURL != "" {
-			hasNgrok = true
-			ngrokURL = urls.NgrokURL
-		}
-	}
-	
+// NewShareDialog returns a dialog that shows the invite links of a share and
+// manages who has access to it.
+func NewShareDialog(share *webshare.SessionShare) *ShareDialog {
 	return &ShareDialog{
-		width:     60,
-		height:    20,
-		localURL:  localURL,
-		ngrokURL:  ngrokURL,
-		hasNgrok:  hasNgrok,
-		sessionID: sessionID,
-		keymap:    DefaultKeyMap(),
+		width:  60,
+		height: 20,
+		share:  share,
+		keymap: DefaultKeyMap(),
 	}
 }
 
+// links returns the participant links of the share.
+func (d *ShareDialog) links() (local, public string) {
+	return d.share.InviteURLs(webshare.RoleParticipant)
+}
+
 func (d *ShareDialog) Init() tea.Cmd {
 	return nil
 }
@@ -88,22 +113,50 @@ func (d *ShareDialog) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, d.keymap.Close):
 			return d, util.CmdHandler(dialogs.CloseDialogMsg{})
 		case key.Matches(msg, d.keymap.Enter):
-			if !d.hasNgrok {
-				// Open ngrok installation instructions
-				return d, d.openNgrokDocs()
-			} else {
-				// Open the local URL
-				return d, d.openLocalURL()
-			}
+			// Open the local URL
+			return d, d.openLocalURL()
+		case key.Matches(msg, d.keymap.Tunnel):
+			// The share restarts with the new tunnel and opens a new dialog
+			return d, tea.Sequence(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(dialogs.OpenDialogMsg{
+					Model: tunnelsetup.NewTunnelSetupDialog(nil, d.share.SessionID()),
+				}),
+			)
 		case key.Matches(msg, d.keymap.CopyLocal):
 			// Copy local URL to clipboard
-			return d, d.copyToClipboard(d.localURL)
+			local, _ := d.links()
+			return d, d.copyToClipboard(local)
 		case key.Matches(msg, d.keymap.CopyPublic):
 			// Copy public URL to clipboard
-			if d.hasNgrok && d.ngrokURL != "" {
-				return d, d.copyToClipboard(d.ngrokURL)
+			if _, public := d.links(); public != "" {
+				return d, d.copyToClipboard(public)
 			}
 			return d, nil
+		case key.Matches(msg, d.keymap.CopyViewer):
+			// Copy the read-only URL, public if possible
+			local, public := d.share.InviteURLs(webshare.RoleViewer)
+			if public == "" {
+				public = local
+			}
+			return d, d.copyToClipboard(public)
+		case key.Matches(msg, d.keymap.Rotate):
+			d.share.RotateLinks()
+			d.selected = 0
+			return d, util.ReportInfo("Share links rotated, everyone was disconnected")
+		case key.Matches(msg, d.keymap.Up):
+			d.selected = max(d.selected-1, 0)
+		case key.Matches(msg, d.keymap.Down):
+			d.selected = min(d.selected+1, max(len(d.buddies())-1, 0))
+		case key.Matches(msg, d.keymap.Revoke):
+			buddies := d.buddies()
+			if d.selected >= len(buddies) {
+				return d, nil
+			}
+			buddy := buddies[d.selected]
+			d.share.RevokeBuddy(buddy.ID)
+			d.selected = max(min(d.selected, len(buddies)-2), 0)
+			return d, util.ReportInfo(fmt.Sprintf("Revoked %s, rotate the links to keep them from rejoining", buddy.Name))
 		}
 	// TODO: Add mouse click handling for URLs
 	case tea.WindowSizeMsg:
@@ -122,41 +175,66 @@ func (d *ShareDialog) View() string {
 	
 	// Content
 	var content strings.Builder
+	localURL, publicURL := d.links()
+	hasPublic := publicURL != ""
 	
 	content.WriteString(t.S().Base.Bold(true).Render("Your session is being shared!"))
+	content.WriteString("\n")
+	content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render(
+		fmt.Sprintf("Links are secret and expire at %s.", d.share.ExpiresAt().Format("Jan 2 15:04"))))
 	content.WriteString("\n\n")
 	
 	// Local URL
 	content.WriteString(t.S().Base.Foreground(t.FgMuted).Render("Local URL:"))
 	content.WriteString("\n")
-	content.WriteString(t.S().Base.Foreground(t.Secondary).Underline(true).Render(d.localURL))
+	content.WriteString(t.S().Base.Foreground(t.Secondary).Underline(true).Render(localURL))
 	content.WriteString("\n")
 	content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Italic(true).Render("(⌘+click to open in browser)"))
 	content.WriteString("\n\n")
 	
-	// Ngrok URL or setup instructions
-	if d.hasNgrok {
-		content.WriteString(t.S().Base.Foreground(t.FgMuted).Render("Public URL (via ngrok):"))
-		content.WriteString("\n")
-		content.WriteString(t.S().Base.Foreground(t.Success).Bold(true).Underline(true).Render(d.ngrokURL))
-		content.WriteString("\n")
-		content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Italic(true).Render("(⌘+click to open, or share with anyone)"))
-	} else {
-		content.WriteString(t.S().Base.Foreground(t.Warning).Render("⚠ Ngrok not found"))
-		content.WriteString("\n\n")
-		content.WriteString(t.S().Base.Foreground(t.FgMuted).Render("To share publicly, install ngrok:"))
+	// Public URL, or why there is none yet
+	provider := d.share.Provider()
+	if hasPublic {
+		content.WriteString(t.S().Base.Foreground(t.FgMuted).Render(fmt.Sprintf("Public URL (via %s):", provider)))
 		content.WriteString("\n")
-		content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("1. Download from https://ngrok.com/download"))
+		content.WriteString(t.S().Base.Foreground(t.Success).Bold(true).Underline(true).Render(publicURL))
 		content.WriteString("\n")
-		content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("2. Or install via: brew install ngrok"))
-		content.WriteString("\n")
-		content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("3. Sign up for free account at ngrok.com"))
+		content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Italic(true).Render("(lets buddies read the session and chat, share only with people you trust)"))
+		if provider == config.TunnelLAN {
+			// Buddies in the room can scan it with their phone
+			if code, err := webshare.QRCode(publicURL); err == nil {
+				content.WriteString("\n\n")
+				content.WriteString(code)
+			}
+		}
+	} else if err := d.share.TunnelError(); err != nil {
+		content.WriteString(t.S().Base.Foreground(t.Warning).Render(fmt.Sprintf("⚠ The %s tunnel failed: %v", provider, err)))
 		content.WriteString("\n")
-		content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("4. Run: ngrok config add-authtoken <token>"))
-		content.WriteString("\n\n")
-		content.WriteString(t.S().Base.Foreground(t.FgMuted).Italic(true).Render("Press Enter to open ngrok docs"))
+		content.WriteString(t.S().Base.Foreground(t.FgMuted).Italic(true).Render("Press T to set up a tunnel"))
+	} else {
+		content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Italic(true).Render(fmt.Sprintf("Starting %s tunnel…", provider)))
 	}
+	content.WriteString("\n\n")
 	
+	// Buddies
+	content.WriteString(t.S().Base.Foreground(t.FgMuted).Render("Buddies:"))
+	content.WriteString("\n")
+	buddies := d.buddies()
+	if len(buddies) == 0 {
+		content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("Nobody joined yet"))
+	}
+	for i, buddy := range buddies {
+		line := fmt.Sprintf("  %s (joined %s)", buddy.Name, buddy.JoinedAt.Format("15:04"))
+		style := t.S().Base.Foreground(t.FgHalfMuted)
+		if i == d.selected {
+			line = "> " + line[2:]
+			style = t.S().Base.Foreground(t.Secondary)
+		}
+		if i > 0 {
+			content.WriteString("\n")
+		}
+		content.WriteString(style.Render(line))
+	}
 	content.WriteString("\n\n")
 	
 	// Instructions
@@ -167,11 +245,19 @@ func (d *ShareDialog) View() string {
 	content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("• Enter: Open local URL"))
 	content.WriteString("\n")
 	content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("• C/L: Copy local URL to clipboard"))
-	if d.hasNgrok {
+	if hasPublic {
 		content.WriteString("\n")
 		content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("• P: Copy public URL to clipboard"))
 	}
 	content.WriteString("\n")
+	content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("• V: Copy read-only URL to clipboard"))
+	content.WriteString("\n")
+	content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("• ↑/↓, X: Revoke a buddy"))
+	content.WriteString("\n")
+	content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("• R: Rotate links and disconnect everyone"))
+	content.WriteString("\n")
+	content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("• T: Set up the tunnel"))
+	content.WriteString("\n")
 	content.WriteString(t.S().Base.Foreground(t.FgHalfMuted).Render("• ESC: Close dialog (sharing continues)"))
 	
 	// Render in a box
@@ -190,28 +276,10 @@ func (d *ShareDialog) View() string {
 	)
 }
 
-func (d *ShareDialog) openNgrokDocs() tea.Cmd {
-	return func() tea.Msg {
-		// Try to open the ngrok download page
-		url := "https://ngrok.com/download"
-		
-		switch runtime.GOOS {
-		case "darwin":
-			exec.Command("open", url).Run()
-		case "linux":
-			exec.Command("xdg-open", url).Run()
-		case "windows":
-			exec.Command("cmd", "/c", "start", url).Run()
-		}
-		
-		return dialogs.CloseDialogMsg{}
-	}
-}
-
 func (d *ShareDialog) openLocalURL() tea.Cmd {
 	return func() tea.Msg {
 		// Try to open the local URL
-		url := d.localURL
+		url, _ := d.links()
 		if url == "" {
 			return nil
 		}
@@ -269,6 +337,15 @@ func (d *ShareDialog) copyToClipboard(text string) tea.Cmd {
 	}
 }
 
+// buddies returns the buddies of the share, oldest first.
+func (d *ShareDialog) buddies() []*webshare.Buddy {
+	buddies := d.share.GetBuddies()
+	slices.SortFunc(buddies, func(a, b *webshare.Buddy) int {
+		return a.JoinedAt.Compare(b.JoinedAt)
+	})
+	return buddies
+}
+
 func (d *ShareDialog) SetSize(width, height int) {
 	d.width = width
 	d.height = height
@@ -0,0 +1,152 @@
+package worktree
+
+import (
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/chasedut/toke/internal/app"
+	"github.com/chasedut/toke/internal/fsext"
+	"github.com/chasedut/toke/internal/tui/components/dialogs"
+	"github.com/chasedut/toke/internal/tui/styles"
+	"github.com/chasedut/toke/internal/tui/util"
+	"github.com/chasedut/toke/internal/worktree"
+)
+
+const WorktreeDialogID dialogs.DialogID = "worktree"
+
+// ActionMsg is sent when the user picks what to do with a session's
+// worktree.
+type ActionMsg struct {
+	SessionID string
+	Action    app.WorktreeAction
+}
+
+// FinishedMsg is sent once a session's worktree has been removed.
+type FinishedMsg struct {
+	SessionID string
+	Action    app.WorktreeAction
+	// URL is the pull request opened for the worktree, if any.
+	URL string
+}
+
+type option struct {
+	label  string
+	action app.WorktreeAction
+}
+
+var options = []option{
+	{label: "Merge Back", action: app.WorktreeMerge},
+	{label: "Open PR", action: app.WorktreePR},
+	{label: "Discard", action: app.WorktreeDiscard},
+	{label: "Keep"},
+}
+
+// WorktreeDialog asks the user what to do with a session's worktree.
+type WorktreeDialog interface {
+	dialogs.DialogModel
+}
+
+type worktreeDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	worktree       worktree.Worktree
+	changed        bool
+	selectedOption int
+	keymap         KeyMap
+}
+
+// NewWorktreeDialog creates a dialog to finish a session's worktree. changed
+// tells whether the worktree holds any work that isn't in the project yet.
+func NewWorktreeDialog(wt worktree.Worktree, changed bool) WorktreeDialog {
+	return &worktreeDialogCmp{
+		worktree: wt,
+		changed:  changed,
+		keymap:   DefaultKeymap(),
+	}
+}
+
+func (w *worktreeDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles keyboard input for the worktree dialog.
+func (w *worktreeDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		w.wWidth = msg.Width
+		w.wHeight = msg.Height
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, w.keymap.LeftRight, w.keymap.Tab):
+			w.selectedOption = (w.selectedOption + 1) % len(options)
+			return w, nil
+		case key.Matches(msg, w.keymap.EnterSpace):
+			action := options[w.selectedOption].action
+			if action == "" {
+				return w, util.CmdHandler(dialogs.CloseDialogMsg{})
+			}
+			return w, tea.Sequence(
+				util.CmdHandler(dialogs.CloseDialogMsg{}),
+				util.CmdHandler(ActionMsg{SessionID: w.worktree.SessionID, Action: action}),
+			)
+		case key.Matches(msg, w.keymap.Close):
+			return w, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return w, nil
+}
+
+// View renders the worktree dialog.
+func (w *worktreeDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	status := "No changes yet."
+	if w.changed {
+		status = "The session has changes that are not in your branch."
+	}
+	lines := []string{
+		t.S().Title.Render("Finish worktree session?"),
+		"",
+		t.S().Muted.Render(w.worktree.Branch),
+		t.S().Subtle.Render(fsext.PrettyPath(w.worktree.Dir)),
+		"",
+		status,
+	}
+
+	buttonStyle := t.S().Text
+	buttons := make([]string, 0, len(options)*2)
+	for i, o := range options {
+		style := buttonStyle.Background(t.BgSubtle)
+		if i == w.selectedOption {
+			style = style.Foreground(t.White).Background(t.Secondary)
+		}
+		if i > 0 {
+			buttons = append(buttons, " ")
+		}
+		buttons = append(buttons, style.Padding(0, 2).Render(o.label))
+	}
+	lines = append(lines, "", baseStyle.Align(lipgloss.Center).Render(
+		lipgloss.JoinHorizontal(lipgloss.Center, buttons...),
+	))
+
+	content := baseStyle.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	return baseStyle.
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(content)
+}
+
+func (w *worktreeDialogCmp) Position() (int, int) {
+	view := w.View()
+	row := (w.wHeight - lipgloss.Height(view)) / 2
+	col := (w.wWidth - lipgloss.Width(view)) / 2
+	return max(row, 0), max(col, 0)
+}
+
+func (w *worktreeDialogCmp) ID() dialogs.DialogID {
+	return WorktreeDialogID
+}
@@ -2,6 +2,7 @@ package chat
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/charmbracelet/bubbles/v2/help"
@@ -16,6 +17,7 @@ import (
 	"github.com/chasedut/toke/internal/permission"
 	"github.com/chasedut/toke/internal/pubsub"
 	"github.com/chasedut/toke/internal/session"
+	"github.com/chasedut/toke/internal/shell"
 	"github.com/chasedut/toke/internal/tui/components/anim"
 	"github.com/chasedut/toke/internal/tui/components/chat"
 	"github.com/chasedut/toke/internal/tui/components/chat/buddychat"
@@ -27,11 +29,13 @@ import (
 	"github.com/chasedut/toke/internal/tui/components/completions"
 	"github.com/chasedut/toke/internal/tui/components/core"
 	"github.com/chasedut/toke/internal/tui/components/core/layout"
+	"github.com/chasedut/toke/internal/tui/components/dialogs"
 	"github.com/chasedut/toke/internal/tui/components/dialogs/commands"
 	"github.com/chasedut/toke/internal/tui/components/dialogs/filepicker"
 	githubDialog "github.com/chasedut/toke/internal/tui/components/dialogs/github"
 	jiraDialog "github.com/chasedut/toke/internal/tui/components/dialogs/jira"
 	"github.com/chasedut/toke/internal/tui/components/dialogs/models"
+	worktreeDialog "github.com/chasedut/toke/internal/tui/components/dialogs/worktree"
 	"github.com/chasedut/toke/internal/tui/page"
 	"github.com/chasedut/toke/internal/tui/styles"
 	"github.com/chasedut/toke/internal/tui/util"
@@ -102,6 +106,13 @@ type chatPage struct {
 	session session.Session
 	keyMap  KeyMap
 
+	// nextAgentID is the agent picked before the session exists. It is
+	// applied when the first message creates the session.
+	nextAgentID string
+	// nextWorktree is set when the next session should get its own git
+	// worktree.
+	nextWorktree bool
+
 	// Components
 	header    header.Header
 	sidebar   sidebar.Sidebar
@@ -236,6 +247,11 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return p, p.sendMessage(msg.Text, msg.Attachments)
 	case chat.SessionSelectedMsg:
 		return p, p.setSession(msg)
+	case chat.MessageSelectedMsg:
+		cmd := p.setSession(msg.Session)
+		// The chat scrolls to its selected item only while focused
+		p.focusedPane = PanelTypeChat
+		return p, tea.Sequence(cmd, p.editor.Blur(), p.chat.Focus(), p.chat.SelectMessage(msg.MessageID))
 	case splash.SubmitAPIKeyMsg:
 		u, cmd := p.splash.Update(msg)
 		p.splash = u.(splash.Splash)
@@ -254,6 +270,31 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return p, tea.Batch(p.SetSize(p.width, p.height), cmd)
 	case commands.ToggleThinkingMsg:
 		return p, p.toggleThinking()
+	case commands.SwitchAgentMsg:
+		return p, p.switchAgent(msg.AgentID)
+	case commands.NewWorktreeSessionMsg:
+		if p.app.IsBusy() {
+			return p, util.ReportWarn("Agent is busy, please wait before starting a new session...")
+		}
+		cmd := p.newSession()
+		p.nextWorktree = true
+		p.header.SetWorktree("new worktree")
+		return p, tea.Batch(cmd, util.ReportInfo("The next session will run in its own git worktree"))
+	case commands.FinishWorktreeMsg:
+		return p, p.finishWorktree(msg.SessionID)
+	case worktreeDialog.ActionMsg:
+		return p, p.applyWorktreeAction(msg)
+	case worktreeDialog.FinishedMsg:
+		if msg.SessionID == p.session.ID {
+			p.header.SetWorktree("")
+		}
+		switch msg.Action {
+		case app.WorktreeMerge:
+			return p, util.ReportInfo("Worktree merged back")
+		case app.WorktreePR:
+			return p, util.ReportInfo("Opened " + msg.URL)
+		}
+		return p, util.ReportInfo("Worktree discarded")
 	case commands.OpenExternalEditorMsg:
 		u, cmd := p.editor.Update(msg)
 		p.editor = u.(editor.Editor)
@@ -305,7 +346,7 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	case commands.WebShareStartedMsg:
 		// Update sidebar with web share URLs
-		p.sidebar.SetWebShareURLs(msg.LocalURL, msg.NgrokURL)
+		p.sidebar.SetWebShareURLs(msg.LocalURL, msg.PublicURL)
 		// Pass webShare to buddy chat if available
 		if msg.WebShare != nil && p.buddyChat != nil {
 			if share, ok := msg.WebShare.(*webshare.SessionShare); ok {
@@ -396,7 +437,7 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		u, cmd := p.editor.Update(msg)
 		p.editor = u.(editor.Editor)
 		return p, cmd
-	case pubsub.Event[history.File], sidebar.SessionFilesMsg:
+	case pubsub.Event[history.File], pubsub.Event[shell.JobInfo], sidebar.SessionFilesMsg:
 		u, cmd := p.sidebar.Update(msg)
 		p.sidebar = u.(sidebar.Sidebar)
 		cmds = append(cmds, cmd)
@@ -408,7 +449,7 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return p, tea.Batch(cmds...)
 
 	case commands.CommandRunCustomMsg:
-		if p.app.CoderAgent.IsBusy() {
+		if p.app.IsBusy() {
 			return p, util.ReportWarn("Agent is busy, please wait before executing a command...")
 		}
 
@@ -432,7 +473,7 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		p.focusedPane = PanelTypeEditor
 		return p, p.SetSize(p.width, p.height)
 	case commands.NewSessionsMsg:
-		if p.app.CoderAgent.IsBusy() {
+		if p.app.IsBusy() {
 			return p, util.ReportWarn("Agent is busy, please wait before starting a new session...")
 		}
 		return p, p.newSession()
@@ -464,12 +505,12 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if p.app.CoderAgent == nil {
 				return p, nil
 			}
-			if p.app.CoderAgent.IsBusy() {
+			if p.app.IsBusy() {
 				return p, util.ReportWarn("Agent is busy, please wait before starting a new session...")
 			}
 			return p, p.newSession()
 		case key.Matches(msg, p.keyMap.AddAttachment):
-			agentCfg := config.Get().Agents["coder"]
+			agentCfg := config.Get().Agents[p.app.SessionAgentID(p.session.ID)]
 			model := config.Get().GetModelByType(agentCfg.Model)
 			if model.SupportsImages {
 				return p, util.CmdHandler(commands.OpenFilePickerMsg{})
@@ -485,7 +526,7 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			p.changeFocus()
 			return p, nil
 		case key.Matches(msg, p.keyMap.Cancel):
-			if p.session.ID != "" && p.app.CoderAgent.IsBusy() {
+			if p.session.ID != "" && p.app.IsBusy() {
 				return p, p.cancel()
 			}
 		case key.Matches(msg, p.keyMap.Details):
@@ -783,7 +824,17 @@ func (p *chatPage) newSession() tea.Cmd {
 		return nil
 	}
 
+	// Offer to finish the worktree of the session being left.
+	var finish tea.Cmd
+	if _, ok := p.app.SessionWorktree(p.session.ID); ok {
+		finish = p.finishWorktree(p.session.ID)
+	}
+
 	p.session = session.Session{}
+	p.nextAgentID = ""
+	p.nextWorktree = false
+	p.header.SetAgent(config.AgentCoder)
+	p.header.SetWorktree("")
 	p.focusedPane = PanelTypeEditor
 	p.editor.Focus()
 	p.chat.Blur()
@@ -791,6 +842,7 @@ func (p *chatPage) newSession() tea.Cmd {
 	return tea.Batch(
 		util.CmdHandler(chat.SessionClearedMsg{}),
 		p.SetSize(p.width, p.height),
+		finish,
 	)
 }
 
@@ -801,6 +853,9 @@ func (p *chatPage) setSession(session session.Session) tea.Cmd {
 
 	var cmds []tea.Cmd
 	p.session = session
+	p.header.SetAgent(p.app.SessionAgentID(session.ID))
+	wt, _ := p.app.SessionWorktree(session.ID)
+	p.header.SetWorktree(wt.Branch)
 
 	cmds = append(cmds, p.SetSize(p.width, p.height))
 	cmds = append(cmds, p.chat.SetSession(session))
@@ -811,6 +866,52 @@ func (p *chatPage) setSession(session session.Session) tea.Cmd {
 	return tea.Sequence(cmds...)
 }
 
+// switchAgent selects the agent for the current session, or for the next
+// one if no session has been started yet.
+func (p *chatPage) switchAgent(agentID string) tea.Cmd {
+	if p.app.IsBusy() {
+		return util.ReportWarn("Agent is busy, please wait before switching agents...")
+	}
+	agentCfg, ok := config.Get().Agents[agentID]
+	if !ok {
+		return util.ReportError(fmt.Errorf("unknown agent %q", agentID))
+	}
+	if p.session.ID == "" {
+		p.nextAgentID = agentID
+	} else if err := p.app.SetSessionAgent(p.session.ID, agentID); err != nil {
+		return util.ReportError(err)
+	}
+	p.header.SetAgent(agentID)
+	return util.ReportInfo(fmt.Sprintf("Switched to the %s agent", agentCfg.Name))
+}
+
+// finishWorktree asks what to do with the worktree of a session.
+func (p *chatPage) finishWorktree(sessionID string) tea.Cmd {
+	wt, ok := p.app.SessionWorktree(sessionID)
+	if !ok {
+		return util.ReportWarn("This session doesn't have a worktree")
+	}
+	changed, err := p.app.Worktrees.Changed(context.Background(), wt)
+	if err != nil {
+		return util.ReportError(err)
+	}
+	return util.CmdHandler(dialogs.OpenDialogMsg{
+		Model: worktreeDialog.NewWorktreeDialog(wt, changed),
+	})
+}
+
+// applyWorktreeAction merges, publishes or discards a session's worktree in
+// the background, as pushing and opening a PR can take a while.
+func (p *chatPage) applyWorktreeAction(msg worktreeDialog.ActionMsg) tea.Cmd {
+	return func() tea.Msg {
+		url, err := p.app.FinishWorktree(context.Background(), msg.SessionID, msg.Action)
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		return worktreeDialog.FinishedMsg{SessionID: msg.SessionID, Action: msg.Action, URL: url}
+	}
+}
+
 func (p *chatPage) changeFocus() {
 	if p.session.ID == "" {
 		return
@@ -830,7 +931,7 @@ func (p *chatPage) changeFocus() {
 func (p *chatPage) cancel() tea.Cmd {
 	if p.isCanceling {
 		p.isCanceling = false
-		p.app.CoderAgent.Cancel(p.session.ID)
+		p.app.AgentForSession(p.session.ID).Cancel(p.session.ID)
 		return nil
 	}
 
@@ -862,9 +963,26 @@ func (p *chatPage) sendMessage(text string, attachments []message.Attachment) te
 			return util.ReportError(err)
 		}
 		session = newSession
+		if p.nextAgentID != "" {
+			if err := p.app.SetSessionAgent(session.ID, p.nextAgentID); err != nil {
+				return util.ReportError(err)
+			}
+			p.nextAgentID = ""
+		}
+		if p.nextWorktree {
+			p.nextWorktree = false
+			if _, err := p.app.CreateWorktree(context.Background(), session.ID); err != nil {
+				// Don't let the agent loose on the project directory when
+				// the user asked for isolation.
+				return tea.Batch(
+					util.CmdHandler(chat.SessionSelectedMsg(session)),
+					util.ReportError(err),
+				)
+			}
+		}
 		cmds = append(cmds, util.CmdHandler(chat.SessionSelectedMsg(session)))
 	}
-	_, err := p.app.CoderAgent.Run(context.Background(), session.ID, text, attachments...)
+	_, err := p.app.AgentForSession(session.ID).Run(context.Background(), session.ID, text, attachments...)
 	if err != nil {
 		return util.ReportError(err)
 	}
@@ -887,7 +1005,7 @@ func (p *chatPage) Bindings() []key.Binding {
 		p.keyMap.NewSession,
 		p.keyMap.AddAttachment,
 	}
-	if p.app.CoderAgent != nil && p.app.CoderAgent.IsBusy() {
+	if p.app.IsBusy() {
 		cancelBinding := p.keyMap.Cancel
 		if p.isCanceling {
 			cancelBinding = key.NewBinding(
@@ -1008,7 +1126,7 @@ func (p *chatPage) Help() help.KeyMap {
 			}
 			return core.NewSimpleHelp(shortList, fullList)
 		}
-		if p.app.CoderAgent != nil && p.app.CoderAgent.IsBusy() {
+		if p.app.IsBusy() {
 			cancelBinding := key.NewBinding(
 				key.WithKeys("esc"),
 				key.WithHelp("esc", "cancel"),
@@ -1078,6 +1196,8 @@ func (p *chatPage) Help() help.KeyMap {
 					key.WithHelp("↑↓", "scroll"),
 				),
 				messages.CopyKey,
+				messages.RewindKey,
+				messages.ForkKey,
 			)
 			fullList = append(fullList,
 				[]key.Binding{
@@ -1118,6 +1238,8 @@ func (p *chatPage) Help() help.KeyMap {
 				},
 				[]key.Binding{
 					messages.CopyKey,
+					messages.RewindKey,
+					messages.ForkKey,
 					messages.ClearSelectionKey,
 				},
 			)
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -14,11 +15,15 @@ import (
 	"github.com/chasedut/toke/internal/app"
 	"github.com/chasedut/toke/internal/backend"
 	"github.com/chasedut/toke/internal/config"
+	"github.com/chasedut/toke/internal/limits"
 	"github.com/chasedut/toke/internal/llm/agent"
 	"github.com/chasedut/toke/internal/permission"
 	"github.com/chasedut/toke/internal/pubsub"
 	"github.com/chasedut/toke/internal/shell"
+	"github.com/chasedut/toke/internal/transcript"
+	"github.com/chasedut/toke/internal/usage"
 	cmpChat "github.com/chasedut/toke/internal/tui/components/chat"
+	"github.com/chasedut/toke/internal/tui/components/chat/messages"
 	"github.com/chasedut/toke/internal/tui/components/chat/splash"
 	"github.com/chasedut/toke/internal/tui/components/completions"
 	"github.com/chasedut/toke/internal/tui/components/core"
@@ -30,12 +35,18 @@ import (
 	"github.com/chasedut/toke/internal/tui/components/dialogs/commands"
 	"github.com/chasedut/toke/internal/tui/components/dialogs/compact"
 	"github.com/chasedut/toke/internal/tui/components/dialogs/filepicker"
+	limitDialog "github.com/chasedut/toke/internal/tui/components/dialogs/limits"
+	"github.com/chasedut/toke/internal/tui/components/dialogs/downloads"
+	"github.com/chasedut/toke/internal/tui/components/dialogs/localmodels"
 	"github.com/chasedut/toke/internal/tui/components/dialogs/models"
 	"github.com/chasedut/toke/internal/tui/components/dialogs/permissions"
+	"github.com/chasedut/toke/internal/tui/components/dialogs/proposals"
 	"github.com/chasedut/toke/internal/tui/components/dialogs/quit"
+	"github.com/chasedut/toke/internal/tui/components/dialogs/rewind"
 	"github.com/chasedut/toke/internal/tui/components/dialogs/sessions"
+	"github.com/chasedut/toke/internal/tui/components/dialogs/tunnelsetup"
 	shellDlg "github.com/chasedut/toke/internal/tui/components/dialogs/shell"
-	"github.com/chasedut/toke/internal/tui/components/dialogs/ngrokauth"
+	usageDialog "github.com/chasedut/toke/internal/tui/components/dialogs/usage"
 	webshareDialog "github.com/chasedut/toke/internal/tui/components/dialogs/webshare"
 	jiraDialog "github.com/chasedut/toke/internal/tui/components/dialogs/jira"
 	githubDialog "github.com/chasedut/toke/internal/tui/components/dialogs/github"
@@ -286,6 +297,8 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// Session
 	case cmpChat.SessionSelectedMsg:
 		a.selectedSessionID = msg.ID
+	case cmpChat.MessageSelectedMsg:
+		a.selectedSessionID = msg.Session.ID
 	case cmpChat.SessionClearedMsg:
 		a.selectedSessionID = ""
 	
@@ -303,7 +316,7 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, func() tea.Msg {
 			allSessions, _ := a.app.Sessions.List(context.Background())
 			return dialogs.OpenDialogMsg{
-				Model: sessions.NewSessionDialogCmp(allSessions, a.selectedSessionID),
+				Model: sessions.NewSessionDialogCmp(allSessions, a.selectedSessionID, a.app.Messages),
 			}
 		}
 
@@ -388,9 +401,11 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		
 		return a, tea.Batch(cmds...)
 	// Compact
+	case commands.ExportSessionMsg:
+		return a, a.exportSession(msg.SessionID, msg.Format)
 	case commands.CompactMsg:
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
-			Model: compact.NewCompactDialogCmp(a.app.CoderAgent, msg.SessionID, true),
+			Model: compact.NewCompactDialogCmp(a.app.AgentForSession(msg.SessionID), msg.SessionID, true),
 		})
 	case commands.InviteBuddyMsg:
 		// Start the web share server
@@ -406,18 +421,116 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if a.webShare != nil {
 			// Show existing share dialog
 			return a, util.CmdHandler(dialogs.OpenDialogMsg{
-				Model: webshareDialog.NewShareDialog(msg.SessionID, a.webShare.GetURLs()),
+				Model: webshareDialog.NewShareDialog(a.webShare),
 			})
 		}
 		return a, a.startWebShare(msg.SessionID)
+	case commands.BuddyProposalsMsg:
+		if a.webShare == nil {
+			return a, util.ReportWarn("Invite a buddy first, they propose prompts from the shared page")
+		}
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: proposals.NewProposalsDialog(a.webShare),
+		})
+	case proposals.AcceptedMsg:
+		return a, a.runProposal(msg)
+	case proposalMsg:
+		return a, tea.Batch(a.handleProposal(msg.event), waitForProposal(msg.events))
 	case commands.QuitMsg:
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: quit.NewQuitDialog(),
 		})
+	// Usage
+	case commands.ShowUsageMsg:
+		ctx := context.Background()
+		records, err := a.app.Usage.List(ctx, time.Time{})
+		if err != nil {
+			return a, util.ReportError(fmt.Errorf("failed to read usage: %w", err))
+		}
+		budgets, err := a.app.Usage.Budgets(ctx)
+		if err != nil {
+			return a, util.ReportError(err)
+		}
+		titles := make(map[string]string)
+		if list, err := a.app.Sessions.List(ctx); err == nil {
+			for _, s := range list {
+				titles[s.ID] = s.Title
+			}
+		}
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: usageDialog.NewUsageDialog(records, budgets, titles),
+		})
+	case commands.ShowLocalModelsMsg:
+		pool := a.app.LocalModels()
+		if pool == nil {
+			return a, util.ReportInfo("No local model configured")
+		}
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: localmodels.NewLocalModelsDialog(pool),
+		})
+	case commands.ShowDownloadsMsg:
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: downloads.NewDownloadsDialog(backend.Downloads),
+		})
+	case pubsub.Event[usage.Budget]:
+		return a, util.ReportWarn(msg.Payload.String())
+	// Limits
+	case pubsub.Event[limits.Request]:
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: limitDialog.NewLimitDialog(msg.Payload),
+		})
+	case limitDialog.LimitResponseMsg:
+		if msg.Continue {
+			a.app.Limits.Continue(msg.Request.ID)
+			return a, nil
+		}
+		a.app.Limits.Stop(msg.Request.ID)
+		return a, util.ReportWarn("Stopped at the " + msg.Request.String())
+	// Rewind
+	case messages.RewindMsg:
+		if a.app.IsBusy() {
+			return a, util.ReportWarn("Agent is busy, please wait before rewinding...")
+		}
+		plan, err := a.app.Checkpoints.Plan(context.Background(), msg.SessionID, msg.MessageID)
+		if err != nil {
+			return a, util.ReportError(err)
+		}
+		return a, util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: rewind.NewRewindDialog(plan),
+		})
+	// Fork
+	case messages.ForkMsg:
+		if a.app.IsBusy() {
+			return a, util.ReportWarn("Agent is busy, please wait before forking...")
+		}
+		sessionID := msg.SessionID
+		if sessionID == "" {
+			sessionID = a.selectedSessionID
+		}
+		forked, err := a.app.Forks.Fork(context.Background(), sessionID, msg.MessageID)
+		if err != nil {
+			return a, util.ReportError(fmt.Errorf("failed to fork session: %w", err))
+		}
+		if agentID := a.app.SessionAgentID(sessionID); agentID != config.AgentCoder {
+			_ = a.app.SetSessionAgent(forked.ID, agentID)
+		}
+		return a, tea.Sequence(
+			util.CmdHandler(cmpChat.SessionSelectedMsg(forked)),
+			util.ReportInfo(fmt.Sprintf("Forked into %q with %d message(s)", forked.Title, forked.MessageCount)),
+		)
+	case rewind.ConfirmedMsg:
+		if err := a.app.Checkpoints.Rewind(context.Background(), msg.Plan, msg.Options); err != nil {
+			return a, util.ReportError(fmt.Errorf("failed to rewind: %w", err))
+		}
+		info := fmt.Sprintf("Rewound %d file(s)", len(msg.Plan.Changes))
+		if msg.Options.Conversation {
+			info += fmt.Sprintf(" and removed %d message(s)", len(msg.Plan.Messages))
+		}
+		return a, util.ReportInfo(info)
 	case commands.ToggleYoloModeMsg:
 		a.app.Permissions.SetSkipRequests(!a.app.Permissions.SkipRequests())
-	case ngrokauth.NgrokAuthSuccessMsg:
-		// Retry web share after successful auth
+	case tunnelsetup.SetupDoneMsg:
+		// Retry web share with the new tunnel
 		return a, a.startWebShare(msg.SessionID)
 	case commands.ToggleHelpMsg:
 		a.status.ToggleFullHelp()
@@ -425,7 +538,7 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, a.handleWindowResize(a.wWidth, a.wHeight)
 	// Model Switch
 	case models.ModelSelectedMsg:
-		if a.app.CoderAgent.IsBusy() {
+		if a.app.IsBusy() {
 			return a, util.ReportWarn("Agent is busy, please wait...")
 		}
 		config.Get().UpdatePreferredModel(msg.ModelType, msg.Model)
@@ -498,11 +611,22 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		})
 	// Permissions
 	case pubsub.Event[permission.PermissionNotification]:
+		if a.webShare != nil && (msg.Payload.Granted || msg.Payload.Denied) {
+			a.webShare.ResolveApproval(msg.Payload.ToolCallID)
+		}
 		// forward to page
 		updated, cmd := a.pages[a.currentPage].Update(msg)
 		a.pages[a.currentPage] = updated.(util.Model)
 		return a, cmd
 	case pubsub.Event[permission.PermissionRequest]:
+		if a.webShare != nil && msg.Payload.SessionID == a.webShare.SessionID() {
+			// Let the buddies vote on it
+			a.webShare.RequestApproval(webshare.Approval{
+				ToolCallID:  msg.Payload.ToolCallID,
+				ToolName:    msg.Payload.ToolName,
+				Description: msg.Payload.Description,
+			})
+		}
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: permissions.NewPermissionDialogCmp(msg.Payload),
 		})
@@ -512,6 +636,15 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.app.Permissions.Grant(msg.Permission)
 		case permissions.PermissionAllowForSession:
 			a.app.Permissions.GrantPersistent(msg.Permission)
+		case permissions.PermissionAllowAndSaveRule:
+			a.app.Permissions.Grant(msg.Permission)
+			cfg := config.Get()
+			rule := permission.SuggestRule(msg.Permission, cfg.WorkingDir())
+			a.app.Permissions.AddRule(rule)
+			if err := cfg.AddPermissionRule(rule); err != nil {
+				return a, util.ReportError(fmt.Errorf("failed to save permission rule: %w", err))
+			}
+			return a, util.ReportInfo("Saved rule: " + rule.String())
 		case permissions.PermissionDeny:
 			a.app.Permissions.Deny(msg.Permission)
 		}
@@ -532,12 +665,12 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Get current session to check token usage
 			session, err := a.app.Sessions.Get(context.Background(), a.selectedSessionID)
 			if err == nil {
-				model := a.app.CoderAgent.Model()
+				model := a.app.AgentForSession(a.selectedSessionID).Model()
 				contextWindow := model.ContextWindow
 				tokens := session.CompletionTokens + session.PromptTokens
 				if (tokens >= int64(float64(contextWindow)*0.95)) && !config.Get().Options.DisableAutoSummarize { // Show compact confirmation dialog
 					cmds = append(cmds, util.CmdHandler(dialogs.OpenDialogMsg{
-						Model: compact.NewCompactDialogCmp(a.app.CoderAgent, a.selectedSessionID, false),
+						Model: compact.NewCompactDialogCmp(a.app.AgentForSession(a.selectedSessionID), a.selectedSessionID, false),
 					}))
 				}
 			}
@@ -750,13 +883,13 @@ func (a *appModel) handleKeyPressMsg(msg tea.KeyPressMsg) tea.Cmd {
 			func() tea.Msg {
 				allSessions, _ := a.app.Sessions.List(context.Background())
 				return dialogs.OpenDialogMsg{
-					Model: sessions.NewSessionDialogCmp(allSessions, a.selectedSessionID),
+					Model: sessions.NewSessionDialogCmp(allSessions, a.selectedSessionID, a.app.Messages),
 				}
 			},
 		)
 		return tea.Sequence(cmds...)
 	case key.Matches(msg, a.keyMap.Suspend):
-		if a.app.CoderAgent != nil && a.app.CoderAgent.IsBusy() {
+		if a.app.IsBusy() {
 			return util.ReportWarn("Agent is busy, please wait...")
 		}
 		return tea.Suspend
@@ -793,7 +926,7 @@ func (a *appModel) handleKeyPressMsg(msg tea.KeyPressMsg) tea.Cmd {
 
 // moveToPage handles navigation between different pages in the application.
 func (a *appModel) moveToPage(pageID page.PageID) tea.Cmd {
-	if a.app.CoderAgent.IsBusy() {
+	if a.app.IsBusy() {
 		// TODO: maybe remove this :  For now we don't move to any page if the agent is busy
 		return util.ReportWarn("Agent is busy, please wait...")
 	}
@@ -1036,24 +1169,6 @@ func (a *appModel) startInitialization() tea.Cmd {
 }
 
 
-// findNgrokPath attempts to find ngrok in common locations
-func findNgrokPath() string {
-	// Check common locations
-	paths := []string{
-		"./ngrok",
-		"./build/ngrok",
-		"ngrok", // in PATH
-	}
-	
-	for _, path := range paths {
-		if _, err := exec.LookPath(path); err == nil {
-			return path
-		}
-	}
-	
-	return ""
-}
-
 // startWebShare starts the web sharing server for the session
 func (a *appModel) startWebShare(sessionID string) tea.Cmd {
 	return func() tea.Msg {
@@ -1074,28 +1189,25 @@ func (a *appModel) startWebShare(sessionID string) tea.Cmd {
 		
 		// Create new web share
 		fmt.Fprintf(os.Stderr, "[DEBUG] startWebShare: Creating new SessionShare\n")
-		a.webShare = webshare.NewSessionShare(sessionID)
+		expiry := webshare.DefaultExpiry
+		if opts := config.Get().Options.WebShare; opts != nil && opts.ExpiryHours > 0 {
+			expiry = time.Duration(opts.ExpiryHours) * time.Hour
+		}
+		a.webShare = webshare.NewSessionShare(sessionID, expiry, webshare.NewTunnel(config.Get().Options.WebShare), a.app.Messages)
 		
 		fmt.Fprintf(os.Stderr, "[DEBUG] startWebShare: Calling webShare.Start()\n")
 		urls, err := a.webShare.Start()
 		fmt.Fprintf(os.Stderr, "[DEBUG] startWebShare: webShare.Start() returned, err=%v\n", err)
 		
 		if err != nil {
-			// Check if the error is about ngrok authentication
-			if strings.Contains(err.Error(), "ngrok authentication required") {
-				// Find ngrok path to pass to auth dialog
-				ngrokPath := a.webShare.GetNgrokPath()
-				if ngrokPath == "" {
-					// Try to find it ourselves
-					ngrokPath = findNgrokPath()
-				}
-				
-				// Show ngrok auth dialog
+			// Let the host set the tunnel up if it isn't
+			var setupErr *webshare.SetupError
+			if errors.As(err, &setupErr) {
 				return dialogs.OpenDialogMsg{
-					Model: ngrokauth.NewNgrokAuthDialog(ngrokPath, sessionID),
+					Model: tunnelsetup.NewTunnelSetupDialog(setupErr, sessionID),
 				}
 			}
-			
+
 			return util.InfoMsg{
 				Type: util.InfoTypeError,
 				Msg:  fmt.Sprintf("Failed to start web share: %v", err),
@@ -1103,19 +1215,12 @@ func (a *appModel) startWebShare(sessionID string) tea.Cmd {
 			}
 		}
 		
-		// Start a goroutine to periodically broadcast updates
-		// Use the webShare's context to properly stop the goroutine
-		fmt.Fprintf(os.Stderr, "[DEBUG] startWebShare: Starting broadcast loop\n")
-		if a.webShare != nil {
-			go a.webShare.StartBroadcastLoop()
-		}
-		
 		// Start SSE client in a goroutine (non-blocking)
 		if urls != nil && urls.LocalURL != "" {
 			fmt.Fprintf(os.Stderr, "[DEBUG] startWebShare: Starting SSE client for %s\n", urls.LocalURL)
 			// Note: We need the tea.Program instance to send messages back
 			// For now, we'll start it without program and rely on polling
-			sseClient := webshare.NewSSEClient(urls.LocalURL, nil)
+			sseClient := webshare.NewSSEClient(urls.LocalURL, a.webShare.HostToken(), nil)
 			sseClient.Start()
 		}
 		
@@ -1125,27 +1230,121 @@ func (a *appModel) startWebShare(sessionID string) tea.Cmd {
 			func() tea.Msg {
 				fmt.Fprintf(os.Stderr, "[DEBUG] startWebShare: Returning OpenDialogMsg\n")
 				return dialogs.OpenDialogMsg{
-					Model: webshareDialog.NewShareDialog(sessionID, urls),
+					Model: webshareDialog.NewShareDialog(a.webShare),
 				}
 			},
 			func() tea.Msg {
 				fmt.Fprintf(os.Stderr, "[DEBUG] startWebShare: Returning WebShareStartedMsg\n")
 				localURL := ""
-				ngrokURL := ""
+				publicURL := ""
 				if urls != nil {
 					localURL = urls.LocalURL
-					ngrokURL = urls.NgrokURL
+					publicURL = urls.PublicURL
 				}
 				return commands.WebShareStartedMsg{
-					LocalURL: localURL,
-					NgrokURL: ngrokURL,
+					LocalURL:  localURL,
+					PublicURL: publicURL,
 					WebShare: a.webShare,
 				}
 			},
+			waitForProposal(a.webShare.SubscribeProposals()),
 		)()
 	}
 }
 
+// exportSession writes a redacted transcript of the session to the working
+// directory.
+func (a *appModel) exportSession(sessionID string, format transcript.Format) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.Background()
+		cfg := config.Get()
+		t, err := transcript.Load(ctx, a.app.Sessions, a.app.Messages, sessionID)
+		if err == nil {
+			err = t.LoadDiffs(ctx, a.app.History, cfg.WorkingDir())
+		}
+		if err == nil {
+			err = t.Redact(cfg.Secrets()...)
+		}
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: fmt.Sprintf("Failed to export session: %v", err)}
+		}
+
+		path := filepath.Join(cfg.WorkingDir(), t.FileName(format))
+		f, err := os.Create(path)
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: fmt.Sprintf("Failed to export session: %v", err)}
+		}
+		defer f.Close()
+		if err := t.Write(f, format); err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: fmt.Sprintf("Failed to export session: %v", err)}
+		}
+		return util.InfoMsg{Type: util.InfoTypeInfo, Msg: "Exported session to " + t.FileName(format)}
+	}
+}
+
+// proposalMsg is a change to the proposals of the web share, along with the
+// subscription it came from.
+type proposalMsg struct {
+	event  pubsub.Event[webshare.Proposal]
+	events <-chan pubsub.Event[webshare.Proposal]
+}
+
+// waitForProposal waits for the next change to the proposals of a web share.
+func waitForProposal(events <-chan pubsub.Event[webshare.Proposal]) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			return nil
+		}
+		return proposalMsg{event: event, events: events}
+	}
+}
+
+// handleProposal tells the host about new proposals and shows the votes of
+// buddies in the permission dialog.
+func (a *appModel) handleProposal(event pubsub.Event[webshare.Proposal]) tea.Cmd {
+	proposal := event.Payload
+	if proposal.Kind != webshare.ProposalVote {
+		if event.Type == pubsub.CreatedEvent {
+			return util.ReportInfo(fmt.Sprintf("%s proposed a prompt, review it with Buddy Proposals", proposal.BuddyName))
+		}
+		return nil
+	}
+	if a.webShare == nil || event.Type == pubsub.DeletedEvent {
+		return nil
+	}
+	votes := a.webShare.Votes(proposal.ToolCallID)
+	summary := make([]string, len(votes))
+	for i, vote := range votes {
+		summary[i] = vote.BuddyName + " denies"
+		if vote.Allow {
+			summary[i] = vote.BuddyName + " allows"
+		}
+	}
+	u, cmd := a.dialog.Update(permissions.VotesMsg{
+		ToolCallID: proposal.ToolCallID,
+		Votes:      strings.Join(summary, ", "),
+	})
+	a.dialog = u.(dialogs.DialogCmp)
+	return cmd
+}
+
+// runProposal runs a prompt proposed by a buddy on the shared session, with
+// the buddy as its author.
+func (a *appModel) runProposal(msg proposals.AcceptedMsg) tea.Cmd {
+	if a.webShare == nil {
+		return nil
+	}
+	sessionID := a.webShare.SessionID()
+	ctx := agent.WithAuthor(context.Background(), msg.Proposal.BuddyName)
+	if _, err := a.app.AgentForSession(sessionID).Run(ctx, sessionID, msg.Prompt); err != nil {
+		// Keep the proposal in the queue, e.g. until the agent is done
+		return util.ReportError(err)
+	}
+	a.webShare.ResolveProposal(msg.Proposal.ID, true)
+	return util.ReportInfo(fmt.Sprintf("Running %s's prompt", msg.Proposal.BuddyName))
+}
+
 // openJiraDialog opens the Jira issues dialog
 func (a *appModel) openJiraDialog() tea.Cmd {
 	return func() tea.Msg {
@@ -0,0 +1,99 @@
+package usage
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/chasedut/toke/internal/config"
+	"github.com/chasedut/toke/internal/db"
+)
+
+// GroupBy is what the rows of a report add up.
+type GroupBy string
+
+const (
+	ByModel   GroupBy = "model"
+	ByDay     GroupBy = "day"
+	BySession GroupBy = "session"
+	ByProject GroupBy = "project"
+)
+
+// Row is the usage of a group of requests.
+type Row struct {
+	Key                 string  `json:"key"`
+	Requests            int     `json:"requests"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	Cost                float64 `json:"cost"`
+}
+
+func (r *Row) add(record Record) {
+	r.Requests++
+	r.InputTokens += record.InputTokens
+	r.OutputTokens += record.OutputTokens
+	r.CacheCreationTokens += record.CacheCreationTokens
+	r.CacheReadTokens += record.CacheReadTokens
+	r.Cost += record.Cost
+}
+
+// Summarize groups records. Days are in the local time zone and sorted in
+// order, the other groups are sorted by cost, highest first.
+func Summarize(records []Record, by GroupBy) []Row {
+	var rows []Row
+	index := make(map[string]int)
+	for _, record := range records {
+		var key string
+		switch by {
+		case ByDay:
+			key = time.UnixMilli(record.CreatedAt).Format(time.DateOnly)
+		case BySession:
+			key = record.SessionID
+		case ByProject:
+			key = record.Project
+		default:
+			key = record.Model
+			if record.Provider != "" {
+				key = record.Provider + "/" + record.Model
+			}
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(rows)
+			index[key] = i
+			rows = append(rows, Row{Key: key})
+		}
+		rows[i].add(record)
+	}
+	if by == ByDay {
+		slices.SortFunc(rows, func(a, b Row) int { return cmp.Compare(a.Key, b.Key) })
+	} else {
+		slices.SortStableFunc(rows, func(a, b Row) int { return cmp.Compare(b.Cost, a.Cost) })
+	}
+	return rows
+}
+
+// Total adds up all records.
+func Total(records []Record) Row {
+	total := Row{Key: "total"}
+	for _, record := range records {
+		total.add(record)
+	}
+	return total
+}
+
+// GlobalDir is the directory of the database shared by all projects.
+func GlobalDir() string {
+	return filepath.Dir(config.GlobalConfigData())
+}
+
+// OpenGlobal opens the database shared by all projects. Only its usage table
+// is used.
+func OpenGlobal(ctx context.Context) (*sql.DB, error) {
+	return db.Connect(ctx, GlobalDir())
+}
@@ -0,0 +1,257 @@
+// Package usage records the tokens and cost of every request made to a model
+// and enforces the daily and monthly budgets of the config.
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/chasedut/toke/internal/config"
+	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+// ErrBudgetExceeded is returned for new requests once a budget that blocks
+// them is used up.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Record is the usage of a single request.
+type Record struct {
+	ID string `json:"id"`
+	// Project is the working directory the request was made from. It is only
+	// set in the global database.
+	Project             string  `json:"project,omitempty"`
+	SessionID           string  `json:"session_id"`
+	MessageID           string  `json:"message_id,omitempty"`
+	Model               string  `json:"model"`
+	Provider            string  `json:"provider"`
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	Cost                float64 `json:"cost"`
+	// CreatedAt is a Unix timestamp in milliseconds.
+	CreatedAt int64 `json:"created_at"`
+}
+
+// Period is the time span a budget applies to.
+type Period string
+
+const (
+	Daily   Period = "daily"
+	Monthly Period = "monthly"
+)
+
+// Start returns when the period that contains t started, in the location of
+// t.
+func (p Period) Start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	if p == Monthly {
+		d = 1
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// Budget is the spending of a period against its limit.
+type Budget struct {
+	Period Period  `json:"period"`
+	Limit  float64 `json:"limit"`
+	Spent  float64 `json:"spent"`
+}
+
+// Exceeded reports whether the budget is used up.
+func (b Budget) Exceeded() bool {
+	return b.Spent >= b.Limit
+}
+
+func (b Budget) String() string {
+	return fmt.Sprintf("$%.2f of the $%.2f %s budget spent", b.Spent, b.Limit, b.Period)
+}
+
+type Service interface {
+	pubsub.Suscriber[Budget]
+	Record(ctx context.Context, record Record) error
+	List(ctx context.Context, since time.Time) ([]Record, error)
+	// SessionTotal adds up the usage of a session and its task sessions
+	// since a time.
+	SessionTotal(ctx context.Context, sessionID string, since time.Time) (Row, error)
+	// Budgets returns the budgets of the config with what was spent in
+	// their current period.
+	Budgets(ctx context.Context) ([]Budget, error)
+	// CheckBudget is called before new requests. It publishes a budget the
+	// first time it is found used up in a period, and returns
+	// ErrBudgetExceeded if the budgets block requests.
+	CheckBudget(ctx context.Context) error
+}
+
+type service struct {
+	*pubsub.Broker[Budget]
+	q db.Querier
+	// global is the database shared by all projects, nil if there is none.
+	global  db.Querier
+	project string
+	budget  *config.BudgetOptions
+
+	mu sync.Mutex
+	// warned holds the periods a used up budget was published for.
+	warned map[string]bool
+}
+
+// NewService returns a service that records usage in the project database
+// and, when global is not nil, in the database shared by all projects.
+func NewService(q, global db.Querier, project string, budget *config.BudgetOptions) Service {
+	return &service{
+		Broker:  pubsub.NewBroker[Budget](),
+		q:       q,
+		global:  global,
+		project: project,
+		budget:  budget,
+		warned:  make(map[string]bool),
+	}
+}
+
+func (s *service) Record(ctx context.Context, record Record) error {
+	if record.ID == "" {
+		record.ID = uuid.New().String()
+	}
+	if record.CreatedAt == 0 {
+		record.CreatedAt = time.Now().UnixMilli()
+	}
+	params := db.CreateUsageParams{
+		ID:                  record.ID,
+		SessionID:           record.SessionID,
+		MessageID:           record.MessageID,
+		Model:               record.Model,
+		Provider:            record.Provider,
+		InputTokens:         record.InputTokens,
+		OutputTokens:        record.OutputTokens,
+		CacheCreationTokens: record.CacheCreationTokens,
+		CacheReadTokens:     record.CacheReadTokens,
+		Cost:                record.Cost,
+		CreatedAt:           record.CreatedAt,
+	}
+	if err := s.q.CreateUsage(ctx, params); err != nil {
+		return err
+	}
+	if s.global != nil {
+		params.Project = s.project
+		if err := s.global.CreateUsage(ctx, params); err != nil {
+			slog.Warn("Failed to record usage in the global database", "error", err)
+		}
+	}
+	return nil
+}
+
+func (s *service) List(ctx context.Context, since time.Time) ([]Record, error) {
+	var createdAt int64
+	if !since.IsZero() {
+		createdAt = since.UnixMilli()
+	}
+	items, err := s.q.ListUsage(ctx, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, len(items))
+	for i, item := range items {
+		records[i] = Record{
+			ID:                  item.ID,
+			Project:             item.Project,
+			SessionID:           item.SessionID,
+			MessageID:           item.MessageID,
+			Model:               item.Model,
+			Provider:            item.Provider,
+			InputTokens:         item.InputTokens,
+			OutputTokens:        item.OutputTokens,
+			CacheCreationTokens: item.CacheCreationTokens,
+			CacheReadTokens:     item.CacheReadTokens,
+			Cost:                item.Cost,
+			CreatedAt:           item.CreatedAt,
+		}
+	}
+	return records, nil
+}
+
+func (s *service) SessionTotal(ctx context.Context, sessionID string, since time.Time) (Row, error) {
+	var createdAt int64
+	if !since.IsZero() {
+		createdAt = since.UnixMilli()
+	}
+	sum, err := s.q.SumSessionUsage(ctx, db.SumSessionUsageParams{
+		SessionID: sessionID,
+		CreatedAt: createdAt,
+	})
+	if err != nil {
+		return Row{}, err
+	}
+	return Row{
+		Key:                 sessionID,
+		Requests:            int(sum.Requests),
+		InputTokens:         sum.InputTokens,
+		OutputTokens:        sum.OutputTokens,
+		CacheCreationTokens: sum.CacheCreationTokens,
+		CacheReadTokens:     sum.CacheReadTokens,
+		Cost:                sum.Cost,
+	}, nil
+}
+
+func (s *service) Budgets(ctx context.Context) ([]Budget, error) {
+	if s.budget == nil {
+		return nil, nil
+	}
+	q := s.q
+	if s.budget.Scope == config.BudgetGlobal {
+		if s.global == nil {
+			slog.Warn("Global budget ignored, the global database is not available")
+			return nil, nil
+		}
+		q = s.global
+	}
+
+	now := time.Now()
+	var budgets []Budget
+	for _, b := range []Budget{
+		{Period: Daily, Limit: s.budget.Daily},
+		{Period: Monthly, Limit: s.budget.Monthly},
+	} {
+		if b.Limit <= 0 {
+			continue
+		}
+		spent, err := q.SumUsageCost(ctx, b.Period.Start(now).UnixMilli())
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute spending: %w", err)
+		}
+		b.Spent = spent
+		budgets = append(budgets, b)
+	}
+	return budgets, nil
+}
+
+func (s *service) CheckBudget(ctx context.Context) error {
+	budgets, err := s.Budgets(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, b := range budgets {
+		if !b.Exceeded() {
+			continue
+		}
+		if s.budget.Action == config.BudgetBlock {
+			return fmt.Errorf("%w: %s", ErrBudgetExceeded, b)
+		}
+		s.mu.Lock()
+		key := string(b.Period) + b.Period.Start(now).Format(time.DateOnly)
+		warned := s.warned[key]
+		s.warned[key] = true
+		s.mu.Unlock()
+		if !warned {
+			s.Publish(pubsub.CreatedEvent, b)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chasedut/toke/internal/config"
+	"github.com/chasedut/toke/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local)
+	records := []Record{
+		{SessionID: "a", Model: "small", Provider: "openai", InputTokens: 10, Cost: 0.1, CreatedAt: day.UnixMilli()},
+		{SessionID: "b", Model: "large", Provider: "anthropic", InputTokens: 20, OutputTokens: 5, Cost: 2, CreatedAt: day.AddDate(0, 0, 1).UnixMilli()},
+		{SessionID: "a", Model: "small", Provider: "openai", InputTokens: 30, Cost: 0.3, CreatedAt: day.UnixMilli()},
+	}
+
+	rows := Summarize(records, ByModel)
+	require.Len(t, rows, 2)
+	require.Equal(t, "anthropic/large", rows[0].Key, "highest cost first")
+	require.Equal(t, "openai/small", rows[1].Key)
+	require.Equal(t, 2, rows[1].Requests)
+	require.Equal(t, int64(40), rows[1].InputTokens)
+
+	rows = Summarize(records, ByDay)
+	require.Equal(t, []string{"2025-03-01", "2025-03-02"}, []string{rows[0].Key, rows[1].Key})
+
+	total := Total(records)
+	require.Equal(t, 3, total.Requests)
+	require.InDelta(t, 2.4, total.Cost, 1e-9)
+}
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2025, 3, 14, 15, 9, 26, 0, time.UTC)
+	require.Equal(t, time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC), Daily.Start(now))
+	require.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Monthly.Start(now))
+}
+
+type spentQuerier struct {
+	db.Querier
+	spent float64
+}
+
+func (q spentQuerier) SumUsageCost(context.Context, int64) (float64, error) {
+	return q.spent, nil
+}
+
+func TestCheckBudget(t *testing.T) {
+	ctx := t.Context()
+
+	s := NewService(spentQuerier{spent: 4}, nil, "", &config.BudgetOptions{Daily: 5})
+	require.NoError(t, s.CheckBudget(ctx))
+
+	s = NewService(spentQuerier{spent: 6}, nil, "", &config.BudgetOptions{Daily: 5, Monthly: 100})
+	budgets, err := s.Budgets(ctx)
+	require.NoError(t, err)
+	require.Len(t, budgets, 2)
+	require.True(t, budgets[0].Exceeded())
+	require.False(t, budgets[1].Exceeded())
+
+	exceeded := s.Subscribe(ctx)
+	require.NoError(t, s.CheckBudget(ctx))
+	require.NoError(t, s.CheckBudget(ctx))
+	event := <-exceeded
+	require.Equal(t, Daily, event.Payload.Period)
+	require.Empty(t, exceeded, "used up budgets are published once per period")
+
+	s = NewService(spentQuerier{spent: 6}, nil, "", &config.BudgetOptions{Daily: 5, Action: config.BudgetBlock})
+	err = s.CheckBudget(ctx)
+	require.True(t, errors.Is(err, ErrBudgetExceeded))
+	require.Contains(t, err.Error(), "$6.00 of the $5.00 daily budget")
+}
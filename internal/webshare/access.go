@@ -0,0 +1,221 @@
+package webshare
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Role is what a token lets its holder do with a share.
+type Role string
+
+const (
+	// RoleViewer can read the session.
+	RoleViewer Role = "viewer"
+	// RoleParticipant can also join the buddy chat.
+	RoleParticipant Role = "participant"
+	// RoleHost is the TUI that shares the session.
+	RoleHost Role = "host"
+)
+
+// DefaultExpiry is how long a share lasts when no expiry is configured.
+const DefaultExpiry = 24 * time.Hour
+
+// tokenCookie keeps the token of a browser after it opened an invite link, so
+// the link doesn't stay in the address bar and EventSource, which can't send
+// headers, is authenticated too.
+const tokenCookie = "toke_share_token"
+
+var (
+	errUnauthorized = errors.New("a valid share link is required")
+	errExpired      = errors.New("this share has expired")
+)
+
+// canAccess reports whether the role includes the required one.
+func (r Role) canAccess(required Role) bool {
+	rank := map[Role]int{RoleViewer: 1, RoleParticipant: 2, RoleHost: 3}
+	return rank[r] >= rank[required]
+}
+
+// newToken returns a random URL-safe secret.
+func newToken() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic("webshare: failed to generate a token: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func tokenEqual(a, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// requestToken returns the token sent with a request: in the token query
+// parameter of invite links, the Authorization header or the cookie.
+func requestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	if auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return auth
+	}
+	if cookie, err := r.Cookie(tokenCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// inviteURL adds a token to the base URL of a share.
+func inviteURL(base, token string) string {
+	if base == "" {
+		return ""
+	}
+	return base + "/?token=" + url.QueryEscape(token)
+}
+
+// authenticate returns the role of the token and, for buddies, the buddy it
+// belongs to.
+func (s *SessionShare) authenticate(token string) (Role, *Buddy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.expiresAt.IsZero() && time.Now().After(s.expiresAt) {
+		return "", nil, errExpired
+	}
+	switch {
+	case tokenEqual(token, s.hostToken):
+		return RoleHost, nil, nil
+	case tokenEqual(token, s.participantToken):
+		return RoleParticipant, nil, nil
+	case tokenEqual(token, s.viewerToken):
+		return RoleViewer, nil, nil
+	}
+	for _, buddy := range s.buddies {
+		if tokenEqual(token, buddy.token) {
+			return RoleParticipant, buddy, nil
+		}
+	}
+	return "", nil, errUnauthorized
+}
+
+// access is what the request was authenticated as.
+type access struct {
+	role  Role
+	token string
+	buddy *Buddy
+}
+
+// requireRole only lets requests with a token of at least the given role
+// through.
+func (s *SessionShare) requireRole(required Role, next func(http.ResponseWriter, *http.Request, access)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+		role, buddy, err := s.authenticate(token)
+		switch {
+		case errors.Is(err, errExpired):
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case !role.canAccess(required):
+			http.Error(w, "this share link doesn't allow that", http.StatusForbidden)
+			return
+		}
+		next(w, r, access{role: role, token: token, buddy: buddy})
+	}
+}
+
+// setTokenCookie stores the token in the browser until the share expires.
+func (s *SessionShare) setTokenCookie(w http.ResponseWriter, token string) {
+	cookie := &http.Cookie{
+		Name:     tokenCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(s.PublicURL(), "https://"),
+		SameSite: http.SameSiteStrictMode,
+	}
+	s.mu.RLock()
+	if !s.expiresAt.IsZero() {
+		cookie.Expires = s.expiresAt
+	}
+	s.mu.RUnlock()
+	http.SetCookie(w, cookie)
+}
+
+// ExpiresAt returns when the share stops accepting requests.
+func (s *SessionShare) ExpiresAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.expiresAt
+}
+
+// InviteURLs returns the links that give access to the share with the given
+// role, the public one being empty until the tunnel is up.
+func (s *SessionShare) InviteURLs(role Role) (local, public string) {
+	s.mu.RLock()
+	token := s.viewerToken
+	if role == RoleParticipant {
+		token = s.participantToken
+	}
+	s.mu.RUnlock()
+	return inviteURL(s.localURL, token), inviteURL(s.PublicURL(), token)
+}
+
+// HostToken returns the token the host uses to listen to the share.
+func (s *SessionShare) HostToken() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hostToken
+}
+
+// RotateLinks replaces the invite links of the share. Everyone who joined
+// with the old ones, buddies included, is disconnected and their proposals are
+// dropped.
+func (s *SessionShare) RotateLinks() {
+	s.mu.Lock()
+	s.viewerToken = newToken()
+	s.participantToken = newToken()
+	revoked := s.buddies
+	s.buddies = make(map[string]*Buddy)
+	s.mu.Unlock()
+
+	for _, buddy := range revoked {
+		s.broadcastBuddyEvent("buddy_left", buddy)
+	}
+	s.dropProposals(func(Proposal) bool { return true })
+	s.disconnectClients(func(c *sseClient) bool { return c.role != RoleHost })
+}
+
+// RevokeBuddy disconnects a buddy, invalidates their token and drops their
+// proposals. They can still rejoin with the participant link until it is
+// rotated.
+func (s *SessionShare) RevokeBuddy(buddyID string) bool {
+	s.mu.Lock()
+	buddy, ok := s.buddies[buddyID]
+	delete(s.buddies, buddyID)
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+	s.broadcastBuddyEvent("buddy_left", buddy)
+	s.dropProposals(func(p Proposal) bool { return p.BuddyID == buddyID })
+	s.disconnectClients(func(c *sseClient) bool { return c.buddyID == buddyID })
+	return true
+}
+
+// expireAfter disconnects every client when the share expires.
+func (s *SessionShare) expireAfter(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		s.disconnectClients(func(c *sseClient) bool { return c.role != RoleHost })
+	case <-s.ctx.Done():
+	}
+}
@@ -0,0 +1,69 @@
+package webshare
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestShare(t *testing.T, expiry time.Duration) *SessionShare {
+	t.Helper()
+	s := NewSessionShare("session", expiry, nil, nil)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	t.Cleanup(s.cancel)
+	if expiry != 0 {
+		s.expiresAt = time.Now().Add(expiry)
+	}
+	return s
+}
+
+func serve(s *SessionShare, role Role, token string) int {
+	handler := s.requireRole(role, func(w http.ResponseWriter, r *http.Request, a access) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/messages", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec.Code
+}
+
+func TestShareRoles(t *testing.T) {
+	s := newTestShare(t, time.Hour)
+
+	require.Equal(t, http.StatusUnauthorized, serve(s, RoleViewer, ""))
+	require.Equal(t, http.StatusUnauthorized, serve(s, RoleViewer, "guess"))
+	require.Equal(t, http.StatusNoContent, serve(s, RoleViewer, s.viewerToken))
+	require.Equal(t, http.StatusForbidden, serve(s, RoleParticipant, s.viewerToken))
+	require.Equal(t, http.StatusNoContent, serve(s, RoleParticipant, s.participantToken))
+	require.Equal(t, http.StatusNoContent, serve(s, RoleParticipant, s.HostToken()))
+}
+
+func TestShareRotateAndRevoke(t *testing.T) {
+	s := newTestShare(t, time.Hour)
+	buddy := &Buddy{ID: "buddy_1", Name: "Mary", token: newToken()}
+	s.buddies[buddy.ID] = buddy
+	require.Equal(t, http.StatusNoContent, serve(s, RoleParticipant, buddy.token))
+
+	require.True(t, s.RevokeBuddy(buddy.ID))
+	require.Equal(t, http.StatusUnauthorized, serve(s, RoleParticipant, buddy.token))
+	require.False(t, s.RevokeBuddy(buddy.ID))
+
+	oldViewer := s.viewerToken
+	s.RotateLinks()
+	require.Equal(t, http.StatusUnauthorized, serve(s, RoleViewer, oldViewer))
+	require.Equal(t, http.StatusNoContent, serve(s, RoleViewer, s.viewerToken))
+	require.Equal(t, http.StatusNoContent, serve(s, RoleViewer, s.HostToken()), "the host is not rotated out")
+}
+
+func TestShareExpiry(t *testing.T) {
+	s := newTestShare(t, time.Hour)
+	s.expiresAt = time.Now().Add(-time.Minute)
+	require.Equal(t, http.StatusGone, serve(s, RoleViewer, s.viewerToken))
+}
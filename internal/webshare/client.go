@@ -16,13 +16,15 @@ import (
 // SSEClient connects to the web share server and listens for buddy events
 type SSEClient struct {
 	url     string
+	token   string
 	program *tea.Program
 }
 
-// NewSSEClient creates a new SSE client
-func NewSSEClient(baseURL string, program *tea.Program) *SSEClient {
+// NewSSEClient creates a new SSE client that authenticates with the token
+func NewSSEClient(baseURL, token string, program *tea.Program) *SSEClient {
 	return &SSEClient{
 		url:     baseURL + "/events",
+		token:   token,
 		program: program,
 	}
 }
@@ -47,6 +49,7 @@ func (c *SSEClient) listen() {
 	// Set SSE headers
 	req.Header.Set("Accept", "text/event-stream")
 	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("Authorization", "Bearer "+c.token)
 	
 	// Make request with timeout
 	client := &http.Client{
@@ -63,6 +66,8 @@ func (c *SSEClient) listen() {
 	
 	// Read events
 	scanner := bufio.NewScanner(resp.Body)
+	// Messages with long tool outputs don't fit in the default buffer
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
 	for scanner.Scan() {
 		line := scanner.Text()
 		
@@ -0,0 +1,273 @@
+package webshare
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/chasedut/toke/internal/pubsub"
+)
+
+// ProposalKind tells what a buddy proposes to the host.
+type ProposalKind string
+
+const (
+	// ProposalPrompt suggests a prompt for the agent.
+	ProposalPrompt ProposalKind = "prompt"
+	// ProposalVote asks the host to allow or deny a tool call that waits
+	// for permission.
+	ProposalVote ProposalKind = "vote"
+)
+
+const (
+	// maxPendingProposals is how many prompts a buddy can have waiting for
+	// the host.
+	maxPendingProposals = 5
+	// maxPromptLength is the size limit of a proposed prompt, in bytes.
+	maxPromptLength = 16 << 10
+)
+
+var (
+	errTooManyProposals = errors.New("wait for the host to review your other prompts")
+	errNotPending       = errors.New("this tool call doesn't wait for permission")
+)
+
+// Proposal is something a buddy asks the host to do. Prompts wait in a queue
+// until the host accepts or dismisses them, votes until the tool call they are
+// for is allowed or denied.
+type Proposal struct {
+	ID        string       `json:"id"`
+	Kind      ProposalKind `json:"kind"`
+	BuddyID   string       `json:"buddy_id"`
+	BuddyName string       `json:"buddy_name"`
+	// Prompt is the suggested prompt.
+	Prompt string `json:"prompt,omitempty"`
+	// ToolCallID is the tool call a vote is for and Allow the vote.
+	ToolCallID string    `json:"tool_call_id,omitempty"`
+	Allow      bool      `json:"allow,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Approval is a tool call of the shared session that waits for permission.
+type Approval struct {
+	ToolCallID  string `json:"tool_call_id"`
+	ToolName    string `json:"tool_name"`
+	Description string `json:"description"`
+}
+
+// SubscribeProposals returns the changes to the proposals until the share
+// stops: created and updated when a buddy proposes or changes a vote, deleted
+// when a proposal is resolved.
+func (s *SessionShare) SubscribeProposals() <-chan pubsub.Event[Proposal] {
+	return s.proposalBroker.Subscribe(s.ctx)
+}
+
+// Proposals returns the prompts waiting for the host, oldest first.
+func (s *SessionShare) Proposals() []Proposal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var prompts []Proposal
+	for _, p := range s.proposals {
+		if p.Kind == ProposalPrompt {
+			prompts = append(prompts, p)
+		}
+	}
+	return prompts
+}
+
+// Votes returns the votes for a tool call.
+func (s *SessionShare) Votes(toolCallID string) []Proposal {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var votes []Proposal
+	for _, p := range s.proposals {
+		if p.Kind == ProposalVote && p.ToolCallID == toolCallID {
+			votes = append(votes, p)
+		}
+	}
+	return votes
+}
+
+// RequestApproval lets buddies vote on a tool call until it is resolved.
+func (s *SessionShare) RequestApproval(approval Approval) {
+	s.mu.Lock()
+	s.approvals[approval.ToolCallID] = approval
+	s.mu.Unlock()
+
+	data, _ := json.Marshal(map[string]any{
+		"type":     "approval_requested",
+		"approval": approval,
+	})
+	s.broadcast(string(data))
+}
+
+// ResolveApproval ends the vote on a tool call once the host allowed or
+// denied it.
+func (s *SessionShare) ResolveApproval(toolCallID string) {
+	s.mu.Lock()
+	_, ok := s.approvals[toolCallID]
+	delete(s.approvals, toolCallID)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	s.dropProposals(func(p Proposal) bool {
+		return p.Kind == ProposalVote && p.ToolCallID == toolCallID
+	})
+
+	data, _ := json.Marshal(map[string]any{
+		"type":         "approval_resolved",
+		"tool_call_id": toolCallID,
+	})
+	s.broadcast(string(data))
+}
+
+// ResolveProposal removes a prompt from the queue and tells its buddy whether
+// the host accepted it.
+func (s *SessionShare) ResolveProposal(id string, accepted bool) (Proposal, bool) {
+	s.mu.Lock()
+	i := slices.IndexFunc(s.proposals, func(p Proposal) bool { return p.ID == id })
+	if i < 0 {
+		s.mu.Unlock()
+		return Proposal{}, false
+	}
+	proposal := s.proposals[i]
+	s.proposals = slices.Delete(s.proposals, i, i+1)
+	s.mu.Unlock()
+
+	s.proposalBroker.Publish(pubsub.DeletedEvent, proposal)
+	data, _ := json.Marshal(map[string]any{
+		"type":     "proposal_resolved",
+		"proposal": proposal,
+		"accepted": accepted,
+	})
+	s.broadcast(string(data))
+	return proposal, true
+}
+
+// propose queues a proposal. A new vote of a buddy replaces their previous
+// one for the same tool call.
+func (s *SessionShare) propose(p Proposal) error {
+	s.mu.Lock()
+	event := pubsub.CreatedEvent
+	switch p.Kind {
+	case ProposalPrompt:
+		pending := 0
+		for _, other := range s.proposals {
+			if other.Kind == ProposalPrompt && other.BuddyID == p.BuddyID {
+				pending++
+			}
+		}
+		if pending >= maxPendingProposals {
+			s.mu.Unlock()
+			return errTooManyProposals
+		}
+	case ProposalVote:
+		if _, ok := s.approvals[p.ToolCallID]; !ok {
+			s.mu.Unlock()
+			return errNotPending
+		}
+		i := slices.IndexFunc(s.proposals, func(other Proposal) bool {
+			return other.Kind == ProposalVote && other.ToolCallID == p.ToolCallID && other.BuddyID == p.BuddyID
+		})
+		if i >= 0 {
+			p.ID = s.proposals[i].ID
+			s.proposals = slices.Delete(s.proposals, i, i+1)
+			event = pubsub.UpdatedEvent
+		}
+	}
+	s.proposals = append(s.proposals, p)
+	s.mu.Unlock()
+
+	s.proposalBroker.Publish(event, p)
+	return nil
+}
+
+// dropProposals removes the matching proposals without telling their buddies,
+// e.g. when they are revoked.
+func (s *SessionShare) dropProposals(match func(Proposal) bool) {
+	s.mu.Lock()
+	var dropped []Proposal
+	s.proposals = slices.DeleteFunc(s.proposals, func(p Proposal) bool {
+		if match(p) {
+			dropped = append(dropped, p)
+			return true
+		}
+		return false
+	})
+	s.mu.Unlock()
+	for _, p := range dropped {
+		s.proposalBroker.Publish(pubsub.DeletedEvent, p)
+	}
+}
+
+func (s *SessionShare) handleBuddyPropose(w http.ResponseWriter, r *http.Request, a access) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.buddy == nil {
+		http.Error(w, "Join the chat first", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		Prompt     string `json:"prompt"`
+		ToolCallID string `json:"tool_call_id"`
+		Allow      bool   `json:"allow"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPromptLength*2)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	proposal := Proposal{
+		ID:        fmt.Sprintf("proposal_%d", time.Now().UnixNano()),
+		BuddyID:   a.buddy.ID,
+		BuddyName: a.buddy.Name,
+		CreatedAt: time.Now(),
+	}
+	switch {
+	case req.ToolCallID != "":
+		proposal.Kind = ProposalVote
+		proposal.ToolCallID = req.ToolCallID
+		proposal.Allow = req.Allow
+	case strings.TrimSpace(req.Prompt) == "":
+		http.Error(w, "The prompt is empty", http.StatusBadRequest)
+		return
+	case len(req.Prompt) > maxPromptLength:
+		http.Error(w, "The prompt is too long", http.StatusRequestEntityTooLarge)
+		return
+	default:
+		proposal.Kind = ProposalPrompt
+		proposal.Prompt = req.Prompt
+	}
+
+	switch err := s.propose(proposal); {
+	case errors.Is(err, errTooManyProposals):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, errNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proposal)
+}
+
+func (s *SessionShare) handleApprovals(w http.ResponseWriter, r *http.Request, _ access) {
+	s.mu.RLock()
+	approvals := make([]Approval, 0, len(s.approvals))
+	for _, approval := range s.approvals {
+		approvals = append(approvals, approval)
+	}
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approvals)
+}
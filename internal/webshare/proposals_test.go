@@ -0,0 +1,73 @@
+package webshare
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chasedut/toke/internal/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+func postProposal(s *SessionShare, token, body string) int {
+	req := httptest.NewRequest(http.MethodPost, "/api/buddy/propose", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.requireRole(RoleParticipant, s.handleBuddyPropose)(rec, req)
+	return rec.Code
+}
+
+func TestProposePrompts(t *testing.T) {
+	s := newTestShare(t, time.Hour)
+	events := s.SubscribeProposals()
+	buddy := &Buddy{ID: "buddy_1", Name: "Mary", token: newToken()}
+	s.buddies[buddy.ID] = buddy
+
+	require.Equal(t, http.StatusForbidden, postProposal(s, s.participantToken, `{"prompt":"fix the tests"}`), "only buddies can propose")
+	require.Equal(t, http.StatusBadRequest, postProposal(s, buddy.token, `{"prompt":" "}`))
+	require.Equal(t, http.StatusOK, postProposal(s, buddy.token, `{"prompt":"fix the tests"}`))
+
+	event := <-events
+	require.Equal(t, pubsub.CreatedEvent, event.Type)
+	require.Equal(t, "Mary", event.Payload.BuddyName)
+	require.Equal(t, "fix the tests", event.Payload.Prompt)
+
+	for range maxPendingProposals - 1 {
+		require.Equal(t, http.StatusOK, postProposal(s, buddy.token, `{"prompt":"more"}`))
+	}
+	require.Equal(t, http.StatusTooManyRequests, postProposal(s, buddy.token, `{"prompt":"more"}`))
+
+	proposals := s.Proposals()
+	require.Len(t, proposals, maxPendingProposals)
+	accepted, ok := s.ResolveProposal(proposals[0].ID, true)
+	require.True(t, ok)
+	require.Equal(t, "fix the tests", accepted.Prompt)
+	_, ok = s.ResolveProposal(proposals[0].ID, true)
+	require.False(t, ok)
+
+	s.RevokeBuddy(buddy.ID)
+	require.Empty(t, s.Proposals())
+}
+
+func TestProposeVotes(t *testing.T) {
+	s := newTestShare(t, time.Hour)
+	buddy := &Buddy{ID: "buddy_1", Name: "Mary", token: newToken()}
+	s.buddies[buddy.ID] = buddy
+
+	require.Equal(t, http.StatusConflict, postProposal(s, buddy.token, `{"tool_call_id":"call_1","allow":true}`))
+
+	s.RequestApproval(Approval{ToolCallID: "call_1", ToolName: "bash"})
+	require.Equal(t, http.StatusOK, postProposal(s, buddy.token, `{"tool_call_id":"call_1","allow":true}`))
+	require.Equal(t, http.StatusOK, postProposal(s, buddy.token, `{"tool_call_id":"call_1","allow":false}`))
+
+	votes := s.Votes("call_1")
+	require.Len(t, votes, 1, "a new vote replaces the previous one")
+	require.False(t, votes[0].Allow)
+	require.Empty(t, s.Proposals(), "votes are not queued as prompts")
+
+	s.ResolveApproval("call_1")
+	require.Empty(t, s.Votes("call_1"))
+	require.Equal(t, http.StatusConflict, postProposal(s, buddy.token, `{"tool_call_id":"call_1","allow":true}`))
+}
@@ -0,0 +1,479 @@
+package webshare
+
+import (
+	"errors"
+	"strings"
+)
+
+// The QR codes of LAN shares are small, so only the versions up to 10 with
+// the medium error correction level are supported. They hold up to 213 bytes,
+// enough for an invite link.
+const maxQRVersion = 10
+
+var (
+	// qrECCPerBlock and qrBlocks are the error correction codewords per block
+	// and the number of blocks of each version at level M.
+	qrECCPerBlock = [maxQRVersion + 1]int{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26}
+	qrBlocks      = [maxQRVersion + 1]int{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5}
+
+	errQRTooLong = errors.New("the text doesn't fit in a QR code")
+)
+
+// qrCode is a QR code whose modules are true when dark.
+type qrCode struct {
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// QRCode renders text as a QR code with half block characters, two rows of
+// modules per line, for terminals with a dark background.
+func QRCode(text string) (string, error) {
+	qr, err := encodeQR([]byte(text))
+	if err != nil {
+		return "", err
+	}
+	// Light modules are drawn, dark ones are the background, and the code is
+	// surrounded by a light quiet zone.
+	const quiet = 2
+	light := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= qr.size || y >= qr.size {
+			return true
+		}
+		return !qr.modules[y][x]
+	}
+	var b strings.Builder
+	for y := -quiet; y < qr.size+quiet; y += 2 {
+		for x := -quiet; x < qr.size+quiet; x++ {
+			switch top, bottom := light(x, y), light(x, y+1); {
+			case top && bottom:
+				b.WriteRune('█')
+			case top:
+				b.WriteRune('▀')
+			case bottom:
+				b.WriteRune('▄')
+			default:
+				b.WriteRune(' ')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// encodeQR encodes data in byte mode in the smallest version that fits it,
+// with the mask that has the lowest penalty.
+func encodeQR(data []byte) (*qrCode, error) {
+	version := 1
+	for ; version <= maxQRVersion; version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= qrDataCodewords(version)*8 {
+			break
+		}
+	}
+	if version > maxQRVersion {
+		return nil, errQRTooLong
+	}
+
+	// Segment: byte mode, character count and data, then the terminator and
+	// the padding.
+	var bits qrBits
+	bits.append(0x4, 4)
+	if version >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := qrDataCodewords(version) * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+
+	size := version*4 + 17
+	qr := &qrCode{size: size, modules: make([][]bool, size), isFunction: make([][]bool, size)}
+	for i := range size {
+		qr.modules[i] = make([]bool, size)
+		qr.isFunction[i] = make([]bool, size)
+	}
+	qr.drawFunctionPatterns(version)
+	qr.drawCodewords(qrAddECC(codewords, version))
+
+	best, bestPenalty := 0, -1
+	for mask := range 8 {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask) // XOR again to undo it
+	}
+	qr.applyMask(best)
+	qr.drawFormatBits(best)
+	return qr, nil
+}
+
+type qrBits []bool
+
+func (b *qrBits) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, value>>i&1 != 0)
+	}
+}
+
+// qrRawModules returns the number of modules of a version that hold data and
+// error correction codewords.
+func qrRawModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		result -= (25*align-10)*align - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func qrDataCodewords(version int) int {
+	return qrRawModules(version)/8 - qrECCPerBlock[version]*qrBlocks[version]
+}
+
+// qrAddECC splits the data in blocks, adds their error correction codewords
+// and interleaves them.
+func qrAddECC(data []byte, version int) []byte {
+	numBlocks := qrBlocks[version]
+	eccLen := qrECCPerBlock[version]
+	rawCodewords := qrRawModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := qrRSDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortBlockLen - eccLen
+		if i >= numShortBlocks {
+			n++
+		}
+		block := append([]byte(nil), data[k:k+n]...)
+		k += n
+		ecc := qrRSRemainder(block, divisor)
+		if i < numShortBlocks {
+			// Short blocks get a dummy byte to line up with the long ones
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range shortBlockLen + 1 {
+		for j, block := range blocks {
+			if i != shortBlockLen-eccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// qrRSDivisor returns the Reed-Solomon generator polynomial of a degree,
+// without its leading term.
+func qrRSDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for range degree {
+		for j := range result {
+			result[j] = qrGFMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = qrGFMultiply(root, 0x02)
+	}
+	return result
+}
+
+func qrRSRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= qrGFMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// qrGFMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func qrGFMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ z>>7*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+func (qr *qrCode) set(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.isFunction[y][x] = true
+}
+
+func (qr *qrCode) drawFunctionPatterns(version int) {
+	// Timing patterns
+	for i := range qr.size {
+		qr.set(6, i, i%2 == 0)
+		qr.set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns and their separators
+	for _, corner := range [][2]int{{3, 3}, {qr.size - 4, 3}, {3, qr.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x < 0 || y < 0 || x >= qr.size || y >= qr.size {
+					continue
+				}
+				dist := max(abs(dx), abs(dy))
+				qr.set(x, y, dist != 2 && dist != 4)
+			}
+		}
+	}
+
+	// Alignment patterns, except where they overlap the finders
+	positions := qrAlignmentPositions(version)
+	last := len(positions) - 1
+	for i, cy := range positions {
+		for j, cx := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format bits and draw the version bits
+	qr.drawFormatBits(0)
+	if version >= 7 {
+		rem := version
+		for range 12 {
+			rem = rem<<1 ^ rem>>11*0x1F25
+		}
+		bits := version<<12 | rem
+		for i := range 18 {
+			dark := bits>>i&1 != 0
+			a, b := qr.size-11+i%3, i/3
+			qr.set(a, b, dark)
+			qr.set(b, a, dark)
+		}
+	}
+}
+
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	step := (version*4 + count*2 + 1) / (count*2 - 2) * 2
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, version*4+10; i > 0; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// drawFormatBits draws the error correction level, M, and the mask.
+func (qr *qrCode) drawFormatBits(mask int) {
+	data := 0<<3 | mask
+	rem := data
+	for range 10 {
+		rem = rem<<1 ^ rem>>9*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	for i := range 6 {
+		qr.set(8, i, bit(i))
+	}
+	qr.set(8, 7, bit(6))
+	qr.set(8, 8, bit(7))
+	qr.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.set(14-i, 8, bit(i))
+	}
+
+	for i := range 8 {
+		qr.set(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.set(8, qr.size-15+i, bit(i))
+	}
+	qr.set(8, qr.size-8, true)
+}
+
+// drawCodewords fills the modules that aren't function patterns in the
+// zigzag order of the spec.
+func (qr *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range qr.size {
+			for j := range 2 {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if !qr.isFunction[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = data[i>>3]>>(7-i&7)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (qr *qrCode) applyMask(mask int) {
+	for y := range qr.size {
+		for x := range qr.size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !qr.isFunction[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan, the mask with the lowest
+// score is used.
+func (qr *qrCode) penalty() int {
+	result := 0
+	line := func(get func(i int) bool) {
+		run, color := 0, false
+		history := make([]int, 0, qr.size+2)
+		for i := range qr.size {
+			if dark := get(i); i > 0 && dark == color {
+				run++
+				if run == 5 {
+					result += 3
+				} else if run > 5 {
+					result++
+				}
+				continue
+			} else if i > 0 {
+				history = append(history, run)
+			}
+			run, color = 1, get(i)
+		}
+		history = append(history, run)
+		result += qr.finderLikePenalty(history, get(0))
+	}
+	for y := range qr.size {
+		line(func(x int) bool { return qr.modules[y][x] })
+	}
+	for x := range qr.size {
+		line(func(y int) bool { return qr.modules[y][x] })
+	}
+
+	// 2x2 blocks of the same color
+	dark := 0
+	for y := range qr.size {
+		for x := range qr.size {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x+1 < qr.size && y+1 < qr.size {
+				c := qr.modules[y][x]
+				if c == qr.modules[y][x+1] && c == qr.modules[y+1][x] && c == qr.modules[y+1][x+1] {
+					result += 3
+				}
+			}
+		}
+	}
+
+	// Balance of dark and light modules
+	total := qr.size * qr.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return result + k*10
+}
+
+// finderLikePenalty counts the 1:1:3:1:1 patterns with 4 light modules on
+// either side in the runs of a line, the outside of the code being light.
+func (qr *qrCode) finderLikePenalty(runs []int, firstDark bool) int {
+	// Make the runs alternate light, dark, light... with the light border.
+	padded := []int{qr.size}
+	if !firstDark {
+		padded[0] += runs[0]
+		runs = runs[1:]
+	}
+	padded = append(padded, runs...)
+	if len(padded)%2 == 0 {
+		padded = append(padded, qr.size)
+	} else {
+		padded[len(padded)-1] += qr.size
+	}
+
+	result := 0
+	for i := 3; i+3 < len(padded); i += 2 {
+		// padded[i] is the dark run in the middle of the pattern.
+		n := padded[i-1]
+		if n == 0 || padded[i-2] != n || padded[i] != 3*n || padded[i+1] != n || padded[i+2] != n {
+			continue
+		}
+		if padded[i-3] >= 4*n {
+			result += 40
+		}
+		if padded[i+3] >= 4*n {
+			result += 40
+		}
+	}
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -5,38 +5,71 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
-	"io"
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chasedut/toke/internal/config"
 	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/pubsub"
 )
 
 type SessionShare struct {
 	server       *http.Server
 	port         int
 	localURL     string
-	ngrokURL     string
 	sessionID    string
 	ctx          context.Context
 	cancel       context.CancelFunc
-	ngrokProcess *exec.Cmd
-	sseClients   map[chan string]bool
-	buddies      map[string]*Buddy  // Track connected buddies
+	tunnel       Tunnel
 	buddyMsgChan chan BuddyMessage  // Channel for buddy messages
+	messages     message.Service
+
+	// mu guards the tokens, the buddies, the SSE clients, the recent events
+	// and the proposals.
+	mu               sync.RWMutex
+	sseClients       map[*sseClient]struct{}
+	lastEventID      int64
+	recentEvents     []sseEvent
+	buddies          map[string]*Buddy // Track connected buddies
+	proposals        []Proposal
+	approvals        map[string]Approval
+	proposalBroker   *pubsub.Broker[Proposal]
+	viewerToken      string
+	participantToken string
+	hostToken        string
+	expiry           time.Duration
+	expiresAt        time.Time
+	// publicURL is set once the tunnel is open, tunnelErr if it failed to.
+	publicURL string
+	tunnelErr error
 }
 
 type Buddy struct {
 	ID       string
 	Name     string
 	JoinedAt time.Time
+
+	// token authenticates the buddy once they joined.
+	token string
+}
+
+// sseClient is a connection to the /events endpoint.
+type sseClient struct {
+	events  chan sseEvent
+	role    Role
+	buddyID string
+	// done is closed to disconnect the client.
+	done chan struct{}
+	once sync.Once
+}
+
+func (c *sseClient) disconnect() {
+	c.once.Do(func() { close(c.done) })
 }
 
 type BuddyMessage struct {
@@ -48,8 +81,8 @@ type BuddyMessage struct {
 }
 
 type ShareURLs struct {
-	LocalURL string
-	NgrokURL string
+	LocalURL  string
+	PublicURL string
 }
 
 type PageData struct {
@@ -58,110 +91,139 @@ type PageData struct {
 	Model       string
 	Messages    []MessageData
 	LocalURL    string
-	NgrokURL    string
+	PublicURL   string
+	// CanChat is set for participants, who can join the buddy chat.
+	CanChat bool
+	// LastEventID is the last event sent before the messages were read, the
+	// page streams the changes that came after it.
+	LastEventID int64
 }
 
 type MessageData struct {
 	ID        string
 	Role      string
+	Author    string
 	Content   template.HTML
 	Timestamp string
 }
 
-func NewSessionShare(sessionID string) *SessionShare {
+// NewSessionShare returns a share of the session whose links stop working
+// after expiry, or never if expiry is zero. The tunnel exposes it outside of
+// this machine and the changes to the messages of the session are streamed to
+// the browsers.
+func NewSessionShare(sessionID string, expiry time.Duration, tunnel Tunnel, messages message.Service) *SessionShare {
 	return &SessionShare{
-		sessionID:    sessionID,
-		sseClients:   make(map[chan string]bool),
-		buddies:      make(map[string]*Buddy),
-		buddyMsgChan: make(chan BuddyMessage, 100),
+		sessionID:        sessionID,
+		tunnel:           tunnel,
+		messages:         messages,
+		sseClients:       make(map[*sseClient]struct{}),
+		buddies:          make(map[string]*Buddy),
+		approvals:        make(map[string]Approval),
+		proposalBroker:   pubsub.NewBroker[Proposal](),
+		buddyMsgChan:     make(chan BuddyMessage, 100),
+		viewerToken:      newToken(),
+		participantToken: newToken(),
+		hostToken:        newToken(),
+		expiry:           expiry,
 	}
 }
 
 func (s *SessionShare) Start() (*ShareURLs, error) {
 	fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: Starting\n")
-	
+
+	// The host has to set the tunnel up first
+	if err := s.tunnel.Ready(); err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	s.ctx = ctx
 	s.cancel = cancel
+	if s.expiry > 0 {
+		s.mu.Lock()
+		s.expiresAt = time.Now().Add(s.expiry)
+		s.mu.Unlock()
+		go s.expireAfter(s.expiry)
+	}
+	if s.messages != nil {
+		go s.streamMessages(s.messages.Subscribe(ctx))
+	}
 
 	// Set up HTTP endpoints
 	fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: Setting up HTTP endpoints\n")
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", s.handleHome)
-	mux.HandleFunc("/api/messages", s.handleMessagesAPI)
-	mux.HandleFunc("/events", s.handleSSE)
-	mux.HandleFunc("/api/buddy/join", s.handleBuddyJoin)
-	mux.HandleFunc("/api/buddy/message", s.handleBuddyMessage)
-	mux.HandleFunc("/api/buddy/list", s.handleBuddyList)
-
-	// Find an available port and start server
-	listener, err := net.Listen("tcp", "localhost:0")
+	mux.HandleFunc("/", s.requireRole(RoleViewer, s.handleHome))
+	mux.HandleFunc("/api/messages", s.requireRole(RoleViewer, s.handleMessagesAPI))
+	mux.HandleFunc("/events", s.requireRole(RoleViewer, s.handleSSE))
+	mux.HandleFunc("/api/buddy/join", s.requireRole(RoleParticipant, s.handleBuddyJoin))
+	mux.HandleFunc("/api/buddy/message", s.requireRole(RoleParticipant, s.handleBuddyMessage))
+	mux.HandleFunc("/api/buddy/list", s.requireRole(RoleParticipant, s.handleBuddyList))
+	mux.HandleFunc("/api/buddy/propose", s.requireRole(RoleParticipant, s.handleBuddyPropose))
+	mux.HandleFunc("/api/buddy/approvals", s.requireRole(RoleParticipant, s.handleApprovals))
+
+	// Listen where the tunnel forwards to, on a free port unless one is
+	// configured
+	listener, err := s.tunnel.Listen()
 	if err != nil {
+		cancel()
 		fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: Failed to create listener: %v\n", err)
 		return nil, fmt.Errorf("failed to create listener: %w", err)
 	}
-	
-	s.port = listener.Addr().(*net.TCPAddr).Port
+	addr := listener.Addr().(*net.TCPAddr)
+	s.port = addr.Port
 	fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: Got port %d\n", s.port)
-	
-	s.server = &http.Server{
-		Addr:    fmt.Sprintf("localhost:%d", s.port),
-		Handler: mux,
-	}
+
+	s.server = &http.Server{Handler: mux}
 
 	// Start server in background
 	go func() {
-		fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: Starting server on localhost:%d\n", s.port)
-		// Close the temporary listener first
-		listener.Close()
-		// Start the actual server
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: Starting server on %s\n", addr)
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			fmt.Fprintf(os.Stderr, "[DEBUG] Server error: %v\n", err)
 		}
 	}()
 
-	// Wait for server to start
-	time.Sleep(500 * time.Millisecond)
-	
-	// Verify server is running
-	for i := 0; i < 5; i++ {
-		testConn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", s.port))
-		if err == nil {
-			testConn.Close()
-			fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: Server verified on port %d\n", s.port)
-			break
-		}
-		if i == 4 {
-			fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: WARNING - Server not responding on port %d after 5 attempts\n", s.port)
-		}
-		time.Sleep(200 * time.Millisecond)
-	}
-
 	// Get local URL
-	s.localURL = fmt.Sprintf("http://localhost:%d", s.port)
+	host := addr.IP.String()
+	if addr.IP.IsLoopback() {
+		host = "localhost"
+	}
+	s.localURL = fmt.Sprintf("http://%s", net.JoinHostPort(host, fmt.Sprint(s.port)))
 	fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: Local URL: %s\n", s.localURL)
 
-	// Start ngrok asynchronously
-	fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: Starting ngrok async\n")
+	// Open the tunnel asynchronously
 	go func() {
-		fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: ngrok goroutine started\n")
-		if err := s.startNgrok(); err != nil {
-			// Non-fatal - continue without ngrok
-			fmt.Fprintf(os.Stderr, "[DEBUG] Warning: Failed to start ngrok: %v\n", err)
-		} else {
-			fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: ngrok started successfully, URL: %s\n", s.ngrokURL)
+		publicURL, err := s.tunnel.Open(ctx, addr)
+		if err != nil {
+			// Non-fatal - continue without a public URL
+			fmt.Fprintf(os.Stderr, "[DEBUG] Warning: Failed to open %s tunnel: %v\n", s.tunnel.Provider(), err)
 		}
+		s.mu.Lock()
+		s.publicURL, s.tunnelErr = publicURL, err
+		s.mu.Unlock()
 	}()
 
-	// Return immediately with local URL, ngrok URL will be populated later
+	// Return immediately with local URL, public URL will be populated later
 	fmt.Fprintf(os.Stderr, "[DEBUG] SessionShare.Start: Returning URLs\n")
 	return &ShareURLs{
-		LocalURL: s.localURL,
-		NgrokURL: "", // Will be populated asynchronously
+		LocalURL:  s.localURL,
+		PublicURL: "", // Will be populated asynchronously
 	}, nil
 }
 
-func (s *SessionShare) handleHome(w http.ResponseWriter, r *http.Request) {
+func (s *SessionShare) handleHome(w http.ResponseWriter, r *http.Request, a access) {
+	// Keep the token of invite links in a cookie and drop it from the URL
+	if r.URL.Query().Has("token") {
+		s.setTokenCookie(w, a.token)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Changes after this event are streamed to the page
+	s.mu.RLock()
+	lastEventID := s.lastEventID
+	s.mu.RUnlock()
+
 	// Get session from database
 	session, err := db.GetSession(s.sessionID)
 	if err != nil {
@@ -196,6 +258,7 @@ func (s *SessionShare) handleHome(w http.ResponseWriter, r *http.Request) {
 		messageData[i] = MessageData{
 			ID:        msg.ID,
 			Role:      msg.Role,
+			Author:    msg.Author,
 			Content:   template.HTML(textContent), // Display text, not JSON
 			Timestamp: timestamp,
 		}
@@ -207,7 +270,9 @@ func (s *SessionShare) handleHome(w http.ResponseWriter, r *http.Request) {
 		Model:        modelName,
 		Messages:     messageData,
 		LocalURL:     s.localURL,
-		NgrokURL:     s.ngrokURL,
+		PublicURL:    s.PublicURL(),
+		CanChat:      a.role.canAccess(RoleParticipant),
+		LastEventID:  lastEventID,
 	}
 
 	tmpl := template.Must(template.New("index").Parse(htmlTemplate))
@@ -215,7 +280,7 @@ func (s *SessionShare) handleHome(w http.ResponseWriter, r *http.Request) {
 	tmpl.Execute(w, pageData)
 }
 
-func (s *SessionShare) handleMessagesAPI(w http.ResponseWriter, r *http.Request) {
+func (s *SessionShare) handleMessagesAPI(w http.ResponseWriter, r *http.Request, _ access) {
 	// Get messages from database
 	messages, err := db.GetSessionMessages(s.sessionID)
 	if err != nil {
@@ -224,193 +289,26 @@ func (s *SessionShare) handleMessagesAPI(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Convert to API format - send raw data for client-side rendering
-	apiMessages := make([]map[string]interface{}, 0, len(messages))
-	for _, msg := range messages {
-		timestamp := time.Unix(msg.CreatedAt, 0).Format("15:04:05")
-		apiMsg := map[string]interface{}{
-			"id":        msg.ID,
-			"role":      msg.Role,
-			"parts":     msg.Parts, // Raw JSON parts data
-			"timestamp": timestamp,
-		}
-		apiMessages = append(apiMessages, apiMsg)
+	apiMessages := make([]apiMessage, len(messages))
+	for i, msg := range messages {
+		apiMessages[i] = fromDBMessage(msg)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(apiMessages)
 }
 
-func (s *SessionShare) handleSSE(w http.ResponseWriter, r *http.Request) {
-	// Set headers for SSE
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	// Create a channel for this client
-	clientChan := make(chan string)
-	s.sseClients[clientChan] = true
-	defer func() {
-		delete(s.sseClients, clientChan)
-		close(clientChan)
-	}()
-
-	// Send initial connection message
-	fmt.Fprintf(w, "data: {\"type\":\"connected\"}\n\n")
-	w.(http.Flusher).Flush()
-
-	// Keep connection alive
-	for {
-		select {
-		case msg := <-clientChan:
-			fmt.Fprintf(w, "data: %s\n\n", msg)
-			w.(http.Flusher).Flush()
-		case <-r.Context().Done():
-			return
-		case <-s.ctx.Done():
-			return
-		}
-	}
-}
-
-func (s *SessionShare) BroadcastUpdate() {
-	// Get latest messages
-	messages, err := db.GetSessionMessages(s.sessionID)
-	if err != nil {
-		return
-	}
-
-	// Convert to JSON - send raw data for client-side rendering
-	apiMessages := make([]map[string]interface{}, 0, len(messages))
-	for _, msg := range messages {
-		timestamp := time.Unix(msg.CreatedAt, 0).Format("15:04:05")
-		apiMsg := map[string]interface{}{
-			"id":        msg.ID,
-			"role":      msg.Role,
-			"parts":     msg.Parts, // Raw JSON parts data
-			"timestamp": timestamp,
-		}
-		apiMessages = append(apiMessages, apiMsg)
-	}
-
-	data, _ := json.Marshal(map[string]interface{}{
-		"type":     "messages",
-		"messages": apiMessages,
-	})
-
-	// Send to all SSE clients
+// disconnectClients closes the SSE connections of the matching clients.
+func (s *SessionShare) disconnectClients(match func(*sseClient) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	for client := range s.sseClients {
-		select {
-		case client <- string(data):
-		default:
-			// Client buffer full, skip
+		if match(client) {
+			client.disconnect()
 		}
 	}
 }
 
-func (s *SessionShare) startNgrok() error {
-	// Look for ngrok in multiple locations
-	ngrokPath := s.findNgrok()
-	if ngrokPath == "" {
-		return fmt.Errorf("ngrok not found. Please install ngrok or rebuild with --all flag")
-	}
-
-	// Check if ngrok is authenticated
-	if !s.isNgrokAuthenticated(ngrokPath) {
-		// Check environment variable first
-		authToken := os.Getenv("NGROK_AUTHTOKEN")
-		if authToken != "" {
-			// Configure ngrok with the token
-			configCmd := exec.Command(ngrokPath, "config", "add-authtoken", authToken)
-			if err := configCmd.Run(); err != nil {
-				return fmt.Errorf("failed to configure ngrok auth: %w", err)
-			}
-		} else {
-			// Return error indicating auth is needed
-			return fmt.Errorf("ngrok authentication required. Please set NGROK_AUTHTOKEN environment variable or run: ngrok config add-authtoken <token>")
-		}
-	}
-
-	// Start ngrok
-	ngrokCmd := fmt.Sprintf("%s http %d", ngrokPath, s.port)
-	fmt.Fprintf(os.Stderr, "[DEBUG] startNgrok: Running command: %s\n", ngrokCmd)
-	s.ngrokProcess = exec.CommandContext(s.ctx, ngrokPath, "http", fmt.Sprintf("%d", s.port))
-	if err := s.ngrokProcess.Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "[DEBUG] startNgrok: Failed to start ngrok: %v\n", err)
-		return fmt.Errorf("failed to start ngrok: %w", err)
-	}
-	fmt.Fprintf(os.Stderr, "[DEBUG] startNgrok: ngrok process started, PID: %d\n", s.ngrokProcess.Process.Pid)
-
-	// Retry getting ngrok URL with exponential backoff
-	maxRetries := 5
-	for i := 0; i < maxRetries; i++ {
-		// Wait before checking (exponential backoff)
-		time.Sleep(time.Duration(500*(i+1)) * time.Millisecond)
-		
-		// Get ngrok URL from API
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		
-		req, err := http.NewRequestWithContext(ctx, "GET", "http://localhost:4040/api/tunnels", nil)
-		if err != nil {
-			if i == maxRetries-1 {
-				return fmt.Errorf("failed to create request for ngrok tunnels: %w", err)
-			}
-			continue // Retry
-		}
-		
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			if i == maxRetries-1 {
-				return fmt.Errorf("failed to get ngrok tunnels after %d retries: %w", maxRetries, err)
-			}
-			continue // Retry
-		}
-		
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			if i == maxRetries-1 {
-				return fmt.Errorf("failed to read ngrok response: %w", err)
-			}
-			continue // Retry
-		}
-
-		var tunnels struct {
-			Tunnels []struct {
-				PublicURL string `json:"public_url"`
-				Proto     string `json:"proto"`
-			} `json:"tunnels"`
-		}
-
-		if err := json.Unmarshal(body, &tunnels); err != nil {
-			if i == maxRetries-1 {
-				return fmt.Errorf("failed to parse ngrok response: %w", err)
-			}
-			continue // Retry
-		}
-
-		// Find HTTPS tunnel
-		for _, tunnel := range tunnels.Tunnels {
-			if tunnel.Proto == "https" {
-				s.ngrokURL = tunnel.PublicURL
-				break
-			}
-		}
-
-		if s.ngrokURL == "" && len(tunnels.Tunnels) > 0 {
-			s.ngrokURL = tunnels.Tunnels[0].PublicURL
-		}
-		
-		// If we got a URL, we're done
-		if s.ngrokURL != "" {
-			break
-		}
-	}
-
-	return nil
-}
-
 func (s *SessionShare) Stop() error {
 	if s.cancel != nil {
 		s.cancel()
@@ -422,48 +320,45 @@ func (s *SessionShare) Stop() error {
 		s.server.Shutdown(ctx)
 	}
 
-	if s.ngrokProcess != nil && s.ngrokProcess.Process != nil {
-		if runtime.GOOS == "windows" {
-			s.ngrokProcess.Process.Kill()
-		} else {
-			s.ngrokProcess.Process.Signal(os.Interrupt)
-		}
-		s.ngrokProcess.Wait()
+	if s.tunnel != nil {
+		return s.tunnel.Close()
 	}
-
 	return nil
 }
 
-// StartBroadcastLoop starts a goroutine that periodically broadcasts updates
-func (s *SessionShare) StartBroadcastLoop() {
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-	
-	for {
-		select {
-		case <-ticker.C:
-			s.BroadcastUpdate()
-		case <-s.ctx.Done():
-			// Context cancelled, exit the goroutine
-			return
-		}
-	}
+// SessionID returns the ID of the shared session.
+func (s *SessionShare) SessionID() string {
+	return s.sessionID
 }
 
 func (s *SessionShare) GetURLs() *ShareURLs {
 	return &ShareURLs{
-		LocalURL: s.localURL,
-		NgrokURL: s.ngrokURL,
+		LocalURL:  s.localURL,
+		PublicURL: s.PublicURL(),
 	}
 }
 
-// GetNgrokURL returns the current ngrok URL (may be empty if still starting)
-func (s *SessionShare) GetNgrokURL() string {
-	return s.ngrokURL
+// PublicURL returns the URL of the tunnel, empty until it is open.
+func (s *SessionShare) PublicURL() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.publicURL
+}
+
+// Provider returns the tunnel provider of the share.
+func (s *SessionShare) Provider() config.TunnelProvider {
+	return s.tunnel.Provider()
+}
+
+// TunnelError returns why the tunnel failed to open, if it did.
+func (s *SessionShare) TunnelError() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tunnelErr
 }
 
 // Buddy chat handlers
-func (s *SessionShare) handleBuddyJoin(w http.ResponseWriter, r *http.Request) {
+func (s *SessionShare) handleBuddyJoin(w http.ResponseWriter, r *http.Request, a access) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -484,13 +379,23 @@ func (s *SessionShare) handleBuddyJoin(w http.ResponseWriter, r *http.Request) {
 		ID:       buddyID,
 		Name:     req.Name,
 		JoinedAt: time.Now(),
+		token:    newToken(),
 	}
 	
+	s.mu.Lock()
+	if a.buddy != nil {
+		// Rejoining replaces the previous buddy of this browser
+		delete(s.buddies, a.buddy.ID)
+	}
 	s.buddies[buddyID] = buddy
+	s.mu.Unlock()
 	
 	// Broadcast buddy joined event
 	s.broadcastBuddyEvent("buddy_joined", buddy)
 	
+	// From now on the browser authenticates as this buddy, so revoking the
+	// buddy cuts it off
+	s.setTokenCookie(w, buddy.token)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"id":   buddyID,
@@ -498,11 +403,15 @@ func (s *SessionShare) handleBuddyJoin(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (s *SessionShare) handleBuddyMessage(w http.ResponseWriter, r *http.Request) {
+func (s *SessionShare) handleBuddyMessage(w http.ResponseWriter, r *http.Request, a access) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if a.buddy == nil {
+		http.Error(w, "Join the chat first", http.StatusForbidden)
+		return
+	}
 
 	var msg BuddyMessage
 	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
@@ -510,6 +419,9 @@ func (s *SessionShare) handleBuddyMessage(w http.ResponseWriter, r *http.Request
 		return
 	}
 	
+	msg.FromID = a.buddy.ID
+	msg.FromName = a.buddy.Name
+	msg.ToID = "host"
 	msg.Time = time.Now()
 	
 	// Broadcast buddy message
@@ -519,11 +431,8 @@ func (s *SessionShare) handleBuddyMessage(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
 
-func (s *SessionShare) handleBuddyList(w http.ResponseWriter, r *http.Request) {
-	buddyList := make([]*Buddy, 0, len(s.buddies))
-	for _, buddy := range s.buddies {
-		buddyList = append(buddyList, buddy)
-	}
+func (s *SessionShare) handleBuddyList(w http.ResponseWriter, r *http.Request, _ access) {
+	buddyList := s.GetBuddies()
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(buddyList)
@@ -536,12 +445,7 @@ func (s *SessionShare) broadcastBuddyEvent(eventType string, buddy *Buddy) {
 		"timestamp": time.Now().Format("15:04:05"),
 	})
 	
-	for client := range s.sseClients {
-		select {
-		case client <- string(data):
-		default:
-		}
-	}
+	s.broadcast(string(data))
 }
 
 func (s *SessionShare) broadcastBuddyMessage(msg BuddyMessage) {
@@ -558,16 +462,13 @@ func (s *SessionShare) broadcastBuddyMessage(msg BuddyMessage) {
 		"message": msg,
 	})
 	
-	for client := range s.sseClients {
-		select {
-		case client <- string(data):
-		default:
-		}
-	}
+	s.broadcast(string(data))
 }
 
 // GetBuddies returns the list of connected buddies
 func (s *SessionShare) GetBuddies() []*Buddy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	buddyList := make([]*Buddy, 0, len(s.buddies))
 	for _, buddy := range s.buddies {
 		buddyList = append(buddyList, buddy)
@@ -603,102 +504,6 @@ func (s *SessionShare) GetPendingMessages() []BuddyMessage {
 	}
 }
 
-// GetNgrokPath returns the path to ngrok if found
-func (s *SessionShare) GetNgrokPath() string {
-	return s.findNgrok()
-}
-
-// isNgrokAuthenticated checks if ngrok has an auth token configured
-func (s *SessionShare) isNgrokAuthenticated(ngrokPath string) bool {
-	// First check environment variable
-	if os.Getenv("NGROK_AUTHTOKEN") != "" {
-		return true
-	}
-	
-	// Try to check ngrok config
-	// ngrok v3 uses "ngrok config check" to verify configuration
-	cmd := exec.Command(ngrokPath, "config", "check")
-	output, err := cmd.CombinedOutput()
-	
-	// If command succeeded, check output
-	if err == nil {
-		outputStr := string(output)
-		// Valid config will contain "Valid configuration"
-		if strings.Contains(outputStr, "Valid") {
-			return true
-		}
-	}
-	
-	// Try alternative method: check if config file exists and has authtoken
-	// This works for ngrok v2 and v3
-	configCheckCmd := exec.Command(ngrokPath, "authtoken", "--log=false")
-	if err := configCheckCmd.Run(); err != nil {
-		// If this fails with specific error about missing token, not authenticated
-		if strings.Contains(err.Error(), "authtoken") {
-			return false
-		}
-	}
-	
-	// Default to false if we can't determine
-	return false
-}
-
-// findNgrok looks for ngrok in various locations
-func (s *SessionShare) findNgrok() string {
-	// Get the directory where the executable is located
-	exePath, err := os.Executable()
-	if err != nil {
-		exePath = ""
-	}
-	exeDir := filepath.Dir(exePath)
-	
-	// Check locations in order of preference
-	var locations []string
-	
-	if runtime.GOOS == "windows" {
-		locations = []string{
-			// Same directory as executable
-			filepath.Join(exeDir, "ngrok.exe"),
-			// Build directory relative to exe
-			filepath.Join(exeDir, "build", "ngrok.exe"),
-			// Current working directory
-			"./ngrok.exe",
-			"./build/ngrok.exe",
-			// System PATH
-			"ngrok.exe",
-		}
-	} else {
-		locations = []string{
-			// Same directory as executable
-			filepath.Join(exeDir, "ngrok"),
-			// Build directory relative to exe
-			filepath.Join(exeDir, "build", "ngrok"),
-			// Current working directory
-			"./ngrok",
-			"./build/ngrok",
-			// System PATH
-			"ngrok",
-		}
-	}
-	
-	for _, loc := range locations {
-		if loc == "ngrok" || loc == "ngrok.exe" {
-			// Check system PATH
-			if path, err := exec.LookPath(loc); err == nil {
-				return path
-			}
-		} else {
-			// Check file existence
-			if _, err := os.Stat(loc); err == nil {
-				absPath, _ := filepath.Abs(loc)
-				return absPath
-			}
-		}
-	}
-	
-	return ""
-}
-
 // extractTextFromJSONParts extracts and formats text from JSON message parts
 func extractTextFromJSONParts(partsJSON string) string {
 	var parts []interface{}
@@ -1143,6 +948,11 @@ const htmlTemplate = `<!DOCTYPE html>
             color: var(--text-tertiary);
         }
         
+        .message-author {
+            font-size: 0.75rem;
+            color: var(--text-secondary);
+        }
+        
         .message-content {
             font-size: 0.9375rem;
             line-height: 1.7;
@@ -1538,6 +1348,31 @@ const htmlTemplate = `<!DOCTYPE html>
             display: none;
         }
         
+        .buddy-approvals {
+            padding: 0.5rem 1rem;
+            border-top: 1px solid var(--border-color);
+        }
+        
+        .buddy-approval {
+            display: flex;
+            align-items: center;
+            gap: 0.5rem;
+            font-size: 0.8125rem;
+            padding: 0.25rem 0;
+        }
+        
+        .buddy-approval-text {
+            flex: 1;
+            overflow: hidden;
+            text-overflow: ellipsis;
+            white-space: nowrap;
+        }
+        
+        .buddy-message.system .buddy-message-text {
+            color: var(--text-tertiary);
+            font-style: italic;
+        }
+        
         @keyframes fadeIn {
             from { opacity: 0; transform: translateY(10px); }
             to { opacity: 1; transform: translateY(0); }
@@ -1575,6 +1410,7 @@ const htmlTemplate = `<!DOCTYPE html>
                                 <div class="message-main">
                                     <div class="message-header">
                                         <span class="message-role">{{.Role}}</span>
+                                        {{if .Author}}<span class="message-author">proposed by {{.Author}}</span>{{end}}
                                         <span class="message-time">{{.Timestamp}}</span>
                                     </div>
                                     <div class="message-content">{{.Content}}</div>
@@ -1594,6 +1430,7 @@ const htmlTemplate = `<!DOCTYPE html>
     </main>
     
     <!-- Buddy Chat -->
+    {{if .CanChat}}
     <div class="buddy-chat-container" id="buddyChat">
         <div class="buddy-chat-header" onclick="toggleBuddyChat()">
             <div class="buddy-chat-title">
@@ -1612,15 +1449,18 @@ const htmlTemplate = `<!DOCTYPE html>
         <!-- Chat Interface (shown when connected) -->
         <div id="buddyChatInterface" style="display: none; flex: 1; display: flex; flex-direction: column;">
             <div class="buddy-messages" id="buddyMessages"></div>
+            <div class="buddy-approvals" id="buddyApprovals" style="display: none;"></div>
             <div class="buddy-input-container">
                 <input type="text" class="buddy-input" id="buddyMessageInput" placeholder="Type a message..." onkeypress="if(event.key==='Enter') sendBuddyMessage()">
                 <button class="buddy-send-btn" onclick="sendBuddyMessage()">Send</button>
+                <button class="buddy-send-btn" onclick="proposePrompt()" title="Ask the host to run this as a prompt">Propose</button>
             </div>
         </div>
     </div>
+    {{end}}
     
     <script>
-        let lastMessageCount = {{len .Messages}};
+        let lastEventId = {{.LastEventID}};
         let eventSource;
         let isUserAtBottom = true;
         
@@ -1834,6 +1674,8 @@ const htmlTemplate = `<!DOCTYPE html>
             
             // Format the message content
             const formattedContent = formatMessageContent(msg.parts, msg.role);
+            const author = msg.author ?
+                '<span class="message-author">proposed by ' + escapeHTML(msg.author) + '</span>' : '';
             
             messageGroup.innerHTML = '<div class="message ' + msg.role + '" data-id="' + msg.id + '">' +
                 '<div class="message-inner">' +
@@ -1841,6 +1683,7 @@ const htmlTemplate = `<!DOCTYPE html>
                     '<div class="message-main">' +
                         '<div class="message-header">' +
                             '<span class="message-role">' + msg.role + '</span>' +
+                            author +
                             '<span class="message-time">' + msg.timestamp + '</span>' +
                         '</div>' +
                         '<div class="message-content">' + formattedContent + '</div>' +
@@ -1895,10 +1738,8 @@ const htmlTemplate = `<!DOCTYPE html>
             pre.insertBefore(codeHeader, pre.firstChild);
         }
         
-        // Update messages display
-        function updateMessages(messages) {
-            if (messages.length === lastMessageCount) return;
-            
+        // Add a message, or replace it if it is already shown
+        function upsertMessage(msg) {
             const container = document.getElementById('messages');
             
             // Remove empty state if it exists
@@ -1907,52 +1748,85 @@ const htmlTemplate = `<!DOCTYPE html>
                 emptyState.remove();
             }
             
-            // Add new messages
-            for (let i = lastMessageCount; i < messages.length; i++) {
-                const msg = messages[i];
-                const messageElement = createMessageElement(msg);
+            const messageElement = createMessageElement(msg);
+            const existing = container.querySelector('.message[data-id="' + CSS.escape(msg.id) + '"]');
+            if (existing) {
+                existing.closest('.message-group').replaceWith(messageElement);
+            } else {
                 container.appendChild(messageElement);
-                
-                // Auto-scroll if user was at bottom
-                if (isUserAtBottom) {
-                    scrollToBottom();
-                }
             }
             
-            lastMessageCount = messages.length;
+            // Auto-scroll if user was at bottom
+            if (isUserAtBottom) {
+                scrollToBottom();
+            }
+        }
+        
+        function removeMessage(msg) {
+            const existing = document.querySelector('.message[data-id="' + CSS.escape(msg.id) + '"]');
+            if (existing) {
+                existing.closest('.message-group').remove();
+            }
         }
         
-        // ===== SSE AND POLLING =====
+        // Replace every message, when the page missed too many changes
+        function replaceMessages(messages) {
+            const container = document.getElementById('messages');
+            container.innerHTML = '';
+            messages.forEach(upsertMessage);
+        }
+        
+        // ===== SSE =====
         
         function connectSSE() {
-            eventSource = new EventSource('/events');
+            // The server sends what happened after lastEventId, EventSource
+            // sends it again by itself when it reconnects
+            eventSource = new EventSource('/events?last_event_id=' + lastEventId);
             
             eventSource.onmessage = function(event) {
+                if (event.lastEventId) {
+                    lastEventId = Number(event.lastEventId);
+                }
                 const data = JSON.parse(event.data);
                 
                 if (data.type === 'connected') {
                     updateStatus('Live', 'var(--success)');
-                } else if (data.type === 'messages') {
-                    updateMessages(data.messages);
+                } else if (data.type === 'snapshot') {
+                    replaceMessages(data.messages);
+                } else if (data.type === 'message_created' || data.type === 'message_updated') {
+                    upsertMessage(data.message);
+                } else if (data.type === 'message_deleted') {
+                    removeMessage(data.message);
+                } else {
+                    handleBuddyEvent(data);
                 }
             };
             
             eventSource.onerror = function() {
-                updateStatus('Reconnecting', 'var(--warning)');
-                setTimeout(connectSSE, 5000);
+                if (eventSource.readyState !== EventSource.CLOSED) {
+                    // EventSource reconnects by itself
+                    updateStatus('Reconnecting', 'var(--warning)');
+                    return;
+                }
+                checkAccess();
             };
         }
         
-        // Poll for updates as backup
-        function pollMessages() {
-            fetch('/api/messages')
-                .then(res => res.json())
-                .then(messages => {
-                    updateMessages(messages);
+        // Find out why the stream was closed: reconnect unless the link was
+        // rotated, the buddy revoked or the share expired
+        function checkAccess() {
+            fetch('/api/messages', { method: 'HEAD' })
+                .then(res => {
+                    if (res.status === 401 || res.status === 410) {
+                        updateStatus('Access ended', 'var(--error)');
+                        return;
+                    }
+                    updateStatus('Reconnecting', 'var(--warning)');
+                    setTimeout(connectSSE, 5000);
                 })
-                .catch(err => {
-                    console.error('Poll error:', err);
+                .catch(() => {
                     updateStatus('Connection Error', 'var(--error)');
+                    setTimeout(connectSSE, 5000);
                 });
         }
         
@@ -1981,9 +1855,6 @@ const htmlTemplate = `<!DOCTYPE html>
             // Connect SSE
             connectSSE();
             
-            // Poll every 3 seconds as backup
-            setInterval(pollMessages, 3000);
-            
             // Auto-scroll to bottom on load
             scrollToBottom('auto');
         });
@@ -2039,6 +1910,7 @@ const htmlTemplate = `<!DOCTYPE html>
                 document.getElementById('buddyStatus').textContent = 'Connected as ' + buddyName;
                 document.getElementById('buddyJoinForm').style.display = 'none';
                 document.getElementById('buddyChatInterface').style.display = 'flex';
+                loadApprovals();
                 
                 // Notify host via SSE
                 console.log('Joined as buddy:', buddyName);
@@ -2091,31 +1963,123 @@ const htmlTemplate = `<!DOCTYPE html>
             messagesContainer.scrollTop = messagesContainer.scrollHeight;
         }
         
-        // Listen for buddy events via SSE
-        if (eventSource) {
-            eventSource.addEventListener('message', (event) => {
-                try {
-                    const data = JSON.parse(event.data);
-                    if (data.type === 'buddy_message') {
-                        const msg = data.message;
-                        // Show messages that are:
-                        // 1. From this buddy (their own messages coming back)
-                        // 2. From host to this buddy or to all buddies
-                        if (msg.from_id === buddyId || 
-                            (msg.from_id === 'host' && (msg.to_id === buddyId || msg.to_id === 'all'))) {
-                            addBuddyMessage(
-                                msg.from_name,
-                                msg.message,
-                                new Date(msg.time || Date.now()).toLocaleTimeString([], {hour: '2-digit', minute: '2-digit'}),
-                                msg.from_id === 'host'
-                            );
-                        }
-                    }
-                } catch (e) {
-                    // Ignore parsing errors
+        function addSystemMessage(text) {
+            const messagesContainer = document.getElementById('buddyMessages');
+            const messageDiv = document.createElement('div');
+            messageDiv.className = 'buddy-message system';
+            const textDiv = document.createElement('div');
+            textDiv.className = 'buddy-message-text';
+            textDiv.textContent = text;
+            messageDiv.appendChild(textDiv);
+            messagesContainer.appendChild(messageDiv);
+            messagesContainer.scrollTop = messagesContainer.scrollHeight;
+        }
+        
+        function escapeHTML(text) {
+            const div = document.createElement('div');
+            div.textContent = text;
+            return div.innerHTML;
+        }
+        
+        // ===== PROPOSALS =====
+        
+        // Tool calls waiting for the host's permission, by ID
+        const approvals = new Map();
+        
+        function propose(body) {
+            return fetch('/api/buddy/propose', {
+                method: 'POST',
+                headers: {'Content-Type': 'application/json'},
+                body: JSON.stringify(body)
+            }).then(res => {
+                if (!res.ok) {
+                    return res.text().then(text => { throw new Error(text.trim()); });
                 }
+                return res.json();
             });
         }
+        
+        // Ask the host to run the message input as a prompt
+        function proposePrompt() {
+            const input = document.getElementById('buddyMessageInput');
+            const prompt = input.value.trim();
+            if (!prompt || !buddyId) return;
+            
+            propose({prompt: prompt})
+                .then(() => {
+                    input.value = '';
+                    addSystemMessage('Prompt sent to the host for review: ' + prompt);
+                })
+                .catch(err => addSystemMessage('Could not propose the prompt: ' + err.message));
+        }
+        
+        function vote(approval, allow) {
+            propose({tool_call_id: approval.tool_call_id, allow: allow})
+                .then(() => addSystemMessage('You voted to ' + (allow ? 'allow' : 'deny') + ' ' + approval.tool_name))
+                .catch(err => addSystemMessage('Could not vote: ' + err.message));
+        }
+        
+        function loadApprovals() {
+            fetch('/api/buddy/approvals')
+                .then(res => res.ok ? res.json() : [])
+                .then(list => {
+                    approvals.clear();
+                    list.forEach(approval => approvals.set(approval.tool_call_id, approval));
+                    renderApprovals();
+                });
+        }
+        
+        function renderApprovals() {
+            const container = document.getElementById('buddyApprovals');
+            container.innerHTML = '';
+            container.style.display = approvals.size > 0 ? 'block' : 'none';
+            approvals.forEach(approval => {
+                const row = document.createElement('div');
+                row.className = 'buddy-approval';
+                const text = document.createElement('span');
+                text.className = 'buddy-approval-text';
+                text.textContent = approval.tool_name + ': ' + approval.description;
+                text.title = approval.description;
+                row.appendChild(text);
+                [['Allow', true], ['Deny', false]].forEach(([label, allow]) => {
+                    const button = document.createElement('button');
+                    button.className = 'buddy-send-btn';
+                    button.textContent = label;
+                    button.onclick = () => vote(approval, allow);
+                    row.appendChild(button);
+                });
+                container.appendChild(row);
+            });
+        }
+        
+        // Handle buddy events received via SSE
+        function handleBuddyEvent(data) {
+            if (!document.getElementById('buddyChat')) return;
+            
+            if (data.type === 'buddy_message') {
+                const msg = data.message;
+                // Show messages that are:
+                // 1. From this buddy (their own messages coming back)
+                // 2. From host to this buddy or to all buddies
+                if (msg.from_id === buddyId || 
+                    (msg.from_id === 'host' && (msg.to_id === buddyId || msg.to_id === 'all'))) {
+                    addBuddyMessage(
+                        escapeHTML(msg.from_name),
+                        escapeHTML(msg.message),
+                        new Date(msg.time || Date.now()).toLocaleTimeString([], {hour: '2-digit', minute: '2-digit'}),
+                        msg.from_id === 'host'
+                    );
+                }
+            } else if (data.type === 'approval_requested') {
+                approvals.set(data.approval.tool_call_id, data.approval);
+                renderApprovals();
+            } else if (data.type === 'approval_resolved') {
+                approvals.delete(data.tool_call_id);
+                renderApprovals();
+            } else if (data.type === 'proposal_resolved' && data.proposal.buddy_id === buddyId) {
+                addSystemMessage('The host ' + (data.accepted ? 'accepted' : 'dismissed') + ' your prompt: ' + data.proposal.prompt);
+            }
+        }
     </script>
 </body>
 </html>`
\ No newline at end of file
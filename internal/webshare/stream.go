@@ -0,0 +1,245 @@
+package webshare
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/chasedut/toke/internal/db"
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/pubsub"
+)
+
+const (
+	// maxRecentEvents is how many events are kept for the browsers that
+	// reconnect. A browser that missed more gets a snapshot instead.
+	maxRecentEvents = 512
+	// messageUpdateInterval is how often the updates of streaming messages
+	// are sent, the tokens that arrive in between are sent together.
+	messageUpdateInterval = 100 * time.Millisecond
+	// clientBuffer is how many events can wait for a slow browser before it
+	// is disconnected.
+	clientBuffer = 256
+)
+
+// sseEvent is an event of the /events stream. Events with an ID can be
+// replayed to the browsers that reconnect with a Last-Event-ID.
+type sseEvent struct {
+	id   int64
+	data string
+}
+
+// apiMessage is a message of the shared session as the page renders it.
+type apiMessage struct {
+	ID        string `json:"id"`
+	Role      string `json:"role"`
+	Author    string `json:"author,omitempty"`
+	Parts     string `json:"parts"` // Raw JSON parts data
+	Timestamp string `json:"timestamp"`
+}
+
+func fromDBMessage(msg db.Message) apiMessage {
+	return apiMessage{
+		ID:        msg.ID,
+		Role:      msg.Role,
+		Author:    msg.Author,
+		Parts:     msg.Parts,
+		Timestamp: time.Unix(msg.CreatedAt, 0).Format("15:04:05"),
+	}
+}
+
+func fromMessage(msg message.Message) apiMessage {
+	parts, _ := message.MarshalParts(msg.Parts)
+	return apiMessage{
+		ID:        msg.ID,
+		Role:      string(msg.Role),
+		Author:    msg.Author,
+		Parts:     string(parts),
+		Timestamp: time.Unix(msg.CreatedAt, 0).Format("15:04:05"),
+	}
+}
+
+// broadcast sends an event to every SSE client and keeps it for the ones that
+// reconnect. A client that can't keep up is disconnected, it catches up when
+// it reconnects.
+func (s *SessionShare) broadcast(data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastEventID++
+	event := sseEvent{id: s.lastEventID, data: data}
+	s.recentEvents = append(s.recentEvents, event)
+	if len(s.recentEvents) > maxRecentEvents {
+		s.recentEvents = s.recentEvents[len(s.recentEvents)-maxRecentEvents:]
+	}
+	for client := range s.sseClients {
+		select {
+		case client.events <- event:
+		default:
+			client.disconnect()
+		}
+	}
+}
+
+// eventsAfter returns the events sent after the given one, or false if some
+// of them aren't kept anymore. It must be called with mu held.
+func (s *SessionShare) eventsAfter(id int64) ([]sseEvent, bool) {
+	if id > s.lastEventID {
+		return nil, false
+	}
+	if id == s.lastEventID {
+		return nil, true
+	}
+	if len(s.recentEvents) == 0 || s.recentEvents[0].id > id+1 {
+		return nil, false
+	}
+	missed := s.recentEvents[id+1-s.recentEvents[0].id:]
+	return append([]sseEvent(nil), missed...), true
+}
+
+// lastEventIDOf returns the last event a browser received: the Last-Event-ID
+// header EventSource sends when it reconnects, or the last_event_id parameter
+// the page connects with the first time.
+func lastEventIDOf(r *http.Request) (int64, bool) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	return id, err == nil && id >= 0
+}
+
+func (s *SessionShare) handleSSE(w http.ResponseWriter, r *http.Request, a access) {
+	// Set headers for SSE
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	// Register this client, along with what it missed
+	client := &sseClient{
+		events: make(chan sseEvent, clientBuffer),
+		role:   a.role,
+		done:   make(chan struct{}),
+	}
+	if a.buddy != nil {
+		client.buddyID = a.buddy.ID
+	}
+	s.mu.Lock()
+	s.sseClients[client] = struct{}{}
+	lastID, resumed := lastEventIDOf(r)
+	var missed []sseEvent
+	if resumed {
+		missed, resumed = s.eventsAfter(lastID)
+	}
+	currentID := s.lastEventID
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.sseClients, client)
+		s.mu.Unlock()
+	}()
+
+	// Send initial connection message
+	fmt.Fprintf(w, "data: {\"type\":\"connected\"}\n\n")
+
+	// Catch up with the events the client missed, or with all the messages
+	// if they aren't kept anymore. The host has them already.
+	if resumed {
+		for _, event := range missed {
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.id, event.data)
+		}
+	} else if a.role == RoleHost {
+		fmt.Fprintf(w, "id: %d\n\n", currentID)
+	} else if snapshot, err := s.snapshot(); err == nil {
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", currentID, snapshot)
+	}
+	w.(http.Flusher).Flush()
+
+	// Keep connection alive
+	for {
+		select {
+		case event := <-client.events:
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.id, event.data)
+			w.(http.Flusher).Flush()
+		case <-client.done:
+			return
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// snapshot returns an event with every message of the session.
+func (s *SessionShare) snapshot() (string, error) {
+	messages, err := db.GetSessionMessages(s.sessionID)
+	if err != nil {
+		return "", err
+	}
+	apiMessages := make([]apiMessage, len(messages))
+	for i, msg := range messages {
+		apiMessages[i] = fromDBMessage(msg)
+	}
+	data, err := json.Marshal(map[string]any{
+		"type":     "snapshot",
+		"messages": apiMessages,
+	})
+	return string(data), err
+}
+
+// streamMessages sends the changes to the messages of the session to the
+// browsers until the share stops. The updates of a streaming message are sent
+// at most every messageUpdateInterval, with its latest content.
+func (s *SessionShare) streamMessages(events <-chan pubsub.Event[message.Message]) {
+	ticker := time.NewTicker(messageUpdateInterval)
+	defer ticker.Stop()
+
+	var order []string
+	pending := make(map[string]message.Message)
+	flush := func() {
+		for _, id := range order {
+			s.broadcastMessage("message_updated", pending[id])
+		}
+		order = order[:0]
+		clear(pending)
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Payload.SessionID != s.sessionID {
+				continue
+			}
+			switch event.Type {
+			case pubsub.UpdatedEvent:
+				if _, ok := pending[event.Payload.ID]; !ok {
+					order = append(order, event.Payload.ID)
+				}
+				pending[event.Payload.ID] = event.Payload
+			case pubsub.CreatedEvent:
+				flush()
+				s.broadcastMessage("message_created", event.Payload)
+			case pubsub.DeletedEvent:
+				flush()
+				s.broadcastMessage("message_deleted", event.Payload)
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *SessionShare) broadcastMessage(eventType string, msg message.Message) {
+	data, _ := json.Marshal(map[string]any{
+		"type":    eventType,
+		"message": fromMessage(msg),
+	})
+	s.broadcast(string(data))
+}
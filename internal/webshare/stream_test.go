@@ -0,0 +1,74 @@
+package webshare
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/chasedut/toke/internal/message"
+	"github.com/chasedut/toke/internal/pubsub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsAfter(t *testing.T) {
+	s := newTestShare(t, 0)
+	for range maxRecentEvents + 10 {
+		s.broadcast(`{"type":"test"}`)
+	}
+
+	missed, ok := s.eventsAfter(s.lastEventID - 3)
+	require.True(t, ok)
+	require.Len(t, missed, 3)
+	require.Equal(t, s.lastEventID-2, missed[0].id)
+
+	missed, ok = s.eventsAfter(s.lastEventID)
+	require.True(t, ok)
+	require.Empty(t, missed)
+
+	_, ok = s.eventsAfter(5)
+	require.False(t, ok, "events that aren't kept anymore can't be replayed")
+	_, ok = s.eventsAfter(s.lastEventID + 1)
+	require.False(t, ok, "events of a previous share can't be replayed")
+}
+
+func TestStreamMessages(t *testing.T) {
+	s := newTestShare(t, 0)
+	client := &sseClient{events: make(chan sseEvent, clientBuffer), done: make(chan struct{})}
+	s.sseClients[client] = struct{}{}
+
+	events := make(chan pubsub.Event[message.Message])
+	go s.streamMessages(events)
+
+	msg := message.Message{ID: "msg_1", SessionID: "session", Role: message.Assistant}
+	events <- pubsub.Event[message.Message]{Type: pubsub.CreatedEvent, Payload: msg}
+	events <- pubsub.Event[message.Message]{Type: pubsub.CreatedEvent, Payload: message.Message{ID: "other", SessionID: "other"}}
+	for _, text := range []string{"He", "Hell", "Hello"} {
+		msg.Parts = []message.ContentPart{message.TextContent{Text: text}}
+		events <- pubsub.Event[message.Message]{Type: pubsub.UpdatedEvent, Payload: msg}
+	}
+
+	events <- pubsub.Event[message.Message]{Type: pubsub.DeletedEvent, Payload: msg}
+
+	var types []string
+	var updated apiMessage
+	for len(types) == 0 || types[len(types)-1] != "message_deleted" {
+		select {
+		case event := <-client.events:
+			var data struct {
+				Type    string     `json:"type"`
+				Message apiMessage `json:"message"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(event.data), &data))
+			require.Equal(t, "msg_1", data.Message.ID, "the other sessions are filtered")
+			types = append(types, data.Type)
+			if data.Type == "message_updated" {
+				updated = data.Message
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the message wasn't deleted")
+		}
+	}
+	require.Equal(t, "message_created", types[0])
+	require.Less(t, len(types), 5, "the updates are coalesced")
+	require.Contains(t, updated.Parts, "Hello", "the updates are sent before the deletion")
+}
@@ -0,0 +1,257 @@
+package webshare
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chasedut/toke/internal/config"
+)
+
+// tunnelOpenTimeout is how long a tunnel has to come up.
+const tunnelOpenTimeout = 30 * time.Second
+
+// Tunnel exposes the server of a share outside of this machine.
+type Tunnel interface {
+	Provider() config.TunnelProvider
+	// Ready returns a *SetupError when the host has to configure the tunnel
+	// before it can open.
+	Ready() error
+	// Listen returns the listener of the server of the share.
+	Listen() (net.Listener, error)
+	// Open exposes the server listening on addr and returns the URL buddies
+	// use. It blocks until the tunnel is up.
+	Open(ctx context.Context, addr *net.TCPAddr) (string, error)
+	// Close stops the tunnel.
+	Close() error
+}
+
+// SetupError tells the host what a tunnel needs before it can open.
+type SetupError struct {
+	Provider config.TunnelProvider
+	Reason   string
+}
+
+func (e *SetupError) Error() string {
+	return fmt.Sprintf("%s needs to be set up: %s", e.Provider, e.Reason)
+}
+
+// NewTunnel returns the tunnel configured in the web share options, ngrok by
+// default.
+func NewTunnel(opts *config.WebShareOptions) Tunnel {
+	if opts == nil {
+		opts = &config.WebShareOptions{}
+	}
+	tunnel := opts.Tunnel
+	if tunnel == nil {
+		tunnel = &config.TunnelOptions{}
+	}
+	local := localListener{port: opts.Port}
+	switch tunnel.Provider {
+	case "", config.TunnelNgrok:
+		return &ngrokTunnel{localListener: local, opts: valueOrZero(tunnel.Ngrok)}
+	case config.TunnelCloudflared:
+		return &cloudflaredTunnel{localListener: local, opts: valueOrZero(tunnel.Cloudflared)}
+	case config.TunnelSSH:
+		return &sshTunnel{localListener: local, opts: valueOrZero(tunnel.SSH)}
+	case config.TunnelLAN:
+		return &lanTunnel{port: opts.Port, opts: valueOrZero(tunnel.LAN)}
+	default:
+		return &unknownTunnel{localListener: local, provider: tunnel.Provider}
+	}
+}
+
+func valueOrZero[T any](v *T) T {
+	if v == nil {
+		var zero T
+		return zero
+	}
+	return *v
+}
+
+// localListener listens on localhost, for the tunnels that forward to it.
+type localListener struct {
+	port int
+}
+
+func (l localListener) Listen() (net.Listener, error) {
+	return net.Listen("tcp", net.JoinHostPort("localhost", fmt.Sprint(l.port)))
+}
+
+// unknownTunnel is a provider that doesn't exist, the host has to pick
+// another one.
+type unknownTunnel struct {
+	localListener
+	provider config.TunnelProvider
+}
+
+func (t *unknownTunnel) Provider() config.TunnelProvider { return t.provider }
+
+func (t *unknownTunnel) Ready() error {
+	return &SetupError{Provider: t.provider, Reason: "this tunnel provider doesn't exist"}
+}
+
+func (t *unknownTunnel) Open(context.Context, *net.TCPAddr) (string, error) {
+	return "", t.Ready()
+}
+
+func (t *unknownTunnel) Close() error { return nil }
+
+// findBinary looks for a program next to toke, in the build directory and in
+// the PATH, so that the ones bundled by build.sh are preferred.
+func findBinary(name string) string {
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	var locations []string
+	if exePath, err := os.Executable(); err == nil {
+		exeDir := filepath.Dir(exePath)
+		locations = append(locations, filepath.Join(exeDir, name), filepath.Join(exeDir, "build", name))
+	}
+	locations = append(locations, filepath.Join(".", name), filepath.Join(".", "build", name))
+	for _, loc := range locations {
+		if info, err := os.Stat(loc); err == nil && !info.IsDir() {
+			absPath, _ := filepath.Abs(loc)
+			return absPath
+		}
+	}
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+	return ""
+}
+
+// tunnelProcess is a program that keeps a tunnel open.
+type tunnelProcess struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	err  error
+}
+
+// startTunnelProcess starts the command and, if match isn't nil, waits until
+// a line of its output matches it. It returns the submatches of that line.
+func startTunnelProcess(ctx context.Context, cmd *exec.Cmd, match *regexp.Regexp) (*tunnelProcess, []string, error) {
+	output, w := io.Pipe()
+	cmd.Stdout = w
+	cmd.Stderr = w
+	// Don't wait for the children that keep the output open once it exited
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	p := &tunnelProcess{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		w.Close()
+		close(p.done)
+	}()
+
+	// Keep reading the output so that the program never blocks on it, and
+	// remember its end to explain why it exited.
+	matched := make(chan []string, 1)
+	var lastLines []string
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		scanner := bufio.NewScanner(output)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if match != nil {
+				if m := match.FindStringSubmatch(line); m != nil {
+					select {
+					case matched <- m:
+					default:
+					}
+				}
+			}
+			if len(lastLines) == 5 {
+				lastLines = lastLines[1:]
+			}
+			lastLines = append(lastLines, line)
+		}
+	}()
+	if match == nil {
+		return p, nil, nil
+	}
+
+	timer := time.NewTimer(tunnelOpenTimeout)
+	defer timer.Stop()
+	select {
+	case m := <-matched:
+		return p, m, nil
+	case <-scanned:
+		<-p.done
+		reason := strings.Join(lastLines, "\n")
+		if reason == "" && p.err != nil {
+			reason = p.err.Error()
+		}
+		return nil, nil, fmt.Errorf("%s exited: %s", filepath.Base(cmd.Path), reason)
+	case <-timer.C:
+		p.stop()
+		return nil, nil, fmt.Errorf("%s didn't open the tunnel in %s", filepath.Base(cmd.Path), tunnelOpenTimeout)
+	case <-ctx.Done():
+		p.stop()
+		return nil, nil, ctx.Err()
+	}
+}
+
+// runningProcess holds the program of a tunnel once it started.
+type runningProcess struct {
+	mu      sync.Mutex
+	process *tunnelProcess
+}
+
+func (r *runningProcess) set(p *tunnelProcess) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.process = p
+}
+
+// Close stops the program.
+func (r *runningProcess) Close() error {
+	r.mu.Lock()
+	p := r.process
+	r.mu.Unlock()
+	return p.stop()
+}
+
+// stop interrupts the program, or kills it on Windows, and waits for it to
+// exit.
+func (p *tunnelProcess) stop() error {
+	if p == nil {
+		return nil
+	}
+	select {
+	case <-p.done:
+		return nil
+	default:
+	}
+	if runtime.GOOS == "windows" {
+		p.cmd.Process.Kill()
+	} else {
+		p.cmd.Process.Signal(os.Interrupt)
+	}
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		p.cmd.Process.Kill()
+		<-p.done
+	}
+	var exitErr *exec.ExitError
+	if errors.As(p.err, &exitErr) {
+		// Exiting because it was interrupted is expected
+		return nil
+	}
+	return p.err
+}
@@ -0,0 +1,69 @@
+package webshare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+
+	"github.com/chasedut/toke/internal/config"
+)
+
+var (
+	// cloudflaredQuickURL is the URL cloudflared logs for quick tunnels.
+	cloudflaredQuickURL = regexp.MustCompile(`https://[a-z0-9-]+\.trycloudflare\.com`)
+	// cloudflaredConnected is logged once a named tunnel is up.
+	cloudflaredConnected = regexp.MustCompile(`Registered tunnel connection`)
+)
+
+// cloudflaredTunnel exposes the share with a Cloudflare quick tunnel, or with
+// a named tunnel when a token is configured. The named tunnel must route its
+// public URL to the share port.
+type cloudflaredTunnel struct {
+	localListener
+	runningProcess
+	opts config.CloudflaredOptions
+}
+
+func (t *cloudflaredTunnel) Provider() config.TunnelProvider { return config.TunnelCloudflared }
+
+func (t *cloudflaredTunnel) Ready() error {
+	switch {
+	case t.opts.Token != "" && t.opts.URL == "":
+		return &SetupError{Provider: config.TunnelCloudflared, Reason: "the public URL of the named tunnel is required"}
+	case t.opts.Token != "" && t.port == 0:
+		return &SetupError{Provider: config.TunnelCloudflared, Reason: "named tunnels need a fixed port, set options.web_share.port"}
+	}
+	return nil
+}
+
+func (t *cloudflaredTunnel) Open(ctx context.Context, addr *net.TCPAddr) (string, error) {
+	cloudflaredPath := findBinary("cloudflared")
+	if cloudflaredPath == "" {
+		return "", errors.New("cloudflared not found, install it from https://developers.cloudflare.com/cloudflare-one/connections/connect-networks/downloads/")
+	}
+
+	var cmd *exec.Cmd
+	match := cloudflaredQuickURL
+	if t.opts.Token != "" {
+		cmd = exec.CommandContext(ctx, cloudflaredPath, "tunnel", "--no-autoupdate", "run")
+		// Keep the token out of the process list
+		cmd.Env = append(os.Environ(), "TUNNEL_TOKEN="+t.opts.Token)
+		match = cloudflaredConnected
+	} else {
+		cmd = exec.CommandContext(ctx, cloudflaredPath, "tunnel", "--no-autoupdate", "--url", fmt.Sprintf("http://localhost:%d", addr.Port))
+	}
+	process, m, err := startTunnelProcess(ctx, cmd, match)
+	if err != nil {
+		return "", err
+	}
+	t.set(process)
+
+	if t.opts.Token != "" {
+		return t.opts.URL, nil
+	}
+	return m[0], nil
+}
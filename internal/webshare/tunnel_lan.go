@@ -0,0 +1,77 @@
+package webshare
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/chasedut/toke/internal/config"
+)
+
+// lanTunnel serves the share on a network interface instead of localhost, so
+// that buddies on the same network can open it. The share dialog shows a QR
+// code of the link for them.
+type lanTunnel struct {
+	port int
+	opts config.LANOptions
+}
+
+func (t *lanTunnel) Provider() config.TunnelProvider { return config.TunnelLAN }
+
+func (t *lanTunnel) Ready() error {
+	_, err := t.address()
+	return err
+}
+
+func (t *lanTunnel) Listen() (net.Listener, error) {
+	ip, err := t.address()
+	if err != nil {
+		return nil, err
+	}
+	return net.Listen("tcp", net.JoinHostPort(ip.String(), fmt.Sprint(t.port)))
+}
+
+func (t *lanTunnel) Open(_ context.Context, addr *net.TCPAddr) (string, error) {
+	return "http://" + addr.String(), nil
+}
+
+func (t *lanTunnel) Close() error { return nil }
+
+// address returns the IPv4 address of the configured interface, or of the
+// first interface that is up and not a loopback.
+func (t *lanTunnel) address() (net.IP, error) {
+	var interfaces []net.Interface
+	if t.opts.Interface != "" {
+		iface, err := net.InterfaceByName(t.opts.Interface)
+		if err != nil {
+			return nil, &SetupError{Provider: config.TunnelLAN, Reason: fmt.Sprintf("there is no network interface %q", t.opts.Interface)}
+		}
+		interfaces = []net.Interface{*iface}
+	} else {
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the network interfaces: %w", err)
+		}
+		for _, iface := range all {
+			if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagLoopback == 0 {
+				interfaces = append(interfaces, iface)
+			}
+		}
+	}
+
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				return ipNet.IP.To4(), nil
+			}
+		}
+	}
+	if t.opts.Interface != "" {
+		return nil, &SetupError{Provider: config.TunnelLAN, Reason: fmt.Sprintf("the network interface %q has no IPv4 address", t.opts.Interface)}
+	}
+	return nil, &SetupError{Provider: config.TunnelLAN, Reason: "no network interface has an IPv4 address"}
+}
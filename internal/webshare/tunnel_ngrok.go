@@ -0,0 +1,148 @@
+package webshare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/chasedut/toke/internal/config"
+)
+
+// ngrokAPI lists the tunnels of the running ngrok agent.
+const ngrokAPI = "http://localhost:4040/api/tunnels"
+
+// ngrokTunnel exposes the share with ngrok. The authtoken comes from the
+// options, $NGROK_AUTHTOKEN or the ngrok config, in that order.
+type ngrokTunnel struct {
+	localListener
+	runningProcess
+	opts config.NgrokOptions
+}
+
+func (t *ngrokTunnel) Provider() config.TunnelProvider { return config.TunnelNgrok }
+
+func (t *ngrokTunnel) Ready() error {
+	ngrokPath := findBinary("ngrok")
+	if ngrokPath == "" {
+		// Open explains how to install it
+		return nil
+	}
+	if t.opts.AuthToken == "" && !isNgrokAuthenticated(ngrokPath) {
+		return &SetupError{
+			Provider: config.TunnelNgrok,
+			Reason:   "an authtoken is required, get one at https://dashboard.ngrok.com/get-started/your-authtoken",
+		}
+	}
+	return nil
+}
+
+func (t *ngrokTunnel) Open(ctx context.Context, addr *net.TCPAddr) (string, error) {
+	ngrokPath := findBinary("ngrok")
+	if ngrokPath == "" {
+		return "", errors.New("ngrok not found, install it from https://ngrok.com/download or rebuild with --all")
+	}
+
+	cmd := exec.CommandContext(ctx, ngrokPath, "http", fmt.Sprint(addr.Port))
+	if t.opts.AuthToken != "" {
+		cmd.Env = append(os.Environ(), "NGROK_AUTHTOKEN="+t.opts.AuthToken)
+	}
+	process, _, err := startTunnelProcess(ctx, cmd, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to start ngrok: %w", err)
+	}
+	t.set(process)
+
+	// Retry getting ngrok URL with exponential backoff
+	maxRetries := 5
+	for i := range maxRetries {
+		select {
+		case <-time.After(time.Duration(500*(i+1)) * time.Millisecond):
+		case <-process.done:
+			return "", fmt.Errorf("ngrok exited: %v", process.err)
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+
+		url, err := ngrokPublicURL(ctx)
+		if err != nil {
+			if i == maxRetries-1 {
+				return "", fmt.Errorf("failed to get ngrok tunnels after %d retries: %w", maxRetries, err)
+			}
+			continue // Retry
+		}
+		if url != "" {
+			return url, nil
+		}
+	}
+	return "", errors.New("ngrok didn't open a tunnel")
+}
+
+// ngrokPublicURL returns the URL of the tunnel the ngrok agent opened, HTTPS
+// if possible.
+func ngrokPublicURL(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ngrokAPI, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var tunnels struct {
+		Tunnels []struct {
+			PublicURL string `json:"public_url"`
+			Proto     string `json:"proto"`
+		} `json:"tunnels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tunnels); err != nil {
+		return "", fmt.Errorf("failed to parse ngrok response: %w", err)
+	}
+
+	// Find HTTPS tunnel
+	for _, tunnel := range tunnels.Tunnels {
+		if tunnel.Proto == "https" {
+			return tunnel.PublicURL, nil
+		}
+	}
+	if len(tunnels.Tunnels) > 0 {
+		return tunnels.Tunnels[0].PublicURL, nil
+	}
+	return "", nil
+}
+
+// isNgrokAuthenticated checks if ngrok has an auth token configured
+func isNgrokAuthenticated(ngrokPath string) bool {
+	// First check environment variable
+	if os.Getenv("NGROK_AUTHTOKEN") != "" {
+		return true
+	}
+
+	// Try to check ngrok config
+	// ngrok v3 uses "ngrok config check" to verify configuration
+	cmd := exec.Command(ngrokPath, "config", "check")
+	output, err := cmd.CombinedOutput()
+
+	// If command succeeded, check output
+	if err == nil {
+		outputStr := string(output)
+		// Valid config will contain "Valid configuration"
+		if strings.Contains(outputStr, "Valid") {
+			return true
+		}
+	}
+
+	// Default to false if we can't determine
+	return false
+}
@@ -0,0 +1,77 @@
+package webshare
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/chasedut/toke/internal/config"
+)
+
+// sshForwarded matches the messages of ssh -v once the remote forward is up,
+// with the port the host picked if it was asked to.
+var sshForwarded = regexp.MustCompile(`Allocated port (\d+) for remote forward|remote forward success`)
+
+// sshTunnel exposes the share with ssh -R: the host listens on a port and
+// forwards it to the share. For buddies to reach the port, the host either
+// needs GatewayPorts enabled in its sshd config or a reverse proxy in front
+// of it.
+type sshTunnel struct {
+	localListener
+	runningProcess
+	opts config.SSHTunnelOptions
+}
+
+func (t *sshTunnel) Provider() config.TunnelProvider { return config.TunnelSSH }
+
+func (t *sshTunnel) Ready() error {
+	if t.opts.Host == "" {
+		return &SetupError{Provider: config.TunnelSSH, Reason: "the host to forward from is required"}
+	}
+	return nil
+}
+
+func (t *sshTunnel) Open(ctx context.Context, addr *net.TCPAddr) (string, error) {
+	sshPath, err := exec.LookPath("ssh")
+	if err != nil {
+		return "", fmt.Errorf("ssh not found: %w", err)
+	}
+
+	args := []string{
+		"-v", "-N",
+		// Fail instead of asking for a password nobody can type
+		"-o", "BatchMode=yes",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "ServerAliveInterval=30",
+		"-R", fmt.Sprintf("%d:localhost:%d", t.opts.RemotePort, addr.Port),
+	}
+	if t.opts.Port != 0 {
+		args = append(args, "-p", fmt.Sprint(t.opts.Port))
+	}
+	if t.opts.IdentityFile != "" {
+		args = append(args, "-i", t.opts.IdentityFile)
+	}
+	args = append(args, t.opts.Host)
+
+	process, m, err := startTunnelProcess(ctx, exec.CommandContext(ctx, sshPath, args...), sshForwarded)
+	if err != nil {
+		return "", err
+	}
+	t.set(process)
+
+	if t.opts.URL != "" {
+		return t.opts.URL, nil
+	}
+	remotePort := fmt.Sprint(t.opts.RemotePort)
+	if m[1] != "" {
+		remotePort = m[1]
+	}
+	host := t.opts.Host
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	return "http://" + net.JoinHostPort(host, remotePort), nil
+}
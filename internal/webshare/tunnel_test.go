@@ -0,0 +1,70 @@
+package webshare
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/chasedut/toke/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTunnel(t *testing.T) {
+	require.Equal(t, config.TunnelNgrok, NewTunnel(nil).Provider())
+
+	tunnels := []struct {
+		opts  config.TunnelOptions
+		setup bool
+	}{
+		{opts: config.TunnelOptions{Provider: config.TunnelCloudflared}},
+		{opts: config.TunnelOptions{Provider: config.TunnelCloudflared, Cloudflared: &config.CloudflaredOptions{Token: "token"}}, setup: true},
+		{opts: config.TunnelOptions{Provider: config.TunnelSSH}, setup: true},
+		{opts: config.TunnelOptions{Provider: config.TunnelSSH, SSH: &config.SSHTunnelOptions{Host: "me@example.com"}}},
+		{opts: config.TunnelOptions{Provider: config.TunnelLAN, LAN: &config.LANOptions{Interface: "no-such-interface"}}, setup: true},
+		{opts: config.TunnelOptions{Provider: "carrier-pigeon"}, setup: true},
+	}
+	for _, tt := range tunnels {
+		tunnel := NewTunnel(&config.WebShareOptions{Tunnel: &tt.opts})
+		require.Equal(t, tt.opts.Provider, tunnel.Provider())
+		if err := tunnel.Ready(); tt.setup {
+			var setupErr *SetupError
+			require.ErrorAs(t, err, &setupErr, "%+v", tt.opts)
+		} else {
+			require.NoError(t, err, "%+v", tt.opts)
+		}
+	}
+}
+
+func TestStartTunnelProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	ctx := context.Background()
+	url := regexp.MustCompile(`https://(\S+)\.trycloudflare\.com`)
+
+	cmd := exec.Command("sh", "-c", "echo starting; echo 'visit https://quick-tunnel.trycloudflare.com'; exec sleep 30")
+	process, m, err := startTunnelProcess(ctx, cmd, url)
+	require.NoError(t, err)
+	require.Equal(t, "quick-tunnel", m[1])
+	require.NoError(t, process.stop())
+
+	cmd = exec.Command("sh", "-c", "echo 'failed to connect'; exit 1")
+	_, _, err = startTunnelProcess(ctx, cmd, url)
+	require.ErrorContains(t, err, "failed to connect", "the output explains why the tunnel didn't open")
+}
+
+func TestQRCode(t *testing.T) {
+	code, err := QRCode("http://192.168.1.20:8080/?token=" + strings.Repeat("x", 32))
+	require.NoError(t, err)
+	lines := strings.Split(code, "\n")
+	// 64 bytes need version 5, 37 modules wide, plus the quiet zone on both
+	// sides, with two rows per line
+	require.Len(t, []rune(lines[0]), 41)
+	require.Len(t, lines, 21)
+
+	_, err = QRCode(strings.Repeat("x", 300))
+	require.ErrorIs(t, err, errQRTooLong)
+}
@@ -0,0 +1,205 @@
+// Package worktree gives sessions their own git worktree and branch so that
+// several sessions can change the same repository without stepping on each
+// other, and merges, publishes or discards that work when a session ends.
+package worktree
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// BranchPrefix is prepended to the session ID to name worktree branches.
+const BranchPrefix = "toke/"
+
+// ErrNotRepository is returned when the project is not inside a git
+// repository.
+var ErrNotRepository = errors.New("not a git repository")
+
+// Worktree is the working tree of a session.
+type Worktree struct {
+	SessionID string
+	// Dir is where the worktree is checked out.
+	Dir    string
+	Branch string
+}
+
+// Manager creates and finishes session worktrees for a project.
+type Manager struct {
+	repoDir string
+	root    string
+}
+
+// NewManager returns a manager for the repository at repoDir that keeps its
+// worktrees under dataDir.
+func NewManager(repoDir, dataDir string) *Manager {
+	return &Manager{
+		repoDir: repoDir,
+		root:    filepath.Join(dataDir, "worktrees"),
+	}
+}
+
+func (m *Manager) worktree(sessionID string) Worktree {
+	return Worktree{
+		SessionID: sessionID,
+		Dir:       filepath.Join(m.root, sessionID),
+		Branch:    BranchPrefix + sessionID,
+	}
+}
+
+// Create checks out a new branch for the session from the current HEAD into
+// its own worktree.
+func (m *Manager) Create(ctx context.Context, sessionID string) (Worktree, error) {
+	if _, err := m.git(ctx, m.repoDir, "rev-parse", "--git-dir"); err != nil {
+		return Worktree{}, ErrNotRepository
+	}
+	wt := m.worktree(sessionID)
+	if _, err := os.Stat(wt.Dir); err == nil {
+		return Worktree{}, fmt.Errorf("worktree for session %s already exists", sessionID)
+	}
+	if err := os.MkdirAll(m.root, 0o755); err != nil {
+		return Worktree{}, fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	if _, err := m.git(ctx, m.repoDir, "worktree", "add", "-b", wt.Branch, wt.Dir, "HEAD"); err != nil {
+		return Worktree{}, err
+	}
+	return wt, nil
+}
+
+// Get returns the worktree of a session if it has one.
+func (m *Manager) Get(sessionID string) (Worktree, bool) {
+	wt := m.worktree(sessionID)
+	if _, err := os.Stat(filepath.Join(wt.Dir, ".git")); err != nil {
+		return Worktree{}, false
+	}
+	return wt, true
+}
+
+// List returns the worktrees of all sessions.
+func (m *Manager) List() ([]Worktree, error) {
+	entries, err := os.ReadDir(m.root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Worktree
+	for _, e := range entries {
+		if wt, ok := m.Get(e.Name()); ok && e.IsDir() {
+			list = append(list, wt)
+		}
+	}
+	return list, nil
+}
+
+// Changed reports whether the worktree has commits or uncommitted changes
+// that are not on the branch checked out in the project.
+func (m *Manager) Changed(ctx context.Context, wt Worktree) (bool, error) {
+	status, err := m.git(ctx, wt.Dir, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	if status != "" {
+		return true, nil
+	}
+	ahead, err := m.git(ctx, m.repoDir, "rev-list", "--count", "HEAD.."+wt.Branch)
+	if err != nil {
+		return false, err
+	}
+	return ahead != "0", nil
+}
+
+// Merge commits any pending changes in the worktree, merges its branch into
+// the branch checked out in the project and removes the worktree.
+func (m *Manager) Merge(ctx context.Context, wt Worktree, message string) error {
+	if err := m.commit(ctx, wt, message); err != nil {
+		return err
+	}
+	if _, err := m.git(ctx, m.repoDir, "merge", "--no-ff", "--no-edit", wt.Branch); err != nil {
+		return fmt.Errorf("merge failed, the worktree was kept: %w", err)
+	}
+	return m.Discard(ctx, wt)
+}
+
+// OpenPR commits any pending changes in the worktree, pushes its branch and
+// opens a pull request for it with the GitHub CLI. The worktree is removed but
+// the branch is kept. It returns the URL of the pull request.
+func (m *Manager) OpenPR(ctx context.Context, wt Worktree, title string) (string, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return "", fmt.Errorf("the GitHub CLI (gh) is required to open pull requests")
+	}
+	if err := m.commit(ctx, wt, title); err != nil {
+		return "", err
+	}
+	if _, err := m.git(ctx, wt.Dir, "push", "-u", "origin", wt.Branch); err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "gh", "pr", "create", "--head", wt.Branch, "--title", title, "--fill")
+	cmd.Dir = wt.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("gh pr create: %s", strings.TrimSpace(string(out)))
+	}
+	if err := m.remove(ctx, wt); err != nil {
+		return "", err
+	}
+	return lastLine(string(out)), nil
+}
+
+// Discard removes the worktree and deletes its branch, throwing away the
+// session's changes.
+func (m *Manager) Discard(ctx context.Context, wt Worktree) error {
+	if err := m.remove(ctx, wt); err != nil {
+		return err
+	}
+	_, err := m.git(ctx, m.repoDir, "branch", "-D", wt.Branch)
+	return err
+}
+
+func (m *Manager) remove(ctx context.Context, wt Worktree) error {
+	_, err := m.git(ctx, m.repoDir, "worktree", "remove", "--force", wt.Dir)
+	return err
+}
+
+// commit commits everything in the worktree, if there is anything to commit.
+func (m *Manager) commit(ctx context.Context, wt Worktree, message string) error {
+	if _, err := m.git(ctx, wt.Dir, "add", "-A"); err != nil {
+		return err
+	}
+	staged, err := m.git(ctx, wt.Dir, "diff", "--cached", "--name-only")
+	if err != nil || staged == "" {
+		return err
+	}
+	_, err = m.git(ctx, wt.Dir, "commit", "-m", message)
+	return err
+}
+
+func (m *Manager) git(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
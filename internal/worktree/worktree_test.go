@@ -0,0 +1,94 @@
+package worktree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "toke@example.com"},
+		{"config", "user.name", "toke"},
+		{"commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return dir
+}
+
+func TestWorktreeMerge(t *testing.T) {
+	repo := initRepo(t)
+	m := NewManager(repo, filepath.Join(t.TempDir(), "data"))
+	ctx := t.Context()
+
+	wt, err := m.Create(ctx, "session-1")
+	require.NoError(t, err)
+	require.Equal(t, BranchPrefix+"session-1", wt.Branch)
+
+	got, ok := m.Get("session-1")
+	require.True(t, ok)
+	require.Equal(t, wt, got)
+
+	changed, err := m.Changed(ctx, wt)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	require.NoError(t, os.WriteFile(filepath.Join(wt.Dir, "hello.txt"), []byte("hi"), 0o644))
+	_, err = os.Stat(filepath.Join(repo, "hello.txt"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	changed, err = m.Changed(ctx, wt)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	require.NoError(t, m.Merge(ctx, wt, "Add hello"))
+	content, err := os.ReadFile(filepath.Join(repo, "hello.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "hi", string(content))
+
+	_, ok = m.Get("session-1")
+	require.False(t, ok)
+}
+
+func TestWorktreeDiscard(t *testing.T) {
+	repo := initRepo(t)
+	m := NewManager(repo, filepath.Join(t.TempDir(), "data"))
+	ctx := t.Context()
+
+	wt, err := m.Create(ctx, "session-2")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(wt.Dir, "scratch.txt"), []byte("x"), 0o644))
+
+	list, err := m.List()
+	require.NoError(t, err)
+	require.Equal(t, []Worktree{wt}, list)
+
+	require.NoError(t, m.Discard(ctx, wt))
+	list, err = m.List()
+	require.NoError(t, err)
+	require.Empty(t, list)
+	_, err = os.Stat(filepath.Join(repo, "scratch.txt"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestWorktreeNotRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	m := NewManager(t.TempDir(), t.TempDir())
+	_, err := m.Create(t.Context(), "session-3")
+	require.ErrorIs(t, err, ErrNotRepository)
+}